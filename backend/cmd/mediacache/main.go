@@ -0,0 +1,62 @@
+// Command mediacache manages the remote media cache.
+//
+//	mediacache purge -all
+//	mediacache purge -domain example.com
+//
+// Purged files are removed from storage and fetched again from their
+// server the next time they are requested. The database and storage are
+// configured through DATABASE_URL and the variables read by
+// media.ConfigFromEnv.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"openfirm/internal/media"
+	"openfirm/internal/models"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: mediacache purge (-all | -domain DOMAIN)")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "purge" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	domain := flags.String("domain", "", "purge only files from this domain")
+	all := flags.Bool("all", false, "purge files from every domain")
+	flags.Parse(os.Args[2:])
+
+	if (*domain == "") == !*all {
+		usage()
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	storage, err := media.NewStorage(media.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to open media storage: %v", err)
+	}
+
+	cache := media.NewCache(storage, models.NewRemoteMediaService(db), media.CacheConfig{})
+	purged, err := cache.Purge(ctx, *domain)
+	if err != nil {
+		log.Fatalf("Failed to purge media cache: %v", err)
+	}
+	fmt.Printf("Purged %d files\n", purged)
+}
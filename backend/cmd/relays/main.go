@@ -0,0 +1,112 @@
+// Command relays manages the relays the instance actor follows.
+//
+//	relays list
+//	relays add [-forward] INBOX
+//	relays remove ID
+//	relays forward ID on|off
+//
+// Adding a relay sends it a Follow; it is used once the relay accepts.
+// With forwarding on, our public posts and job postings are sent to the
+// relay too. The database and domain are configured through DATABASE_URL
+// and DOMAIN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  relays list
+  relays add [-forward] INBOX
+  relays remove ID
+  relays forward ID on|off`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	relayService := models.NewRelayService(db)
+	apService := activitypub.NewService(db, os.Getenv("DOMAIN"))
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "list":
+		relays, err := relayService.ListRelays(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list relays: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tINBOX\tSTATE\tFORWARD")
+		for _, relay := range relays {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%t\n", relay.ID, relay.Inbox, relay.State, relay.Forward)
+		}
+		w.Flush()
+
+	case "add":
+		flags := flag.NewFlagSet("add", flag.ExitOnError)
+		forward := flags.Bool("forward", false, "forward our public posts and job postings to the relay")
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			usage()
+		}
+
+		relay, err := apService.SubscribeRelay(ctx, flags.Arg(0), *forward)
+		if err != nil {
+			log.Fatalf("Failed to add relay: %v", err)
+		}
+		fmt.Printf("Relay %d added, waiting for it to accept\n", relay.ID)
+
+	case "remove":
+		if len(args) != 1 {
+			usage()
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			usage()
+		}
+
+		if err := apService.UnsubscribeRelay(ctx, id); err != nil {
+			log.Fatalf("Failed to remove relay: %v", err)
+		}
+		fmt.Printf("Relay %d removed\n", id)
+
+	case "forward":
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			usage()
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			usage()
+		}
+
+		if err := relayService.SetRelayForward(ctx, id, args[1] == "on"); err != nil {
+			log.Fatalf("Failed to update relay: %v", err)
+		}
+		fmt.Printf("Forwarding to relay %d turned %s\n", id, args[1])
+
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,76 @@
+// Command skills curates the skills taxonomy.
+//
+//	skills list
+//	skills alias SKILL ALIAS
+//
+// An alias makes another spelling resolve to a skill, e.g. golang to Go.
+// If the alias was already a skill of its own, its jobs and users are
+// merged into SKILL. The database is configured through DATABASE_URL.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"openfirm/internal/models"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  skills list
+  skills alias SKILL ALIAS`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	ctx := context.Background()
+
+	db, err := pgxpool.New(ctx, os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	skillService := models.NewSkillService(db)
+
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "list":
+		skills, err := skillService.ListSkills(ctx)
+		if err != nil {
+			log.Fatalf("Failed to list skills: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tALIASES")
+		for _, skill := range skills {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", skill.ID, skill.Name, strings.Join(skill.Aliases, ", "))
+		}
+		w.Flush()
+
+	case "alias":
+		if len(args) != 2 {
+			usage()
+		}
+
+		skill, err := skillService.ResolveSkill(ctx, args[0])
+		if err != nil {
+			log.Fatalf("Failed to find skill: %v", err)
+		}
+		if err := skillService.AddAlias(ctx, skill.ID, args[1]); err != nil {
+			log.Fatalf("Failed to add alias: %v", err)
+		}
+		fmt.Printf("%q now resolves to %s\n", models.NormalizeSkill(args[1]), skill.Name)
+
+	default:
+		usage()
+	}
+}
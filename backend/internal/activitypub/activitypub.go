@@ -3,46 +3,138 @@ package activitypub
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-fed/activity/streams"
 	"github.com/go-fed/activity/streams/vocab"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"openfirm/internal/models"
 )
 
 type Service struct {
-	db         *pgxpool.Pool
-	domain     string
-	userSvc    *models.UserService
-	postSvc    *models.PostService
+	db              *pgxpool.Pool
+	domain          string
+	userSvc         *models.UserService
+	postSvc         *models.PostService
+	jobSvc          *models.JobService
+	jobDetailsSvc   *models.JobDetailsService
+	jobLifecycleSvc *models.JobLifecycleService
+	featuredSvc     *models.FeaturedService
+	tagSvc          *models.TagService
+	remotePostSvc   *models.RemotePostService
+	timelineSvc     *models.TimelineService
+	threadSvc       *models.ThreadService
+	pollSvc         *models.PollService
+	mediaSvc        *models.MediaService
+	remoteMediaSvc  *models.RemoteMediaService
+	remoteActorSvc  *models.RemoteActorService
+	relaySvc        *models.RelayService
+	skillSvc        *models.SkillService
+	orgSvc          *models.OrganizationService
+	actorKeySvc     *models.ActorKeyService
+	followerSvc     *models.FollowerService
 }
 
 func NewService(db *pgxpool.Pool, domain string) *Service {
 	return &Service{
-		db:      db,
-		domain:  domain,
-		userSvc: models.NewUserService(db),
-		postSvc: models.NewPostService(db),
+		db:              db,
+		domain:          domain,
+		userSvc:         models.NewUserService(db),
+		postSvc:         models.NewPostService(db),
+		jobSvc:          models.NewJobService(db),
+		jobDetailsSvc:   models.NewJobDetailsService(db),
+		jobLifecycleSvc: models.NewJobLifecycleService(db),
+		featuredSvc:     models.NewFeaturedService(db),
+		tagSvc:          models.NewTagService(db),
+		remotePostSvc:   models.NewRemotePostService(db),
+		timelineSvc:     models.NewTimelineService(db),
+		threadSvc:       models.NewThreadService(db),
+		pollSvc:         models.NewPollService(db),
+		mediaSvc:        models.NewMediaService(db),
+		remoteMediaSvc:  models.NewRemoteMediaService(db),
+		remoteActorSvc:  models.NewRemoteActorService(db),
+		relaySvc:        models.NewRelayService(db),
+		skillSvc:        models.NewSkillService(db),
+		orgSvc:          models.NewOrganizationService(db),
+		actorKeySvc:     models.NewActorKeyService(db),
+		followerSvc:     models.NewFollowerService(db),
 	}
 }
 
+// ActorIRI returns the IRI of a local user's actor
+func (s *Service) ActorIRI(username string) string {
+	return fmt.Sprintf("https://%s/users/%s", s.domain, username)
+}
+
+// localUsername returns the username of a local user actor IRI
+func (s *Service) localUsername(iri string) (string, bool) {
+	prefix := fmt.Sprintf("https://%s/users/", s.domain)
+	username := strings.TrimPrefix(iri, prefix)
+	if username == iri || username == "" || strings.Contains(username, "/") {
+		return "", false
+	}
+	return username, true
+}
+
+// PostIRI returns the IRI of a local post
+func (s *Service) PostIRI(id int) string {
+	return fmt.Sprintf("https://%s/posts/%d", s.domain, id)
+}
+
+// JobIRI returns the IRI of a local job posting
+func (s *Service) JobIRI(id int) string {
+	return fmt.Sprintf("https://%s/jobs/%d", s.domain, id)
+}
+
+// mustParseIRI parses an IRI built by this service. IRIs read back from
+// storage go through parseIRI instead.
+func mustParseIRI(raw string) *url.URL {
+	u, err := url.Parse(raw)
+	if err != nil {
+		panic(fmt.Sprintf("invalid IRI %q: %v", raw, err))
+	}
+	return u
+}
+
+// parseIRI parses an absolute IRI, which may have come from a remote server
+func parseIRI(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IRI %q: %v", raw, err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, fmt.Errorf("invalid IRI %q: not absolute", raw)
+	}
+	return u, nil
+}
+
+// validIRI reports whether a remote value can be stored as an IRI
+func validIRI(raw string) bool {
+	_, err := parseIRI(raw)
+	return err == nil
+}
+
 // Actor represents an ActivityPub actor (user)
 type Actor struct {
-	Context           []string `json:"@context"`
-	ID                string   `json:"id"`
-	Type              string   `json:"type"`
-	PreferredUsername string   `json:"preferredUsername"`
-	Name              string   `json:"name,omitempty"`
-	Summary           string   `json:"summary,omitempty"`
-	Icon              *Image   `json:"icon,omitempty"`
-	Inbox            string   `json:"inbox"`
-	Outbox           string   `json:"outbox"`
-	Following        string   `json:"following"`
-	Followers        string   `json:"followers"`
-	PublicKey        PublicKey `json:"publicKey,omitempty"`
+	Context           []interface{} `json:"@context"`
+	ID                string        `json:"id"`
+	Type              string        `json:"type"`
+	PreferredUsername string        `json:"preferredUsername"`
+	Name              string        `json:"name,omitempty"`
+	Summary           string        `json:"summary,omitempty"`
+	Icon              *Image        `json:"icon,omitempty"`
+	Inbox             string        `json:"inbox"`
+	Outbox            string        `json:"outbox"`
+	Following         string        `json:"following"`
+	Followers         string        `json:"followers"`
+	Featured          string        `json:"featured,omitempty"`
+	PublicKey         PublicKey     `json:"publicKey,omitempty"`
+	Tag               []*TagObject  `json:"tag,omitempty"`
 }
 
 type Image struct {
@@ -64,27 +156,51 @@ func (s *Service) GetActor(ctx context.Context, username string) (*Actor, error)
 		return nil, err
 	}
 
-	actorURL := fmt.Sprintf("https://%s/users/%s", s.domain, username)
+	actorURL := s.ActorIRI(username)
 	actor := &Actor{
-		Context: []string{
+		Context: []interface{}{
 			"https://www.w3.org/ns/activitystreams",
 			"https://w3id.org/security/v1",
+			map[string]interface{}{
+				"toot": "http://joinmastodon.org/ns#",
+				"featured": map[string]string{
+					"@id":   "toot:featured",
+					"@type": "@id",
+				},
+				"schema": "http://schema.org#",
+				"Skill":  "schema:DefinedTerm",
+			},
 		},
 		ID:                actorURL,
-		Type:             "Person",
+		Type:              "Person",
 		PreferredUsername: user.Username,
-		Name:             user.DisplayName,
-		Summary:          user.Bio,
-		Inbox:            fmt.Sprintf("%s/inbox", actorURL),
-		Outbox:           fmt.Sprintf("%s/outbox", actorURL),
-		Following:        fmt.Sprintf("%s/following", actorURL),
-		Followers:        fmt.Sprintf("%s/followers", actorURL),
+		Name:              user.DisplayName,
+		Summary:           user.Bio,
+		Inbox:             fmt.Sprintf("%s/inbox", actorURL),
+		Outbox:            fmt.Sprintf("%s/outbox", actorURL),
+		Following:         fmt.Sprintf("%s/following", actorURL),
+		Followers:         fmt.Sprintf("%s/followers", actorURL),
+		Featured:          s.FeaturedIRI(username),
 	}
 
+	if actor.PublicKey, err = s.publicKey(ctx, actorURL); err != nil {
+		return nil, err
+	}
+
+	userSkills, err := s.skillSvc.ListUserSkills(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	skills := make([]models.Skill, 0, len(userSkills))
+	for _, skill := range userSkills {
+		skills = append(skills, skill.Skill)
+	}
+	actor.Tag = s.skillTags(skills)
+
 	if user.AvatarURL != "" {
 		actor.Icon = &Image{
 			Type:      "Image",
-			MediaType: "image/jpeg", // Adjust based on actual image type
+			MediaType: s.avatarMediaType(ctx, user.AvatarURL),
 			URL:       user.AvatarURL,
 		}
 	}
@@ -93,39 +209,135 @@ func (s *Service) GetActor(ctx context.Context, username string) (*Actor, error)
 }
 
 // CreateNote creates an ActivityPub Note object from a post
-func (s *Service) CreateNote(post *models.Post) (vocab.Type, error) {
+func (s *Service) CreateNote(ctx context.Context, post *models.Post, author *models.User) (vocab.ActivityStreamsNote, error) {
 	note := streams.NewActivityStreamsNote()
-	
+	actorIRI := s.ActorIRI(author.Username)
+
 	// Set ID
-	id := streams.NewActivityStreamsId()
-	id.Set(fmt.Sprintf("https://%s/posts/%d", s.domain, post.ID))
-	note.SetActivityStreamsId(id)
+	id := streams.NewJSONLDIdProperty()
+	id.Set(mustParseIRI(s.PostIRI(post.ID)))
+	note.SetJSONLDId(id)
 
 	// Set content
-	content := streams.NewActivityStreamsContent()
+	content := streams.NewActivityStreamsContentProperty()
 	content.AppendXMLSchemaString(post.Content)
 	note.SetActivityStreamsContent(content)
 
 	// Set published time
-	published := streams.NewActivityStreamsPublished()
+	published := streams.NewActivityStreamsPublishedProperty()
 	published.Set(post.CreatedAt)
 	note.SetActivityStreamsPublished(published)
 
 	// Set attribution
-	attribution := streams.NewActivityStreamsAttributedTo()
-	actorIRI := fmt.Sprintf("https://%s/users/%d", s.domain, post.UserID)
-	attribution.AppendIRI(actorIRI)
+	attribution := streams.NewActivityStreamsAttributedToProperty()
+	attribution.AppendIRI(mustParseIRI(actorIRI))
 	note.SetActivityStreamsAttributedTo(attribution)
 
+	// Address the note publicly, copying the author's followers
+	to := streams.NewActivityStreamsToProperty()
+	to.AppendIRI(mustParseIRI(PublicAddress))
+	note.SetActivityStreamsTo(to)
+
+	cc := streams.NewActivityStreamsCcProperty()
+	cc.AppendIRI(mustParseIRI(actorIRI + "/followers"))
+
+	// Set mentions, copying mentioned actors so they are notified. Hashtags
+	// have no vocabulary type and are added by PostObject.
+	tags, err := s.tagSvc.ListTags(ctx, models.ObjectPost, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	tagProp := streams.NewActivityStreamsTagProperty()
+	for _, tag := range tags {
+		if tag.Kind != models.TagMention {
+			continue
+		}
+		hrefIRI, err := parseIRI(tag.Href)
+		if err != nil {
+			return nil, err
+		}
+		href := streams.NewActivityStreamsHrefProperty()
+		href.Set(hrefIRI)
+		name := streams.NewActivityStreamsNameProperty()
+		name.AppendXMLSchemaString(tag.Name)
+
+		mention := streams.NewActivityStreamsMention()
+		mention.SetActivityStreamsHref(href)
+		mention.SetActivityStreamsName(name)
+		tagProp.AppendActivityStreamsMention(mention)
+		cc.AppendIRI(hrefIRI)
+	}
+	if tagProp.Len() > 0 {
+		note.SetActivityStreamsTag(tagProp)
+	}
+	note.SetActivityStreamsCc(cc)
+
+	// Set the conversation the note belongs to
+	entry, err := s.threadSvc.GetEntryByObject(ctx, models.ObjectPost, post.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		entry, err = s.backfillEntry(ctx, post.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry.InReplyTo != "" {
+		parent, err := parseIRI(entry.InReplyTo)
+		if err != nil {
+			return nil, err
+		}
+		inReplyTo := streams.NewActivityStreamsInReplyToProperty()
+		inReplyTo.AppendIRI(parent)
+		note.SetActivityStreamsInReplyTo(inReplyTo)
+	}
+
+	contextIRI, err := parseIRI(entry.Context)
+	if err != nil {
+		return nil, err
+	}
+	conversation := streams.NewActivityStreamsContextProperty()
+	conversation.AppendIRI(contextIRI)
+	note.SetActivityStreamsContext(conversation)
+
+	// Set the replies collection
+	repliesID := streams.NewJSONLDIdProperty()
+	repliesID.Set(mustParseIRI(s.RepliesIRI(s.PostIRI(post.ID))))
+	repliesCollection := streams.NewActivityStreamsCollection()
+	repliesCollection.SetJSONLDId(repliesID)
+	replies := streams.NewActivityStreamsRepliesProperty()
+	replies.SetActivityStreamsCollection(repliesCollection)
+	note.SetActivityStreamsReplies(replies)
+
+	// Set media attachments
+	attachments, err := s.mediaSvc.ListPostMedia(ctx, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) > 0 {
+		attachmentProp := streams.NewActivityStreamsAttachmentProperty()
+		for _, attachment := range attachments {
+			document, err := mediaDocument(attachment)
+			if err != nil {
+				return nil, err
+			}
+			attachmentProp.AppendActivityStreamsDocument(document)
+		}
+		note.SetActivityStreamsAttachment(attachmentProp)
+	}
+
 	return note, nil
 }
 
-// HandleInbox processes incoming ActivityPub activities
-func (s *Service) HandleInbox(ctx context.Context, body []byte) error {
+// HandleInbox processes incoming ActivityPub activities. signer is the
+// actor whose HTTP Signature was verified by VerifyRequest; activities
+// claiming any other actor are rejected with ErrActorMismatch.
+func (s *Service) HandleInbox(ctx context.Context, signer string, body []byte) error {
 	var activity map[string]interface{}
 	if err := json.Unmarshal(body, &activity); err != nil {
 		return fmt.Errorf("failed to unmarshal activity: %v", err)
 	}
+	if actorIRI, _ := activity["actor"].(string); actorIRI != signer {
+		return fmt.Errorf("%w: %q signed by %s", ErrActorMismatch, activity["actor"], signer)
+	}
 
 	switch activity["type"] {
 	case "Follow":
@@ -138,10 +350,16 @@ func (s *Service) HandleInbox(ctx context.Context, body []byte) error {
 		}
 	case "Create":
 		if nested, ok := activity["object"].(map[string]interface{}); ok {
-			if nested["type"] == "Note" {
+			if isPostType(nested) {
 				return s.handleCreate(ctx, activity)
 			}
 		}
+	case "Accept":
+		return s.handleFollowResponse(ctx, activity, models.RelayAccepted)
+	case "Reject":
+		return s.handleFollowResponse(ctx, activity, models.RelayRejected)
+	case "Announce":
+		return s.handleAnnounce(ctx, activity)
 	}
 
 	return nil
@@ -149,65 +367,233 @@ func (s *Service) HandleInbox(ctx context.Context, body []byte) error {
 
 // handleFollow processes Follow activities
 func (s *Service) handleFollow(ctx context.Context, activity map[string]interface{}) error {
-	// Implementation for handling Follow activities
-	// This would typically involve:
-	// 1. Validating the activity
-	// 2. Creating a follower relationship in the database
-	// 3. Sending an Accept activity in response
+	if slug, ok := s.localOrganization(objectID(activity["object"])); ok {
+		return s.handleOrganizationFollow(ctx, slug, activity)
+	}
+	if username, ok := s.localUsername(objectID(activity["object"])); ok {
+		return s.handleUserFollow(ctx, username, activity)
+	}
 	return nil
 }
 
 // handleUnfollow processes Unfollow activities
 func (s *Service) handleUnfollow(ctx context.Context, activity map[string]interface{}) error {
-	// Implementation for handling Unfollow activities
-	// This would typically involve:
-	// 1. Validating the activity
-	// 2. Removing the follower relationship from the database
+	follow, _ := activity["object"].(map[string]interface{})
+	if slug, ok := s.localOrganization(objectID(follow["object"])); ok {
+		actorIRI, _ := activity["actor"].(string)
+		if follower, _ := follow["actor"].(string); follower != actorIRI {
+			return fmt.Errorf("undo by %s of a follow by %s", actorIRI, follower)
+		}
+		return s.handleOrganizationUnfollow(ctx, slug, actorIRI)
+	}
+	if username, ok := s.localUsername(objectID(follow["object"])); ok {
+		actorIRI, _ := activity["actor"].(string)
+		if follower, _ := follow["actor"].(string); follower != actorIRI {
+			return fmt.Errorf("undo by %s of a follow by %s", actorIRI, follower)
+		}
+		user, err := s.userSvc.GetUserByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		return s.followerSvc.RemoveFollower(ctx, user.ID, actorIRI)
+	}
 	return nil
 }
 
-// handleCreate processes Create activities
+// handleUserFollow records a remote actor following a local user and
+// accepts the Follow, signed as the user. The actor has been checked to be
+// the signer of the request by HandleInbox.
+func (s *Service) handleUserFollow(ctx context.Context, username string, activity map[string]interface{}) error {
+	user, err := s.userSvc.GetUserByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	actorIRI, _ := activity["actor"].(string)
+	if actorIRI == "" {
+		return fmt.Errorf("follow has no actor")
+	}
+	follower, err := s.FetchActor(ctx, actorIRI)
+	if err != nil {
+		return err
+	}
+
+	if err := s.followerSvc.AddFollower(ctx, user.ID, &models.Follower{
+		ActorIRI:    follower.ID,
+		Inbox:       follower.Inbox,
+		SharedInbox: follower.Endpoints.SharedInbox,
+	}); err != nil {
+		return err
+	}
+
+	accept := s.actorActivity(s.ActorIRI(user.Username), "Accept", activity)
+	body, err := json.Marshal(accept)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %v", err)
+	}
+	return s.deliver(ctx, s.ActorIRI(user.Username), follower.Inbox, body)
+}
+
+// handleCreate processes Create activities, storing remote notes with
+// their content sanitized
 func (s *Service) handleCreate(ctx context.Context, activity map[string]interface{}) error {
-	// Implementation for handling Create activities
-	// This would typically involve:
-	// 1. Validating the activity
-	// 2. Creating a new post in the database
-	// 3. Federating the post to followers if necessary
-	return nil
+	object, _ := activity["object"].(map[string]interface{})
+	actorIRI, _ := activity["actor"].(string)
+
+	if attributedTo, _ := object["attributedTo"].(string); attributedTo != actorIRI {
+		return fmt.Errorf("note %v is not attributed to %s", object["id"], actorIRI)
+	}
+
+	// Poll votes are named Notes replying to one of our Questions
+	if name, _ := object["name"].(string); name != "" {
+		inReplyTo, _ := object["inReplyTo"].(string)
+		if kind, postID, ok := s.localObject(inReplyTo); ok && kind == models.ObjectPost {
+			return s.handleVote(ctx, postID, object)
+		}
+	}
+
+	_, err := s.storeRemoteNote(ctx, object)
+	return err
+}
+
+// storeRemoteNote stores a remote note along with its tags and its place
+// in the conversation it belongs to
+func (s *Service) storeRemoteNote(ctx context.Context, object map[string]interface{}) (*models.RemotePost, error) {
+	id, _ := object["id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("note has no id")
+	}
+	if !validIRI(id) {
+		return nil, fmt.Errorf("note has an invalid id %q", id)
+	}
+
+	content, _ := object["content"].(string)
+	attributedTo, _ := object["attributedTo"].(string)
+	post := &models.RemotePost{
+		IRI:         id,
+		ActorIRI:    attributedTo,
+		Content:     SanitizeHTML(content),
+		PublishedAt: time.Now(),
+	}
+	if u, ok := object["url"].(string); ok {
+		post.URL = u
+	}
+	if published, ok := object["published"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, published); err == nil {
+			post.PublishedAt = t
+		}
+	}
+
+	if err := s.remotePostSvc.UpsertRemotePost(ctx, post); err != nil {
+		return nil, err
+	}
+
+	if err := s.tagSvc.SetTags(ctx, models.ObjectRemotePost, post.ID, remoteTags(object)); err != nil {
+		return nil, err
+	}
+
+	if err := s.storeRemoteAttachments(ctx, post.ID, object); err != nil {
+		return nil, err
+	}
+	s.refreshRemoteActor(ctx, attributedTo)
+
+	// Values that are not IRIs are dropped so the note is stored as the
+	// start of its own conversation
+	inReplyTo, _ := object["inReplyTo"].(string)
+	if !validIRI(inReplyTo) {
+		inReplyTo = ""
+	}
+	conversation, _ := object["context"].(string)
+	if !validIRI(conversation) {
+		conversation, _ = object["conversation"].(string)
+	}
+	if !validIRI(conversation) {
+		conversation = id
+	}
+
+	entry := &models.ThreadEntry{
+		IRI:         id,
+		Kind:        models.ObjectRemotePost,
+		ObjectID:    post.ID,
+		InReplyTo:   inReplyTo,
+		Context:     conversation,
+		PublishedAt: post.PublishedAt,
+	}
+	if err := s.threadSvc.SaveEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// remoteTags extracts the mentions and hashtags of a remote object
+func remoteTags(object map[string]interface{}) []*models.Tag {
+	var raw []interface{}
+	switch t := object["tag"].(type) {
+	case []interface{}:
+		raw = t
+	case map[string]interface{}:
+		raw = []interface{}{t}
+	}
+
+	var tags []*models.Tag
+	for _, item := range raw {
+		tag, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := tag["name"].(string)
+		href, _ := tag["href"].(string)
+		if name == "" {
+			continue
+		}
+		if !validIRI(href) {
+			href = ""
+		}
+
+		switch tag["type"] {
+		case "Hashtag":
+			tags = append(tags, &models.Tag{Kind: models.TagHashtag, Name: models.NormalizeHashtag(name), Href: href})
+		case "Mention":
+			if href == "" {
+				continue
+			}
+			tags = append(tags, &models.Tag{Kind: models.TagMention, Name: name, Href: href})
+		}
+	}
+	return tags
 }
 
 // GetOutbox returns a user's outbox (their posts)
-func (s *Service) GetOutbox(ctx context.Context, username string, page int) (vocab.Type, error) {
+func (s *Service) GetOutbox(ctx context.Context, username string, page int) (map[string]interface{}, error) {
 	user, err := s.userSvc.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	collection := streams.NewActivityStreamsOrderedCollection()
-	
-	// Set ID
-	id := streams.NewActivityStreamsId()
-	id.Set(fmt.Sprintf("https://%s/users/%s/outbox", s.domain, username))
-	collection.SetActivityStreamsId(id)
-
 	// Get posts
 	posts, err := s.postSvc.ListUserPosts(ctx, user.ID, (page-1)*20, 20)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert posts to activities
-	items := streams.NewActivityStreamsOrderedItems()
+	// Convert posts to Notes and Questions
+	items := make([]interface{}, 0, len(posts))
 	for _, post := range posts {
-		note, err := s.CreateNote(post)
+		object, err := s.PostObject(ctx, post, user)
 		if err != nil {
 			return nil, err
 		}
-		items.Append(note)
+		delete(object, "@context")
+		items = append(items, object)
 	}
-	collection.SetActivityStreamsOrderedItems(items)
 
-	return collection, nil
+	return map[string]interface{}{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           s.ActorIRI(username) + "/outbox",
+		"type":         "OrderedCollection",
+		"orderedItems": items,
+	}, nil
 }
 
 // WebFinger handles .well-known/webfinger requests
@@ -220,7 +606,7 @@ func (s *Service) WebFinger(ctx context.Context, resource string) (map[string]in
 			{
 				"rel":  "self",
 				"type": "application/activity+json",
-				"href": fmt.Sprintf("https://%s/users/%s", s.domain, resource),
+				"href": s.ActorIRI(resource),
 			},
 		},
 	}, nil
@@ -0,0 +1,168 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+// ErrNotBookmarkable is returned for IRIs that don't point to a job posting
+// or post that can be bookmarked
+var ErrNotBookmarkable = errors.New("object can't be bookmarked")
+
+// ResolveBookmark returns a bookmark of the job posting or post at an IRI,
+// with a snapshot of the object filled in. Local objects are read from the
+// database and remote ones from the post cache, or fetched if they aren't
+// cached. Fetched objects aren't stored: the snapshot in the bookmark is
+// all that is kept. Remote IRIs must be https URLs, or ErrInsecureIRI is
+// returned.
+func (s *Service) ResolveBookmark(ctx context.Context, iri string) (*models.Bookmark, error) {
+	if kind, id, ok := s.localObject(iri); ok {
+		return s.localBookmark(ctx, kind, id)
+	}
+	if err := checkRemoteIRI(iri); err != nil {
+		return nil, err
+	}
+
+	if post, err := s.remotePostSvc.GetRemotePostByIRI(ctx, iri); err == nil {
+		return &models.Bookmark{
+			Kind:      models.BookmarkPost,
+			ObjectIRI: post.IRI,
+			Summary:   PlainText(post.Content),
+			URL:       firstNonEmpty(post.URL, post.IRI),
+			Author:    s.remoteAuthorName(ctx, post.ActorIRI),
+		}, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	object, err := s.FetchObject(ctx, iri)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotBookmarkable, err)
+	}
+	if id, _ := object["id"].(string); id != iri {
+		return nil, fmt.Errorf("%w: object id %q does not match %q", ErrNotBookmarkable, id, iri)
+	}
+	return s.remoteBookmark(ctx, object)
+}
+
+// localBookmark returns a bookmark of a local job posting or post. Drafts
+// can't be bookmarked.
+func (s *Service) localBookmark(ctx context.Context, kind models.ObjectKind, id int) (*models.Bookmark, error) {
+	switch kind {
+	case models.ObjectJob:
+		job, err := s.jobSvc.GetJob(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotBookmarkable, err)
+		}
+		lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if lifecycle.State == models.JobDraft {
+			return nil, fmt.Errorf("%w: job is a draft", ErrNotBookmarkable)
+		}
+
+		jobID := job.ID
+		return &models.Bookmark{
+			Kind:      models.BookmarkJob,
+			ObjectIRI: s.JobIRI(job.ID),
+			JobID:     &jobID,
+			Title:     job.Title,
+			Summary:   joinNonEmpty(" · ", job.Location, job.SalaryRange),
+			URL:       s.JobIRI(job.ID),
+			Author:    job.Company,
+		}, nil
+
+	case models.ObjectPost:
+		post, err := s.postSvc.GetPost(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotBookmarkable, err)
+		}
+		author, err := s.userSvc.GetUserByID(ctx, post.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		postID := post.ID
+		return &models.Bookmark{
+			Kind:      models.BookmarkPost,
+			ObjectIRI: s.PostIRI(post.ID),
+			PostID:    &postID,
+			Summary:   PlainText(post.Content),
+			URL:       s.PostIRI(post.ID),
+			Author:    author.Username,
+		}, nil
+	}
+	return nil, ErrNotBookmarkable
+}
+
+// remoteBookmark returns a bookmark of a fetched remote JobPosting, Note,
+// Article or Question
+func (s *Service) remoteBookmark(ctx context.Context, object map[string]interface{}) (*models.Bookmark, error) {
+	iri, _ := object["id"].(string)
+	objectType, _ := object["type"].(string)
+	name, _ := object["name"].(string)
+	content, _ := object["content"].(string)
+	attributedTo, _ := object["attributedTo"].(string)
+	url, _ := object["url"].(string)
+
+	b := &models.Bookmark{
+		ObjectIRI: iri,
+		Title:     PlainText(name),
+		Summary:   PlainText(content),
+		URL:       firstNonEmpty(url, iri),
+		Author:    s.remoteAuthorName(ctx, attributedTo),
+	}
+
+	switch objectType {
+	case "JobPosting":
+		b.Kind = models.BookmarkJob
+		location, _ := object["jobLocation"].(string)
+		salary, _ := object["salaryRange"].(string)
+		b.Summary = joinNonEmpty(" · ", location, salary)
+		if company, _ := object["hiringOrganization"].(string); company != "" {
+			b.Author = company
+		}
+	case "Note", "Article", "Question":
+		b.Kind = models.BookmarkPost
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q", ErrNotBookmarkable, objectType)
+	}
+	return b, nil
+}
+
+// remoteAuthorName returns the display name of a remote actor if its
+// profile is stored, or its IRI
+func (s *Service) remoteAuthorName(ctx context.Context, actorIRI string) string {
+	profile, err := s.remoteActorSvc.GetRemoteActor(ctx, actorIRI)
+	if err != nil {
+		return actorIRI
+	}
+	return firstNonEmpty(profile.Name, profile.PreferredUsername, actorIRI)
+}
+
+// firstNonEmpty returns the first of values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// joinNonEmpty joins the values that aren't empty with sep
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
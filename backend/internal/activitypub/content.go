@@ -0,0 +1,199 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"openfirm/internal/models"
+)
+
+// contentTokenRe matches the parts of plain-text post content that are
+// rendered as links: URLs, @user or @user@host mentions and #hashtags
+var contentTokenRe = regexp.MustCompile(`https?://[^\s<>"]+|@[A-Za-z0-9_]+(?:@[A-Za-z0-9.-]+\.[A-Za-z]{2,})?|#[\p{L}\p{N}_]+`)
+
+// remoteContentPolicy is the allowlist applied to HTML received from other
+// servers. It keeps the markup Mastodon and friends produce for mentions,
+// hashtags and basic formatting, and drops everything else.
+var remoteContentPolicy = func() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "span", "strong", "b", "em", "i", "u", "del", "s",
+		"code", "pre", "blockquote", "ul", "ol", "li")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^((h-card|u-url|mention|hashtag|invisible|ellipsis)\s*)+$`)).OnElements("a", "span")
+	p.AllowURLSchemes("http", "https")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// SanitizeHTML strips remote HTML down to the allowed elements and attributes
+func SanitizeHTML(content string) string {
+	return remoteContentPolicy.Sanitize(content)
+}
+
+// plainTextPolicy strips all markup
+var plainTextPolicy = bluemonday.StrictPolicy()
+
+// PlainText turns HTML content into plain text, collapsing whitespace
+func PlainText(content string) string {
+	content = strings.NewReplacer("<br>", " ", "<br/>", " ", "<br />", " ", "</p>", "</p> ").Replace(content)
+	return strings.Join(strings.Fields(html.UnescapeString(plainTextPolicy.Sanitize(content))), " ")
+}
+
+// Content is post content rendered from plain text
+type Content struct {
+	HTML string
+	Tags []*models.Tag
+	// Mentioned holds the actor IRIs of everyone mentioned, for addressing
+	Mentioned []string
+	// Unresolved holds the user@host accounts on other servers that were
+	// mentioned but not looked up, for LookupAccounts
+	Unresolved []string
+}
+
+// TagIRI returns the URL of a hashtag's timeline
+func (s *Service) TagIRI(name string) string {
+	return fmt.Sprintf("https://%s/tags/%s", s.domain, models.NormalizeHashtag(name))
+}
+
+// RenderContent turns plain-text post content into safe HTML, linking URLs,
+// mentions and hashtags. Mentions of accounts on other servers are linked
+// through accounts, as returned by LookupAccounts. Ones not in it are
+// listed in Unresolved; they and accounts that don't exist are left as
+// plain text.
+func (s *Service) RenderContent(ctx context.Context, source string, accounts map[string]string) *Content {
+	content := &Content{}
+	seen := make(map[string]bool)
+	resolved := make(map[string]string, len(accounts))
+	for acct, actorIRI := range accounts {
+		resolved[acct] = actorIRI
+	}
+
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range contentTokenRe.FindAllStringIndex(paragraph, -1) {
+			start, end := loc[0], loc[1]
+			if !isTokenBoundary(paragraph, start) {
+				continue
+			}
+
+			token := paragraph[start:end]
+			var link string
+			switch token[0] {
+			case '@':
+				link = s.renderMention(ctx, token, resolved, seen, content)
+			case '#':
+				link = s.renderHashtag(token, seen, content)
+			default:
+				token = strings.TrimRight(token, ".,:;!?)'\"")
+				end = start + len(token)
+				link = fmt.Sprintf(`<a href="%s" rel="nofollow noopener noreferrer" target="_blank">%s</a>`,
+					html.EscapeString(token), html.EscapeString(token))
+			}
+			if link == "" {
+				continue
+			}
+
+			b.WriteString(html.EscapeString(paragraph[last:start]))
+			b.WriteString(link)
+			last = end
+		}
+		b.WriteString(html.EscapeString(paragraph[last:]))
+
+		paragraphs = append(paragraphs, "<p>"+strings.ReplaceAll(b.String(), "\n", "<br>")+"</p>")
+	}
+
+	content.HTML = strings.Join(paragraphs, "")
+	return content
+}
+
+// isTokenBoundary reports whether a link token starting at i stands on its
+// own rather than being part of a word, email address or URL
+func isTokenBoundary(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(text[:i])
+	return !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_@#/&", r))
+}
+
+// renderMention links a mention to its actor, recording the Mention tag
+func (s *Service) renderMention(ctx context.Context, token string, resolved map[string]string, seen map[string]bool, content *Content) string {
+	username, host := token[1:], s.domain
+	if i := strings.Index(username, "@"); i >= 0 {
+		username, host = username[:i], username[i+1:]
+	}
+	acct := strings.ToLower(username + "@" + host)
+
+	actorIRI, ok := resolved[acct]
+	if !ok {
+		if strings.EqualFold(host, s.domain) {
+			actorIRI = s.localMention(ctx, username)
+		} else {
+			content.Unresolved = append(content.Unresolved, acct)
+		}
+		resolved[acct] = actorIRI
+	}
+	if actorIRI == "" {
+		return ""
+	}
+
+	if !seen[actorIRI] {
+		seen[actorIRI] = true
+		content.Tags = append(content.Tags, &models.Tag{
+			Kind: models.TagMention,
+			Name: "@" + username + "@" + host,
+			Href: actorIRI,
+		})
+		content.Mentioned = append(content.Mentioned, actorIRI)
+	}
+
+	return fmt.Sprintf(`<span class="h-card"><a href="%s" class="u-url mention">@<span>%s</span></a></span>`,
+		html.EscapeString(actorIRI), html.EscapeString(username))
+}
+
+// localMention returns the actor IRI of a local user, or an empty string if
+// there is no such user
+func (s *Service) localMention(ctx context.Context, username string) string {
+	user, err := s.userSvc.GetUserByUsername(ctx, username)
+	if err != nil {
+		return ""
+	}
+	return s.ActorIRI(user.Username)
+}
+
+// renderHashtag links a hashtag to its timeline, recording the Hashtag tag
+func (s *Service) renderHashtag(token string, seen map[string]bool, content *Content) string {
+	name := token[1:]
+	if strings.IndexFunc(name, unicode.IsLetter) < 0 {
+		// Purely numeric tags like #1 are almost never meant as hashtags
+		return ""
+	}
+
+	href := s.TagIRI(name)
+	if !seen[href] {
+		seen[href] = true
+		content.Tags = append(content.Tags, &models.Tag{
+			Kind: models.TagHashtag,
+			Name: models.NormalizeHashtag(name),
+			Href: href,
+		})
+	}
+
+	return fmt.Sprintf(`<a href="%s" class="mention hashtag" rel="tag">#<span>%s</span></a>`,
+		html.EscapeString(href), html.EscapeString(name))
+}
@@ -0,0 +1,171 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"openfirm/internal/media"
+	"openfirm/internal/models"
+)
+
+const (
+	// deliveryTimeout bounds how long a single request to a remote server
+	// may take
+	deliveryTimeout = 30 * time.Second
+	// connectTimeout bounds connecting to a remote server
+	connectTimeout = 10 * time.Second
+	// maxRedirects is how many redirects a remote request follows
+	maxRedirects = 5
+)
+
+// httpClient makes every request to other servers. IRIs come from users
+// and remote servers, so it only connects to public addresses and only
+// follows redirects to https URLs.
+var httpClient = func() *http.Client {
+	dialer := &net.Dialer{Timeout: connectTimeout, Control: media.RefusePrivateAddresses}
+	return &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkRemoteURL(req.URL)
+		},
+	}
+}()
+
+// newActivity builds an activity with the common fields filled in
+func (s *Service) newActivity(activityType string, actor *models.User, object interface{}) map[string]interface{} {
+	actorIRI := s.ActorIRI(actor.Username)
+	return map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#%s-%d", actorIRI, activityType, time.Now().UnixNano()),
+		"type":     activityType,
+		"actor":    actorIRI,
+		"object":   object,
+		"to":       []string{PublicAddress},
+		"cc":       []string{actorIRI + "/followers"},
+	}
+}
+
+// wrapObject builds an activity around a serialized object, moving the
+// object's @context and addressing up to the activity
+func (s *Service) wrapObject(activityType string, actor *models.User, object map[string]interface{}) map[string]interface{} {
+	activity := s.newActivity(activityType, actor, object)
+	if context, ok := object["@context"]; ok {
+		activity["@context"] = context
+		delete(object, "@context")
+	}
+	if cc, ok := object["cc"]; ok {
+		activity["cc"] = cc
+	}
+	return activity
+}
+
+// Publish delivers an activity to the followers of the given user and to
+// any extra inboxes, such as those of mentioned actors. Public content is
+// also forwarded to the relays we forward to. Delivery happens in the
+// background so callers are not held up by remote servers.
+func (s *Service) Publish(ctx context.Context, actor *models.User, activity map[string]interface{}, extraInboxes ...string) error {
+	inboxes, err := s.followerSvc.FollowerInboxes(ctx, actor.ID)
+	if err != nil {
+		return err
+	}
+	inboxes = appendUnique(inboxes, extraInboxes...)
+
+	if relayable(activity) {
+		relays, err := s.relaySvc.ListForwardingInboxes(ctx)
+		if err != nil {
+			return err
+		}
+		inboxes = appendUnique(inboxes, relays...)
+	}
+
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %v", err)
+	}
+
+	go s.deliverAll(s.ActorIRI(actor.Username), body, inboxes)
+	return nil
+}
+
+// deliverAll posts an activity body, signed by the local actor given, to
+// each inbox, logging failures
+func (s *Service) deliverAll(actorIRI string, body []byte, inboxes []string) {
+	ctx := context.Background()
+	sg, err := s.signer(ctx, actorIRI)
+	if err != nil {
+		log.Printf("Failed to load the key of %s: %v", actorIRI, err)
+		return
+	}
+	for _, inbox := range inboxes {
+		if err := s.deliverSigned(ctx, sg, inbox, body); err != nil {
+			log.Printf("Failed to deliver activity to %s: %v", inbox, err)
+		}
+	}
+}
+
+// deliver posts an activity body, signed by the local actor given, to a
+// single inbox
+func (s *Service) deliver(ctx context.Context, actorIRI string, inbox string, body []byte) error {
+	sg, err := s.signer(ctx, actorIRI)
+	if err != nil {
+		return err
+	}
+	return s.deliverSigned(ctx, sg, inbox, body)
+}
+
+// deliverSigned posts a signed activity body to a single inbox
+func (s *Service) deliverSigned(ctx context.Context, sg *signer, inbox string, body []byte) error {
+	if err := checkRemoteIRI(inbox); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	if err := sg.sign(req, body); err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("inbox responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// appendUnique appends the values not already present in list
+func appendUnique(list []string, values ...string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, v := range list {
+		seen[v] = true
+	}
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,186 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+var (
+	// ErrNotOwner is returned when a user acts on an object they didn't
+	// author, or a job posting they can't edit
+	ErrNotOwner = errors.New("object is not owned by user")
+	// ErrNotListed is returned when pinning a job posting that is a draft,
+	// closed or expired
+	ErrNotListed = errors.New("job posting is not listed")
+)
+
+// FeaturedIRI returns the IRI of a local user's featured collection
+func (s *Service) FeaturedIRI(username string) string {
+	return fmt.Sprintf("%s/collections/featured", s.ActorIRI(username))
+}
+
+// GetFeatured returns the featured collection of a user, with the pinned
+// objects embedded so remote servers don't have to fetch each one
+func (s *Service) GetFeatured(ctx context.Context, username string) (map[string]interface{}, error) {
+	user, err := s.userSvc.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	featured, err := s.featuredSvc.ListFeatured(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]interface{}, 0, len(featured))
+	for _, item := range featured {
+		object, err := s.featuredObject(ctx, item, user)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Skip pins whose object has since been deleted or unlisted
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, object)
+	}
+
+	return map[string]interface{}{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           s.FeaturedIRI(username),
+		"type":         "OrderedCollection",
+		"totalItems":   len(items),
+		"orderedItems": items,
+	}, nil
+}
+
+// featuredObject returns the federated representation of a featured item.
+// Job postings that are no longer listed are reported as pgx.ErrNoRows, as
+// GetJobPosting hides drafts.
+func (s *Service) featuredObject(ctx context.Context, item *models.FeaturedItem, user *models.User) (interface{}, error) {
+	switch item.Kind {
+	case models.FeaturedPost:
+		post, err := s.postSvc.GetPost(ctx, item.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		return s.PostObject(ctx, post, user)
+	case models.FeaturedJob:
+		job, err := s.jobSvc.GetJob(ctx, item.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !lifecycle.Listed() {
+			return nil, pgx.ErrNoRows
+		}
+		// Organization jobs can be pinned by members who didn't post them
+		poster := user
+		if job.PostedBy != user.ID {
+			if poster, err = s.userSvc.GetUserByID(ctx, job.PostedBy); err != nil {
+				return nil, err
+			}
+		}
+		return s.CreateJobPosting(ctx, job, poster)
+	default:
+		return nil, fmt.Errorf("unknown featured kind: %s", item.Kind)
+	}
+}
+
+// Feature pins a post or job posting to a user's profile and federates an
+// Add activity targeting their featured collection
+func (s *Service) Feature(ctx context.Context, userID int, kind models.FeaturedKind, objectID int) error {
+	user, objectIRI, err := s.ownedObject(ctx, userID, kind, objectID)
+	if err != nil {
+		return err
+	}
+
+	item := &models.FeaturedItem{UserID: userID, Kind: kind, ObjectID: objectID}
+	if err := s.featuredSvc.AddFeatured(ctx, item); err != nil {
+		return err
+	}
+
+	activity := s.newActivity("Add", user, objectIRI)
+	activity["target"] = s.FeaturedIRI(user.Username)
+	return s.Publish(ctx, user, activity)
+}
+
+// Unfeature unpins a post or job posting from a user's profile and federates
+// a Remove activity targeting their featured collection. Only the user's
+// own pins can be removed, so the object itself isn't loaded: pins of
+// deleted objects can be removed too.
+func (s *Service) Unfeature(ctx context.Context, userID int, kind models.FeaturedKind, objectID int) error {
+	user, err := s.userSvc.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var objectIRI string
+	switch kind {
+	case models.FeaturedPost:
+		objectIRI = s.PostIRI(objectID)
+	case models.FeaturedJob:
+		objectIRI = s.JobIRI(objectID)
+	default:
+		return fmt.Errorf("unknown featured kind: %s", kind)
+	}
+
+	if err := s.featuredSvc.RemoveFeatured(ctx, userID, kind, objectID); err != nil {
+		return err
+	}
+
+	activity := s.newActivity("Remove", user, objectIRI)
+	activity["target"] = s.FeaturedIRI(user.Username)
+	return s.Publish(ctx, user, activity)
+}
+
+// ownedObject loads the user and verifies they authored the given post or
+// can edit the given job posting, returning the object's IRI. Job postings
+// must be listed.
+func (s *Service) ownedObject(ctx context.Context, userID int, kind models.FeaturedKind, objectID int) (*models.User, string, error) {
+	user, err := s.userSvc.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch kind {
+	case models.FeaturedPost:
+		post, err := s.postSvc.GetPost(ctx, objectID)
+		if err != nil {
+			return nil, "", err
+		}
+		if post.UserID != userID {
+			return nil, "", ErrNotOwner
+		}
+		return user, s.PostIRI(objectID), nil
+	case models.FeaturedJob:
+		job, err := s.jobSvc.GetJob(ctx, objectID)
+		if err != nil {
+			return nil, "", err
+		}
+		role, err := s.orgSvc.JobRole(ctx, job, userID)
+		if err != nil {
+			return nil, "", err
+		}
+		if !role.CanEdit() {
+			return nil, "", ErrNotOwner
+		}
+		lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, objectID)
+		if err != nil {
+			return nil, "", err
+		}
+		if !lifecycle.Listed() {
+			return nil, "", ErrNotListed
+		}
+		return user, s.JobIRI(objectID), nil
+	default:
+		return nil, "", fmt.Errorf("unknown featured kind: %s", kind)
+	}
+}
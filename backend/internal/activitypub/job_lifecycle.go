@@ -0,0 +1,103 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"openfirm/internal/models"
+)
+
+// SetJobState moves a job to a new state and tells remote servers about
+// it. Publishing a draft federates it for the first time; any other change
+// federates an Update whose validThrough shows whether the posting is open.
+func (s *Service) SetJobState(ctx context.Context, job *models.Job, state models.JobState) error {
+	lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.jobLifecycleSvc.Transition(ctx, job.ID, state); err != nil {
+		return err
+	}
+
+	if lifecycle.State == models.JobDraft {
+		return s.PublishJob(ctx, job)
+	}
+	return s.PublishJobUpdate(ctx, job)
+}
+
+// jobPostingObject returns the serialized JobPosting of a job and its poster
+func (s *Service) jobPostingObject(ctx context.Context, job *models.Job) (map[string]interface{}, *models.User, error) {
+	poster, err := s.userSvc.GetUserByID(ctx, job.PostedBy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	posting, err := s.CreateJobPosting(ctx, job, poster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Round trip through JSON to build the activity around the posting
+	data, err := json.Marshal(posting)
+	if err != nil {
+		return nil, nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, nil, err
+	}
+	return object, poster, nil
+}
+
+// PublishJobUpdate federates the current version of a job posting. Drafts
+// have never been federated and are skipped.
+func (s *Service) PublishJobUpdate(ctx context.Context, job *models.Job) error {
+	lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if lifecycle.State == models.JobDraft {
+		return nil
+	}
+
+	object, poster, err := s.jobPostingObject(ctx, job)
+	if err != nil {
+		return err
+	}
+	inboxes, err := s.organizationFollowerInboxes(ctx, job)
+	if err != nil {
+		return err
+	}
+	return s.Publish(ctx, poster, s.wrapObject("Update", poster, object), inboxes...)
+}
+
+// PublishJobDelete federates the deletion of a job posting. It must be
+// called before the job is deleted.
+func (s *Service) PublishJobDelete(ctx context.Context, job *models.Job) error {
+	lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, job.ID)
+	if err != nil {
+		return err
+	}
+	if lifecycle.State == models.JobDraft {
+		return nil
+	}
+
+	poster, err := s.userSvc.GetUserByID(ctx, job.PostedBy)
+	if err != nil {
+		return err
+	}
+
+	inboxes, err := s.organizationFollowerInboxes(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	tombstone := map[string]interface{}{
+		"id":         s.JobIRI(job.ID),
+		"type":       "Tombstone",
+		"formerType": "JobPosting",
+		"deleted":    time.Now().UTC().Format(time.RFC3339),
+	}
+	return s.Publish(ctx, poster, s.newActivity("Delete", poster, tombstone), inboxes...)
+}
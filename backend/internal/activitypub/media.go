@@ -0,0 +1,62 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"path"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/go-fed/activity/streams/vocab"
+	"openfirm/internal/models"
+)
+
+// MediaIRI returns the IRI clients use to reference an upload when
+// attaching it to a post
+func (s *Service) MediaIRI(id int) string {
+	return fmt.Sprintf("https://%s/media/%d", s.domain, id)
+}
+
+// mediaDocument serializes a media attachment as a Document
+func mediaDocument(attachment *models.MediaAttachment) (vocab.ActivityStreamsDocument, error) {
+	iri, err := parseIRI(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	document := streams.NewActivityStreamsDocument()
+
+	mediaType := streams.NewActivityStreamsMediaTypeProperty()
+	mediaType.Set(attachment.MediaType)
+	document.SetActivityStreamsMediaType(mediaType)
+
+	url := streams.NewActivityStreamsUrlProperty()
+	url.AppendIRI(iri)
+	document.SetActivityStreamsUrl(url)
+
+	if attachment.Description != "" {
+		name := streams.NewActivityStreamsNameProperty()
+		name.AppendXMLSchemaString(attachment.Description)
+		document.SetActivityStreamsName(name)
+	}
+
+	if attachment.Blurhash != "" {
+		blurhash := streams.NewTootBlurhashProperty()
+		blurhash.Set(attachment.Blurhash)
+		document.SetTootBlurhash(blurhash)
+	}
+
+	return document, nil
+}
+
+// avatarMediaType returns the content type of a user's avatar, falling back
+// to guessing from the file extension for avatars set before uploads were
+// tracked
+func (s *Service) avatarMediaType(ctx context.Context, avatarURL string) string {
+	if attachment, err := s.mediaSvc.GetMediaByURL(ctx, avatarURL); err == nil {
+		return attachment.MediaType
+	}
+	if mediaType := mime.TypeByExtension(path.Ext(avatarURL)); mediaType != "" {
+		return mediaType
+	}
+	return "image/jpeg"
+}
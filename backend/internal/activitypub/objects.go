@@ -0,0 +1,258 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+// PublicAddress is the special collection addressing an object to everyone
+const PublicAddress = "https://www.w3.org/ns/activitystreams#Public"
+
+// JobPosting represents a job posting as a federated object. The schema.org
+// terms are mapped into the ActivityStreams context so that remote servers
+// that don't understand JobPosting can still render it like an Article.
+type JobPosting struct {
+	Context            []interface{} `json:"@context"`
+	ID                 string        `json:"id"`
+	Type               string        `json:"type"`
+	Name               string        `json:"name"`
+	Content            string        `json:"content"`
+	AttributedTo       string        `json:"attributedTo"`
+	To                 []string      `json:"to"`
+	Cc                 []string      `json:"cc,omitempty"`
+	Published          time.Time     `json:"published"`
+	Replies            string        `json:"replies"`
+	HiringOrganization string        `json:"hiringOrganization,omitempty"`
+	JobLocation        string        `json:"jobLocation,omitempty"`
+	Qualifications     string        `json:"qualifications,omitempty"`
+	SalaryRange        string        `json:"salaryRange,omitempty"`
+	Tag                []*TagObject  `json:"tag,omitempty"`
+
+	EmploymentType                string          `json:"employmentType,omitempty"`
+	JobLocationType               string          `json:"jobLocationType,omitempty"`
+	ApplicantLocationRequirements []*Place        `json:"applicantLocationRequirements,omitempty"`
+	BaseSalary                    *MonetaryAmount `json:"baseSalary,omitempty"`
+	// ValidThrough is when the posting expires, or when it closed for
+	// postings that are no longer open
+	ValidThrough *time.Time `json:"validThrough,omitempty"`
+}
+
+// Place is a region remote applicants must live in
+type Place struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// MonetaryAmount is the schema.org salary of a job posting
+type MonetaryAmount struct {
+	Type     string             `json:"type"`
+	Currency string             `json:"currency"`
+	Value    *QuantitativeValue `json:"value"`
+}
+
+// QuantitativeValue is a salary range over a pay period
+type QuantitativeValue struct {
+	Type     string `json:"type"`
+	MinValue *int64 `json:"minValue,omitempty"`
+	MaxValue *int64 `json:"maxValue,omitempty"`
+	UnitText string `json:"unitText"`
+}
+
+// schemaEmploymentTypes maps employment types to their schema.org names
+var schemaEmploymentTypes = map[models.EmploymentType]string{
+	models.EmploymentFullTime:   "FULL_TIME",
+	models.EmploymentPartTime:   "PART_TIME",
+	models.EmploymentContract:   "CONTRACTOR",
+	models.EmploymentTemporary:  "TEMPORARY",
+	models.EmploymentInternship: "INTERN",
+	models.EmploymentVolunteer:  "VOLUNTEER",
+}
+
+// setJobDetails fills in the structured schema.org fields of a posting
+func setJobDetails(posting *JobPosting, d *models.JobDetails) {
+	posting.EmploymentType = schemaEmploymentTypes[d.EmploymentType]
+
+	if d.RemotePolicy == models.RemoteRemote {
+		posting.JobLocationType = "TELECOMMUTE"
+	}
+	for _, region := range d.RemoteRegions {
+		posting.ApplicantLocationRequirements = append(posting.ApplicantLocationRequirements,
+			&Place{Type: "AdministrativeArea", Name: region})
+	}
+
+	if d.SalaryMin != nil || d.SalaryMax != nil {
+		posting.BaseSalary = &MonetaryAmount{
+			Type:     "MonetaryAmount",
+			Currency: d.SalaryCurrency,
+			Value: &QuantitativeValue{
+				Type:     "QuantitativeValue",
+				MinValue: d.SalaryMin,
+				MaxValue: d.SalaryMax,
+				UnitText: strings.ToUpper(string(d.PayPeriod)),
+			},
+		}
+	}
+}
+
+// TagObject is a Mention or Hashtag attached to a federated object
+type TagObject struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+// tagObjects converts stored tags into their federated form
+func tagObjects(tags []*models.Tag) []*TagObject {
+	objects := make([]*TagObject, 0, len(tags))
+	for _, tag := range tags {
+		name := tag.Name
+		if tag.Kind == models.TagHashtag {
+			name = "#" + name
+		}
+		objects = append(objects, &TagObject{Type: string(tag.Kind), Name: name, Href: tag.Href})
+	}
+	return objects
+}
+
+// jobPostingContext extends the ActivityStreams context with the schema.org
+// terms used by JobPosting
+var jobPostingContext = []interface{}{
+	"https://www.w3.org/ns/activitystreams",
+	map[string]string{
+		"schema":             "http://schema.org#",
+		"JobPosting":         "schema:JobPosting",
+		"hiringOrganization": "schema:hiringOrganization",
+		"jobLocation":        "schema:jobLocation",
+		"qualifications":     "schema:qualifications",
+		"salaryRange":        "schema:estimatedSalary",
+
+		"employmentType":                "schema:employmentType",
+		"jobLocationType":               "schema:jobLocationType",
+		"applicantLocationRequirements": "schema:applicantLocationRequirements",
+		"AdministrativeArea":            "schema:AdministrativeArea",
+		"baseSalary":                    "schema:baseSalary",
+		"MonetaryAmount":                "schema:MonetaryAmount",
+		"QuantitativeValue":             "schema:QuantitativeValue",
+		"currency":                      "schema:currency",
+		"value":                         "schema:value",
+		"minValue":                      "schema:minValue",
+		"maxValue":                      "schema:maxValue",
+		"unitText":                      "schema:unitText",
+		"validThrough":                  "schema:validThrough",
+		"Skill":                         "schema:DefinedTerm",
+	},
+}
+
+// GetNote returns the serialized Note or Question for a local post
+func (s *Service) GetNote(ctx context.Context, id int) (map[string]interface{}, error) {
+	post, err := s.postSvc.GetPost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := s.userSvc.GetUserByID(ctx, post.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.PostObject(ctx, post, author)
+}
+
+// GetJobPosting returns the JobPosting object for a local job. Drafts are
+// not found.
+func (s *Service) GetJobPosting(ctx context.Context, id int) (*JobPosting, error) {
+	job, err := s.jobSvc.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if lifecycle.State == models.JobDraft {
+		return nil, pgx.ErrNoRows
+	}
+
+	poster, err := s.userSvc.GetUserByID(ctx, job.PostedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.CreateJobPosting(ctx, job, poster)
+}
+
+// jobValidThrough returns when a job stops being open: its expiry, or the
+// time it was paused or closed
+func jobValidThrough(l *models.JobLifecycle) *time.Time {
+	if l.State == models.JobPublished {
+		return l.ExpiresAt
+	}
+	return l.StateChangedAt
+}
+
+// CreateJobPosting creates a JobPosting object from a job
+func (s *Service) CreateJobPosting(ctx context.Context, job *models.Job, poster *models.User) (*JobPosting, error) {
+	actorIRI := s.ActorIRI(poster.Username)
+
+	tags, err := s.tagSvc.ListTags(ctx, models.ObjectJob, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := s.jobDetailsSvc.GetDetails(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	lifecycle, err := s.jobLifecycleSvc.GetLifecycle(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	jobSkills, err := s.skillSvc.ListJobSkills(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Jobs posted for an organization are addressed to its followers too
+	org, err := s.orgSvc.GetJobOrganization(ctx, job.ID)
+	if err != nil {
+		return nil, err
+	}
+	cc := []string{fmt.Sprintf("%s/followers", actorIRI)}
+	if org != nil {
+		cc = append(cc, s.OrganizationIRI(org.Slug)+"/followers")
+	}
+
+	skills := make([]models.Skill, 0, len(jobSkills))
+	for _, skill := range jobSkills {
+		skills = append(skills, skill.Skill)
+	}
+
+	posting := &JobPosting{
+		Context:            jobPostingContext,
+		ID:                 s.JobIRI(job.ID),
+		Type:               "JobPosting",
+		Name:               job.Title,
+		Content:            job.Description,
+		AttributedTo:       actorIRI,
+		To:                 []string{PublicAddress},
+		Cc:                 cc,
+		Published:          job.CreatedAt,
+		Replies:            s.RepliesIRI(s.JobIRI(job.ID)),
+		HiringOrganization: job.Company,
+		JobLocation:        job.Location,
+		Qualifications:     job.Requirements,
+		SalaryRange:        job.SalaryRange,
+		Tag:                append(tagObjects(tags), s.skillTags(skills)...),
+		ValidThrough:       jobValidThrough(lifecycle),
+	}
+	setJobDetails(posting, details)
+	return posting, nil
+}
@@ -0,0 +1,183 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"openfirm/internal/models"
+)
+
+// OrganizationIRI returns the IRI of an organization's actor
+func (s *Service) OrganizationIRI(slug string) string {
+	return fmt.Sprintf("https://%s/orgs/%s", s.domain, slug)
+}
+
+// localOrganization returns the slug of a local organization actor IRI
+func (s *Service) localOrganization(iri string) (string, bool) {
+	prefix := fmt.Sprintf("https://%s/orgs/", s.domain)
+	slug := strings.TrimPrefix(iri, prefix)
+	if slug == iri || slug == "" || strings.Contains(slug, "/") {
+		return "", false
+	}
+	return slug, true
+}
+
+// GetOrganizationActor returns the actor of an organization, which remote
+// users follow to hear about its new openings
+func (s *Service) GetOrganizationActor(ctx context.Context, slug string) (*Actor, error) {
+	org, err := s.orgSvc.GetOrganizationBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	actorURL := s.OrganizationIRI(org.Slug)
+	actor := &Actor{
+		Context: []interface{}{
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1",
+		},
+		ID:                actorURL,
+		Type:              "Organization",
+		PreferredUsername: org.Slug,
+		Name:              org.Name,
+		Summary:           org.Description,
+		Inbox:             fmt.Sprintf("%s/inbox", actorURL),
+		Outbox:            fmt.Sprintf("%s/outbox", actorURL),
+		Following:         fmt.Sprintf("%s/following", actorURL),
+		Followers:         fmt.Sprintf("%s/followers", actorURL),
+	}
+
+	if actor.PublicKey, err = s.publicKey(ctx, actorURL); err != nil {
+		return nil, err
+	}
+
+	if org.LogoURL != "" {
+		actor.Icon = &Image{
+			Type:      "Image",
+			MediaType: s.avatarMediaType(ctx, org.LogoURL),
+			URL:       org.LogoURL,
+		}
+	}
+
+	return actor, nil
+}
+
+// GetOrganizationFollowers returns the followers collection of an
+// organization. Only its size is public.
+func (s *Service) GetOrganizationFollowers(ctx context.Context, slug string) (map[string]interface{}, error) {
+	org, err := s.orgSvc.GetOrganizationBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.orgSvc.CountFollowers(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"@context":   "https://www.w3.org/ns/activitystreams",
+		"id":         s.OrganizationIRI(org.Slug) + "/followers",
+		"type":       "OrderedCollection",
+		"totalItems": count,
+	}, nil
+}
+
+// organizationActivity builds an activity sent by an organization actor
+func (s *Service) organizationActivity(org *models.Organization, activityType string, object interface{}) map[string]interface{} {
+	return s.actorActivity(s.OrganizationIRI(org.Slug), activityType, object)
+}
+
+// actorActivity builds an unaddressed activity sent by a local actor, such
+// as the Accept of a Follow
+func (s *Service) actorActivity(actorIRI, activityType string, object interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#%s-%d", actorIRI, activityType, time.Now().UnixNano()),
+		"type":     activityType,
+		"actor":    actorIRI,
+		"object":   object,
+	}
+}
+
+// handleOrganizationFollow records a remote actor following an
+// organization and accepts the Follow, signed as the organization. The
+// actor has been checked to be the signer of the request by HandleInbox.
+func (s *Service) handleOrganizationFollow(ctx context.Context, slug string, activity map[string]interface{}) error {
+	org, err := s.orgSvc.GetOrganizationBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	actorIRI, _ := activity["actor"].(string)
+	if actorIRI == "" {
+		return fmt.Errorf("follow has no actor")
+	}
+	follower, err := s.FetchActor(ctx, actorIRI)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orgSvc.AddFollower(ctx, org.ID, &models.OrganizationFollower{
+		ActorIRI:    follower.ID,
+		Inbox:       follower.Inbox,
+		SharedInbox: follower.Endpoints.SharedInbox,
+	}); err != nil {
+		return err
+	}
+
+	accept := s.organizationActivity(org, "Accept", activity)
+	body, err := json.Marshal(accept)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %v", err)
+	}
+	return s.deliver(ctx, s.OrganizationIRI(org.Slug), follower.Inbox, body)
+}
+
+// handleOrganizationUnfollow forgets a remote actor that stopped following
+// an organization
+func (s *Service) handleOrganizationUnfollow(ctx context.Context, slug, actorIRI string) error {
+	org, err := s.orgSvc.GetOrganizationBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	return s.orgSvc.RemoveFollower(ctx, org.ID, actorIRI)
+}
+
+// organizationFollowerInboxes returns the inboxes of the followers of the
+// organization a job is posted for, if any
+func (s *Service) organizationFollowerInboxes(ctx context.Context, job *models.Job) ([]string, error) {
+	org, err := s.orgSvc.GetJobOrganization(ctx, job.ID)
+	if err != nil || org == nil {
+		return nil, err
+	}
+	return s.orgSvc.FollowerInboxes(ctx, org.ID)
+}
+
+// announceJob shares a new job posting with the followers of the
+// organization it is posted for. Delivery happens in the background.
+func (s *Service) announceJob(ctx context.Context, job *models.Job) error {
+	org, err := s.orgSvc.GetJobOrganization(ctx, job.ID)
+	if err != nil || org == nil {
+		return err
+	}
+
+	inboxes, err := s.orgSvc.FollowerInboxes(ctx, org.ID)
+	if err != nil {
+		return err
+	}
+
+	announce := s.organizationActivity(org, "Announce", s.JobIRI(job.ID))
+	announce["to"] = []string{PublicAddress}
+	announce["cc"] = []string{s.OrganizationIRI(org.Slug) + "/followers"}
+	body, err := json.Marshal(announce)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %v", err)
+	}
+
+	go s.deliverAll(s.OrganizationIRI(org.Slug), body, inboxes)
+	return nil
+}
@@ -0,0 +1,223 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"openfirm/internal/models"
+)
+
+// HandleOutbox processes an activity posted to a local user's outbox and
+// returns the activity as published
+func (s *Service) HandleOutbox(ctx context.Context, username string, activity map[string]interface{}) (map[string]interface{}, error) {
+	user, err := s.userSvc.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	object, _ := activity["object"].(map[string]interface{})
+
+	switch activity["type"] {
+	case "Create":
+		mediaIDs, err := s.attachmentMediaIDs(object)
+		if err != nil {
+			return nil, err
+		}
+		opts := PostOptions{MediaIDs: mediaIDs}
+		opts.InReplyTo, _ = object["inReplyTo"].(string)
+
+		switch object["type"] {
+		case "Note":
+			return s.CreatePost(ctx, user, noteSource(object), opts)
+		case "Question":
+			if opts.Poll, err = questionPoll(object); err != nil {
+				return nil, err
+			}
+			return s.CreatePost(ctx, user, noteSource(object), opts)
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported activity type: %v", activity["type"])
+}
+
+// noteSource returns the plain-text source of a note submitted by a client
+func noteSource(note map[string]interface{}) string {
+	if source, ok := note["source"].(map[string]interface{}); ok {
+		if content, ok := source["content"].(string); ok {
+			return content
+		}
+	}
+	content, _ := note["content"].(string)
+	return content
+}
+
+// PostOptions holds the optional parts of a new post
+type PostOptions struct {
+	// InReplyTo is the IRI of the post or job posting replied to
+	InReplyTo string
+	// Poll turns the post into a Question
+	Poll *models.Poll
+	// MediaIDs are the author's uploads to attach, in order
+	MediaIDs []int
+}
+
+// CreatePost stores a new post written by a local user, rendering its
+// mentions and hashtags, and federates it as a Create activity. Mentions of
+// accounts on other servers are looked up in the background, as remote
+// servers can be slow, so the activity returned links only local ones.
+func (s *Service) CreatePost(ctx context.Context, author *models.User, source string, opts PostOptions) (map[string]interface{}, error) {
+	if opts.Poll != nil {
+		if err := opts.Poll.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.MediaIDs) > models.MaxPostAttachments {
+		return nil, fmt.Errorf("a post can have at most %d attachments", models.MaxPostAttachments)
+	}
+
+	content := s.RenderContent(ctx, source, nil)
+
+	// Resolve the parent first so replies to unknown posts fail early
+	var conversation string
+	notify := content.Mentioned
+	if opts.InReplyTo != "" {
+		parentContext, parentActor, err := s.threadParent(ctx, opts.InReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %v", opts.InReplyTo, err)
+		}
+		conversation = parentContext
+		notify = appendUnique(notify, parentActor)
+	}
+
+	post := &models.Post{
+		UserID:  author.ID,
+		Content: content.HTML,
+	}
+	parts := &models.NewPostParts{
+		Tags: content.Tags,
+		Thread: func(post *models.Post) *models.ThreadEntry {
+			if conversation == "" {
+				conversation = s.ContextIRI(post.ID)
+			}
+			return &models.ThreadEntry{
+				IRI:         s.PostIRI(post.ID),
+				Kind:        models.ObjectPost,
+				ObjectID:    post.ID,
+				InReplyTo:   opts.InReplyTo,
+				Context:     conversation,
+				PublishedAt: post.CreatedAt,
+			}
+		},
+		Poll:     opts.Poll,
+		MediaIDs: opts.MediaIDs,
+	}
+	if err := s.postSvc.CreatePostWithParts(ctx, post, parts); err != nil {
+		return nil, err
+	}
+
+	activity, err := s.createActivity(ctx, post, author)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.federatePost(post, author, source, content, notify)
+	return activity, nil
+}
+
+// createActivity builds the Create activity of a post
+func (s *Service) createActivity(ctx context.Context, post *models.Post, author *models.User) (map[string]interface{}, error) {
+	object, err := s.PostObject(ctx, post, author)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := s.wrapObject("Create", author, object)
+	activity["id"] = s.PostIRI(post.ID) + "/activity"
+	return activity, nil
+}
+
+// federatePost looks up the accounts on other servers a new post mentions,
+// linking them in its content, then federates the post to its author's
+// followers and everyone it mentions or replies to
+func (s *Service) federatePost(post *models.Post, author *models.User, source string, content *Content, notify []string) {
+	ctx := context.Background()
+
+	if len(content.Unresolved) > 0 {
+		resolved := s.RenderContent(ctx, source, s.LookupAccounts(ctx, content.Unresolved))
+		if resolved.HTML != content.HTML {
+			post.Content = resolved.HTML
+			if err := s.postSvc.SetPostContent(ctx, post.ID, post.Content); err != nil {
+				log.Printf("Failed to link mentions in post %d: %v", post.ID, err)
+				return
+			}
+			if err := s.tagSvc.SetTags(ctx, models.ObjectPost, post.ID, resolved.Tags); err != nil {
+				log.Printf("Failed to tag mentions in post %d: %v", post.ID, err)
+				return
+			}
+			notify = appendUnique(notify, resolved.Mentioned...)
+		}
+	}
+
+	activity, err := s.createActivity(ctx, post, author)
+	if err != nil {
+		log.Printf("Failed to build activity for post %d: %v", post.ID, err)
+		return
+	}
+	if err := s.Publish(ctx, author, activity, s.mentionInboxes(ctx, notify)...); err != nil {
+		log.Printf("Failed to federate post %d: %v", post.ID, err)
+	}
+}
+
+// attachmentMediaIDs reads the uploads a client attaches to a new note.
+// Attachments are referenced by the IRI returned from the upload API.
+func (s *Service) attachmentMediaIDs(object map[string]interface{}) ([]int, error) {
+	var attachments []interface{}
+	switch attachment := object["attachment"].(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		attachments = attachment
+	default:
+		attachments = []interface{}{attachment}
+	}
+
+	prefix := fmt.Sprintf("https://%s/media/", s.domain)
+
+	ids := make([]int, 0, len(attachments))
+	for _, attachment := range attachments {
+		iri, ok := attachment.(string)
+		if document, isDocument := attachment.(map[string]interface{}); isDocument {
+			iri, ok = document["id"].(string)
+		}
+		if !ok || !strings.HasPrefix(iri, prefix) {
+			return nil, models.ErrMediaUnavailable
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(iri, prefix))
+		if err != nil {
+			return nil, models.ErrMediaUnavailable
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// mentionInboxes returns the inboxes of the remote actors in actorIRIs
+func (s *Service) mentionInboxes(ctx context.Context, actorIRIs []string) []string {
+	local := fmt.Sprintf("https://%s/", s.domain)
+
+	var inboxes []string
+	for _, iri := range actorIRIs {
+		if strings.HasPrefix(iri, local) {
+			continue
+		}
+		actor, err := s.FetchActor(ctx, iri)
+		if err != nil {
+			continue
+		}
+		inboxes = append(inboxes, actor.DeliveryInbox())
+	}
+	return inboxes
+}
@@ -0,0 +1,222 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-fed/activity/streams"
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+// PostObject serializes a local post as a Note, or as a Question when a
+// poll is attached to it
+func (s *Service) PostObject(ctx context.Context, post *models.Post, author *models.User) (map[string]interface{}, error) {
+	note, err := s.CreateNote(ctx, post, author)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := streams.Serialize(note)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := s.tagSvc.ListTags(ctx, models.ObjectPost, post.ID)
+	if err != nil {
+		return nil, err
+	}
+	appendHashtags(object, tags)
+
+	poll, err := s.pollSvc.GetPollByPost(ctx, post.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return object, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	setQuestion(object, poll)
+	return object, nil
+}
+
+// appendHashtags adds the Hashtag tags of a post to its serialized object
+func appendHashtags(object map[string]interface{}, tags []*models.Tag) {
+	var tagList []interface{}
+	switch existing := object["tag"].(type) {
+	case []interface{}:
+		tagList = existing
+	case nil:
+	default:
+		tagList = []interface{}{existing}
+	}
+
+	added := false
+	for _, tag := range tags {
+		if tag.Kind != models.TagHashtag {
+			continue
+		}
+		tagList = append(tagList, map[string]interface{}{
+			"type": "Hashtag",
+			"href": tag.Href,
+			"name": "#" + tag.Name,
+		})
+		added = true
+	}
+	if added {
+		object["tag"] = tagList
+	}
+}
+
+// setQuestion turns a serialized Note into a Question carrying the poll's
+// options and current tallies
+func setQuestion(object map[string]interface{}, poll *models.Poll) {
+	options := make([]map[string]interface{}, 0, len(poll.Options))
+	for _, option := range poll.Options {
+		options = append(options, map[string]interface{}{
+			"type": "Note",
+			"name": option.Name,
+			"replies": map[string]interface{}{
+				"type":       "Collection",
+				"totalItems": option.Votes,
+			},
+		})
+	}
+
+	object["@context"] = []interface{}{
+		"https://www.w3.org/ns/activitystreams",
+		map[string]string{
+			"toot":        "http://joinmastodon.org/ns#",
+			"votersCount": "toot:votersCount",
+		},
+	}
+	object["type"] = "Question"
+	object["endTime"] = poll.EndTime.UTC().Format(time.RFC3339)
+	object["votersCount"] = poll.VotersCount
+	if poll.Multiple {
+		object["anyOf"] = options
+	} else {
+		object["oneOf"] = options
+	}
+	if poll.Closed() {
+		object["closed"] = poll.EndTime.UTC().Format(time.RFC3339)
+	}
+}
+
+// questionPoll reads the poll of a Question submitted by a client
+func questionPoll(question map[string]interface{}) (*models.Poll, error) {
+	poll := &models.Poll{}
+
+	choices, ok := question["oneOf"].([]interface{})
+	if anyOf, isAnyOf := question["anyOf"].([]interface{}); isAnyOf {
+		if ok {
+			return nil, fmt.Errorf("%w: question cannot have both oneOf and anyOf", models.ErrInvalidPoll)
+		}
+		choices, ok, poll.Multiple = anyOf, true, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: question has no oneOf or anyOf", models.ErrInvalidPoll)
+	}
+
+	for _, choice := range choices {
+		option, _ := choice.(map[string]interface{})
+		name, _ := option["name"].(string)
+		poll.Options = append(poll.Options, &models.PollOption{Name: name})
+	}
+
+	endTime, _ := question["endTime"].(string)
+	t, err := time.Parse(time.RFC3339, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid endTime: %v", models.ErrInvalidPoll, err)
+	}
+	poll.EndTime = t
+
+	return poll, poll.Validate()
+}
+
+// Vote records a local user's choices in a poll and federates the new
+// tallies. choices are indexes into the poll's options.
+func (s *Service) Vote(ctx context.Context, userID, postID int, choices []int) (*models.Poll, error) {
+	voter, err := s.userSvc.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	poll, err := s.pollSvc.GetPollByPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(choices))
+	for _, choice := range choices {
+		if choice < 0 || choice >= len(poll.Options) {
+			return nil, models.ErrInvalidChoice
+		}
+		names = append(names, poll.Options[choice].Name)
+	}
+	if err := s.pollSvc.RecordVote(ctx, poll, s.ActorIRI(voter.Username), "", names...); err != nil {
+		return nil, err
+	}
+
+	return s.publishPollUpdate(ctx, postID)
+}
+
+// handleVote processes a remote vote: a Note with a name replying to one of
+// our Questions
+func (s *Service) handleVote(ctx context.Context, postID int, vote map[string]interface{}) error {
+	poll, err := s.pollSvc.GetPollByPost(ctx, postID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not a poll, so this is an ordinary reply that happens to be named
+		_, err = s.storeRemoteNote(ctx, vote)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	voteIRI, _ := vote["id"].(string)
+	voterIRI, _ := vote["attributedTo"].(string)
+	name, _ := vote["name"].(string)
+
+	err = s.pollSvc.RecordVote(ctx, poll, voterIRI, voteIRI, name)
+	if errors.Is(err, models.ErrAlreadyVoted) {
+		// Redelivered votes are expected
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = s.publishPollUpdate(ctx, postID)
+	return err
+}
+
+// publishPollUpdate federates an Update carrying a Question's new tallies
+func (s *Service) publishPollUpdate(ctx context.Context, postID int) (*models.Poll, error) {
+	post, err := s.postSvc.GetPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := s.userSvc.GetUserByID(ctx, post.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	question, err := s.PostObject(ctx, post, author)
+	if err != nil {
+		return nil, err
+	}
+
+	poll, err := s.pollSvc.GetPollByPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Publish(ctx, author, s.wrapObject("Update", author, question)); err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
@@ -0,0 +1,236 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+// InstanceActorIRI returns the IRI of the actor representing the instance
+// itself, which follows relays on behalf of the whole server
+func (s *Service) InstanceActorIRI() string {
+	return fmt.Sprintf("https://%s/actor", s.domain)
+}
+
+// GetInstanceActor returns the instance actor, with the key relays verify
+// its deliveries with
+func (s *Service) GetInstanceActor(ctx context.Context) (*Actor, error) {
+	actorURL := s.InstanceActorIRI()
+	publicKey, err := s.publicKey(ctx, actorURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Actor{
+		Context: []interface{}{
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1",
+		},
+		ID:                actorURL,
+		Type:              "Application",
+		PreferredUsername: s.domain,
+		Name:              s.domain,
+		Inbox:             fmt.Sprintf("%s/inbox", actorURL),
+		Outbox:            fmt.Sprintf("%s/outbox", actorURL),
+		Following:         fmt.Sprintf("%s/following", actorURL),
+		Followers:         fmt.Sprintf("%s/followers", actorURL),
+		PublicKey:         publicKey,
+	}, nil
+}
+
+// instanceActivity builds an activity sent by the instance actor
+func (s *Service) instanceActivity(activityType string, object interface{}) map[string]interface{} {
+	actorIRI := s.InstanceActorIRI()
+	return map[string]interface{}{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s#%s-%d", actorIRI, activityType, time.Now().UnixNano()),
+		"type":     activityType,
+		"actor":    actorIRI,
+		"object":   object,
+	}
+}
+
+// sendAsInstance delivers an activity from the instance actor to one inbox
+// and waits for the result
+func (s *Service) sendAsInstance(ctx context.Context, inbox string, activity map[string]interface{}) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity: %v", err)
+	}
+	return s.deliver(ctx, s.InstanceActorIRI(), inbox, body)
+}
+
+// SubscribeRelay follows a relay from the instance actor. The relay stays
+// pending until it answers with an Accept.
+func (s *Service) SubscribeRelay(ctx context.Context, inbox string, forward bool) (*models.Relay, error) {
+	follow := s.instanceActivity("Follow", PublicAddress)
+
+	relay := &models.Relay{
+		Inbox:            inbox,
+		FollowActivityID: follow["id"].(string),
+		Forward:          forward,
+	}
+	if err := s.relaySvc.CreateRelay(ctx, relay); err != nil {
+		return nil, err
+	}
+
+	if err := s.sendAsInstance(ctx, inbox, follow); err != nil {
+		s.relaySvc.DeleteRelay(ctx, relay.ID)
+		return nil, fmt.Errorf("failed to follow relay: %v", err)
+	}
+	return relay, nil
+}
+
+// UnsubscribeRelay undoes our Follow of a relay and forgets it. The relay
+// is forgotten even if it can't be reached.
+func (s *Service) UnsubscribeRelay(ctx context.Context, id int) error {
+	relay, err := s.relaySvc.GetRelay(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	follow := map[string]interface{}{
+		"id":     relay.FollowActivityID,
+		"type":   "Follow",
+		"actor":  s.InstanceActorIRI(),
+		"object": PublicAddress,
+	}
+	deliveryErr := s.sendAsInstance(ctx, relay.Inbox, s.instanceActivity("Undo", follow))
+
+	if err := s.relaySvc.DeleteRelay(ctx, id); err != nil {
+		return err
+	}
+	if deliveryErr != nil {
+		return fmt.Errorf("relay removed but could not be notified: %v", deliveryErr)
+	}
+	return nil
+}
+
+// objectID returns the id of an object property, which may be an IRI or an
+// embedded object
+func objectID(object interface{}) string {
+	switch o := object.(type) {
+	case string:
+		return o
+	case map[string]interface{}:
+		id, _ := o["id"].(string)
+		return id
+	}
+	return ""
+}
+
+// handleFollowResponse processes an Accept or Reject of one of our relay
+// Follows. Responses to anything else are ignored. The responding actor
+// must live on the relay's host, as Announces are later trusted by actor.
+func (s *Service) handleFollowResponse(ctx context.Context, activity map[string]interface{}, state models.RelayState) error {
+	relay, err := s.relaySvc.GetRelayByFollow(ctx, objectID(activity["object"]))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	actorIRI, _ := activity["actor"].(string)
+	if !sameHost(actorIRI, relay.Inbox) {
+		return fmt.Errorf("relay response from %q does not match relay inbox %s", actorIRI, relay.Inbox)
+	}
+	return s.relaySvc.SetRelayState(ctx, relay.ID, state, actorIRI)
+}
+
+// sameHost reports whether two IRIs are on the same host
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil || ua.Host == "" {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Host, ub.Host)
+}
+
+// handleAnnounce processes content pushed by a relay we follow. The
+// announced object is always fetched from its origin rather than trusting
+// a copy embedded by the relay.
+func (s *Service) handleAnnounce(ctx context.Context, activity map[string]interface{}) error {
+	actorIRI, _ := activity["actor"].(string)
+	if _, err := s.relaySvc.GetAcceptedRelayByActor(ctx, actorIRI); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Boosts from people are not handled yet
+			return nil
+		}
+		return err
+	}
+
+	iri := objectID(activity["object"])
+	if iri == "" {
+		return fmt.Errorf("announce has no object")
+	}
+	if _, _, ok := s.localObject(iri); ok {
+		return nil
+	}
+
+	object, err := s.FetchObject(ctx, iri)
+	if err != nil {
+		return err
+	}
+	if object["id"] != iri {
+		return fmt.Errorf("%s has mismatched id %v", iri, object["id"])
+	}
+	if !isPostType(object) {
+		return nil
+	}
+
+	_, err = s.storeRemoteNote(ctx, object)
+	return err
+}
+
+// relayable reports whether an activity is public content relays accept
+func relayable(activity map[string]interface{}) bool {
+	switch activity["type"] {
+	case "Create", "Update", "Delete":
+	default:
+		return false
+	}
+
+	switch to := activity["to"].(type) {
+	case []string:
+		for _, iri := range to {
+			if iri == PublicAddress {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, iri := range to {
+			if iri == PublicAddress {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PublishJob federates a new job posting to the poster's followers and to
+// the relays we forward to. Jobs posted for an organization are also
+// announced to the organization's followers.
+func (s *Service) PublishJob(ctx context.Context, job *models.Job) error {
+	object, poster, err := s.jobPostingObject(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	activity := s.wrapObject("Create", poster, object)
+	activity["id"] = s.JobIRI(job.ID) + "/activity"
+	if err := s.Publish(ctx, poster, activity); err != nil {
+		return err
+	}
+	return s.announceJob(ctx, job)
+}
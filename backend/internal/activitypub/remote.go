@@ -0,0 +1,174 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRemoteDocumentSize caps how much of a remote JSON document is read
+	maxRemoteDocumentSize = 1 << 20
+	// accountLookupTimeout bounds resolving all the accounts a post mentions
+	accountLookupTimeout = 15 * time.Second
+)
+
+// ErrInsecureIRI is returned for remote IRIs that aren't https URLs
+var ErrInsecureIRI = errors.New("remote IRIs must be https URLs")
+
+// checkRemoteURL returns ErrInsecureIRI unless u is an https URL of a named
+// host
+func checkRemoteURL(u *url.URL) error {
+	if u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("%w: %s", ErrInsecureIRI, u.Redacted())
+	}
+	return nil
+}
+
+// checkRemoteIRI returns ErrInsecureIRI unless iri is an https URL
+func checkRemoteIRI(iri string) error {
+	u, err := url.Parse(iri)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInsecureIRI, err)
+	}
+	return checkRemoteURL(u)
+}
+
+// RemoteActor holds the fields of a remote actor we need for addressing
+type RemoteActor struct {
+	ID                string `json:"id"`
+	Type              string `json:"type"`
+	PreferredUsername string `json:"preferredUsername"`
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	Inbox             string `json:"inbox"`
+	Endpoints         struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	// Icon is the actor's avatar, either an Image or a list of them
+	Icon interface{} `json:"icon"`
+	// PublicKey verifies the signatures of requests the actor sends
+	PublicKey PublicKey `json:"publicKey"`
+}
+
+// DeliveryInbox returns the inbox activities for this actor should be sent
+// to, preferring the shared inbox
+func (a *RemoteActor) DeliveryInbox() string {
+	if a.Endpoints.SharedInbox != "" {
+		return a.Endpoints.SharedInbox
+	}
+	return a.Inbox
+}
+
+// fetchJSON retrieves a JSON document from a remote server
+func (s *Service) fetchJSON(ctx context.Context, rawURL, accept string, v interface{}) error {
+	if err := checkRemoteIRI(rawURL); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", rawURL, resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxRemoteDocumentSize)).Decode(v)
+}
+
+// FetchObject dereferences an ActivityPub object by IRI
+func (s *Service) FetchObject(ctx context.Context, iri string) (map[string]interface{}, error) {
+	var object map[string]interface{}
+	if err := s.fetchJSON(ctx, iri, "application/activity+json", &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// FetchActor dereferences a remote actor by IRI
+func (s *Service) FetchActor(ctx context.Context, iri string) (*RemoteActor, error) {
+	actor := &RemoteActor{}
+	if err := s.fetchJSON(ctx, iri, "application/activity+json", actor); err != nil {
+		return nil, err
+	}
+	if actor.ID != iri {
+		return nil, fmt.Errorf("actor id %q does not match %q", actor.ID, iri)
+	}
+	s.storeRemoteActor(ctx, actor)
+	return actor, nil
+}
+
+// LookupAccount resolves a user@host account through WebFinger to the IRI
+// of its actor
+func (s *Service) LookupAccount(ctx context.Context, username, host string) (string, error) {
+	endpoint := fmt.Sprintf("https://%s/.well-known/webfinger?resource=%s",
+		host, url.QueryEscape(fmt.Sprintf("acct:%s@%s", username, host)))
+
+	var jrd struct {
+		Links []struct {
+			Rel  string `json:"rel"`
+			Type string `json:"type"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	if err := s.fetchJSON(ctx, endpoint, "application/jrd+json", &jrd); err != nil {
+		return "", err
+	}
+
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && (link.Type == "application/activity+json" ||
+			link.Type == `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`) {
+			if err := checkRemoteIRI(link.Href); err != nil {
+				return "", err
+			}
+			return link.Href, nil
+		}
+	}
+	return "", fmt.Errorf("no ActivityPub actor for %s@%s", username, host)
+}
+
+// LookupAccounts resolves user@host accounts through WebFinger at the same
+// time, mapping each to the IRI of its actor, or to "" if it can't be
+// resolved
+func (s *Service) LookupAccounts(ctx context.Context, accts []string) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, accountLookupTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	actors := make(map[string]string, len(accts))
+	for _, acct := range accts {
+		wg.Add(1)
+		go func(acct string) {
+			defer wg.Done()
+			var actorIRI string
+			if i := strings.LastIndex(acct, "@"); i > 0 {
+				actorIRI, _ = s.LookupAccount(ctx, acct[:i], acct[i+1:])
+			}
+			mu.Lock()
+			actors[acct] = actorIRI
+			mu.Unlock()
+		}(acct)
+	}
+	wg.Wait()
+	return actors
+}
@@ -0,0 +1,187 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+const (
+	// maxRemoteAttachments caps the attachments stored per remote post
+	maxRemoteAttachments = 8
+	// remoteActorRefreshInterval is how long a remote actor's profile is
+	// used before it is fetched again
+	remoteActorRefreshInterval = 24 * time.Hour
+)
+
+// Attachment is a media attachment as shown to clients. Remote files are
+// served through our media cache.
+type Attachment struct {
+	URL         string `json:"url"`
+	PreviewURL  string `json:"preview_url,omitempty"`
+	MediaType   string `json:"media_type"`
+	Description string `json:"description,omitempty"`
+	Blurhash    string `json:"blurhash,omitempty"`
+}
+
+// RemoteMediaURL returns the URL a cached remote file is served from
+func (s *Service) RemoteMediaURL(id int) string {
+	return fmt.Sprintf("https://%s/media/proxy/%d", s.domain, id)
+}
+
+// linkHref returns the URL of a url or icon property, which may be a
+// plain IRI, a Link or Image object, or a list of them
+func linkHref(v interface{}) string {
+	switch link := v.(type) {
+	case string:
+		return link
+	case map[string]interface{}:
+		if href, ok := link["href"].(string); ok {
+			return href
+		}
+		return linkHref(link["url"])
+	case []interface{}:
+		if len(link) > 0 {
+			return linkHref(link[0])
+		}
+	}
+	return ""
+}
+
+// storeRemoteAttachments records the attachments of a remote note and
+// queues their files for caching
+func (s *Service) storeRemoteAttachments(ctx context.Context, remotePostID int, object map[string]interface{}) error {
+	var raw []interface{}
+	switch a := object["attachment"].(type) {
+	case []interface{}:
+		raw = a
+	case map[string]interface{}:
+		raw = []interface{}{a}
+	}
+
+	var attachments []*models.RemoteAttachment
+	for _, item := range raw {
+		if len(attachments) == maxRemoteAttachments {
+			break
+		}
+		document, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		href := linkHref(document["url"])
+		if href == "" || checkRemoteIRI(href) != nil {
+			continue
+		}
+
+		m, err := s.remoteMediaSvc.RegisterRemoteMedia(ctx, href)
+		if err != nil {
+			return err
+		}
+
+		attachment := &models.RemoteAttachment{RemoteMediaID: m.ID}
+		attachment.MediaType, _ = document["mediaType"].(string)
+		attachment.Description, _ = document["name"].(string)
+		attachment.Blurhash, _ = document["blurhash"].(string)
+		attachments = append(attachments, attachment)
+	}
+
+	return s.remoteMediaSvc.SetRemotePostAttachments(ctx, remotePostID, attachments)
+}
+
+// storeRemoteActor records the profile of a fetched actor, queueing its
+// avatar for caching
+func (s *Service) storeRemoteActor(ctx context.Context, actor *RemoteActor) {
+	profile := &models.RemoteActorProfile{
+		IRI:               actor.ID,
+		PreferredUsername: actor.PreferredUsername,
+		Name:              actor.Name,
+		URL:               actor.URL,
+	}
+
+	if icon := linkHref(actor.Icon); icon != "" {
+		m, err := s.remoteMediaSvc.RegisterRemoteMedia(ctx, icon)
+		if err != nil {
+			log.Printf("Failed to register avatar of %s: %v", actor.ID, err)
+		} else {
+			profile.AvatarMediaID = &m.ID
+		}
+	}
+
+	if err := s.remoteActorSvc.UpsertRemoteActor(ctx, profile); err != nil {
+		log.Printf("Failed to store remote actor %s: %v", actor.ID, err)
+	}
+}
+
+// refreshRemoteActor fetches a remote actor's profile in the background if
+// we don't have it or it is stale
+func (s *Service) refreshRemoteActor(ctx context.Context, iri string) {
+	profile, err := s.remoteActorSvc.GetRemoteActor(ctx, iri)
+	if err == nil && time.Since(profile.FetchedAt) < remoteActorRefreshInterval {
+		return
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("Failed to load remote actor %s: %v", iri, err)
+		return
+	}
+
+	go func() {
+		if _, err := s.FetchActor(context.Background(), iri); err != nil {
+			log.Printf("Failed to fetch remote actor %s: %v", iri, err)
+		}
+	}()
+}
+
+// remoteAuthor fills in the display name and cached avatar of a remote
+// post's author, if we know them
+func (s *Service) remoteAuthor(ctx context.Context, node *ThreadNode) {
+	profile, err := s.remoteActorSvc.GetRemoteActor(ctx, node.Author)
+	if err != nil {
+		return
+	}
+	node.AuthorName = profile.Name
+	if profile.AvatarMediaID != nil {
+		node.AuthorAvatar = s.RemoteMediaURL(*profile.AvatarMediaID)
+	}
+}
+
+// postAttachments returns the attachments of a local or remote post
+func (s *Service) postAttachments(ctx context.Context, kind models.ObjectKind, id int) ([]*Attachment, error) {
+	var attachments []*Attachment
+
+	switch kind {
+	case models.ObjectPost:
+		media, err := s.mediaSvc.ListPostMedia(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range media {
+			attachments = append(attachments, &Attachment{
+				URL:         m.URL,
+				PreviewURL:  m.PreviewURL,
+				MediaType:   m.MediaType,
+				Description: m.Description,
+				Blurhash:    m.Blurhash,
+			})
+		}
+	case models.ObjectRemotePost:
+		remote, err := s.remoteMediaSvc.ListRemotePostAttachments(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range remote {
+			attachments = append(attachments, &Attachment{
+				URL:         s.RemoteMediaURL(a.RemoteMediaID),
+				MediaType:   a.MediaType,
+				Description: a.Description,
+				Blurhash:    a.Blurhash,
+			})
+		}
+	}
+
+	return attachments, nil
+}
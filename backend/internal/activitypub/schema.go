@@ -0,0 +1,168 @@
+package activitypub
+
+import (
+	"context"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaJobPosting is a job posting as schema.org JSON-LD, the form search
+// engines and job aggregators index. It is built from the federated
+// JobPosting so both carry the same fields.
+type SchemaJobPosting struct {
+	Context     string            `json:"@context"`
+	Type        string            `json:"@type"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Identifier  *SchemaIdentifier `json:"identifier,omitempty"`
+	URL         string            `json:"url"`
+	DatePosted  time.Time         `json:"datePosted"`
+	// ValidThrough is when the posting expires, or when it closed for
+	// postings that are no longer open
+	ValidThrough                  *time.Time            `json:"validThrough,omitempty"`
+	EmploymentType                string                `json:"employmentType,omitempty"`
+	HiringOrganization            *SchemaOrganization   `json:"hiringOrganization"`
+	JobLocation                   *SchemaPlace          `json:"jobLocation,omitempty"`
+	JobLocationType               string                `json:"jobLocationType,omitempty"`
+	ApplicantLocationRequirements []*SchemaPlace        `json:"applicantLocationRequirements,omitempty"`
+	BaseSalary                    *SchemaMonetaryAmount `json:"baseSalary,omitempty"`
+	Qualifications                string                `json:"qualifications,omitempty"`
+	Skills                        []string              `json:"skills,omitempty"`
+	DirectApply                   bool                  `json:"directApply"`
+}
+
+// SchemaIdentifier is the ID of a posting at its hiring organization
+type SchemaIdentifier struct {
+	Type  string `json:"@type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SchemaOrganization is the organization hiring for a posting
+type SchemaOrganization struct {
+	Type   string `json:"@type"`
+	Name   string `json:"name"`
+	SameAs string `json:"sameAs,omitempty"`
+	Logo   string `json:"logo,omitempty"`
+}
+
+// SchemaPlace is where a job is done, or a region remote applicants must
+// live in
+type SchemaPlace struct {
+	Type    string         `json:"@type"`
+	Name    string         `json:"name,omitempty"`
+	Address *SchemaAddress `json:"address,omitempty"`
+}
+
+// SchemaAddress is the address of a job location. Locations are free text,
+// so all of it goes in addressLocality.
+type SchemaAddress struct {
+	Type            string `json:"@type"`
+	AddressLocality string `json:"addressLocality"`
+}
+
+// SchemaMonetaryAmount is the salary of a posting
+type SchemaMonetaryAmount struct {
+	Type     string                   `json:"@type"`
+	Currency string                   `json:"currency"`
+	Value    *SchemaQuantitativeValue `json:"value"`
+}
+
+// SchemaQuantitativeValue is a salary range over a pay period
+type SchemaQuantitativeValue struct {
+	Type     string `json:"@type"`
+	MinValue *int64 `json:"minValue,omitempty"`
+	MaxValue *int64 `json:"maxValue,omitempty"`
+	UnitText string `json:"unitText"`
+}
+
+// GetSchemaJobPosting returns the schema.org JobPosting of a local job,
+// linking to its page at pageURL. Drafts are not found.
+func (s *Service) GetSchemaJobPosting(ctx context.Context, id int, pageURL string) (*SchemaJobPosting, error) {
+	posting, err := s.GetJobPosting(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	hiring := &SchemaOrganization{Type: "Organization", Name: posting.HiringOrganization}
+	org, err := s.orgSvc.GetJobOrganization(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if org != nil {
+		hiring.Name = org.Name
+		hiring.SameAs = org.Website
+		hiring.Logo = org.LogoURL
+	}
+
+	schema := &SchemaJobPosting{
+		Context:     "https://schema.org",
+		Type:        "JobPosting",
+		Title:       posting.Name,
+		Description: textHTML(posting.Content),
+		Identifier: &SchemaIdentifier{
+			Type:  "PropertyValue",
+			Name:  hiring.Name,
+			Value: strconv.Itoa(id),
+		},
+		URL:                pageURL,
+		DatePosted:         posting.Published,
+		ValidThrough:       posting.ValidThrough,
+		EmploymentType:     posting.EmploymentType,
+		HiringOrganization: hiring,
+		JobLocationType:    posting.JobLocationType,
+		Qualifications:     posting.Qualifications,
+		DirectApply:        true,
+	}
+
+	// Fully remote jobs are located by where applicants may live rather
+	// than by an address
+	remote := posting.JobLocationType == "TELECOMMUTE"
+	if posting.JobLocation != "" && !(remote && strings.Contains(strings.ToLower(posting.JobLocation), "remote")) {
+		schema.JobLocation = &SchemaPlace{
+			Type:    "Place",
+			Address: &SchemaAddress{Type: "PostalAddress", AddressLocality: posting.JobLocation},
+		}
+	}
+	for _, place := range posting.ApplicantLocationRequirements {
+		schema.ApplicantLocationRequirements = append(schema.ApplicantLocationRequirements,
+			&SchemaPlace{Type: place.Type, Name: place.Name})
+	}
+
+	if salary := posting.BaseSalary; salary != nil {
+		schema.BaseSalary = &SchemaMonetaryAmount{
+			Type:     salary.Type,
+			Currency: salary.Currency,
+			Value: &SchemaQuantitativeValue{
+				Type:     salary.Value.Type,
+				MinValue: salary.Value.MinValue,
+				MaxValue: salary.Value.MaxValue,
+				UnitText: salary.Value.UnitText,
+			},
+		}
+	}
+
+	for _, tag := range posting.Tag {
+		if tag.Type == skillTagType {
+			schema.Skills = append(schema.Skills, tag.Name)
+		}
+	}
+
+	return schema, nil
+}
+
+// textHTML turns plain text into HTML paragraphs, keeping line breaks
+func textHTML(text string) string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		paragraphs = append(paragraphs,
+			"<p>"+strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>")+"</p>")
+	}
+	return strings.Join(paragraphs, "")
+}
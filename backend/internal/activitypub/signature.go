@@ -0,0 +1,280 @@
+package activitypub
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+const (
+	// actorKeyBits is the size of the RSA keys generated for local actors
+	actorKeyBits = 2048
+	// maxSignatureAge is how far the Date of a signed request may be from
+	// our clock
+	maxSignatureAge = time.Hour
+)
+
+var (
+	// ErrInvalidSignature is returned for inbox requests whose HTTP
+	// Signature is missing or doesn't verify
+	ErrInvalidSignature = errors.New("invalid HTTP signature")
+	// ErrActorMismatch is returned for activities whose actor is not the
+	// one that signed the request delivering them
+	ErrActorMismatch = errors.New("activity actor did not sign the request")
+)
+
+// signedHeaders are the parts of a delivery covered by its signature
+var signedHeaders = []string{"(request-target)", "host", "date", "digest"}
+
+// KeyIRI returns the IRI of an actor's public key
+func KeyIRI(actorIRI string) string {
+	return actorIRI + "#main-key"
+}
+
+// signer signs deliveries on behalf of a local actor
+type signer struct {
+	keyID string
+	key   *rsa.PrivateKey
+}
+
+// actorKey returns the key pair of a local actor, generating one the first
+// time the actor needs it
+func (s *Service) actorKey(ctx context.Context, actorIRI string) (*models.ActorKey, error) {
+	key, err := s.actorKeySvc.GetKey(ctx, actorIRI)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return key, err
+	}
+
+	private, err := rsa.GenerateKey(rand.Reader, actorKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.actorKeySvc.CreateKey(ctx, &models.ActorKey{
+		ActorIRI:      actorIRI,
+		PublicKeyPem:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+		PrivateKeyPem: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})),
+	})
+}
+
+// publicKey returns the publicKey property of a local actor
+func (s *Service) publicKey(ctx context.Context, actorIRI string) (PublicKey, error) {
+	key, err := s.actorKey(ctx, actorIRI)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	return PublicKey{
+		ID:           KeyIRI(actorIRI),
+		Owner:        actorIRI,
+		PublicKeyPem: key.PublicKeyPem,
+	}, nil
+}
+
+// signer returns the signer of a local actor's deliveries
+func (s *Service) signer(ctx context.Context, actorIRI string) (*signer, error) {
+	key, err := s.actorKey(ctx, actorIRI)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKeyPem))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key of %s", actorIRI)
+	}
+	private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &signer{keyID: KeyIRI(actorIRI), key: private}, nil
+}
+
+// sign adds a Digest of the body and an HTTP Signature (draft-cavage,
+// rsa-sha256) to a request, as most servers reject unsigned deliveries.
+// The request must already carry its Date header.
+func (sg *signer) sign(req *http.Request, body []byte) error {
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+
+	hashed := sha256.Sum256([]byte(signingString(req, signedHeaders)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sg.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		sg.keyID, strings.Join(signedHeaders, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// signingString builds the string an HTTP Signature covers
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, name := range headers {
+		switch name {
+		case "(request-target)":
+			lines = append(lines, fmt.Sprintf("(request-target): %s %s", strings.ToLower(req.Method), req.URL.RequestURI()))
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines = append(lines, "host: "+host)
+		default:
+			lines = append(lines, name+": "+strings.Join(req.Header.Values(name), ", "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// VerifyRequest checks the HTTP Signature of a request delivered to one of
+// our inboxes and returns the IRI of the actor that signed it. The signed
+// headers must cover the request target, host, date and body digest.
+func (s *Service) VerifyRequest(ctx context.Context, req *http.Request, body []byte) (string, error) {
+	return verifyRequest(req, body, func(keyID string) (*RemoteActor, *rsa.PublicKey, error) {
+		return s.fetchPublicKey(ctx, keyID)
+	})
+}
+
+// verifyRequest is VerifyRequest with the lookup of the signing key passed
+// in. The key is only fetched once the rest of the request checks out.
+func verifyRequest(req *http.Request, body []byte, fetchKey func(keyID string) (*RemoteActor, *rsa.PublicKey, error)) (string, error) {
+	params, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return "", err
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	for _, required := range signedHeaders {
+		if !containsString(headers, required) {
+			return "", fmt.Errorf("%w: %s is not signed", ErrInvalidSignature, required)
+		}
+	}
+
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid date", ErrInvalidSignature)
+	}
+	if age := time.Since(date); age > maxSignatureAge || age < -maxSignatureAge {
+		return "", fmt.Errorf("%w: date is too far from now", ErrInvalidSignature)
+	}
+
+	digest := sha256.Sum256(body)
+	if req.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]) {
+		return "", fmt.Errorf("%w: digest does not match body", ErrInvalidSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+
+	actor, key, err := fetchKey(params["keyId"])
+	if err != nil {
+		return "", err
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		return "", fmt.Errorf("%w: signature does not verify", ErrInvalidSignature)
+	}
+	return actor.ID, nil
+}
+
+// fetchPublicKey fetches the actor owning a key and returns it with the key
+func (s *Service) fetchPublicKey(ctx context.Context, keyID string) (*RemoteActor, *rsa.PublicKey, error) {
+	keyURL, err := url.Parse(keyID)
+	if err != nil || keyID == "" {
+		return nil, nil, fmt.Errorf("%w: invalid keyId", ErrInvalidSignature)
+	}
+	keyURL.Fragment = ""
+
+	actor, err := s.FetchActor(ctx, keyURL.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: fetching key: %v", ErrInvalidSignature, err)
+	}
+	if actor.PublicKey.ID != keyID || actor.PublicKey.Owner != actor.ID {
+		return nil, nil, fmt.Errorf("%w: %s is not the key of %s", ErrInvalidSignature, keyID, actor.ID)
+	}
+
+	key, err := parsePublicKey(actor.PublicKey.PublicKeyPem)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return actor, key, nil
+}
+
+// parsePublicKey parses an RSA public key in PKIX or PKCS #1 PEM form
+func parsePublicKey(raw string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("invalid public key")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
+
+// parseSignature parses the parameters of a Signature header. Only RSA keys
+// are supported, signed as rsa-sha256 or the equivalent hs2019.
+func parseSignature(header string) (map[string]string, error) {
+	if header == "" {
+		return nil, fmt.Errorf("%w: request is not signed", ErrInvalidSignature)
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+		}
+		params[name] = strings.Trim(value, `"`)
+	}
+
+	if params["keyId"] == "" || params["signature"] == "" {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	switch params["algorithm"] {
+	case "", "rsa-sha256", "hs2019":
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, params["algorithm"])
+	}
+	if params["headers"] == "" {
+		params["headers"] = "date"
+	}
+	return params, nil
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
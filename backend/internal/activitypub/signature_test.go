@@ -0,0 +1,244 @@
+package activitypub
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testActorIRI = "https://remote.example/users/bob"
+
+// signedRequest builds an inbox delivery dated date and signed by key over
+// headers
+func signedRequest(t *testing.T, key *rsa.PrivateKey, headers []string, date time.Time) (*http.Request, []byte) {
+	t.Helper()
+	body := []byte(`{"type":"Follow","actor":"` + testActorIRI + `"}`)
+	req := httptest.NewRequest("POST", "https://openfirm.example/users/alice/inbox", bytes.NewReader(body))
+	req.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		KeyIRI(testActorIRI), strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return req, body
+}
+
+func TestVerifyRequest(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		headers []string
+		date    time.Time
+		// tamper changes the delivery after it was signed
+		tamper  func(req *http.Request, body []byte) []byte
+		wantErr error
+	}{
+		{name: "valid signature", key: key, headers: signedHeaders, date: time.Now()},
+		{
+			name:    "tampered digest",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now(),
+			tamper: func(req *http.Request, body []byte) []byte {
+				digest := sha256.Sum256([]byte("{}"))
+				req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "tampered body",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now(),
+			tamper: func(req *http.Request, body []byte) []byte {
+				return []byte(`{"type":"Delete","actor":"` + testActorIRI + `"}`)
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "tampered body with a matching digest",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now(),
+			tamper: func(req *http.Request, body []byte) []byte {
+				body = []byte(`{"type":"Delete","actor":"` + testActorIRI + `"}`)
+				digest := sha256.Sum256(body)
+				req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "stale date",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now().Add(-2 * maxSignatureAge),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "date in the future",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now().Add(2 * maxSignatureAge),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "missing (request-target)",
+			key:     key,
+			headers: []string{"host", "date", "digest"},
+			date:    time.Now(),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "signed by another key",
+			key:     otherKey,
+			headers: signedHeaders,
+			date:    time.Now(),
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "unsigned",
+			key:     key,
+			headers: signedHeaders,
+			date:    time.Now(),
+			tamper: func(req *http.Request, body []byte) []byte {
+				req.Header.Del("Signature")
+				return body
+			},
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	fetchKey := func(keyID string) (*RemoteActor, *rsa.PublicKey, error) {
+		if keyID != KeyIRI(testActorIRI) {
+			return nil, nil, fmt.Errorf("%w: unknown key %s", ErrInvalidSignature, keyID)
+		}
+		return &RemoteActor{ID: testActorIRI}, &key.PublicKey, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, body := signedRequest(t, tt.key, tt.headers, tt.date)
+			if tt.tamper != nil {
+				body = tt.tamper(req, body)
+			}
+
+			actor, err := verifyRequest(req, body, fetchKey)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("verifyRequest() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyRequest() error = %v", err)
+			}
+			if actor != testActorIRI {
+				t.Errorf("verifyRequest() = %q, want %q", actor, testActorIRI)
+			}
+		})
+	}
+}
+
+func TestSignerSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"type":"Create"}`)
+	req := httptest.NewRequest("POST", "https://remote.example/inbox", bytes.NewReader(body))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	sg := &signer{keyID: KeyIRI("https://openfirm.example/users/alice"), key: key}
+	if err := sg.sign(req, body); err != nil {
+		t.Fatal(err)
+	}
+
+	actor, err := verifyRequest(req, body, func(keyID string) (*RemoteActor, *rsa.PublicKey, error) {
+		return &RemoteActor{ID: "https://openfirm.example/users/alice"}, &key.PublicKey, nil
+	})
+	if err != nil {
+		t.Fatalf("verifyRequest() error = %v", err)
+	}
+	if actor != "https://openfirm.example/users/alice" {
+		t.Errorf("verifyRequest() = %q", actor)
+	}
+}
+
+func TestParseSignature(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantHeaders string
+		wantErr     error
+	}{
+		{
+			name:        "rsa-sha256",
+			header:      `keyId="https://remote.example/users/bob#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest",signature="c2ln"`,
+			wantHeaders: "(request-target) host date digest",
+		},
+		{
+			name:        "hs2019",
+			header:      `keyId="https://remote.example/users/bob#main-key",algorithm="hs2019",headers="(request-target) host date digest",signature="c2ln"`,
+			wantHeaders: "(request-target) host date digest",
+		},
+		{
+			name:        "headers default to date",
+			header:      `keyId="https://remote.example/users/bob#main-key",signature="c2ln"`,
+			wantHeaders: "date",
+		},
+		{name: "empty", header: "", wantErr: ErrInvalidSignature},
+		{name: "missing keyId", header: `algorithm="rsa-sha256",signature="c2ln"`, wantErr: ErrInvalidSignature},
+		{name: "missing signature", header: `keyId="https://remote.example/users/bob#main-key"`, wantErr: ErrInvalidSignature},
+		{name: "malformed parameter", header: `keyId="https://remote.example/users/bob#main-key",signature`, wantErr: ErrInvalidSignature},
+		{
+			name:    "unsupported algorithm",
+			header:  `keyId="https://remote.example/users/bob#main-key",algorithm="ed25519",signature="c2ln"`,
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseSignature(tt.header)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("parseSignature() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSignature() error = %v", err)
+			}
+			if params["headers"] != tt.wantHeaders {
+				t.Errorf("headers = %q, want %q", params["headers"], tt.wantHeaders)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package activitypub
+
+import (
+	"fmt"
+	"net/url"
+
+	"openfirm/internal/models"
+)
+
+// skillTagType is the type of the tags skills are federated as, mapped to
+// schema:DefinedTerm in the contexts of actors and job postings
+const skillTagType = "Skill"
+
+// SkillIRI returns the IRI of a skill of the taxonomy
+func (s *Service) SkillIRI(slug string) string {
+	return fmt.Sprintf("https://%s/skills/%s", s.domain, url.PathEscape(slug))
+}
+
+// skillTags converts skills into their federated form
+func (s *Service) skillTags(skills []models.Skill) []*TagObject {
+	tags := make([]*TagObject, 0, len(skills))
+	for _, skill := range skills {
+		tags = append(tags, &TagObject{Type: skillTagType, Name: skill.Name, Href: s.SkillIRI(skill.Slug)})
+	}
+	return tags
+}
@@ -0,0 +1,101 @@
+package activitypub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"openfirm/internal/models"
+)
+
+// tagCollectionPageSize is the number of items per page of a tag collection
+const tagCollectionPageSize = 20
+
+// extractHashtags returns the hashtags found in plain text, without
+// rendering anything
+func (s *Service) extractHashtags(text string) []*models.Tag {
+	seen := make(map[string]bool)
+
+	var tags []*models.Tag
+	for _, loc := range contentTokenRe.FindAllStringIndex(text, -1) {
+		token := text[loc[0]:loc[1]]
+		if token[0] != '#' || !isTokenBoundary(text, loc[0]) {
+			continue
+		}
+
+		name := models.NormalizeHashtag(token)
+		if seen[name] || strings.IndexFunc(name, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, &models.Tag{Kind: models.TagHashtag, Name: name, Href: s.TagIRI(name)})
+	}
+	return tags
+}
+
+// TagJob records the hashtags used in a job posting's description and
+// requirements so it shows up on their timelines
+func (s *Service) TagJob(ctx context.Context, job *models.Job) error {
+	tags := s.extractHashtags(job.Description + "\n" + job.Requirements)
+	return s.tagSvc.SetTags(ctx, models.ObjectJob, job.ID, tags)
+}
+
+// timelineItemIRI returns the IRI of the object behind a timeline item
+func (s *Service) timelineItemIRI(item *models.TimelineItem) string {
+	switch item.Kind {
+	case models.ObjectPost:
+		return s.PostIRI(item.ID)
+	case models.ObjectJob:
+		return s.JobIRI(item.ID)
+	default:
+		return item.IRI
+	}
+}
+
+// GetTagCollection returns the OrderedCollection of objects carrying a
+// hashtag. Without a page it returns the collection itself, pointing at its
+// first page.
+func (s *Service) GetTagCollection(ctx context.Context, name string, page int) (map[string]interface{}, error) {
+	collectionIRI := s.TagIRI(name)
+
+	if page < 1 {
+		total, err := s.timelineSvc.CountHashtag(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         collectionIRI,
+			"type":       "OrderedCollection",
+			"totalItems": total,
+			"first":      fmt.Sprintf("%s?page=1", collectionIRI),
+		}, nil
+	}
+
+	items, err := s.timelineSvc.HashtagTimeline(ctx, name, (page-1)*tagCollectionPageSize, tagCollectionPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	iris := make([]string, 0, len(items))
+	for _, item := range items {
+		iris = append(iris, s.timelineItemIRI(item))
+	}
+
+	collectionPage := map[string]interface{}{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           fmt.Sprintf("%s?page=%d", collectionIRI, page),
+		"type":         "OrderedCollectionPage",
+		"partOf":       collectionIRI,
+		"orderedItems": iris,
+	}
+	if len(items) == tagCollectionPageSize {
+		collectionPage["next"] = fmt.Sprintf("%s?page=%d", collectionIRI, page+1)
+	}
+	if page > 1 {
+		collectionPage["prev"] = fmt.Sprintf("%s?page=%d", collectionIRI, page-1)
+	}
+	return collectionPage, nil
+}
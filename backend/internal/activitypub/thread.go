@@ -0,0 +1,332 @@
+package activitypub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+const (
+	// maxAncestorDepth bounds how far up a thread we walk, fetching missing
+	// remote posts on the way
+	maxAncestorDepth = 20
+	// maxDescendantDepth and maxDescendants bound the replies returned
+	// below a post
+	maxDescendantDepth = 20
+	maxDescendants     = 500
+	// repliesPageSize is the number of replies embedded in a replies collection
+	repliesPageSize = 50
+)
+
+// ThreadNode is a post or job posting within a thread. AuthorName and
+// AuthorAvatar are only set for remote authors whose profile we have.
+type ThreadNode struct {
+	IRI          string            `json:"iri"`
+	Kind         models.ObjectKind `json:"kind"`
+	ID           int               `json:"id"`
+	Author       string            `json:"author"`
+	AuthorName   string            `json:"author_name,omitempty"`
+	AuthorAvatar string            `json:"author_avatar,omitempty"`
+	Title        string            `json:"title,omitempty"`
+	Content      string            `json:"content"`
+	URL          string            `json:"url,omitempty"`
+	Attachments  []*Attachment     `json:"attachments,omitempty"`
+	InReplyTo    string            `json:"in_reply_to,omitempty"`
+	Context      string            `json:"context,omitempty"`
+	Published    time.Time         `json:"published"`
+	Replies      []*ThreadNode     `json:"replies,omitempty"`
+}
+
+// Thread is a post with the posts it replies to and the tree of replies
+// below it
+type Thread struct {
+	Ancestors   []*ThreadNode `json:"ancestors"`
+	Post        *ThreadNode   `json:"post"`
+	Descendants []*ThreadNode `json:"descendants"`
+}
+
+// RepliesIRI returns the IRI of the replies collection of a local object
+func (s *Service) RepliesIRI(objectIRI string) string {
+	return objectIRI + "/replies"
+}
+
+// ContextIRI returns the IRI of a conversation started by a local post
+func (s *Service) ContextIRI(rootPostID int) string {
+	return fmt.Sprintf("https://%s/contexts/%d", s.domain, rootPostID)
+}
+
+// localObject parses the IRI of a local post or job posting
+func (s *Service) localObject(iri string) (models.ObjectKind, int, bool) {
+	prefixes := map[models.ObjectKind]string{
+		models.ObjectPost: fmt.Sprintf("https://%s/posts/", s.domain),
+		models.ObjectJob:  fmt.Sprintf("https://%s/jobs/", s.domain),
+	}
+	for kind, prefix := range prefixes {
+		if strings.HasPrefix(iri, prefix) {
+			if id, err := strconv.Atoi(strings.TrimPrefix(iri, prefix)); err == nil {
+				return kind, id, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// postTypes are the object types stored as remote posts and placed in threads
+var postTypes = map[string]bool{"Note": true, "Article": true, "Question": true, "Page": true}
+
+// isPostType reports whether an object is stored as a remote post
+func isPostType(object map[string]interface{}) bool {
+	objectType, _ := object["type"].(string)
+	return postTypes[objectType]
+}
+
+// threadParent resolves the post a new local post replies to, returning the
+// conversation it joins and the actor to notify
+func (s *Service) threadParent(ctx context.Context, inReplyTo string) (conversation, actorIRI string, err error) {
+	if kind, id, ok := s.localObject(inReplyTo); ok && kind == models.ObjectJob {
+		// Discussions on a job posting form their own conversation
+		if _, err := s.jobSvc.GetJob(ctx, id); err != nil {
+			return "", "", err
+		}
+		return inReplyTo, "", nil
+	}
+
+	entry, err := s.resolveEntry(ctx, inReplyTo)
+	if err != nil {
+		return "", "", err
+	}
+
+	if entry.Kind == models.ObjectRemotePost {
+		post, err := s.remotePostSvc.GetRemotePostByIRI(ctx, entry.IRI)
+		if err != nil {
+			return "", "", err
+		}
+		actorIRI = post.ActorIRI
+	}
+	return entry.Context, actorIRI, nil
+}
+
+// resolveEntry returns the thread entry for a post, fetching and storing it
+// if it is a remote post we haven't seen yet
+func (s *Service) resolveEntry(ctx context.Context, iri string) (*models.ThreadEntry, error) {
+	entry, err := s.getEntry(ctx, iri)
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return entry, err
+	}
+
+	if _, _, ok := s.localObject(iri); ok {
+		return nil, err
+	}
+
+	object, err := s.FetchObject(ctx, iri)
+	if err != nil {
+		return nil, err
+	}
+	if !isPostType(object) || object["id"] != iri {
+		return nil, fmt.Errorf("%s is not a post", iri)
+	}
+	if _, err := s.storeRemoteNote(ctx, object); err != nil {
+		return nil, err
+	}
+
+	return s.threadSvc.GetEntry(ctx, iri)
+}
+
+// getEntry returns the stored thread entry for a post. Local posts written
+// before threads were tracked have none; their entry is recorded on first
+// use, starting a conversation of their own.
+func (s *Service) getEntry(ctx context.Context, iri string) (*models.ThreadEntry, error) {
+	entry, err := s.threadSvc.GetEntry(ctx, iri)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return entry, err
+	}
+	kind, id, ok := s.localObject(iri)
+	if !ok || kind != models.ObjectPost {
+		return nil, err
+	}
+	return s.backfillEntry(ctx, id)
+}
+
+// backfillEntry records the thread entry of a local post that has none
+func (s *Service) backfillEntry(ctx context.Context, postID int) (*models.ThreadEntry, error) {
+	post, err := s.postSvc.GetPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	entry := &models.ThreadEntry{
+		IRI:         s.PostIRI(post.ID),
+		Kind:        models.ObjectPost,
+		ObjectID:    post.ID,
+		Context:     s.ContextIRI(post.ID),
+		PublishedAt: post.CreatedAt,
+	}
+	if err := s.threadSvc.SaveEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// threadNode loads the post or job posting behind an IRI
+func (s *Service) threadNode(ctx context.Context, iri string) (*ThreadNode, error) {
+	if kind, id, ok := s.localObject(iri); ok && kind == models.ObjectJob {
+		job, err := s.jobSvc.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		poster, err := s.userSvc.GetUserByID(ctx, job.PostedBy)
+		if err != nil {
+			return nil, err
+		}
+		return &ThreadNode{
+			IRI:       iri,
+			Kind:      models.ObjectJob,
+			ID:        job.ID,
+			Author:    s.ActorIRI(poster.Username),
+			Title:     job.Title,
+			Content:   job.Description,
+			Context:   iri,
+			Published: job.CreatedAt,
+		}, nil
+	}
+
+	entry, err := s.getEntry(ctx, iri)
+	if err != nil {
+		return nil, err
+	}
+	return s.entryNode(ctx, entry)
+}
+
+// entryNode loads the post behind a thread entry
+func (s *Service) entryNode(ctx context.Context, entry *models.ThreadEntry) (*ThreadNode, error) {
+	node := &ThreadNode{
+		IRI:       entry.IRI,
+		Kind:      entry.Kind,
+		ID:        entry.ObjectID,
+		InReplyTo: entry.InReplyTo,
+		Context:   entry.Context,
+		Published: entry.PublishedAt,
+	}
+
+	switch entry.Kind {
+	case models.ObjectPost:
+		post, err := s.postSvc.GetPost(ctx, entry.ObjectID)
+		if err != nil {
+			return nil, err
+		}
+		author, err := s.userSvc.GetUserByID(ctx, post.UserID)
+		if err != nil {
+			return nil, err
+		}
+		node.Author = s.ActorIRI(author.Username)
+		node.Content = post.Content
+	case models.ObjectRemotePost:
+		post, err := s.remotePostSvc.GetRemotePostByIRI(ctx, entry.IRI)
+		if err != nil {
+			return nil, err
+		}
+		node.Author = post.ActorIRI
+		node.Content = post.Content
+		node.URL = post.URL
+		s.remoteAuthor(ctx, node)
+	}
+
+	attachments, err := s.postAttachments(ctx, entry.Kind, entry.ObjectID)
+	if err != nil {
+		return nil, err
+	}
+	node.Attachments = attachments
+	return node, nil
+}
+
+// GetThread returns a post or job posting with its ancestors and the tree of
+// its replies. Ancestors we haven't seen are fetched from their servers, up
+// to maxAncestorDepth levels.
+func (s *Service) GetThread(ctx context.Context, iri string) (*Thread, error) {
+	post, err := s.threadNode(ctx, iri)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := &Thread{Post: post, Ancestors: []*ThreadNode{}, Descendants: []*ThreadNode{}}
+
+	// Walk up the thread, stopping quietly at anything we can't resolve
+	seen := map[string]bool{iri: true}
+	parent := post.InReplyTo
+	for depth := 0; parent != "" && !seen[parent] && depth < maxAncestorDepth; depth++ {
+		seen[parent] = true
+
+		var node *ThreadNode
+		if kind, _, ok := s.localObject(parent); ok && kind == models.ObjectJob {
+			node, err = s.threadNode(ctx, parent)
+		} else {
+			var entry *models.ThreadEntry
+			if entry, err = s.resolveEntry(ctx, parent); err == nil {
+				node, err = s.entryNode(ctx, entry)
+			}
+		}
+		if err != nil {
+			break
+		}
+
+		thread.Ancestors = append([]*ThreadNode{node}, thread.Ancestors...)
+		parent = node.InReplyTo
+	}
+
+	// Build the tree of replies; parents always precede their replies
+	descendants, err := s.threadSvc.ListDescendants(ctx, iri, maxDescendantDepth, maxDescendants)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]*ThreadNode{iri: {}}
+	for _, d := range descendants {
+		node, err := s.entryNode(ctx, d.ThreadEntry)
+		if err != nil {
+			continue
+		}
+		parentNode, ok := nodes[d.InReplyTo]
+		if !ok {
+			continue
+		}
+		nodes[d.IRI] = node
+		if d.InReplyTo == iri {
+			thread.Descendants = append(thread.Descendants, node)
+		} else {
+			parentNode.Replies = append(parentNode.Replies, node)
+		}
+	}
+
+	return thread, nil
+}
+
+// GetReplies returns the replies collection of a local post or job posting
+func (s *Service) GetReplies(ctx context.Context, objectIRI string) (map[string]interface{}, error) {
+	total, err := s.threadSvc.CountReplies(ctx, objectIRI)
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := s.threadSvc.ListReplies(ctx, objectIRI, 0, repliesPageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]string, 0, len(replies))
+	for _, reply := range replies {
+		items = append(items, reply.IRI)
+	}
+
+	return map[string]interface{}{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           s.RepliesIRI(objectIRI),
+		"type":         "OrderedCollection",
+		"totalItems":   total,
+		"orderedItems": items,
+	}, nil
+}
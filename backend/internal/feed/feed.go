@@ -0,0 +1,296 @@
+// Package feed renders lists of jobs and posts as syndication feeds.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Feed is a syndication feed independent of its format
+type Feed struct {
+	Title       string
+	Description string
+	// Link is the HTML page the feed mirrors and Self the URL of the feed
+	// itself
+	Link string
+	Self string
+	// Updated defaults to the time of the newest item
+	Updated time.Time
+	Items   []*Item
+}
+
+// Item is an entry of a feed
+type Item struct {
+	// ID is a permanent, unique ID such as the IRI of the object
+	ID    string
+	Title string
+	Link  string
+	// Summary is plain text and Content HTML
+	Summary    string
+	Content    string
+	Author     string
+	Published  time.Time
+	Updated    time.Time
+	Categories []string
+}
+
+// updated returns when an item last changed
+func (i *Item) updated() time.Time {
+	if i.Updated.After(i.Published) {
+		return i.Updated
+	}
+	return i.Published
+}
+
+// LastModified returns when the feed last changed
+func (f *Feed) LastModified() time.Time {
+	updated := f.Updated
+	for _, item := range f.Items {
+		if t := item.updated(); t.After(updated) {
+			updated = t
+		}
+	}
+	return updated
+}
+
+const (
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	Description string   `xml:"description,omitempty"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// WriteRSS writes a feed as RSS 2.0
+func WriteRSS(w io.Writer, f *Feed) error {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		Self:        atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+		Items:       make([]rssItem, 0, len(f.Items)),
+	}
+	if updated := f.LastModified(); !updated.IsZero() {
+		channel.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, item := range f.Items {
+		description := item.Content
+		if description == "" {
+			description = item.Summary
+		}
+		i := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID, IsPermaLink: item.ID == item.Link},
+			Description: description,
+			Categories:  item.Categories,
+		}
+		if !item.Published.IsZero() {
+			i.PubDate = item.Published.UTC().Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, i)
+	}
+
+	return writeXML(w, &rss{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: channel})
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteAtom writes a feed as Atom 1.0
+func WriteAtom(w io.Writer, f *Feed) error {
+	// Atom requires an updated time, so an empty feed gets a fixed one
+	// that keeps its output stable
+	updated := f.LastModified()
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	feed := &atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.Self,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: make([]atomEntry, 0, len(f.Items)),
+	}
+
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:   item.Title,
+			ID:      item.ID,
+			Updated: item.updated().UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: item.Link, Rel: "alternate", Type: "text/html"}},
+		}
+		if !item.Published.IsZero() {
+			entry.Published = item.Published.UTC().Format(time.RFC3339)
+		}
+		if item.Author != "" {
+			entry.Author = &atomPerson{Name: item.Author}
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Content}
+		}
+		for _, category := range item.Categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return writeXML(w, feed)
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url,omitempty"`
+	FeedURL     string     `json:"feed_url,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished *time.Time   `json:"date_published,omitempty"`
+	DateModified  *time.Time   `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// WriteJSON writes a feed as JSON Feed 1.1
+func WriteJSON(w io.Writer, f *Feed) error {
+	feed := &jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.Self,
+		Description: f.Description,
+		Items:       make([]jsonItem, 0, len(f.Items)),
+	}
+
+	for _, item := range f.Items {
+		i := jsonItem{
+			ID:    item.ID,
+			URL:   item.Link,
+			Title: item.Title,
+			Tags:  item.Categories,
+		}
+		// Items need content, so summaries stand in when there is none
+		if item.Content != "" {
+			i.ContentHTML = item.Content
+			i.Summary = item.Summary
+		} else {
+			i.ContentText = item.Summary
+		}
+		if !item.Published.IsZero() {
+			published := item.Published.UTC()
+			i.DatePublished = &published
+		}
+		if item.Updated.After(item.Published) {
+			updated := item.Updated.UTC()
+			i.DateModified = &updated
+		}
+		if item.Author != "" {
+			i.Authors = []jsonAuthor{{Name: item.Author}}
+		}
+		feed.Items = append(feed.Items, i)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
+
+// writeXML writes v as an XML document
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
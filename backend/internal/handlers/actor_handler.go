@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -11,22 +12,24 @@ import (
 
 type ActorHandler struct {
 	activityPubService *activitypub.Service
+	frontendURL        string
 }
 
-func NewActorHandler(activityPubService *activitypub.Service) *ActorHandler {
+func NewActorHandler(activityPubService *activitypub.Service, frontendURL string) *ActorHandler {
 	return &ActorHandler{
 		activityPubService: activityPubService,
+		frontendURL:        frontendURL,
 	}
 }
 
-// Get returns the ActivityPub actor representation of a user
+// Get returns the ActivityPub actor representation of a user, or redirects
+// browsers to the user's public profile
 func (h *ActorHandler) Get(w http.ResponseWriter, r *http.Request) {
 	username := chi.URLParam(r, "username")
 
-	// Check if client accepts ActivityPub format
-	accept := r.Header.Get("Accept")
-	if !strings.Contains(accept, "application/activity+json") &&
-		!strings.Contains(accept, "application/ld+json") {
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
 		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
 		return
 	}
@@ -37,8 +40,77 @@ func (h *ActorHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Browsers following a shared actor URL land on the public profile
+	if mediaType == mediaTypeHTML {
+		http.Redirect(w, r, fmt.Sprintf("%s/profile/%s", h.frontendURL, actor.PreferredUsername), http.StatusSeeOther)
+		return
+	}
+
+	writeActivity(w, mediaType, actor)
+}
+
+// Instance returns the instance actor, which follows relays on behalf of
+// the server
+func (h *ActorHandler) Instance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	if mediaType == mediaTypeHTML {
+		http.Redirect(w, r, h.frontendURL, http.StatusSeeOther)
+		return
+	}
+
+	actor, err := h.activityPubService.GetInstanceActor(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to load actor", http.StatusInternalServerError)
+		return
+	}
+
+	writeActivity(w, mediaType, actor)
+}
+
+// Organization returns the ActivityPub actor of an organization, or
+// redirects browsers to its public page
+func (h *ActorHandler) Organization(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	actor, err := h.activityPubService.GetOrganizationActor(r.Context(), slug)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	if mediaType == mediaTypeHTML {
+		http.Redirect(w, r, fmt.Sprintf("%s/orgs/%s", h.frontendURL, actor.PreferredUsername), http.StatusSeeOther)
+		return
+	}
+
+	writeActivity(w, mediaType, actor)
+}
+
+// OrganizationFollowers returns the followers collection of an organization
+func (h *ActorHandler) OrganizationFollowers(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	followers, err := h.activityPubService.GetOrganizationFollowers(r.Context(), slug)
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/activity+json")
-	json.NewEncoder(w).Encode(actor)
+	json.NewEncoder(w).Encode(followers)
 }
 
 // Webfinger handles .well-known/webfinger requests
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/models"
+)
+
+// applicationsPerPage is the page size of an applicant's applications
+const applicationsPerPage = 20
+
+// ApplicationHandler serves applicants their own applications
+type ApplicationHandler struct {
+	applicationService *models.ApplicationService
+	pipelineService    *models.PipelineService
+}
+
+func NewApplicationHandler(applicationService *models.ApplicationService, pipelineService *models.PipelineService) *ApplicationHandler {
+	return &ApplicationHandler{
+		applicationService: applicationService,
+		pipelineService:    pipelineService,
+	}
+}
+
+// List returns a page of the authenticated user's applications with their
+// current stage, newest first
+func (h *ApplicationHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	page := pageParam(r)
+
+	applications, err := h.applicationService.ListUserApplications(r.Context(), userID,
+		(page-1)*applicationsPerPage, applicationsPerPage)
+	if err != nil {
+		http.Error(w, "Failed to fetch applications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(applications)
+}
+
+// Get returns one of the authenticated user's applications with the stages
+// it went through
+func (h *ApplicationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	applicationID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	application, err := h.applicationService.GetUserApplication(r.Context(), userID, applicationID)
+	switch {
+	case errors.Is(err, models.ErrApplicationNotFound):
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to fetch application", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(application)
+}
+
+// Withdraw withdraws one of the authenticated user's applications, which
+// lets them apply to the job again later
+func (h *ApplicationHandler) Withdraw(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	applicationID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return
+	}
+
+	_, err = h.pipelineService.Withdraw(r.Context(), userID, applicationID)
+	switch {
+	case errors.Is(err, models.ErrApplicationNotFound):
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrCannotWithdraw):
+		http.Error(w, "Application can no longer be withdrawn", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to withdraw application", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+// bookmarksPerPage is the page size of the bookmark list
+const bookmarksPerPage = 20
+
+type BookmarkHandler struct {
+	bookmarkService    *models.BookmarkService
+	activityPubService *activitypub.Service
+}
+
+func NewBookmarkHandler(bookmarkService *models.BookmarkService, activityPubService *activitypub.Service) *BookmarkHandler {
+	return &BookmarkHandler{
+		bookmarkService:    bookmarkService,
+		activityPubService: activityPubService,
+	}
+}
+
+// CreateBookmarkRequest names the object to bookmark by IRI, or a local job
+// posting or post by ID
+type CreateBookmarkRequest struct {
+	IRI    string `json:"iri"`
+	JobID  int    `json:"job_id"`
+	PostID int    `json:"post_id"`
+	UpdateBookmarkRequest
+}
+
+// UpdateBookmarkRequest is the part of a bookmark its user can change. A
+// null list_id files it under no list and a null remind_at clears the
+// reminder.
+type UpdateBookmarkRequest struct {
+	ListID   *int       `json:"list_id"`
+	Note     string     `json:"note"`
+	RemindAt *time.Time `json:"remind_at"`
+}
+
+// BookmarkListRequest is the body of bookmark list creates and renames
+type BookmarkListRequest struct {
+	Name string `json:"name"`
+}
+
+// writeBookmarkError responds to an error from BookmarkService
+func writeBookmarkError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, models.ErrBookmarkNotFound):
+		http.Error(w, "Bookmark not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrBookmarkListNotFound):
+		http.Error(w, "Bookmark list not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrAlreadyBookmarked):
+		http.Error(w, "Already bookmarked", http.StatusConflict)
+	case errors.Is(err, models.ErrTooManyBookmarks):
+		http.Error(w, fmt.Sprintf("You can keep at most %d bookmarks", models.MaxBookmarks), http.StatusConflict)
+	case errors.Is(err, models.ErrBookmarkListTaken):
+		http.Error(w, "You already have a list with this name", http.StatusConflict)
+	case errors.Is(err, models.ErrTooManyBookmarkLists):
+		http.Error(w, fmt.Sprintf("You can have at most %d bookmark lists", models.MaxBookmarkLists), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to "+action, http.StatusInternalServerError)
+	}
+}
+
+// Create bookmarks a job posting or post for the authenticated user
+func (h *BookmarkHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var req CreateBookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	iri := strings.TrimSpace(req.IRI)
+	switch {
+	case req.JobID > 0:
+		iri = h.activityPubService.JobIRI(req.JobID)
+	case req.PostID > 0:
+		iri = h.activityPubService.PostIRI(req.PostID)
+	}
+	if iri == "" {
+		http.Error(w, "An iri, job_id or post_id is required", http.StatusBadRequest)
+		return
+	}
+
+	bookmark, err := h.activityPubService.ResolveBookmark(r.Context(), iri)
+	if errors.Is(err, activitypub.ErrInsecureIRI) {
+		http.Error(w, "IRI must be an https URL", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, activitypub.ErrNotBookmarkable) {
+		http.Error(w, "Job posting or post not found", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to resolve bookmark of %s: %v", iri, err)
+		http.Error(w, "Failed to resolve object", http.StatusInternalServerError)
+		return
+	}
+
+	bookmark.UserID = userID
+	bookmark.ListID = req.ListID
+	bookmark.Note = req.Note
+	bookmark.RemindAt = req.RemindAt
+	bookmark.Normalize()
+	if err := bookmark.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.bookmarkService.CreateBookmark(r.Context(), bookmark); err != nil {
+		writeBookmarkError(w, err, "create bookmark")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// List returns a page of the authenticated user's bookmarks. Query
+// parameters:
+//
+//	list      list ID, or none for bookmarks filed under no list
+//	kind      job or post
+//	q         text to find in the title, summary, author or note
+//	reminder  set for bookmarks with a reminder, due for those due by now
+//	sort      created (default, newest first), reminder (soonest first) or title
+//	page      page number, 20 bookmarks per page
+func (h *BookmarkHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	query := r.URL.Query()
+	page := pageParam(r)
+
+	q := &models.BookmarkQuery{
+		UserID: userID,
+		Query:  strings.TrimSpace(query.Get("q")),
+		Sort:   models.BookmarkSortCreated,
+		Offset: (page - 1) * bookmarksPerPage,
+		Limit:  bookmarksPerPage,
+	}
+
+	switch list := query.Get("list"); list {
+	case "":
+	case "none":
+		q.Unfiled = true
+	default:
+		id, err := strconv.Atoi(list)
+		if err != nil || id < 1 {
+			http.Error(w, "Invalid list filter", http.StatusBadRequest)
+			return
+		}
+		q.ListID = id
+	}
+
+	if kind := query.Get("kind"); kind != "" {
+		q.Kind = models.BookmarkKind(kind)
+		if !q.Kind.Valid() {
+			http.Error(w, "Invalid kind filter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch query.Get("reminder") {
+	case "":
+	case "set":
+		q.HasReminder = true
+	case "due":
+		q.RemindBefore = time.Now()
+	default:
+		http.Error(w, "Invalid reminder filter", http.StatusBadRequest)
+		return
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		q.Sort = models.BookmarkSort(sort)
+		if !q.Sort.Valid() {
+			http.Error(w, "Invalid sort", http.StatusBadRequest)
+			return
+		}
+	}
+
+	bookmarks, total, err := h.bookmarkService.ListBookmarks(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Failed to fetch bookmarks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bookmarks": bookmarks,
+		"page":      page,
+		"total":     total,
+	})
+}
+
+// bookmarkParam returns the ID in the URL, writing an error response if it
+// is invalid
+func bookmarkParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s ID", name), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// Get returns one of the authenticated user's bookmarks
+func (h *BookmarkHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, ok := bookmarkParam(w, r, "bookmark")
+	if !ok {
+		return
+	}
+
+	bookmark, err := h.bookmarkService.GetBookmark(r.Context(), userID, id)
+	if err != nil {
+		writeBookmarkError(w, err, "fetch bookmark")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// Update changes the list, note and reminder of one of the authenticated
+// user's bookmarks
+func (h *BookmarkHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, ok := bookmarkParam(w, r, "bookmark")
+	if !ok {
+		return
+	}
+
+	var req UpdateBookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	bookmark, err := h.bookmarkService.GetBookmark(r.Context(), userID, id)
+	if err != nil {
+		writeBookmarkError(w, err, "fetch bookmark")
+		return
+	}
+
+	bookmark.ListID = req.ListID
+	bookmark.Note = req.Note
+	bookmark.RemindAt = req.RemindAt
+	bookmark.Normalize()
+	if err := bookmark.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.bookmarkService.UpdateBookmark(r.Context(), bookmark); err != nil {
+		writeBookmarkError(w, err, "update bookmark")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// Delete removes one of the authenticated user's bookmarks
+func (h *BookmarkHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, ok := bookmarkParam(w, r, "bookmark")
+	if !ok {
+		return
+	}
+
+	if err := h.bookmarkService.DeleteBookmark(r.Context(), userID, id); err != nil {
+		writeBookmarkError(w, err, "delete bookmark")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Lists returns the authenticated user's bookmark lists
+func (h *BookmarkHandler) Lists(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	lists, err := h.bookmarkService.ListBookmarkLists(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch bookmark lists", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+// decodeListName reads and validates a bookmark list name from the request
+// body, writing an error response if it is invalid
+func decodeListName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req BookmarkListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if err := models.ValidateBookmarkListName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
+
+// CreateList creates a bookmark list for the authenticated user
+func (h *BookmarkHandler) CreateList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	name, ok := decodeListName(w, r)
+	if !ok {
+		return
+	}
+
+	list := &models.BookmarkList{UserID: userID, Name: name}
+	if err := h.bookmarkService.CreateBookmarkList(r.Context(), list); err != nil {
+		writeBookmarkError(w, err, "create bookmark list")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(list)
+}
+
+// RenameList renames one of the authenticated user's bookmark lists
+func (h *BookmarkHandler) RenameList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, ok := bookmarkParam(w, r, "bookmark list")
+	if !ok {
+		return
+	}
+
+	name, ok := decodeListName(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.bookmarkService.RenameBookmarkList(r.Context(), userID, id, name); err != nil {
+		writeBookmarkError(w, err, "rename bookmark list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteList deletes one of the authenticated user's bookmark lists. Its
+// bookmarks are kept, filed under no list.
+func (h *BookmarkHandler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, ok := bookmarkParam(w, r, "bookmark list")
+	if !ok {
+		return
+	}
+
+	if err := h.bookmarkService.DeleteBookmarkList(r.Context(), userID, id); err != nil {
+		writeBookmarkError(w, err, "delete bookmark list")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
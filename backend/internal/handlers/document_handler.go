@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/media"
+	"openfirm/internal/models"
+)
+
+// documentLinkTTL is how long download links to documents stay valid
+const documentLinkTTL = 15 * time.Minute
+
+type DocumentHandler struct {
+	documentService *models.DocumentService
+	storage         media.Storage
+	scanner         media.Scanner
+	signer          *media.URLSigner
+}
+
+// NewDocumentHandler creates a DocumentHandler. storage must be private:
+// documents are only served through Download.
+func NewDocumentHandler(documentService *models.DocumentService, storage media.Storage, scanner media.Scanner, signer *media.URLSigner) *DocumentHandler {
+	return &DocumentHandler{
+		documentService: documentService,
+		storage:         storage,
+		scanner:         scanner,
+		signer:          signer,
+	}
+}
+
+// DocumentLink is a document with a link to download it until ExpiresAt
+type DocumentLink struct {
+	*models.Document
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// documentPath is the path documents are downloaded from
+func documentPath(id int) string {
+	return fmt.Sprintf("/documents/%d", id)
+}
+
+// newDocumentLink returns a time-limited download link to a document, or
+// nil for no document
+func newDocumentLink(signer *media.URLSigner, d *models.Document) *DocumentLink {
+	if d == nil {
+		return nil
+	}
+	url, expires := signer.SignedURL(documentPath(d.ID), documentLinkTTL)
+	return &DocumentLink{Document: d, URL: url, ExpiresAt: expires}
+}
+
+// Upload accepts a PDF, PNG or JPEG as the "file" field of a multipart form
+// and stores it privately for the authenticated user to attach to
+// applications. Files are virus scanned before they are stored.
+func (h *DocumentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxDocumentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, contentType, err := media.SniffDocument(file)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, media.ErrUnsupportedType):
+		http.Error(w, "Documents must be PDF, PNG or JPEG", http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(w, "Failed to read file", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	err = h.scanner.Scan(ctx, data)
+	switch {
+	case errors.Is(err, media.ErrInfected):
+		log.Printf("Rejected upload from user %d: %v", userID, err)
+		http.Error(w, "File failed virus scan", http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		log.Printf("Failed to scan upload from user %d: %v", userID, err)
+		http.Error(w, "Failed to scan file", http.StatusServiceUnavailable)
+		return
+	}
+
+	name, err := newMediaKey()
+	if err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	document := &models.Document{
+		UserID:     userID,
+		StorageKey: fmt.Sprintf("documents/%d/%s%s", userID, name, media.DocumentExtension(contentType)),
+		Filename:   models.CleanFilename(header.Filename),
+		MediaType:  contentType,
+		Size:       int64(len(data)),
+	}
+
+	if err := h.storage.Put(ctx, document.StorageKey, bytes.NewReader(data), document.Size, document.MediaType); err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := h.documentService.CreateDocument(ctx, document); err != nil {
+		if err := h.storage.Delete(ctx, document.StorageKey); err != nil {
+			log.Printf("Failed to delete document file %s: %v", document.StorageKey, err)
+		}
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(document)
+}
+
+// Download serves a document to the holder of a signed link to it
+func (h *DocumentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid document ID", http.StatusBadRequest)
+		return
+	}
+
+	if !h.signer.Verify(documentPath(id), r.URL.Query()) {
+		http.Error(w, "Link is invalid or has expired", http.StatusForbidden)
+		return
+	}
+
+	document, err := h.documentService.GetDocument(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := h.storage.Get(r.Context(), document.StorageKey)
+	if errors.Is(err, media.ErrNotFound) {
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read document", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", document.MediaType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": document.Filename}))
+	w.Header().Set("Content-Length", strconv.FormatInt(document.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, f)
+}
+
+// ProfileResume returns the resume on the authenticated user's profile
+// with a link to download it
+func (h *DocumentHandler) ProfileResume(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	document, err := h.documentService.GetProfileResume(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch resume", http.StatusInternalServerError)
+		return
+	}
+	if document == nil {
+		http.Error(w, "No resume on profile", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDocumentLink(h.signer, document))
+}
+
+// SetProfileResume stores one of the authenticated user's uploaded PDFs as
+// the resume on their profile
+func (h *DocumentHandler) SetProfileResume(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var req struct {
+		DocumentID int `json:"document_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	document, err := h.documentService.GetUserDocument(r.Context(), userID, req.DocumentID)
+	switch {
+	case errors.Is(err, models.ErrDocumentNotFound):
+		http.Error(w, "Document not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to fetch document", http.StatusInternalServerError)
+		return
+	}
+	if document.MediaType != media.ResumeType {
+		http.Error(w, "Resumes must be PDF", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := h.documentService.SetProfileResume(r.Context(), userID, document.ID); err != nil {
+		http.Error(w, "Failed to save resume", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newDocumentLink(h.signer, document))
+}
+
+// DeleteProfileResume removes the resume from the authenticated user's
+// profile
+func (h *DocumentHandler) DeleteProfileResume(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	if err := h.documentService.DeleteProfileResume(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to delete resume", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
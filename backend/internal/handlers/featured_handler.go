@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+type FeaturedHandler struct {
+	activityPubService *activitypub.Service
+}
+
+func NewFeaturedHandler(activityPubService *activitypub.Service) *FeaturedHandler {
+	return &FeaturedHandler{
+		activityPubService: activityPubService,
+	}
+}
+
+type FeatureRequest struct {
+	Kind     models.FeaturedKind `json:"kind"`
+	ObjectID int                 `json:"object_id"`
+}
+
+// Pin features a post or job posting on the authenticated user's profile
+func (h *FeaturedHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var req FeatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !req.Kind.Valid() {
+		http.Error(w, "Invalid kind", http.StatusBadRequest)
+		return
+	}
+
+	err := h.activityPubService.Feature(r.Context(), userID, req.Kind, req.ObjectID)
+	switch {
+	case errors.Is(err, activitypub.ErrNotOwner):
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	case errors.Is(err, activitypub.ErrNotListed):
+		http.Error(w, "Job posting is not listed", http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrFeaturedLimit):
+		http.Error(w, "You can feature at most "+strconv.Itoa(models.MaxFeaturedItems)+" items", http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrAlreadyFeatured):
+		http.Error(w, "Already featured", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to feature item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unpin removes a post or job posting from the authenticated user's profile
+func (h *FeaturedHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	kind := models.FeaturedKind(chi.URLParam(r, "kind"))
+	if !kind.Valid() {
+		http.Error(w, "Invalid kind", http.StatusBadRequest)
+		return
+	}
+
+	objectID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid object ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.activityPubService.Unfeature(r.Context(), userID, kind, objectID)
+	switch {
+	case errors.Is(err, models.ErrNotFeatured):
+		http.Error(w, "Not featured", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to unfeature item", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
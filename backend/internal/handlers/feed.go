@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"openfirm/internal/feed"
+	"openfirm/internal/models"
+)
+
+// writeFeed responds with a feed in the format named by the format
+// parameter: rss, the default, atom or json. The response carries an ETag
+// and Last-Modified so that feed readers polling it get 304 Not Modified
+// until it changes.
+func writeFeed(w http.ResponseWriter, r *http.Request, f *feed.Feed) {
+	var contentType string
+	var write func(w io.Writer, f *feed.Feed) error
+	switch r.URL.Query().Get("format") {
+	case "", "rss":
+		contentType, write = feed.RSSContentType, feed.WriteRSS
+	case "atom":
+		contentType, write = feed.AtomContentType, feed.WriteAtom
+	case "json":
+		contentType, write = feed.JSONContentType, feed.WriteJSON
+	default:
+		http.Error(w, "Format must be rss, atom or json", http.StatusBadRequest)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := write(&body, f); err != nil {
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	lastModified := f.LastModified()
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body.Bytes())
+}
+
+// notModified reports whether a conditional GET can be answered with 304
+// Not Modified. If-None-Match takes precedence over If-Modified-Since, as
+// RFC 9110 requires.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// jobItem returns the feed item of a job, linking to its page on the
+// frontend
+func jobItem(job *models.JobWithDetails, frontendURL string) *feed.Item {
+	link := fmt.Sprintf("%s/jobs/%d", frontendURL, job.ID)
+
+	title := job.Title
+	if job.Company != "" {
+		title = fmt.Sprintf("%s at %s", job.Title, job.Company)
+	}
+
+	var facts []string
+	for _, fact := range []string{job.Location, job.Type, job.SalaryRange} {
+		if fact != "" {
+			facts = append(facts, fact)
+		}
+	}
+	summary := strings.Join(facts, " · ")
+	if job.Description != "" {
+		summary = strings.TrimSpace(summary + "\n\n" + job.Description)
+	}
+
+	item := &feed.Item{
+		ID:         link,
+		Title:      title,
+		Link:       link,
+		Summary:    summary,
+		Author:     job.Company,
+		Published:  job.CreatedAt,
+		Categories: job.Skills,
+	}
+	if job.StateChangedAt != nil {
+		item.Updated = *job.StateChangedAt
+	}
+	return item
+}
+
+// excerpt cuts text to at most n runes at a word boundary, ending it with
+// an ellipsis if it was cut
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[:n-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/feed"
+	"openfirm/internal/models"
+)
+
+const (
+	// jobFeedSize is how many of the newest jobs a job feed lists
+	jobFeedSize = 50
+	// postFeedSize is how many of the newest posts a user's feed lists,
+	// the first page of their outbox
+	postFeedSize = 20
+	// postTitleLen is how much of a post's text makes up its feed title
+	postTitleLen = 80
+)
+
+// FeedHandler serves RSS, Atom and JSON feeds of the job board, users'
+// posts and organizations' openings for feed readers
+type FeedHandler struct {
+	jobSearchService   *models.JobSearchService
+	jobService         *models.JobService
+	jobDetailsService  *models.JobDetailsService
+	orgService         *models.OrganizationService
+	userService        *models.UserService
+	postService        *models.PostService
+	tagService         *models.TagService
+	activityPubService *activitypub.Service
+	baseURL            string
+	frontendURL        string
+}
+
+// NewFeedHandler creates a FeedHandler. Feeds link to their own URL under
+// baseURL and to pages under frontendURL.
+func NewFeedHandler(jobSearchService *models.JobSearchService, jobService *models.JobService, jobDetailsService *models.JobDetailsService, orgService *models.OrganizationService, userService *models.UserService, postService *models.PostService, tagService *models.TagService, activityPubService *activitypub.Service, baseURL, frontendURL string) *FeedHandler {
+	return &FeedHandler{
+		jobSearchService:   jobSearchService,
+		jobService:         jobService,
+		jobDetailsService:  jobDetailsService,
+		orgService:         orgService,
+		userService:        userService,
+		postService:        postService,
+		tagService:         tagService,
+		activityPubService: activityPubService,
+		baseURL:            baseURL,
+		frontendURL:        frontendURL,
+	}
+}
+
+// Jobs returns the newest jobs on the job board as a feed. It takes the
+// same filters as JobHandler.List, but always lists the newest jobs first.
+func (h *FeedHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	search, ok := parseJobSearch(w, r)
+	if !ok {
+		return
+	}
+	search.Sort = models.JobSortRecent
+	search.Limit = jobFeedSize
+
+	result, err := h.jobSearchService.SearchJobs(r.Context(), search)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	// The job board page takes the same filters as the feed
+	link := h.frontendURL + "/jobs"
+	query := r.URL.Query()
+	query.Del("format")
+	if encoded := query.Encode(); encoded != "" {
+		link += "?" + encoded
+	}
+
+	title := "Jobs"
+	if search.Query != "" {
+		title = fmt.Sprintf("Jobs matching %q", search.Query)
+	}
+
+	f := &feed.Feed{
+		Title:       title,
+		Description: "The newest jobs on the job board",
+		Link:        link,
+		Self:        h.baseURL + r.URL.RequestURI(),
+		Items:       make([]*feed.Item, 0, len(result.Jobs)),
+	}
+	for _, job := range result.Jobs {
+		f.Items = append(f.Items, jobItem(job, h.frontendURL))
+	}
+	writeFeed(w, r, f)
+}
+
+// UserPosts returns a user's newest posts as a feed, the same posts as the
+// first page of their outbox
+func (h *FeedHandler) UserPosts(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userService.GetUserByUsername(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	posts, err := h.postService.ListUserPosts(r.Context(), user.ID, 0, postFeedSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
+		return
+	}
+
+	author := user.DisplayName
+	if author == "" {
+		author = user.Username
+	}
+
+	f := &feed.Feed{
+		Title:       fmt.Sprintf("Posts by %s", author),
+		Description: user.Bio,
+		Link:        fmt.Sprintf("%s/users/%s", h.frontendURL, user.Username),
+		Self:        h.baseURL + r.URL.RequestURI(),
+		Items:       make([]*feed.Item, 0, len(posts)),
+	}
+	for _, post := range posts {
+		tags, err := h.tagService.ListTags(r.Context(), models.ObjectPost, post.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch posts", http.StatusInternalServerError)
+			return
+		}
+		var hashtags []string
+		for _, tag := range tags {
+			if tag.Kind == models.TagHashtag {
+				hashtags = append(hashtags, tag.Name)
+			}
+		}
+
+		// Post content is HTML rendered when the post was created
+		f.Items = append(f.Items, &feed.Item{
+			ID:         h.activityPubService.PostIRI(post.ID),
+			Title:      excerpt(activitypub.PlainText(post.Content), postTitleLen),
+			Link:       fmt.Sprintf("%s/posts/%d", h.frontendURL, post.ID),
+			Content:    post.Content,
+			Author:     author,
+			Published:  post.CreatedAt,
+			Categories: hashtags,
+		})
+	}
+	writeFeed(w, r, f)
+}
+
+// Organization returns an organization's newest openings as a feed
+func (h *FeedHandler) Organization(w http.ResponseWriter, r *http.Request) {
+	org, err := h.orgService.GetOrganizationBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	ids, err := h.orgService.ListJobIDs(r.Context(), org.ID, 0, jobFeedSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	jobs, err := h.jobService.GetJobs(r.Context(), ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	withDetails, err := h.jobDetailsService.WithDetails(r.Context(), jobs...)
+	if err != nil {
+		http.Error(w, "Failed to fetch job details", http.StatusInternalServerError)
+		return
+	}
+
+	f := &feed.Feed{
+		Title:       fmt.Sprintf("Jobs at %s", org.Name),
+		Description: org.Description,
+		Link:        fmt.Sprintf("%s/orgs/%s", h.frontendURL, org.Slug),
+		Self:        h.baseURL + r.URL.RequestURI(),
+		Items:       make([]*feed.Item, 0, len(withDetails)),
+	}
+	for _, job := range withDetails {
+		f.Items = append(f.Items, jobItem(job, h.frontendURL))
+	}
+	writeFeed(w, r, f)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -53,8 +54,6 @@ func (h *InboxHandler) Get(w http.ResponseWriter, r *http.Request) {
 
 // Post handles incoming ActivityPub activities
 func (h *InboxHandler) Post(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
-
 	// Verify Content-Type header
 	contentType := r.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/activity+json") &&
@@ -70,14 +69,20 @@ func (h *InboxHandler) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify signature (if implemented)
-	if err := h.verifyHttpSignature(r); err != nil {
+	// Verify signature
+	signer, err := h.verifyHttpSignature(r, body)
+	if err != nil {
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
 	// Process the activity
-	if err := h.activityPubService.HandleInbox(r.Context(), username, body); err != nil {
+	err = h.activityPubService.HandleInbox(r.Context(), signer, body)
+	switch {
+	case errors.Is(err, activitypub.ErrActorMismatch):
+		http.Error(w, "Activity not signed by its actor", http.StatusUnauthorized)
+		return
+	case err != nil:
 		http.Error(w, "Failed to process activity", http.StatusInternalServerError)
 		return
 	}
@@ -85,14 +90,10 @@ func (h *InboxHandler) Post(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// verifyHttpSignature verifies the HTTP Signature of the request
-func (h *InboxHandler) verifyHttpSignature(r *http.Request) error {
-	// TODO: Implement HTTP Signature verification
-	// This should:
-	// 1. Parse the Signature header
-	// 2. Fetch the public key of the sender
-	// 3. Verify the signature against the request
-	return nil
+// verifyHttpSignature verifies the HTTP Signature of the request, returning
+// the IRI of the actor that signed it
+func (h *InboxHandler) verifyHttpSignature(r *http.Request, body []byte) (string, error) {
+	return h.activityPubService.VerifyRequest(r.Context(), r, body)
 }
 
 // validateActivity validates an incoming activity
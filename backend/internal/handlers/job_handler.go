@@ -1,21 +1,54 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/media"
 	"openfirm/internal/models"
 )
 
 type JobHandler struct {
-	jobService *models.JobService
+	jobService          *models.JobService
+	jobDetailsService   *models.JobDetailsService
+	jobLifecycleService *models.JobLifecycleService
+	skillService        *models.SkillService
+	jobSearchService    *models.JobSearchService
+	pipelineService     *models.PipelineService
+	applicationService  *models.ApplicationService
+	formService         *models.ApplicationFormService
+	documentService     *models.DocumentService
+	notificationService *models.NotificationService
+	orgService          *models.OrganizationService
+	importService       *models.JobImportService
+	activityPubService  *activitypub.Service
 }
 
-func NewJobHandler(jobService *models.JobService) *JobHandler {
+func NewJobHandler(jobService *models.JobService, jobDetailsService *models.JobDetailsService, jobLifecycleService *models.JobLifecycleService, skillService *models.SkillService, jobSearchService *models.JobSearchService, pipelineService *models.PipelineService, applicationService *models.ApplicationService, formService *models.ApplicationFormService, documentService *models.DocumentService, notificationService *models.NotificationService, orgService *models.OrganizationService, importService *models.JobImportService, activityPubService *activitypub.Service) *JobHandler {
 	return &JobHandler{
-		jobService: jobService,
+		jobService:          jobService,
+		jobDetailsService:   jobDetailsService,
+		jobLifecycleService: jobLifecycleService,
+		skillService:        skillService,
+		jobSearchService:    jobSearchService,
+		pipelineService:     pipelineService,
+		applicationService:  applicationService,
+		formService:         formService,
+		documentService:     documentService,
+		notificationService: notificationService,
+		orgService:          orgService,
+		importService:       importService,
+		activityPubService:  activityPubService,
 	}
 }
 
@@ -27,11 +60,202 @@ type CreateJobRequest struct {
 	Requirements string `json:"requirements"`
 	SalaryRange  string `json:"salary_range"`
 	ContactEmail string `json:"contact_email"`
-	ExpiresAt    string `json:"expires_at"`
+	// ExpiresAt is an RFC 3339 time or a date, which expires at the end of
+	// that day in UTC. Empty means the job never expires.
+	ExpiresAt string `json:"expires_at"`
+	// State is draft or published, on creation only. Defaults to published.
+	State models.JobState `json:"state"`
+	// OrganizationID posts the job for an organization the user is a
+	// recruiter or owner of, on creation only. Company is then the
+	// organization's name.
+	OrganizationID *int `json:"organization_id,omitempty"`
+	// Skills are required, NiceToHaveSkills are a plus. Either can be
+	// given by alias, e.g. golang for Go.
+	Skills           []string `json:"skills"`
+	NiceToHaveSkills []string `json:"nice_to_have_skills"`
+	// Structured fields. When a salary is given, salary_range is generated
+	// from it.
+	models.JobDetails
+}
+
+// details returns the validated structured fields of a request
+func (req *CreateJobRequest) details() (*models.JobDetails, error) {
+	details := req.JobDetails
+	details.Normalize()
+	if err := details.Validate(); err != nil {
+		return nil, err
+	}
+
+	if len(req.Skills)+len(req.NiceToHaveSkills) > models.MaxJobSkills {
+		return nil, fmt.Errorf("at most %d skills are allowed", models.MaxJobSkills)
+	}
+	for _, skill := range append(req.Skills, req.NiceToHaveSkills...) {
+		if !models.ValidSkillName(skill) {
+			return nil, fmt.Errorf("skills must be 1 to %d characters long", models.MaxSkillLength)
+		}
+	}
+	return &details, nil
+}
+
+// salaryRange returns the display string of a job's salary
+func (req *CreateJobRequest) salaryRange(details *models.JobDetails) string {
+	if text := details.SalaryText(); text != "" {
+		return text
+	}
+	return req.SalaryRange
+}
+
+// expiresAt parses the expiry of a request
+func (req *CreateJobRequest) expiresAt() (*time.Time, error) {
+	if req.ExpiresAt == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, req.ExpiresAt); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", req.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	t = t.Add(24 * time.Hour)
+	return &t, nil
+}
+
+// writeJob responds with a job and its structured fields
+func (h *JobHandler) writeJob(w http.ResponseWriter, r *http.Request, job *models.Job) {
+	jobs, err := h.jobDetailsService.WithDetails(r.Context(), job)
+	if err != nil {
+		http.Error(w, "Failed to fetch job details", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobs[0])
+}
+
+// checkPostingLimit writes an error and returns false if a user posting a
+// job for org, nil for none, has posted as many jobs as they can without a
+// verified organization
+func (h *JobHandler) checkPostingLimit(w http.ResponseWriter, r *http.Request, userID int, org *models.Organization) bool {
+	if org != nil && org.Verified {
+		return true
+	}
+
+	count, err := h.orgService.CountUnverifiedJobs(r.Context(), userID, time.Now().Add(-models.UnverifiedJobWindow))
+	if err != nil {
+		http.Error(w, "Failed to check posting limit", http.StatusInternalServerError)
+		return false
+	}
+	if count >= models.UnverifiedJobLimit {
+		w.Header().Set("Retry-After", strconv.Itoa(int(models.UnverifiedJobWindow.Seconds())))
+		http.Error(w, "Too many jobs posted without a verified organization", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// jobRole returns the authenticated user's role for a job, writing an
+// error if it can't be looked up
+func (h *JobHandler) jobRole(w http.ResponseWriter, r *http.Request, job *models.Job, userID int) (models.OrgRole, bool) {
+	role, err := h.orgService.JobRole(r.Context(), job, userID)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return "", false
+	}
+	return role, true
+}
+
+// saveNewJob stores a new job with its organization, nil for none, its
+// structured fields, state, skills and the external reference of an
+// imported job, "" for none, in one transaction, then tags it and
+// federates it if it is published
+func (h *JobHandler) saveNewJob(ctx context.Context, job *models.Job, org *models.Organization, req *CreateJobRequest, details *models.JobDetails, state models.JobState, expiresAt *time.Time, externalRef string) error {
+	skills, err := h.skillService.ResolveJobSkills(ctx, req.Skills, req.NiceToHaveSkills)
+	if err != nil {
+		return fmt.Errorf("resolve job skills: %w", err)
+	}
+
+	parts := &models.NewJobParts{
+		Details:     details,
+		State:       state,
+		ExpiresAt:   expiresAt,
+		Skills:      skills,
+		ExternalRef: externalRef,
+	}
+	if org != nil {
+		parts.OrganizationID = &org.ID
+	}
+	if err := h.jobService.CreateJobWithParts(ctx, job, parts); err != nil {
+		return fmt.Errorf("create job: %w", err)
+	}
+
+	if err := h.activityPubService.TagJob(ctx, job); err != nil {
+		log.Printf("Failed to tag job %d: %v", job.ID, err)
+	}
+	if state == models.JobPublished {
+		if err := h.activityPubService.PublishJob(ctx, job); err != nil {
+			log.Printf("Failed to publish job %d: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// validExpiryUpdate reports whether a job can be given a new expiry: a
+// changed expiry must be in the future, while an unchanged one may already
+// have passed for closed jobs
+func validExpiryUpdate(lifecycle *models.JobLifecycle, expiresAt *time.Time) bool {
+	return !expiryChanged(lifecycle, expiresAt) || expiresAt == nil || expiresAt.After(time.Now())
+}
+
+// expiryChanged reports whether expiresAt differs from a job's expiry
+func expiryChanged(lifecycle *models.JobLifecycle, expiresAt *time.Time) bool {
+	if expiresAt == nil || lifecycle.ExpiresAt == nil {
+		return expiresAt != lifecycle.ExpiresAt
+	}
+	return !expiresAt.Equal(*lifecycle.ExpiresAt)
+}
+
+// saveJobUpdate saves changes to a job from a request along with its
+// structured fields, expiry and skills, then tags it and federates the
+// update
+func (h *JobHandler) saveJobUpdate(ctx context.Context, job *models.Job, req *CreateJobRequest, details *models.JobDetails, lifecycle *models.JobLifecycle, expiresAt *time.Time) error {
+	job.Title = req.Title
+	job.Company = req.Company
+	job.Location = req.Location
+	job.Description = req.Description
+	job.Requirements = req.Requirements
+	job.SalaryRange = req.salaryRange(details)
+	job.ContactEmail = req.ContactEmail
+
+	skills, err := h.skillService.ResolveJobSkills(ctx, req.Skills, req.NiceToHaveSkills)
+	if err != nil {
+		return fmt.Errorf("resolve job skills: %w", err)
+	}
+	if err := h.jobService.UpdateJobWithParts(ctx, job, &models.JobUpdateParts{
+		Details:   details,
+		SetExpiry: expiryChanged(lifecycle, expiresAt),
+		ExpiresAt: expiresAt,
+		Skills:    skills,
+	}); err != nil {
+		return fmt.Errorf("update job: %w", err)
+	}
+
+	if err := h.activityPubService.TagJob(ctx, job); err != nil {
+		log.Printf("Failed to tag job %d: %v", job.ID, err)
+	}
+	if err := h.activityPubService.PublishJobUpdate(ctx, job); err != nil {
+		log.Printf("Failed to federate update of job %d: %v", job.ID, err)
+	}
+	return nil
 }
 
 type JobApplicationRequest struct {
 	CoverLetter string `json:"cover_letter"`
+	// ResumeDocumentID is an uploaded PDF to send as the resume. Set
+	// UseProfileResume instead to send the resume stored on the profile.
+	ResumeDocumentID *int                  `json:"resume_document_id"`
+	UseProfileResume bool                  `json:"use_profile_resume"`
+	Answers          []*models.AnswerInput `json:"answers"`
 }
 
 // Create handles job posting creation
@@ -44,28 +268,77 @@ func (h *JobHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	details, err := req.details()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := req.expiresAt()
+	if err != nil {
+		http.Error(w, "Invalid expiry date", http.StatusBadRequest)
+		return
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		http.Error(w, "Expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	state := req.State
+	if state == "" {
+		state = models.JobPublished
+	}
+	if state != models.JobDraft && state != models.JobPublished {
+		http.Error(w, "New jobs must be draft or published", http.StatusBadRequest)
+		return
+	}
+
+	var org *models.Organization
+	if req.OrganizationID != nil {
+		org, err = h.orgService.GetOrganization(r.Context(), *req.OrganizationID)
+		if err != nil {
+			http.Error(w, "Organization not found", http.StatusBadRequest)
+			return
+		}
+		role, err := h.orgService.GetRole(r.Context(), org.ID, userID)
+		if err != nil {
+			http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+			return
+		}
+		if !role.CanEdit() {
+			http.Error(w, "Cannot post jobs for this organization", http.StatusForbidden)
+			return
+		}
+		req.Company = org.Name
+	}
+	if !h.checkPostingLimit(w, r, userID, org) {
+		return
+	}
+
 	job := &models.Job{
 		Title:        req.Title,
 		Company:      req.Company,
 		Location:     req.Location,
 		Description:  req.Description,
 		Requirements: req.Requirements,
-		SalaryRange:  req.SalaryRange,
+		SalaryRange:  req.salaryRange(details),
 		ContactEmail: req.ContactEmail,
 		PostedBy:     userID,
 	}
 
-	if err := h.jobService.CreateJob(r.Context(), job); err != nil {
+	if err := h.saveNewJob(r.Context(), job, org, &req, details, state, expiresAt, ""); err != nil {
+		log.Printf("Failed to create job: %v", err)
 		http.Error(w, "Failed to create job posting", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	h.writeJob(w, r, job)
 }
 
-// Get returns a specific job posting
+// Get returns a specific job posting. Drafts are only visible to their
+// poster and the members of the job's organization.
 func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value("userID").(int)
 	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		http.Error(w, "Invalid job ID", http.StatusBadRequest)
@@ -78,20 +351,63 @@ func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	lifecycle, err := h.jobLifecycleService.GetLifecycle(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job state", http.StatusInternalServerError)
+		return
+	}
+	if lifecycle.State == models.JobDraft {
+		role, ok := h.jobRole(w, r, job, userID)
+		if !ok {
+			return
+		}
+		if !role.CanView() {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	h.writeJob(w, r, job)
+}
+
+// postedPeriods maps the values of the posted filter to how far back they
+// reach
+var postedPeriods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
 }
 
-// List returns a paginated list of job postings
+// List searches job postings. All parameters are optional:
+//
+//	q                full-text query over title, company, description and requirements
+//	location         location containing this text
+//	company          exact company name
+//	remote           onsite, hybrid or remote
+//	employment_type  full_time, part_time, contract, temporary, internship or volunteer
+//	seniority        intern, junior, mid, senior, lead, principal or executive
+//	currency         salary currency, e.g. EUR
+//	salary_min       lowest acceptable yearly salary in currency
+//	salary_max       highest yearly salary in currency a job may start at
+//	skills           comma-separated skills every job must list, by name or alias
+//	posted           24h, 7d or 30d
+//	sort             relevance (default) or recent
+//	page             page number, 20 jobs per page
+//
+// The response carries the total number of matches and facet counts for
+// each filter but salary.
 func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page < 1 {
-		page = 1
-	}
+	page := pageParam(r)
 	limit := 20
-	offset := (page - 1) * limit
 
-	jobs, err := h.jobService.ListJobs(r.Context(), offset, limit)
+	search, ok := parseJobSearch(w, r)
+	if !ok {
+		return
+	}
+	search.Offset = (page - 1) * limit
+	search.Limit = limit
+
+	result, err := h.jobSearchService.SearchJobs(r.Context(), search)
 	if err != nil {
 		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
 		return
@@ -99,11 +415,97 @@ func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"jobs": jobs,
-		"page": page,
+		"jobs":   result.Jobs,
+		"page":   page,
+		"total":  result.Total,
+		"facets": result.Facets,
 	})
 }
 
+// parseJobSearch reads the job board filters documented on List from the
+// query string, writing an error response if one is invalid
+func parseJobSearch(w http.ResponseWriter, r *http.Request) (*models.JobSearch, bool) {
+	query := r.URL.Query()
+
+	search := &models.JobSearch{
+		Query:    strings.TrimSpace(query.Get("q")),
+		Location: strings.TrimSpace(query.Get("location")),
+		Company:  strings.TrimSpace(query.Get("company")),
+		Sort:     models.JobSortRelevance,
+	}
+
+	if remote := query.Get("remote"); remote != "" {
+		search.Remote = models.RemotePolicy(remote)
+		if !search.Remote.Valid() {
+			http.Error(w, "Invalid remote filter", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	if employmentType := query.Get("employment_type"); employmentType != "" {
+		search.EmploymentType = models.EmploymentType(employmentType)
+		if !search.EmploymentType.Valid() {
+			http.Error(w, "Invalid employment type filter", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	if seniority := query.Get("seniority"); seniority != "" {
+		search.Seniority = models.Seniority(seniority)
+		if !search.Seniority.Valid() {
+			http.Error(w, "Invalid seniority filter", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	search.SalaryCurrency = strings.ToUpper(strings.TrimSpace(query.Get("currency")))
+	for param, dest := range map[string]*int64{"salary_min": &search.MinSalary, "salary_max": &search.MaxSalary} {
+		salary := query.Get(param)
+		if salary == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(salary, 10, 64)
+		if err != nil || value < 0 {
+			http.Error(w, "Invalid salary filter", http.StatusBadRequest)
+			return nil, false
+		}
+		if search.SalaryCurrency == "" {
+			http.Error(w, "Salary filter requires a currency", http.StatusBadRequest)
+			return nil, false
+		}
+		*dest = value
+	}
+	if search.MaxSalary > 0 && search.MinSalary > search.MaxSalary {
+		http.Error(w, "Minimum salary cannot exceed maximum salary", http.StatusBadRequest)
+		return nil, false
+	}
+
+	for _, skill := range strings.Split(query.Get("skills"), ",") {
+		if skill = strings.TrimSpace(skill); skill != "" {
+			search.Skills = append(search.Skills, skill)
+		}
+	}
+
+	if posted := query.Get("posted"); posted != "" {
+		period, ok := postedPeriods[posted]
+		if !ok {
+			http.Error(w, "Invalid posted filter", http.StatusBadRequest)
+			return nil, false
+		}
+		search.PostedSince = time.Now().Add(-period)
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		search.Sort = models.JobSort(sort)
+		if !search.Sort.Valid() {
+			http.Error(w, "Invalid sort", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	return search, true
+}
+
 // Update handles job posting updates
 func (h *JobHandler) Update(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int)
@@ -119,7 +521,11 @@ func (h *JobHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.PostedBy != userID {
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -130,21 +536,43 @@ func (h *JobHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	job.Title = req.Title
-	job.Company = req.Company
-	job.Location = req.Location
-	job.Description = req.Description
-	job.Requirements = req.Requirements
-	job.SalaryRange = req.SalaryRange
-	job.ContactEmail = req.ContactEmail
+	details, err := req.details()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err := h.jobService.UpdateJob(r.Context(), job); err != nil {
+	expiresAt, err := req.expiresAt()
+	if err != nil {
+		http.Error(w, "Invalid expiry date", http.StatusBadRequest)
+		return
+	}
+	lifecycle, err := h.jobLifecycleService.GetLifecycle(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job state", http.StatusInternalServerError)
+		return
+	}
+	if !validExpiryUpdate(lifecycle, expiresAt) {
+		http.Error(w, "Expiry must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.orgService.GetJobOrganization(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job organization", http.StatusInternalServerError)
+		return
+	}
+	if org != nil {
+		req.Company = org.Name
+	}
+
+	if err := h.saveJobUpdate(r.Context(), job, &req, details, lifecycle, expiresAt); err != nil {
+		log.Printf("Failed to update job %d: %v", job.ID, err)
 		http.Error(w, "Failed to update job posting", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(job)
+	h.writeJob(w, r, job)
 }
 
 // Delete removes a job posting
@@ -162,12 +590,21 @@ func (h *JobHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.PostedBy != userID {
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
 
-	if err := h.jobService.DeleteJob(r.Context(), jobID, userID); err != nil {
+	// Federate first, as the posting can't be built once the job is gone
+	if err := h.activityPubService.PublishJobDelete(r.Context(), job); err != nil {
+		log.Printf("Failed to federate deletion of job %d: %v", job.ID, err)
+	}
+
+	if err := h.jobService.DeleteJob(r.Context(), jobID, job.PostedBy); err != nil {
 		http.Error(w, "Failed to delete job posting", http.StatusInternalServerError)
 		return
 	}
@@ -175,6 +612,160 @@ func (h *JobHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SetState moves a job posting to another state: published, paused or
+// filled. Expiry is left to the scheduler.
+func (h *JobHandler) SetState(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		State models.JobState `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.State != models.JobPublished && req.State != models.JobPaused && req.State != models.JobFilled {
+		http.Error(w, "Invalid state", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	if req.State == models.JobPublished {
+		lifecycle, err := h.jobLifecycleService.GetLifecycle(r.Context(), jobID)
+		if err != nil {
+			http.Error(w, "Failed to fetch job state", http.StatusInternalServerError)
+			return
+		}
+		if lifecycle.ExpiresAt != nil && !lifecycle.ExpiresAt.After(time.Now()) {
+			http.Error(w, "Job has passed its expiry, update it first", http.StatusConflict)
+			return
+		}
+	}
+
+	err = h.activityPubService.SetJobState(r.Context(), job, req.State)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		http.Error(w, "Job cannot move to that state", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update job state", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJob(w, r, job)
+}
+
+// Repost publishes a copy of an expired job posting. A job that had an
+// expiry gets one the same distance from now.
+func (h *JobHandler) Repost(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	lifecycle, err := h.jobLifecycleService.GetLifecycle(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job state", http.StatusInternalServerError)
+		return
+	}
+	if lifecycle.State != models.JobExpired {
+		http.Error(w, "Only expired jobs can be reposted", http.StatusConflict)
+		return
+	}
+
+	details, err := h.jobDetailsService.GetDetails(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job details", http.StatusInternalServerError)
+		return
+	}
+
+	var expiresAt *time.Time
+	if lifecycle.ExpiresAt != nil {
+		t := time.Now().Add(lifecycle.ExpiresAt.Sub(job.CreatedAt))
+		expiresAt = &t
+	}
+
+	org, err := h.orgService.GetJobOrganization(r.Context(), job.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job organization", http.StatusInternalServerError)
+		return
+	}
+	if !h.checkPostingLimit(w, r, userID, org) {
+		return
+	}
+
+	repost := &models.Job{
+		Title:        job.Title,
+		Company:      job.Company,
+		Location:     job.Location,
+		Description:  job.Description,
+		Requirements: job.Requirements,
+		SalaryRange:  job.SalaryRange,
+		ContactEmail: job.ContactEmail,
+		PostedBy:     userID,
+	}
+
+	parts := &models.NewJobParts{
+		Details:      details,
+		State:        models.JobPublished,
+		ExpiresAt:    expiresAt,
+		RepostedFrom: &job.ID,
+	}
+	if org != nil {
+		parts.OrganizationID = &org.ID
+	}
+	if err := h.jobService.CreateJobWithParts(r.Context(), repost, parts); err != nil {
+		log.Printf("Failed to repost job %d: %v", job.ID, err)
+		http.Error(w, "Failed to repost job", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.activityPubService.TagJob(r.Context(), repost); err != nil {
+		log.Printf("Failed to tag job %d: %v", repost.ID, err)
+	}
+	if err := h.activityPubService.PublishJob(r.Context(), repost); err != nil {
+		log.Printf("Failed to publish job %d: %v", repost.ID, err)
+	}
+
+	h.writeJob(w, r, repost)
+}
+
 // Apply handles job applications
 func (h *JobHandler) Apply(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int)
@@ -190,14 +781,82 @@ func (h *JobHandler) Apply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if role.CanView() {
+		http.Error(w, "Cannot apply to your own job", http.StatusForbidden)
+		return
+	}
+
+	lifecycle, err := h.jobLifecycleService.GetLifecycle(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch job state", http.StatusInternalServerError)
+		return
+	}
+	if lifecycle.State != models.JobPublished || (lifecycle.ExpiresAt != nil && !lifecycle.ExpiresAt.After(time.Now())) {
+		http.Error(w, "Job is not accepting applications", http.StatusConflict)
+		return
+	}
+
+	applied, err := h.applicationService.HasApplied(r.Context(), jobID, userID)
+	if err != nil {
+		http.Error(w, "Failed to submit application", http.StatusInternalServerError)
+		return
+	}
+	if applied {
+		http.Error(w, "Already applied to this job", http.StatusConflict)
+		return
+	}
+
+	resume, ok := h.applicationResume(w, r, userID, &req)
+	if !ok {
+		return
+	}
+
+	answers, err := h.formService.CheckAnswers(r.Context(), jobID, userID, req.Answers)
+	switch {
+	case errors.Is(err, models.ErrInvalidAnswer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrDocumentNotFound):
+		http.Error(w, "Document not found", http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to check answers", http.StatusInternalServerError)
+		return
+	}
+
+	pipeline, err := h.pipelineService.GetPipeline(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch pipeline", http.StatusInternalServerError)
+		return
+	}
+
 	application := &models.JobApplication{
 		JobID:       jobID,
 		UserID:      userID,
 		CoverLetter: req.CoverLetter,
-		Status:      "pending",
+		Status:      pipeline.First().Key,
 	}
 
-	if err := h.jobService.CreateJobApplication(r.Context(), application); err != nil {
+	var resumeID *int
+	if resume != nil {
+		resumeID = &resume.ID
+	}
+	err = h.formService.SubmitApplication(r.Context(), application, resumeID, answers)
+	if errors.Is(err, models.ErrAlreadyApplied) {
+		http.Error(w, "Already applied to this job", http.StatusConflict)
+		return
+	}
+	if err != nil {
 		http.Error(w, "Failed to submit application", http.StatusInternalServerError)
 		return
 	}
@@ -206,7 +865,119 @@ func (h *JobHandler) Apply(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(application)
 }
 
-// ListApplications returns all applications for a job posting
+// applicationResume returns the resume an application is sent with, or nil
+// for none, writing an error if the request names one that can't be sent
+func (h *JobHandler) applicationResume(w http.ResponseWriter, r *http.Request, userID int, req *JobApplicationRequest) (*models.Document, bool) {
+	var resume *models.Document
+	var err error
+	switch {
+	case req.UseProfileResume && req.ResumeDocumentID != nil:
+		http.Error(w, "Send either a resume or the profile resume", http.StatusBadRequest)
+		return nil, false
+	case req.UseProfileResume:
+		resume, err = h.documentService.GetProfileResume(r.Context(), userID)
+		if err == nil && resume == nil {
+			http.Error(w, "No resume on profile", http.StatusBadRequest)
+			return nil, false
+		}
+	case req.ResumeDocumentID != nil:
+		resume, err = h.documentService.GetUserDocument(r.Context(), userID, *req.ResumeDocumentID)
+		if errors.Is(err, models.ErrDocumentNotFound) {
+			http.Error(w, "Resume not found", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch resume", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if resume != nil && resume.MediaType != media.ResumeType {
+		http.Error(w, "Resumes must be PDF", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+	return resume, true
+}
+
+// Questions returns the questions applicants to a job are asked
+func (h *JobHandler) Questions(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.jobService.GetJob(r.Context(), jobID); err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	questions, err := h.formService.ListQuestions(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
+
+// UpdateQuestions replaces the questions applicants to a job are asked.
+// Existing questions are kept by sending them with their ID.
+func (h *JobHandler) UpdateQuestions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	var req []*models.ApplicationQuestion
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	err = h.formService.SetQuestions(r.Context(), jobID, req)
+	switch {
+	case errors.Is(err, models.ErrInvalidQuestion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrQuestionInUse):
+		http.Error(w, "Answered questions can't be removed or change kind", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update questions", http.StatusInternalServerError)
+		return
+	}
+
+	questions, err := h.formService.ListQuestions(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(questions)
+}
+
+// ListApplications returns the applications for a job posting, optionally
+// only those at the pipeline stage given by the stage parameter
 func (h *JobHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int)
 	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -221,7 +992,11 @@ func (h *JobHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if job.PostedBy != userID {
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanView() {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
@@ -232,11 +1007,22 @@ func (h *JobHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if stage := r.URL.Query().Get("stage"); stage != "" {
+		filtered := make([]*models.JobApplication, 0, len(applications))
+		for _, application := range applications {
+			if application.Status == stage {
+				filtered = append(filtered, application)
+			}
+		}
+		applications = filtered
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(applications)
 }
 
-// UpdateApplicationStatus updates the status of a job application
+// UpdateApplicationStatus moves a job application to another stage of the
+// job's pipeline, recording who moved it and why
 func (h *JobHandler) UpdateApplicationStatus(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(int)
 	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -251,13 +1037,15 @@ func (h *JobHandler) UpdateApplicationStatus(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var req struct {
-		Status string `json:"status"`
-	}
+	var req MoveApplicationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if utf8.RuneCountInString(req.Reason) > maxReasonLength {
+		http.Error(w, "Reason is too long", http.StatusBadRequest)
+		return
+	}
 
 	job, err := h.jobService.GetJob(r.Context(), jobID)
 	if err != nil {
@@ -265,15 +1053,37 @@ func (h *JobHandler) UpdateApplicationStatus(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if job.PostedBy != userID {
+	role, ok := h.jobRole(w, r, job, userID)
+	if !ok {
+		return
+	}
+	if !role.CanEdit() {
 		http.Error(w, "Unauthorized", http.StatusForbidden)
 		return
 	}
 
-	if err := h.jobService.UpdateApplicationStatus(r.Context(), applicationID, req.Status); err != nil {
+	event, err := h.pipelineService.MoveApplication(r.Context(), jobID, applicationID, userID, req.Status, strings.TrimSpace(req.Reason))
+	switch {
+	case errors.Is(err, models.ErrApplicationNotFound):
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrInvalidStage):
+		http.Error(w, "Unknown stage", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrStageTransition):
+		http.Error(w, "Application cannot move to that stage", http.StatusConflict)
+		return
+	case err != nil:
 		http.Error(w, "Failed to update application status", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	if pipeline, err := h.pipelineService.GetPipeline(r.Context(), jobID); err != nil {
+		log.Printf("Failed to fetch pipeline of job %d: %v", jobID, err)
+	} else {
+		notifyApplicant(r.Context(), h.notificationService, job, pipeline, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
 }
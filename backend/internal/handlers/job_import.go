@@ -0,0 +1,618 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/models"
+)
+
+// maxImportSize is how large an import file can be
+const maxImportSize = 10 << 20
+
+// ImportAction is what an import does, or would do on a dry run, with a job
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+	// ImportUnchanged leaves alone a job that already matches its row
+	ImportUnchanged ImportAction = "unchanged"
+	// ImportExpire closes a job missing from an import that replaces all
+	// of an organization's imported jobs
+	ImportExpire ImportAction = "expire"
+	// ImportSkip leaves out a job that failed validation or couldn't be
+	// saved, with the reason in Error
+	ImportSkip ImportAction = "skip"
+)
+
+// ImportRowResult is the outcome for one job of an import. Row is the
+// job's position in the file, starting at 1, and is left out for jobs
+// expired because they were missing from it.
+type ImportRowResult struct {
+	Row         int          `json:"row,omitempty"`
+	ExternalRef string       `json:"external_ref,omitempty"`
+	Action      ImportAction `json:"action"`
+	JobID       int          `json:"job_id,omitempty"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// ImportResult reports an import job by job. On a dry run nothing is saved
+// and the counts are of what would be.
+type ImportResult struct {
+	DryRun    bool               `json:"dry_run"`
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Expired   int                `json:"expired"`
+	Skipped   int                `json:"skipped"`
+	Rows      []*ImportRowResult `json:"rows"`
+}
+
+// add records the outcome for a job
+func (res *ImportResult) add(row *ImportRowResult) {
+	switch row.Action {
+	case ImportCreate:
+		res.Created++
+	case ImportUpdate:
+		res.Updated++
+	case ImportUnchanged:
+		res.Unchanged++
+	case ImportExpire:
+		res.Expired++
+	case ImportSkip:
+		res.Skipped++
+	}
+	res.Rows = append(res.Rows, row)
+}
+
+// errImportFailed is reported for jobs that failed to save, the details of
+// which are logged
+var errImportFailed = errors.New("failed to save job")
+
+// jobImport is an import in progress into an organization's jobs
+type jobImport struct {
+	h      *JobHandler
+	userID int
+	org    *models.Organization
+	dryRun bool
+	// refs maps the organization's external references to their jobs
+	refs map[string]int
+	// jobIDs holds the organization's jobs, and matched the ones the
+	// import names
+	jobIDs  map[int]bool
+	matched map[int]bool
+	// seen maps the external references in the import to their rows
+	seen map[string]int
+	// remaining is how many more jobs can be created without a verified
+	// organization, -1 for no limit
+	remaining int
+}
+
+// importFormat returns the format of an import or export: the format
+// parameter, or else the request's content type. JSON is the default.
+func importFormat(r *http.Request) (string, bool) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = importCSV
+		case "application/xml", "text/xml":
+			format = importXML
+		default:
+			format = importJSON
+		}
+	}
+	return format, format == importCSV || format == importJSON || format == importXML
+}
+
+// importOrganization returns the organization in the URL if the
+// authenticated user's role in it is allowed, writing an error otherwise
+func (h *JobHandler) importOrganization(w http.ResponseWriter, r *http.Request, allowed func(models.OrgRole) bool) (*models.Organization, bool) {
+	userID := r.Context().Value("userID").(int)
+
+	org, err := h.orgService.GetOrganizationBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	role, err := h.orgService.GetRole(r.Context(), org.ID, userID)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed(role) {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return nil, false
+	}
+	return org, true
+}
+
+// Import creates and updates an organization's jobs in bulk from a CSV,
+// JSON or XML file in the request body, in the format given by the format
+// parameter or the content type. Jobs are matched to existing ones by
+// external_ref, or by id, and jobs matching their row are left alone, so
+// importing the same file twice changes nothing. Each job is validated on its own and the response reports what
+// happened to each; with dry_run=true nothing is saved. With
+// expire_missing=true, open jobs imported before whose external_ref is
+// missing from the file are expired, for files listing every opening.
+func (h *JobHandler) Import(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	org, ok := h.importOrganization(w, r, models.OrgRole.CanEdit)
+	if !ok {
+		return
+	}
+
+	format, ok := importFormat(r)
+	if !ok {
+		http.Error(w, "Format must be csv, json or xml", http.StatusBadRequest)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	expireMissing, _ := strconv.ParseBool(r.URL.Query().Get("expire_missing"))
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	rows, err := decodeImport(format, r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s file: %v", strings.ToUpper(format), err), http.StatusBadRequest)
+		return
+	}
+	if len(rows) > models.MaxImportJobs {
+		http.Error(w, fmt.Sprintf("At most %d jobs can be imported at once", models.MaxImportJobs), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx := r.Context()
+	imp := &jobImport{
+		h:         h,
+		userID:    userID,
+		org:       org,
+		dryRun:    dryRun,
+		jobIDs:    make(map[int]bool),
+		matched:   make(map[int]bool),
+		seen:      make(map[string]int),
+		remaining: -1,
+	}
+	imp.refs, err = h.importService.ListExternalRefs(ctx, org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	ids, err := h.importService.ListOrganizationJobIDs(ctx, org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	for _, id := range ids {
+		imp.jobIDs[id] = true
+	}
+	if !org.Verified {
+		count, err := h.orgService.CountUnverifiedJobs(ctx, userID, time.Now().Add(-models.UnverifiedJobWindow))
+		if err != nil {
+			http.Error(w, "Failed to check posting limit", http.StatusInternalServerError)
+			return
+		}
+		imp.remaining = models.UnverifiedJobLimit - count
+		if imp.remaining < 0 {
+			imp.remaining = 0
+		}
+	}
+
+	result := &ImportResult{DryRun: dryRun, Rows: make([]*ImportRowResult, 0, len(rows))}
+	for i, row := range rows {
+		result.add(imp.row(ctx, i+1, row))
+	}
+	if expireMissing {
+		for _, row := range imp.expireMissing(ctx) {
+			result.add(row)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// row imports the job at position n of the file
+func (imp *jobImport) row(ctx context.Context, n int, row *importRow) *ImportRowResult {
+	job := row.Job
+	job.ExternalRef = strings.TrimSpace(job.ExternalRef)
+	result := &ImportRowResult{Row: n, ExternalRef: job.ExternalRef, JobID: job.ID}
+
+	// Jobs named by a row count as present even if the row is rejected, so
+	// a mistake in the file can't expire them
+	ref := job.ExternalRef
+	var err error
+	if ref != "" {
+		if first, ok := imp.seen[ref]; ok {
+			err = fmt.Errorf("external_ref %q is repeated from row %d", ref, first)
+		} else {
+			imp.seen[ref] = n
+		}
+	}
+	if job.ID != 0 {
+		imp.matched[job.ID] = true
+	}
+	if err == nil {
+		err = row.Err
+	}
+
+	if err == nil {
+		var action ImportAction
+		var jobID int
+		action, jobID, err = imp.save(ctx, job)
+		result.Action = action
+		result.JobID = jobID
+	}
+	if err != nil {
+		result.Action = ImportSkip
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// save validates a job and creates or updates it, returning what it did
+// and the ID of the job. On a dry run new jobs have no ID.
+func (imp *jobImport) save(ctx context.Context, job *ImportJob) (ImportAction, int, error) {
+	ref := job.ExternalRef
+	switch {
+	case ref == "" && job.ID == 0:
+		return "", 0, errors.New("external_ref or id is required")
+	case ref != "" && !models.ValidExternalRef(ref):
+		return "", 0, fmt.Errorf("external_ref must be at most %d characters without surrounding spaces", models.MaxExternalRefLength)
+	case strings.TrimSpace(job.Title) == "":
+		return "", 0, errors.New("title is required")
+	case job.State != "" && !job.State.Valid():
+		return "", 0, fmt.Errorf("invalid state %q", job.State)
+	}
+
+	req := &job.CreateJobRequest
+	req.OrganizationID = nil
+	req.Company = imp.org.Name
+	details, err := req.details()
+	if err != nil {
+		return "", 0, err
+	}
+	expiresAt, err := req.expiresAt()
+	if err != nil {
+		return "", 0, errors.New("invalid expiry date")
+	}
+
+	jobID := imp.refs[ref]
+	if job.ID != 0 {
+		if !imp.jobIDs[job.ID] {
+			return "", 0, fmt.Errorf("job %d is not one of the organization's jobs", job.ID)
+		}
+		if jobID != 0 && jobID != job.ID {
+			return "", 0, fmt.Errorf("external_ref %q belongs to job %d", ref, jobID)
+		}
+		jobID = job.ID
+	}
+
+	if jobID == 0 {
+		id, err := imp.create(ctx, req, details, expiresAt, ref)
+		if !errors.Is(err, models.ErrExternalRefTaken) {
+			return ImportCreate, id, err
+		}
+
+		// Another import created the job since the references were listed
+		if jobID, err = imp.h.importService.GetJobByExternalRef(ctx, imp.org.ID, ref); err != nil {
+			log.Printf("Failed to fetch imported job %q of organization %d: %v", ref, imp.org.ID, err)
+			return "", 0, errImportFailed
+		}
+		imp.refs[ref] = jobID
+		imp.jobIDs[jobID] = true
+	}
+
+	imp.matched[jobID] = true
+	action, err := imp.update(ctx, jobID, req, details, expiresAt, ref)
+	return action, jobID, err
+}
+
+// create posts a new job for the organization
+func (imp *jobImport) create(ctx context.Context, req *CreateJobRequest, details *models.JobDetails, expiresAt *time.Time, ref string) (int, error) {
+	state := req.State
+	if state == "" {
+		state = models.JobPublished
+	}
+	if state != models.JobDraft && state != models.JobPublished {
+		return 0, errors.New("new jobs must be draft or published")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return 0, errors.New("expiry must be in the future")
+	}
+	if imp.remaining == 0 {
+		return 0, errors.New("too many jobs posted without a verified organization")
+	}
+	if imp.dryRun {
+		if imp.remaining > 0 {
+			imp.remaining--
+		}
+		return 0, nil
+	}
+
+	job := &models.Job{
+		Title:        req.Title,
+		Company:      req.Company,
+		Location:     req.Location,
+		Description:  req.Description,
+		Requirements: req.Requirements,
+		SalaryRange:  req.salaryRange(details),
+		ContactEmail: req.ContactEmail,
+		PostedBy:     imp.userID,
+	}
+	err := imp.h.saveNewJob(ctx, job, imp.org, req, details, state, expiresAt, ref)
+	if errors.Is(err, models.ErrExternalRefTaken) {
+		return 0, err
+	}
+	if err != nil {
+		log.Printf("Failed to import job for organization %d: %v", imp.org.ID, err)
+		return 0, errImportFailed
+	}
+	if imp.remaining > 0 {
+		imp.remaining--
+	}
+	imp.jobIDs[job.ID] = true
+	imp.matched[job.ID] = true
+	if ref != "" {
+		imp.refs[ref] = job.ID
+	}
+	return job.ID, nil
+}
+
+// update changes one of the organization's jobs, moving it to the state
+// given if that differs from its own. Jobs that already match the row are
+// left alone.
+func (imp *jobImport) update(ctx context.Context, jobID int, req *CreateJobRequest, details *models.JobDetails, expiresAt *time.Time, ref string) (ImportAction, error) {
+	job, err := imp.h.jobService.GetJob(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to fetch imported job %d: %v", jobID, err)
+		return "", errImportFailed
+	}
+	withDetails, err := imp.h.jobDetailsService.WithDetails(ctx, job)
+	if err != nil {
+		log.Printf("Failed to fetch details of imported job %d: %v", jobID, err)
+		return "", errImportFailed
+	}
+	current := withDetails[0]
+	lifecycle := &current.JobLifecycle
+
+	if !validExpiryUpdate(lifecycle, expiresAt) {
+		return "", errors.New("expiry must be in the future")
+	}
+	state := req.State
+	if state == lifecycle.State {
+		state = ""
+	}
+	if state != "" && !lifecycle.State.CanTransition(state) {
+		return "", fmt.Errorf("job cannot move from %s to %s", lifecycle.State, state)
+	}
+	if state == models.JobPublished && expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", errors.New("job has passed its expiry, give it a new one to publish it")
+	}
+
+	changed := state != "" || jobChanged(current, req, details, expiresAt)
+	refChanged := ref != "" && imp.refs[ref] != jobID
+	if !changed && !refChanged {
+		return ImportUnchanged, nil
+	}
+	if imp.dryRun {
+		return ImportUpdate, nil
+	}
+
+	if changed {
+		if err := imp.h.saveJobUpdate(ctx, job, req, details, lifecycle, expiresAt); err != nil {
+			log.Printf("Failed to import update of job %d: %v", jobID, err)
+			return "", errImportFailed
+		}
+	}
+	if state != "" {
+		err := imp.h.activityPubService.SetJobState(ctx, job, state)
+		switch {
+		case errors.Is(err, models.ErrInvalidTransition):
+			return "", fmt.Errorf("job cannot move to %s", state)
+		case err != nil:
+			log.Printf("Failed to set state of imported job %d: %v", jobID, err)
+			return "", errImportFailed
+		}
+	}
+	return ImportUpdate, imp.setRef(ctx, jobID, ref)
+}
+
+// jobChanged reports whether saving an import row would change a job, its
+// structured fields, skills or expiry
+func jobChanged(current *models.JobWithDetails, req *CreateJobRequest, details *models.JobDetails, expiresAt *time.Time) bool {
+	return current.Title != req.Title ||
+		current.Company != req.Company ||
+		current.Location != req.Location ||
+		current.Description != req.Description ||
+		current.Requirements != req.Requirements ||
+		current.SalaryRange != req.salaryRange(details) ||
+		current.ContactEmail != req.ContactEmail ||
+		!current.JobDetails.Equal(details) ||
+		!sameSkills(current.Skills, req.Skills) ||
+		!sameSkills(current.NiceToHaveSkills, req.NiceToHaveSkills) ||
+		expiryChanged(&current.JobLifecycle, expiresAt)
+}
+
+// sameSkills reports whether two lists name the same skills in order
+func sameSkills(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if models.NormalizeSkill(a[i]) != models.NormalizeSkill(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// setRef gives a job an external reference, replacing any it had
+func (imp *jobImport) setRef(ctx context.Context, jobID int, ref string) error {
+	if ref == "" || imp.refs[ref] == jobID {
+		return nil
+	}
+	if err := imp.h.importService.SetExternalRef(ctx, imp.org.ID, jobID, ref); err != nil {
+		log.Printf("Failed to save external reference of job %d: %v", jobID, err)
+		return errImportFailed
+	}
+	for other, id := range imp.refs {
+		if id == jobID {
+			delete(imp.refs, other)
+		}
+	}
+	imp.refs[ref] = jobID
+	return nil
+}
+
+// expireMissing expires the organization's open jobs with an external
+// reference that the import didn't name
+func (imp *jobImport) expireMissing(ctx context.Context) []*ImportRowResult {
+	refs := make([]string, 0, len(imp.refs))
+	for ref, jobID := range imp.refs {
+		if _, ok := imp.seen[ref]; !ok && !imp.matched[jobID] {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+
+	var results []*ImportRowResult
+	for _, ref := range refs {
+		jobID := imp.refs[ref]
+		lifecycle, err := imp.h.jobLifecycleService.GetLifecycle(ctx, jobID)
+		if err != nil {
+			log.Printf("Failed to fetch state of imported job %d: %v", jobID, err)
+			results = append(results, &ImportRowResult{ExternalRef: ref, JobID: jobID, Action: ImportSkip, Error: errImportFailed.Error()})
+			continue
+		}
+		if lifecycle.State != models.JobPublished && lifecycle.State != models.JobPaused {
+			continue
+		}
+
+		result := &ImportRowResult{ExternalRef: ref, JobID: jobID, Action: ImportExpire}
+		if !imp.dryRun {
+			if err := imp.expire(ctx, jobID); err != nil {
+				result.Action = ImportSkip
+				result.Error = err.Error()
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// expire moves a job to expired
+func (imp *jobImport) expire(ctx context.Context, jobID int) error {
+	job, err := imp.h.jobService.GetJob(ctx, jobID)
+	if err != nil {
+		log.Printf("Failed to fetch imported job %d: %v", jobID, err)
+		return errImportFailed
+	}
+	err = imp.h.activityPubService.SetJobState(ctx, job, models.JobExpired)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		return errors.New("job cannot move to expired")
+	case err != nil:
+		log.Printf("Failed to expire imported job %d: %v", jobID, err)
+		return errImportFailed
+	}
+	return nil
+}
+
+// Export returns all of an organization's jobs, whatever their state, as a
+// CSV, JSON or XML file given by the format parameter, in the form Import
+// takes. Importing the file back changes nothing.
+func (h *JobHandler) Export(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.importOrganization(w, r, models.OrgRole.CanView)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = importJSON
+	}
+	if format != importCSV && format != importJSON && format != importXML {
+		http.Error(w, "Format must be csv, json or xml", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	ids, err := h.importService.ListOrganizationJobIDs(ctx, org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	jobs, err := h.jobService.GetJobs(ctx, ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	withDetails, err := h.jobDetailsService.WithDetails(ctx, jobs...)
+	if err != nil {
+		http.Error(w, "Failed to fetch job details", http.StatusInternalServerError)
+		return
+	}
+
+	refs, err := h.importService.ListExternalRefs(ctx, org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+	jobRefs := make(map[int]string, len(refs))
+	for ref, jobID := range refs {
+		jobRefs[jobID] = ref
+	}
+
+	exported := make([]*ImportJob, 0, len(withDetails))
+	for _, job := range withDetails {
+		e := &ImportJob{
+			ExternalRef: jobRefs[job.ID],
+			ID:          job.ID,
+			CreateJobRequest: CreateJobRequest{
+				Title:            job.Title,
+				Company:          job.Company,
+				Location:         job.Location,
+				Description:      job.Description,
+				Requirements:     job.Requirements,
+				SalaryRange:      job.SalaryRange,
+				ContactEmail:     job.ContactEmail,
+				State:            job.State,
+				Skills:           job.Skills,
+				NiceToHaveSkills: job.NiceToHaveSkills,
+				JobDetails:       job.JobDetails,
+			},
+		}
+		// Full precision keeps an unchanged expiry equal on import
+		if job.ExpiresAt != nil {
+			e.ExpiresAt = job.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		}
+		exported = append(exported, e)
+	}
+
+	switch format {
+	case importCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case importXML:
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-jobs.%s"`, org.Slug, format))
+	if err := encodeExport(format, w, exported); err != nil {
+		log.Printf("Failed to export jobs of organization %d: %v", org.ID, err)
+	}
+}
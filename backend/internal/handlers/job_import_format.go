@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"openfirm/internal/models"
+)
+
+// Formats jobs are imported and exported in
+const (
+	importCSV  = "csv"
+	importJSON = "json"
+	importXML  = "xml"
+)
+
+// ImportJob is a job in an import or export file. ExternalRef is the job's
+// ID in the organization's own system, which later imports match it by. ID
+// matches one of the organization's jobs on the board instead.
+type ImportJob struct {
+	ExternalRef string `json:"external_ref,omitempty"`
+	ID          int    `json:"id,omitempty"`
+	CreateJobRequest
+}
+
+// importRow is a job read from an import file, with Err set if it couldn't
+// be read in full
+type importRow struct {
+	Job *ImportJob
+	Err error
+}
+
+// decodeImport reads the jobs of an import file. An error is returned if
+// the file can't be read at all; errors in single jobs are left to their
+// rows.
+func decodeImport(format string, body io.Reader) ([]*importRow, error) {
+	switch format {
+	case importCSV:
+		return decodeCSVJobs(body)
+	case importXML:
+		return decodeXMLJobs(body)
+	default:
+		return decodeJSONJobs(body)
+	}
+}
+
+// encodeExport writes jobs as an export file that imports them again
+func encodeExport(format string, w io.Writer, jobs []*ImportJob) error {
+	switch format {
+	case importCSV:
+		return encodeCSVJobs(w, jobs)
+	case importXML:
+		return encodeXMLJobs(w, jobs)
+	default:
+		return json.NewEncoder(w).Encode(struct {
+			Jobs []*ImportJob `json:"jobs"`
+		}{jobs})
+	}
+}
+
+// decodeJSONJobs reads jobs as a JSON array or as the jobs array of an
+// object, the form they are exported in
+func decodeJSONJobs(body io.Reader) ([]*importRow, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	var raw []json.RawMessage
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper struct {
+			Jobs []json.RawMessage `json:"jobs"`
+		}
+		err = json.Unmarshal(data, &wrapper)
+		raw = wrapper.Jobs
+	} else {
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]*importRow, 0, len(raw))
+	for _, msg := range raw {
+		// A job with a mistyped field still has the rest filled in, so its
+		// row can report its external reference
+		job := &ImportJob{}
+		err := json.Unmarshal(msg, job)
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			err = fmt.Errorf("invalid %s", typeErr.Field)
+		}
+		rows = append(rows, &importRow{Job: job, Err: err})
+	}
+	return rows, nil
+}
+
+// jobColumn is a column of a CSV import or export
+type jobColumn struct {
+	name string
+	get  func(j *ImportJob) string
+	set  func(j *ImportJob, value string) error
+}
+
+// textColumn is a column holding a string field as is
+func textColumn(name string, field func(j *ImportJob) *string) *jobColumn {
+	return &jobColumn{
+		name: name,
+		get:  func(j *ImportJob) string { return *field(j) },
+		set: func(j *ImportJob, value string) error {
+			*field(j) = value
+			return nil
+		},
+	}
+}
+
+// amountColumn is a column holding an optional whole amount
+func amountColumn(name string, field func(j *ImportJob) **int64) *jobColumn {
+	return &jobColumn{
+		name: name,
+		get: func(j *ImportJob) string {
+			if *field(j) == nil {
+				return ""
+			}
+			return strconv.FormatInt(**field(j), 10)
+		},
+		set: func(j *ImportJob, value string) error {
+			amount, err := parseAmount(name, value)
+			*field(j) = amount
+			return err
+		},
+	}
+}
+
+// listColumn is a column holding a comma separated list
+func listColumn(name string, field func(j *ImportJob) *[]string) *jobColumn {
+	return &jobColumn{
+		name: name,
+		get:  func(j *ImportJob) string { return strings.Join(*field(j), ", ") },
+		set: func(j *ImportJob, value string) error {
+			*field(j) = splitList(value)
+			return nil
+		},
+	}
+}
+
+// jobColumns are the columns of CSV files, named after the JSON fields of
+// ImportJob and exported in this order
+var jobColumns = []*jobColumn{
+	textColumn("external_ref", func(j *ImportJob) *string { return &j.ExternalRef }),
+	{
+		name: "id",
+		get: func(j *ImportJob) string {
+			if j.ID == 0 {
+				return ""
+			}
+			return strconv.Itoa(j.ID)
+		},
+		set: func(j *ImportJob, value string) error {
+			id, err := parseImportID(value)
+			j.ID = id
+			return err
+		},
+	},
+	textColumn("title", func(j *ImportJob) *string { return &j.Title }),
+	textColumn("company", func(j *ImportJob) *string { return &j.Company }),
+	textColumn("location", func(j *ImportJob) *string { return &j.Location }),
+	textColumn("description", func(j *ImportJob) *string { return &j.Description }),
+	textColumn("requirements", func(j *ImportJob) *string { return &j.Requirements }),
+	textColumn("salary_range", func(j *ImportJob) *string { return &j.SalaryRange }),
+	textColumn("contact_email", func(j *ImportJob) *string { return &j.ContactEmail }),
+	textColumn("expires_at", func(j *ImportJob) *string { return &j.ExpiresAt }),
+	{
+		name: "state",
+		get:  func(j *ImportJob) string { return string(j.State) },
+		set: func(j *ImportJob, value string) error {
+			j.State = models.JobState(value)
+			return nil
+		},
+	},
+	listColumn("skills", func(j *ImportJob) *[]string { return &j.Skills }),
+	listColumn("nice_to_have_skills", func(j *ImportJob) *[]string { return &j.NiceToHaveSkills }),
+	amountColumn("salary_min", func(j *ImportJob) **int64 { return &j.SalaryMin }),
+	amountColumn("salary_max", func(j *ImportJob) **int64 { return &j.SalaryMax }),
+	textColumn("salary_currency", func(j *ImportJob) *string { return &j.SalaryCurrency }),
+	{
+		name: "pay_period",
+		get:  func(j *ImportJob) string { return string(j.PayPeriod) },
+		set: func(j *ImportJob, value string) error {
+			j.PayPeriod = models.PayPeriod(value)
+			return nil
+		},
+	},
+	{
+		name: "employment_type",
+		get:  func(j *ImportJob) string { return string(j.EmploymentType) },
+		set: func(j *ImportJob, value string) error {
+			j.EmploymentType = models.EmploymentType(value)
+			return nil
+		},
+	},
+	{
+		name: "remote_policy",
+		get:  func(j *ImportJob) string { return string(j.RemotePolicy) },
+		set: func(j *ImportJob, value string) error {
+			j.RemotePolicy = models.RemotePolicy(value)
+			return nil
+		},
+	},
+	listColumn("remote_regions", func(j *ImportJob) *[]string { return &j.RemoteRegions }),
+	{
+		name: "seniority",
+		get:  func(j *ImportJob) string { return string(j.Seniority) },
+		set: func(j *ImportJob, value string) error {
+			j.Seniority = models.Seniority(value)
+			return nil
+		},
+	},
+}
+
+// decodeCSVJobs reads jobs from a CSV file with a header row naming its
+// columns. Columns can come in any order and be left out, but unknown ones
+// are rejected so misspelt columns aren't silently dropped.
+func decodeCSVJobs(body io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(body)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*jobColumn, len(jobColumns))
+	for _, column := range jobColumns {
+		byName[column.name] = column
+	}
+	columns := make([]*jobColumn, len(header))
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets often save CSV files with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("repeated column %q", name)
+		}
+		seen[name] = true
+		columns[i] = column
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row := &importRow{Job: &ImportJob{}}
+		if errors.Is(err, csv.ErrFieldCount) {
+			row.Err = fmt.Errorf("expected %d columns, got %d", len(columns), len(record))
+		} else if err != nil {
+			return nil, err
+		}
+
+		for i, value := range record {
+			if i >= len(columns) {
+				break
+			}
+			if err := columns[i].set(row.Job, strings.TrimSpace(value)); err != nil && row.Err == nil {
+				row.Err = err
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// encodeCSVJobs writes jobs as a CSV file with every column
+func encodeCSVJobs(w io.Writer, jobs []*ImportJob) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(jobColumns))
+	for i, column := range jobColumns {
+		header[i] = column.name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		record := make([]string, len(jobColumns))
+		for i, column := range jobColumns {
+			record[i] = column.get(job)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// parseImportID parses an optional job ID
+func parseImportID(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id")
+	}
+	return id, nil
+}
+
+// parseAmount parses an optional whole amount for the field name
+func parseAmount(name, value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &n, nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// xmlText is text written as CDATA, as job feeds conventionally do
+type xmlText struct {
+	Text string `xml:",cdata"`
+}
+
+// xmlSource is an XML job feed in the form Indeed and most other job
+// aggregators read: a source element holding job elements. Any root
+// element is accepted on import.
+type xmlSource struct {
+	XMLName       xml.Name
+	Publisher     string    `xml:"publisher,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Jobs          []*xmlJob `xml:"job"`
+}
+
+// xmlJob is a job in an XML job feed. Fields the common format lacks are
+// carried in elements of their own; on import, the common city, state and
+// country stand in for location and remotetype for remotepolicy.
+type xmlJob struct {
+	ID              string   `xml:"id,omitempty"`
+	ReferenceNumber string   `xml:"referencenumber,omitempty"`
+	Title           xmlText  `xml:"title"`
+	Company         xmlText  `xml:"company"`
+	Location        xmlText  `xml:"location"`
+	City            string   `xml:"city,omitempty"`
+	Region          string   `xml:"state,omitempty"`
+	Country         string   `xml:"country,omitempty"`
+	Description     xmlText  `xml:"description"`
+	Requirements    xmlText  `xml:"requirements"`
+	Salary          string   `xml:"salary,omitempty"`
+	SalaryMin       string   `xml:"salarymin,omitempty"`
+	SalaryMax       string   `xml:"salarymax,omitempty"`
+	SalaryCurrency  string   `xml:"salarycurrency,omitempty"`
+	PayPeriod       string   `xml:"payperiod,omitempty"`
+	JobType         string   `xml:"jobtype,omitempty"`
+	RemoteType      string   `xml:"remotetype,omitempty"`
+	RemotePolicy    string   `xml:"remotepolicy,omitempty"`
+	RemoteRegions   []string `xml:"remoteregions>region,omitempty"`
+	Seniority       string   `xml:"seniority,omitempty"`
+	Skills          []string `xml:"skills>skill,omitempty"`
+	NiceToHave      []string `xml:"nicetohaveskills>skill,omitempty"`
+	Email           string   `xml:"email,omitempty"`
+	ExpirationDate  string   `xml:"expirationdate,omitempty"`
+	Status          string   `xml:"status,omitempty"`
+}
+
+// employmentTypes are matched against the free text job types of XML feeds
+var employmentTypes = []models.EmploymentType{
+	models.EmploymentFullTime,
+	models.EmploymentPartTime,
+	models.EmploymentContract,
+	models.EmploymentTemporary,
+	models.EmploymentInternship,
+	models.EmploymentVolunteer,
+}
+
+// parseJobType matches a feed's job type, e.g. "Full-time" or "fulltime",
+// to an employment type. Types that don't match are left out.
+func parseJobType(jobType string) models.EmploymentType {
+	squash := strings.NewReplacer(" ", "", "-", "", "_", "")
+	jobType = squash.Replace(strings.ToLower(jobType))
+	for _, t := range employmentTypes {
+		if squash.Replace(string(t)) == jobType {
+			return t
+		}
+	}
+	return ""
+}
+
+// parseRemoteType matches a feed's remote type, e.g. "Fully remote", to a
+// remote policy
+func parseRemoteType(remoteType string) models.RemotePolicy {
+	remoteType = strings.ToLower(remoteType)
+	switch {
+	case strings.Contains(remoteType, "hybrid"):
+		return models.RemoteHybrid
+	case strings.Contains(remoteType, "remote"):
+		return models.RemoteRemote
+	}
+	return ""
+}
+
+// importJob converts a feed's job into an ImportJob
+func (x *xmlJob) importJob() (*ImportJob, error) {
+	job := &ImportJob{ExternalRef: strings.TrimSpace(x.ReferenceNumber)}
+	job.Title = strings.TrimSpace(x.Title.Text)
+	job.Company = strings.TrimSpace(x.Company.Text)
+	job.Location = strings.TrimSpace(x.Location.Text)
+	if job.Location == "" {
+		var parts []string
+		for _, part := range []string{x.City, x.Region, x.Country} {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		job.Location = strings.Join(parts, ", ")
+	}
+	job.Description = strings.TrimSpace(x.Description.Text)
+	job.Requirements = strings.TrimSpace(x.Requirements.Text)
+	job.SalaryRange = strings.TrimSpace(x.Salary)
+	job.ContactEmail = strings.TrimSpace(x.Email)
+	job.ExpiresAt = strings.TrimSpace(x.ExpirationDate)
+	job.State = models.JobState(strings.TrimSpace(x.Status))
+	job.Skills = x.Skills
+	job.NiceToHaveSkills = x.NiceToHave
+
+	job.SalaryCurrency = strings.TrimSpace(x.SalaryCurrency)
+	job.PayPeriod = models.PayPeriod(strings.TrimSpace(x.PayPeriod))
+	job.EmploymentType = parseJobType(strings.TrimSpace(x.JobType))
+	job.RemotePolicy = models.RemotePolicy(strings.TrimSpace(x.RemotePolicy))
+	if job.RemotePolicy == "" {
+		job.RemotePolicy = parseRemoteType(x.RemoteType)
+	}
+	job.RemoteRegions = x.RemoteRegions
+	job.Seniority = models.Seniority(strings.TrimSpace(x.Seniority))
+
+	// Report the first field that doesn't parse, after filling in the rest
+	var errs []error
+	var err error
+	job.ID, err = parseImportID(strings.TrimSpace(x.ID))
+	errs = append(errs, err)
+	job.SalaryMin, err = parseAmount("salarymin", strings.TrimSpace(x.SalaryMin))
+	errs = append(errs, err)
+	job.SalaryMax, err = parseAmount("salarymax", strings.TrimSpace(x.SalaryMax))
+	errs = append(errs, err)
+	for _, err := range errs {
+		if err != nil {
+			return job, err
+		}
+	}
+	return job, nil
+}
+
+// decodeXMLJobs reads jobs from an XML job feed
+func decodeXMLJobs(body io.Reader) ([]*importRow, error) {
+	var source xmlSource
+	if err := xml.NewDecoder(body).Decode(&source); err != nil {
+		return nil, err
+	}
+
+	rows := make([]*importRow, 0, len(source.Jobs))
+	for _, x := range source.Jobs {
+		job, err := x.importJob()
+		rows = append(rows, &importRow{Job: job, Err: err})
+	}
+	return rows, nil
+}
+
+// encodeXMLJobs writes jobs as an XML job feed
+func encodeXMLJobs(w io.Writer, jobs []*ImportJob) error {
+	source := &xmlSource{
+		XMLName:       xml.Name{Local: "source"},
+		LastBuildDate: time.Now().UTC().Format(time.RFC1123),
+		Jobs:          make([]*xmlJob, 0, len(jobs)),
+	}
+	for _, job := range jobs {
+		x := &xmlJob{
+			ReferenceNumber: job.ExternalRef,
+			Title:           xmlText{job.Title},
+			Company:         xmlText{job.Company},
+			Location:        xmlText{job.Location},
+			Description:     xmlText{job.Description},
+			Requirements:    xmlText{job.Requirements},
+			Salary:          job.SalaryRange,
+			SalaryCurrency:  job.SalaryCurrency,
+			PayPeriod:       string(job.PayPeriod),
+			JobType:         job.EmploymentType.Label(),
+			RemotePolicy:    string(job.RemotePolicy),
+			RemoteRegions:   job.RemoteRegions,
+			Seniority:       string(job.Seniority),
+			Skills:          job.Skills,
+			NiceToHave:      job.NiceToHaveSkills,
+			Email:           job.ContactEmail,
+			ExpirationDate:  job.ExpiresAt,
+			Status:          string(job.State),
+		}
+		if job.ID != 0 {
+			x.ID = strconv.Itoa(job.ID)
+		}
+		if job.SalaryMin != nil {
+			x.SalaryMin = strconv.FormatInt(*job.SalaryMin, 10)
+		}
+		if job.SalaryMax != nil {
+			x.SalaryMax = strconv.FormatInt(*job.SalaryMax, 10)
+		}
+		switch job.RemotePolicy {
+		case models.RemoteRemote:
+			x.RemoteType = "Fully remote"
+		case models.RemoteHybrid:
+			x.RemoteType = "Hybrid remote"
+		}
+		source.Jobs = append(source.Jobs, x)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(source)
+}
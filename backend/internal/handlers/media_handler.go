@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/media"
+	"openfirm/internal/models"
+)
+
+// maxMediaDescription is the longest alt text accepted for an upload
+const maxMediaDescription = 1500
+
+type MediaHandler struct {
+	activityPubService *activitypub.Service
+	mediaService       *models.MediaService
+	storage            media.Storage
+	cache              *media.Cache
+}
+
+func NewMediaHandler(activityPubService *activitypub.Service, mediaService *models.MediaService, storage media.Storage, cache *media.Cache) *MediaHandler {
+	return &MediaHandler{
+		activityPubService: activityPubService,
+		mediaService:       mediaService,
+		storage:            storage,
+		cache:              cache,
+	}
+}
+
+// MediaResponse is an uploaded attachment along with the IRI used to
+// attach it to a post
+type MediaResponse struct {
+	*models.MediaAttachment
+	IRI string `json:"iri"`
+}
+
+// newMediaKey returns a random storage key name
+func newMediaKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Upload accepts an image as the "file" field of a multipart form, with
+// optional alt text in "description"
+func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxImageSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	description := r.FormValue("description")
+	if utf8.RuneCountInString(description) > maxMediaDescription {
+		http.Error(w, "Description is too long", http.StatusBadRequest)
+		return
+	}
+
+	processed, err := media.ProcessImage(file)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		http.Error(w, "File is too large", http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, media.ErrUnsupportedType):
+		http.Error(w, "Unsupported file type", http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(w, "Failed to process file", http.StatusInternalServerError)
+		return
+	}
+
+	name, err := newMediaKey()
+	if err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	attachment := &models.MediaAttachment{
+		UserID:       userID,
+		StorageKey:   "attachments/" + name + media.Extension(processed.MediaType),
+		ThumbnailKey: "thumbnails/" + name + ".jpg",
+		MediaType:    processed.MediaType,
+		Size:         int64(len(processed.Data)),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		Blurhash:     processed.Blurhash,
+		Description:  description,
+	}
+	attachment.URL = h.storage.URL(attachment.StorageKey)
+	attachment.PreviewURL = h.storage.URL(attachment.ThumbnailKey)
+
+	ctx := r.Context()
+	if err := h.storage.Put(ctx, attachment.StorageKey, bytes.NewReader(processed.Data), attachment.Size, attachment.MediaType); err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := h.storage.Put(ctx, attachment.ThumbnailKey, bytes.NewReader(processed.Thumbnail), int64(len(processed.Thumbnail)), "image/jpeg"); err != nil {
+		h.deleteFiles(ctx, attachment)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.mediaService.CreateMedia(ctx, attachment); err != nil {
+		h.deleteFiles(ctx, attachment)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(MediaResponse{
+		MediaAttachment: attachment,
+		IRI:             h.activityPubService.MediaIRI(attachment.ID),
+	})
+}
+
+// deleteFiles removes the stored files of an upload that failed part way
+func (h *MediaHandler) deleteFiles(ctx context.Context, attachment *models.MediaAttachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if err := h.storage.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete media file %s: %v", key, err)
+		}
+	}
+}
+
+// Serve serves files from local media storage under /media/files/*
+func (h *MediaHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+
+	f, err := h.storage.Get(r.Context(), key)
+	if errors.Is(err, media.ErrNotFound) {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Keys are random and never reused, so files can be cached forever
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	io.Copy(w, f)
+}
+
+// Proxy serves a remote file from the media cache, so clients never load
+// it from the remote server
+func (h *MediaHandler) Proxy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	f, remote, err := h.cache.Open(r.Context(), id)
+	switch {
+	case errors.Is(err, media.ErrNotFound), errors.Is(err, media.ErrRemoteMediaUnavailable):
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", remote.MediaType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	io.Copy(w, f)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaTypeActivityJSON = "application/activity+json"
+	mediaTypeLDJSON       = "application/ld+json"
+	mediaTypeJSON         = "application/json"
+	mediaTypeHTML         = "text/html"
+
+	activityStreamsProfile = "https://www.w3.org/ns/activitystreams"
+)
+
+// activityOffers are the representations served for ActivityPub resources,
+// in order of preference when the client has no preference of its own
+var activityOffers = []string{mediaTypeActivityJSON, mediaTypeLDJSON, mediaTypeJSON, mediaTypeHTML}
+
+// mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	params    map[string]string
+	q         float64
+}
+
+// parseAccept parses an Accept header into its media ranges, skipping
+// malformed entries
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if qs, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(qs, 64); err == nil {
+				q = parsed
+			}
+			delete(params, "q")
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, params: params, q: q})
+	}
+	return ranges
+}
+
+// specificity ranks how closely a media range matches an offer, or returns
+// -1 if it doesn't match at all
+func (m mediaRange) specificity(offer string) int {
+	switch {
+	case m.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(m.mediaType, "/*"):
+		if strings.HasPrefix(offer, strings.TrimSuffix(m.mediaType, "*")) {
+			return 1
+		}
+		return -1
+	case m.mediaType != offer:
+		return -1
+	}
+
+	// JSON-LD is only ActivityStreams when the profile says so
+	if profile, ok := m.params["profile"]; ok && offer == mediaTypeLDJSON {
+		for _, p := range strings.Fields(profile) {
+			if p == activityStreamsProfile {
+				return 3
+			}
+		}
+		return -1
+	}
+	return 2
+}
+
+// negotiate picks the offer the client prefers according to its Accept
+// header. It returns an empty string when none of the offers is acceptable.
+func negotiate(r *http.Request, offers ...string) string {
+	ranges := parseAccept(r.Header.Get("Accept"))
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, specificity := 0.0, -1
+		for _, m := range ranges {
+			if s := m.specificity(offer); s > specificity {
+				q, specificity = m.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// isActivityPubMediaType reports whether a negotiated media type should be
+// answered with ActivityStreams JSON
+func isActivityPubMediaType(mediaType string) bool {
+	return mediaType == mediaTypeActivityJSON || mediaType == mediaTypeLDJSON || mediaType == mediaTypeJSON
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []mediaRange
+	}{
+		{name: "empty", header: "", want: nil},
+		{
+			name:   "single type",
+			header: "text/html",
+			want:   []mediaRange{{mediaType: "text/html", params: map[string]string{}, q: 1}},
+		},
+		{
+			name:   "quality values",
+			header: "application/json;q=0.5, text/html",
+			want: []mediaRange{
+				{mediaType: "application/json", params: map[string]string{}, q: 0.5},
+				{mediaType: "text/html", params: map[string]string{}, q: 1},
+			},
+		},
+		{
+			name:   "zero quality",
+			header: "text/html;q=0",
+			want:   []mediaRange{{mediaType: "text/html", params: map[string]string{}, q: 0}},
+		},
+		{
+			name:   "invalid quality counts as 1",
+			header: "text/html;q=high",
+			want:   []mediaRange{{mediaType: "text/html", params: map[string]string{}, q: 1}},
+		},
+		{
+			name:   "parameters kept and type lowercased",
+			header: `Application/LD+JSON; profile="https://www.w3.org/ns/activitystreams"`,
+			want: []mediaRange{{
+				mediaType: "application/ld+json",
+				params:    map[string]string{"profile": activityStreamsProfile},
+				q:         1,
+			}},
+		},
+		{
+			name:   "malformed entries skipped",
+			header: "text/, */*;q=0.1",
+			want:   []mediaRange{{mediaType: "*/*", params: map[string]string{}, q: 0.1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseAccept(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAccept(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "no header prefers ActivityStreams", accept: "", want: mediaTypeActivityJSON},
+		{name: "activity+json", accept: "application/activity+json", want: mediaTypeActivityJSON},
+		{
+			name:   "ld+json with the ActivityStreams profile",
+			accept: `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`,
+			want:   mediaTypeLDJSON,
+		},
+		{
+			name:   "ld+json with another profile",
+			accept: `application/ld+json; profile="https://example.com/profile"`,
+			want:   "",
+		},
+		{name: "ld+json without a profile", accept: "application/ld+json", want: mediaTypeLDJSON},
+		{name: "plain json", accept: "application/json", want: mediaTypeJSON},
+		{
+			name:   "browser",
+			accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			want:   mediaTypeHTML,
+		},
+		{name: "any type takes the first offer", accept: "*/*", want: mediaTypeActivityJSON},
+		{name: "subtype wildcard", accept: "text/*", want: mediaTypeHTML},
+		{name: "zero quality excludes an offer", accept: "text/html;q=0, */*", want: mediaTypeActivityJSON},
+		{
+			name:   "exact type beats a wildcard",
+			accept: "application/activity+json;q=0, application/*",
+			want:   mediaTypeLDJSON,
+		},
+		{name: "higher quality wins", accept: "text/html;q=0.5, */*;q=0.1", want: mediaTypeHTML},
+		{
+			name:   "first of equally specific ranges wins",
+			accept: "application/json;q=0.1, application/json;q=0.9, text/html;q=0.5",
+			want:   mediaTypeHTML,
+		},
+		{name: "nothing acceptable", accept: "image/png", want: ""},
+		{name: "everything refused", accept: "*/*;q=0", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/users/alice", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := negotiate(r, activityOffers...); got != tt.want {
+				t.Errorf("negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsActivityPubMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		want      bool
+	}{
+		{mediaTypeActivityJSON, true},
+		{mediaTypeLDJSON, true},
+		{mediaTypeJSON, true},
+		{mediaTypeHTML, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isActivityPubMediaType(tt.mediaType); got != tt.want {
+			t.Errorf("isActivityPubMediaType(%q) = %v, want %v", tt.mediaType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"openfirm/internal/models"
+)
+
+// notificationsPerPage is the page size of the notification list
+const notificationsPerPage = 20
+
+type NotificationHandler struct {
+	notificationService *models.NotificationService
+}
+
+func NewNotificationHandler(notificationService *models.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// List returns a page of the authenticated user's notifications, newest
+// first. With unread=true only unread ones are returned.
+func (h *NotificationHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	page := pageParam(r)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := h.notificationService.ListNotifications(r.Context(), userID, unreadOnly,
+		(page-1)*notificationsPerPage, notificationsPerPage)
+	if err != nil {
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// MarkRead marks the authenticated user's notifications up to and
+// including a given id as read
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var req struct {
+		UpTo int `json:"up_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UpTo < 1 {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.notificationService.MarkRead(r.Context(), userID, req.UpTo); err != nil {
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+)
+
+type ObjectHandler struct {
+	activityPubService *activitypub.Service
+	frontendURL        string
+}
+
+func NewObjectHandler(activityPubService *activitypub.Service, frontendURL string) *ObjectHandler {
+	return &ObjectHandler{
+		activityPubService: activityPubService,
+		frontendURL:        frontendURL,
+	}
+}
+
+// Post handles /posts/{id} requests, returning the Note to ActivityPub
+// clients and redirecting browsers to the post page
+func (h *ObjectHandler) Post(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+	if !isActivityPubMediaType(mediaType) {
+		http.Redirect(w, r, fmt.Sprintf("%s/posts/%d", h.frontendURL, postID), http.StatusSeeOther)
+		return
+	}
+
+	note, err := h.activityPubService.GetNote(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	writeActivity(w, mediaType, note)
+}
+
+// Job handles /jobs/{id} requests, returning the JobPosting to ActivityPub
+// clients and redirecting browsers to the job details page
+func (h *ObjectHandler) Job(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+	if !isActivityPubMediaType(mediaType) {
+		http.Redirect(w, r, fmt.Sprintf("%s/jobs/%d", h.frontendURL, jobID), http.StatusSeeOther)
+		return
+	}
+
+	jobPosting, err := h.activityPubService.GetJobPosting(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	writeActivity(w, mediaType, jobPosting)
+}
+
+// schemaOffers are the representations of a job's schema.org JobPosting:
+// the JSON-LD document, or an HTML snippet to embed in the job page
+var schemaOffers = []string{mediaTypeLDJSON, mediaTypeJSON, mediaTypeHTML}
+
+// JobSchema handles /jobs/{id}/schema requests, returning the schema.org
+// JobPosting of a job for search engines and job aggregators. Clients
+// preferring HTML, or asking for format=snippet, get a script element to
+// embed in the job page.
+func (h *ObjectHandler) JobSchema(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, schemaOffers...)
+	if r.URL.Query().Get("format") == "snippet" {
+		mediaType = mediaTypeHTML
+	}
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	posting, err := h.activityPubService.GetSchemaJobPosting(r.Context(), jobID,
+		fmt.Sprintf("%s/jobs/%d", h.frontendURL, jobID))
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	// json escapes <, > and &, so the document can't end the script
+	// element early
+	body, err := json.Marshal(posting)
+	if err != nil {
+		http.Error(w, "Failed to encode job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	switch mediaType {
+	case mediaTypeHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<script type=\"application/ld+json\">%s</script>\n", body)
+	default:
+		w.Header().Set("Content-Type", mediaType)
+		w.Write(body)
+	}
+}
+
+// writeActivity encodes an ActivityStreams object as the response body,
+// labelled with the media type the client negotiated
+func writeActivity(w http.ResponseWriter, mediaType string, object interface{}) {
+	switch mediaType {
+	case mediaTypeLDJSON:
+		w.Header().Set("Content-Type", mediaTypeLDJSON+`; profile="`+activityStreamsProfile+`"`)
+	case mediaTypeJSON:
+		w.Header().Set("Content-Type", mediaTypeJSON)
+	default:
+		w.Header().Set("Content-Type", mediaTypeActivityJSON)
+	}
+	json.NewEncoder(w).Encode(object)
+}
+
+// PostReplies handles /posts/{id}/replies requests
+func (h *ObjectHandler) PostReplies(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	h.replies(w, r, h.activityPubService.PostIRI(postID))
+}
+
+// JobReplies handles /jobs/{id}/replies requests
+func (h *ObjectHandler) JobReplies(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	h.replies(w, r, h.activityPubService.JobIRI(jobID))
+}
+
+func (h *ObjectHandler) replies(w http.ResponseWriter, r *http.Request, objectIRI string) {
+	mediaType := negotiate(r, mediaTypeActivityJSON, mediaTypeLDJSON, mediaTypeJSON)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+
+	replies, err := h.activityPubService.GetReplies(r.Context(), objectIRI)
+	if err != nil {
+		http.Error(w, "Failed to get replies", http.StatusInternalServerError)
+		return
+	}
+
+	writeActivity(w, mediaType, replies)
+}
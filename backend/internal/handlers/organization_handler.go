@@ -0,0 +1,578 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/mail"
+	"openfirm/internal/models"
+	"openfirm/internal/verify"
+)
+
+// organizationJobsPerPage is the page size of an organization's jobs
+const organizationJobsPerPage = 20
+
+type OrganizationHandler struct {
+	orgService         *models.OrganizationService
+	jobService         *models.JobService
+	jobDetailsService  *models.JobDetailsService
+	userService        *models.UserService
+	activityPubService *activitypub.Service
+	checker            *verify.Checker
+	mailer             mail.Mailer
+}
+
+// NewOrganizationHandler creates an OrganizationHandler. checker looks for
+// domain verification tokens and mailer sends emailed verification codes.
+func NewOrganizationHandler(orgService *models.OrganizationService, jobService *models.JobService, jobDetailsService *models.JobDetailsService, userService *models.UserService, activityPubService *activitypub.Service, checker *verify.Checker, mailer mail.Mailer) *OrganizationHandler {
+	return &OrganizationHandler{
+		orgService:         orgService,
+		jobService:         jobService,
+		jobDetailsService:  jobDetailsService,
+		userService:        userService,
+		activityPubService: activityPubService,
+		checker:            checker,
+		mailer:             mailer,
+	}
+}
+
+// OrganizationProfile is the public profile of an organization. Actor is
+// the IRI fediverse users follow to hear about its new openings.
+type OrganizationProfile struct {
+	*models.Organization
+	Actor     string                       `json:"actor"`
+	Domains   []*models.OrganizationDomain `json:"domains"`
+	Followers int                          `json:"followers"`
+}
+
+// DomainChallenge is a domain with what to publish to verify it: DNSRecord
+// as a TXT record of the domain, or Token alone in a file at WellKnownURL
+type DomainChallenge struct {
+	*models.OrganizationDomain
+	DNSRecord    string `json:"dns_record,omitempty"`
+	WellKnownURL string `json:"well_known_url,omitempty"`
+}
+
+// VerifyDomainRequest checks a domain by dns or well_known, or emails a
+// code to Email, one of the admin addresses at the domain
+type VerifyDomainRequest struct {
+	Method models.VerificationMethod `json:"method"`
+	Email  string                    `json:"email"`
+}
+
+// SetMemberRequest adds a user to an organization or changes their role
+type SetMemberRequest struct {
+	Username string         `json:"username"`
+	Role     models.OrgRole `json:"role"`
+}
+
+// memberOrganization returns the organization in the URL if the
+// authenticated user's role in it is allowed, e.g. by
+// models.OrgRole.CanManage, writing an error otherwise
+func (h *OrganizationHandler) memberOrganization(w http.ResponseWriter, r *http.Request, allowed func(models.OrgRole) bool) (*models.Organization, bool) {
+	userID := r.Context().Value("userID").(int)
+
+	org, err := h.orgService.GetOrganizationBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	role, err := h.orgService.GetRole(r.Context(), org.ID, userID)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed(role) {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return nil, false
+	}
+	return org, true
+}
+
+// Create creates an organization owned by the authenticated user
+func (h *OrganizationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var org models.Organization
+	if err := json.NewDecoder(r.Body).Decode(&org); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	org.Normalize()
+	if err := org.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.orgService.CreateOrganization(r.Context(), &org, userID)
+	switch {
+	case errors.Is(err, models.ErrSlugTaken):
+		http.Error(w, "Slug is taken", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to create organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&org)
+}
+
+// Get returns the public profile of an organization
+func (h *OrganizationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	org, err := h.orgService.GetOrganizationBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	domains, err := h.orgService.ListDomains(r.Context(), org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch domains", http.StatusInternalServerError)
+		return
+	}
+	verified := make([]*models.OrganizationDomain, 0, len(domains))
+	for _, d := range domains {
+		if d.VerifiedAt != nil {
+			d.Token = ""
+			verified = append(verified, d)
+		}
+	}
+	followers, err := h.orgService.CountFollowers(r.Context(), org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch followers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&OrganizationProfile{
+		Organization: org,
+		Actor:        h.activityPubService.OrganizationIRI(org.Slug),
+		Domains:      verified,
+		Followers:    followers,
+	})
+}
+
+// Update saves an organization's profile. Only owners can change it, and
+// the slug stays the same.
+func (h *OrganizationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return
+	}
+
+	var req models.Organization
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.ID = org.ID
+	req.Slug = org.Slug
+	req.Verified = org.Verified
+	req.CreatedAt = org.CreatedAt
+	req.Normalize()
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orgService.UpdateOrganization(r.Context(), &req); err != nil {
+		http.Error(w, "Failed to update organization", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&req)
+}
+
+// Mine returns the organizations the authenticated user belongs to and
+// their role in each
+func (h *OrganizationHandler) Mine(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	memberships, err := h.orgService.ListMemberships(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch organizations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(memberships)
+}
+
+// Members returns the members of an organization to its members
+func (h *OrganizationHandler) Members(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanView)
+	if !ok {
+		return
+	}
+
+	members, err := h.orgService.ListMembers(r.Context(), org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// SetMember adds a user to an organization or changes their role. Only
+// owners can manage members.
+func (h *OrganizationHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return
+	}
+
+	var req SetMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !req.Role.Valid() {
+		http.Error(w, "Role must be owner, recruiter or viewer", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userService.GetUserByUsername(r.Context(), req.Username)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = h.orgService.SetMember(r.Context(), org.ID, user.ID, req.Role)
+	switch {
+	case errors.Is(err, models.ErrLastOwner):
+		http.Error(w, "Organization must keep an owner", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to save member", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := h.orgService.ListMembers(r.Context(), org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch members", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// RemoveMember removes a user from an organization. Owners can remove
+// anyone and members can leave.
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	memberID, err := strconv.Atoi(chi.URLParam(r, "userId"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	allowed := models.OrgRole.CanManage
+	if memberID == userID {
+		allowed = models.OrgRole.CanView
+	}
+	org, ok := h.memberOrganization(w, r, allowed)
+	if !ok {
+		return
+	}
+
+	err = h.orgService.RemoveMember(r.Context(), org.ID, memberID)
+	switch {
+	case errors.Is(err, models.ErrLastOwner):
+		http.Error(w, "Organization must keep an owner", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Domains returns the domains an organization claims with how to verify
+// those that aren't yet. Only owners can manage domains.
+func (h *OrganizationHandler) Domains(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return
+	}
+	h.writeDomains(w, r, org)
+}
+
+// writeDomains responds with the domains of an organization and their
+// verification challenges
+func (h *OrganizationHandler) writeDomains(w http.ResponseWriter, r *http.Request, org *models.Organization) {
+	domains, err := h.orgService.ListDomains(r.Context(), org.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch domains", http.StatusInternalServerError)
+		return
+	}
+
+	challenges := make([]*DomainChallenge, 0, len(domains))
+	for _, d := range domains {
+		challenges = append(challenges, newDomainChallenge(d))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(challenges)
+}
+
+// newDomainChallenge describes how to verify a domain, if it isn't yet
+func newDomainChallenge(d *models.OrganizationDomain) *DomainChallenge {
+	if d.VerifiedAt != nil {
+		return &DomainChallenge{OrganizationDomain: d}
+	}
+	return &DomainChallenge{
+		OrganizationDomain: d,
+		DNSRecord:          verify.RecordPrefix + d.Token,
+		WellKnownURL:       "https://" + d.Domain + verify.WellKnownPath,
+	}
+}
+
+// AddDomain claims a domain for an organization. Only owners can manage
+// domains.
+func (h *OrganizationHandler) AddDomain(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	domain, err := models.NormalizeDomain(req.Domain)
+	if err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orgService.AddDomain(r.Context(), org.ID, domain); err != nil {
+		http.Error(w, "Failed to add domain", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeDomains(w, r, org)
+}
+
+// ownerDomain returns the organization and domain in the URL if the
+// authenticated user owns the organization and it claims the domain,
+// writing an error otherwise
+func (h *OrganizationHandler) ownerDomain(w http.ResponseWriter, r *http.Request) (*models.Organization, *models.OrganizationDomain, bool) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return nil, nil, false
+	}
+
+	name, err := models.NormalizeDomain(chi.URLParam(r, "domain"))
+	if err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	domain, err := h.orgService.GetDomain(r.Context(), org.ID, name)
+	switch {
+	case errors.Is(err, models.ErrDomainNotFound):
+		http.Error(w, "Domain not found", http.StatusNotFound)
+		return nil, nil, false
+	case err != nil:
+		http.Error(w, "Failed to fetch domain", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return org, domain, true
+}
+
+// writeVerifyError responds to a failed attempt to mark a domain verified
+func writeVerifyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrDomainVerified):
+		http.Error(w, "Domain is already verified", http.StatusConflict)
+	case errors.Is(err, models.ErrDomainTaken):
+		http.Error(w, "Domain is verified by another organization", http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidCode):
+		http.Error(w, "Invalid or expired code", http.StatusUnprocessableEntity)
+	case errors.Is(err, models.ErrTooManyCodes):
+		http.Error(w, "Too many verification codes requested, try again later", http.StatusTooManyRequests)
+	default:
+		http.Error(w, "Failed to verify domain", http.StatusInternalServerError)
+	}
+}
+
+// VerifyDomain proves an organization controls one of its domains. The dns
+// and well_known methods check for the domain's token right away; email
+// sends a code to confirm with ConfirmDomain.
+func (h *OrganizationHandler) VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	org, domain, ok := h.ownerDomain(w, r)
+	if !ok {
+		return
+	}
+
+	var req VerifyDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if domain.VerifiedAt != nil {
+		http.Error(w, "Domain is already verified", http.StatusConflict)
+		return
+	}
+
+	var err error
+	switch req.Method {
+	case models.VerifyDNS:
+		err = h.checker.CheckDNS(r.Context(), domain.Domain, domain.Token)
+	case models.VerifyWellKnown:
+		err = h.checker.CheckWellKnown(r.Context(), domain.Domain, domain.Token)
+	case models.VerifyEmail:
+		h.sendVerificationEmail(w, r, org, domain, req.Email)
+		return
+	default:
+		http.Error(w, "Method must be dns, well_known or email", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, verify.ErrNotVerified) {
+		http.Error(w, "Verification token not found", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to check domain %s of organization %d: %v", domain.Domain, org.ID, err)
+		http.Error(w, "Failed to check domain", http.StatusBadGateway)
+		return
+	}
+
+	if err := h.orgService.MarkDomainVerified(r.Context(), org.ID, domain.Domain, req.Method); err != nil {
+		writeVerifyError(w, err)
+		return
+	}
+	h.writeDomains(w, r, org)
+}
+
+// sendVerificationEmail emails a verification code for a domain to an
+// admin address at it
+func (h *OrganizationHandler) sendVerificationEmail(w http.ResponseWriter, r *http.Request, org *models.Organization, domain *models.OrganizationDomain, email string) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	allowed := false
+	for _, local := range models.VerificationEmailLocalParts {
+		if email == local+"@"+domain.Domain {
+			allowed = true
+		}
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("Email must be admin, administrator, hostmaster, postmaster or webmaster@%s", domain.Domain), http.StatusBadRequest)
+		return
+	}
+
+	code, err := h.orgService.StartEmailVerification(r.Context(), org.ID, domain.Domain)
+	if err != nil {
+		writeVerifyError(w, err)
+		return
+	}
+
+	body := fmt.Sprintf("Someone asked to verify that %s belongs to the organization %s.\n\n"+
+		"If that was you, enter this code to confirm: %s\n\n"+
+		"The code expires in 30 minutes. If you didn't ask for it, you can ignore this email.\n",
+		domain.Domain, org.Name, code)
+	err = h.mailer.Send(r.Context(), email, "Verify "+domain.Domain, body)
+	if errors.Is(err, mail.ErrDisabled) {
+		http.Error(w, "Email verification is not available", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to send verification email for %s: %v", domain.Domain, err)
+		http.Error(w, "Failed to send email", http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmDomain verifies a domain with the code emailed by VerifyDomain
+func (h *OrganizationHandler) ConfirmDomain(w http.ResponseWriter, r *http.Request) {
+	org, domain, ok := h.ownerDomain(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orgService.ConfirmEmailVerification(r.Context(), org.ID, domain.Domain, req.Code); err != nil {
+		writeVerifyError(w, err)
+		return
+	}
+	h.writeDomains(w, r, org)
+}
+
+// RemoveDomain drops a domain an organization claims
+func (h *OrganizationHandler) RemoveDomain(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.memberOrganization(w, r, models.OrgRole.CanManage)
+	if !ok {
+		return
+	}
+
+	domain, err := models.NormalizeDomain(chi.URLParam(r, "domain"))
+	if err != nil {
+		http.Error(w, "Invalid domain", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.orgService.RemoveDomain(r.Context(), org.ID, domain); err != nil {
+		http.Error(w, "Failed to remove domain", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Jobs returns a page of an organization's open jobs, newest first
+func (h *OrganizationHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	page := pageParam(r)
+
+	org, err := h.orgService.GetOrganizationBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	ids, err := h.orgService.ListJobIDs(r.Context(), org.ID,
+		(page-1)*organizationJobsPerPage, organizationJobsPerPage)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	jobs, err := h.jobService.GetJobs(r.Context(), ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	withDetails, err := h.jobDetailsService.WithDetails(r.Context(), jobs...)
+	if err != nil {
+		http.Error(w, "Failed to fetch job details", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(withDetails)
+}
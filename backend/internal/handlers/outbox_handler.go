@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
 )
 
 type OutboxHandler struct {
@@ -87,6 +91,14 @@ func (h *OutboxHandler) Post(w http.ResponseWriter, r *http.Request) {
 
 	// Process the activity
 	result, err := h.activityPubService.HandleOutbox(r.Context(), username, activity)
+	if errors.Is(err, models.ErrMediaUnavailable) {
+		http.Error(w, "Invalid attachment", http.StatusUnprocessableEntity)
+		return
+	}
+	if errors.Is(err, models.ErrInvalidPoll) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to process activity", http.StatusInternalServerError)
 		return
@@ -138,11 +150,53 @@ func (h *OutboxHandler) validateOutboxActivity(activity map[string]interface{})
 			"Note":        true,
 			"Article":     true,
 			"JobPosting": true,
+			"Question":    true,
 		}
 
 		if !supportedObjectTypes[objType] {
 			return fmt.Errorf("unsupported object type: %s", objType)
 		}
+
+		if attachments, ok := obj["attachment"].([]interface{}); ok && len(attachments) > models.MaxPostAttachments {
+			return fmt.Errorf("too many attachments: at most %d are allowed", models.MaxPostAttachments)
+		}
+
+		if objType == "Question" {
+			return h.validateQuestion(obj)
+		}
+	}
+
+	return nil
+}
+
+// validateQuestion validates the choices and end time of a poll
+func (h *OutboxHandler) validateQuestion(question map[string]interface{}) error {
+	oneOf, hasOneOf := question["oneOf"].([]interface{})
+	anyOf, hasAnyOf := question["anyOf"].([]interface{})
+	if hasOneOf == hasAnyOf {
+		return fmt.Errorf("question must have exactly one of oneOf or anyOf")
+	}
+
+	choices := oneOf
+	if hasAnyOf {
+		choices = anyOf
+	}
+	if len(choices) < models.MinPollOptions || len(choices) > models.MaxPollOptions {
+		return fmt.Errorf("question must have between %d and %d choices", models.MinPollOptions, models.MaxPollOptions)
+	}
+	for _, choice := range choices {
+		option, ok := choice.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid question choice")
+		}
+		if name, _ := option["name"].(string); name == "" {
+			return fmt.Errorf("question choice must have a name")
+		}
+	}
+
+	endTime, _ := question["endTime"].(string)
+	if _, err := time.Parse(time.RFC3339, endTime); err != nil {
+		return fmt.Errorf("invalid endTime for Question")
 	}
 
 	return nil
@@ -168,7 +222,7 @@ func (h *OutboxHandler) handleCreateActivity(ctx context.Context, activity map[s
 
 	// Process based on object type
 	switch objType := obj["type"].(string); objType {
-	case "Note":
+	case "Note", "Question":
 		return h.handleCreateNote(ctx, obj)
 	case "Article":
 		return h.handleCreateArticle(ctx, obj)
@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/media"
+	"openfirm/internal/models"
+)
+
+const (
+	// maxReasonLength is the longest reason accepted for moving an application
+	maxReasonLength = 500
+	// maxBulkApplications is how many applications a bulk action can change
+	maxBulkApplications = 100
+)
+
+type PipelineHandler struct {
+	jobService          *models.JobService
+	pipelineService     *models.PipelineService
+	formService         *models.ApplicationFormService
+	notificationService *models.NotificationService
+	orgService          *models.OrganizationService
+	signer              *media.URLSigner
+}
+
+func NewPipelineHandler(jobService *models.JobService, pipelineService *models.PipelineService, formService *models.ApplicationFormService, notificationService *models.NotificationService, orgService *models.OrganizationService, signer *media.URLSigner) *PipelineHandler {
+	return &PipelineHandler{
+		jobService:          jobService,
+		pipelineService:     pipelineService,
+		formService:         formService,
+		notificationService: notificationService,
+		orgService:          orgService,
+		signer:              signer,
+	}
+}
+
+// MoveApplicationRequest moves an application to the stage given by its key
+type MoveApplicationRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// BulkApplicationRequest applies an action to several applications of a
+// job. Action is move, which needs a stage, or reject.
+type BulkApplicationRequest struct {
+	ApplicationIDs []int  `json:"application_ids"`
+	Action         string `json:"action"`
+	Stage          string `json:"stage"`
+	Reason         string `json:"reason"`
+}
+
+// BulkApplicationResult lists the applications a bulk action moved and why
+// the others could not be
+type BulkApplicationResult struct {
+	Moved  []int          `json:"moved"`
+	Failed map[int]string `json:"failed"`
+}
+
+// ApplicationDetail is an application with what the applicant sent and its
+// history, notes and ratings. Documents come with time-limited links.
+type ApplicationDetail struct {
+	*models.JobApplication
+	Resume  *DocumentLink               `json:"resume"`
+	Answers []*AnswerResponse           `json:"answers"`
+	Events  []*models.ApplicationEvent  `json:"events"`
+	Notes   []*models.ApplicationNote   `json:"notes"`
+	Ratings []*models.ApplicationRating `json:"ratings"`
+}
+
+// AnswerResponse is an answer with a link to its document, if any
+type AnswerResponse struct {
+	*models.ApplicationAnswer
+	Document *DocumentLink `json:"document,omitempty"`
+}
+
+// memberJob returns the job in the URL if the authenticated user's role for
+// it is allowed, e.g. by models.OrgRole.CanEdit, writing an error otherwise
+func (h *PipelineHandler) memberJob(w http.ResponseWriter, r *http.Request, allowed func(models.OrgRole) bool) (*models.Job, bool) {
+	userID := r.Context().Value("userID").(int)
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	role, err := h.orgService.JobRole(r.Context(), job, userID)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !allowed(role) {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return nil, false
+	}
+	return job, true
+}
+
+// memberApplication returns the ID of the application in the URL if it
+// belongs to a job memberJob allows, writing an error otherwise
+func (h *PipelineHandler) memberApplication(w http.ResponseWriter, r *http.Request, allowed func(models.OrgRole) bool) (*models.Job, int, bool) {
+	job, ok := h.memberJob(w, r, allowed)
+	if !ok {
+		return nil, 0, false
+	}
+
+	applicationID, err := strconv.Atoi(chi.URLParam(r, "applicationId"))
+	if err != nil {
+		http.Error(w, "Invalid application ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	_, err = h.pipelineService.ApplicationStage(r.Context(), job.ID, applicationID)
+	switch {
+	case errors.Is(err, models.ErrApplicationNotFound):
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return nil, 0, false
+	case err != nil:
+		http.Error(w, "Failed to fetch application", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	return job, applicationID, true
+}
+
+// Get returns the stages of a job's pipeline with how many applications are
+// in each
+func (h *PipelineHandler) Get(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.memberJob(w, r, models.OrgRole.CanView)
+	if !ok {
+		return
+	}
+
+	pipeline, err := h.pipelineService.GetPipeline(r.Context(), job.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch pipeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pipeline)
+}
+
+// Update replaces the active stages of a job's pipeline, given in order as
+// keys and names. Hired, rejected and withdrawn always end the pipeline.
+func (h *PipelineHandler) Update(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.memberJob(w, r, models.OrgRole.CanEdit)
+	if !ok {
+		return
+	}
+
+	var req []*models.PipelineStage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	pipeline, err := h.pipelineService.SetPipeline(r.Context(), job.ID, req)
+	switch {
+	case errors.Is(err, models.ErrInvalidPipeline):
+		http.Error(w, "Stages need unique lowercase keys and names", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrStageInUse):
+		http.Error(w, "Cannot remove a stage that still has applications", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update pipeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pipeline)
+}
+
+// GetApplication returns an application to a job with its history, notes
+// and ratings
+func (h *PipelineHandler) GetApplication(w http.ResponseWriter, r *http.Request) {
+	job, applicationID, ok := h.memberApplication(w, r, models.OrgRole.CanView)
+	if !ok {
+		return
+	}
+
+	applications, err := h.jobService.GetJobApplications(r.Context(), job.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch application", http.StatusInternalServerError)
+		return
+	}
+
+	detail := &ApplicationDetail{}
+	for _, application := range applications {
+		if application.ID == applicationID {
+			detail.JobApplication = application
+		}
+	}
+	if detail.JobApplication == nil {
+		http.Error(w, "Application not found", http.StatusNotFound)
+		return
+	}
+
+	resume, err := h.formService.GetResume(r.Context(), applicationID)
+	if err != nil {
+		http.Error(w, "Failed to fetch resume", http.StatusInternalServerError)
+		return
+	}
+	detail.Resume = newDocumentLink(h.signer, resume)
+
+	answers, err := h.formService.ListAnswers(r.Context(), applicationID)
+	if err != nil {
+		http.Error(w, "Failed to fetch answers", http.StatusInternalServerError)
+		return
+	}
+	detail.Answers = make([]*AnswerResponse, 0, len(answers))
+	for _, answer := range answers {
+		detail.Answers = append(detail.Answers, &AnswerResponse{
+			ApplicationAnswer: answer,
+			Document:          newDocumentLink(h.signer, answer.Document),
+		})
+	}
+
+	if detail.Events, err = h.pipelineService.ListEvents(r.Context(), applicationID); err != nil {
+		http.Error(w, "Failed to fetch application history", http.StatusInternalServerError)
+		return
+	}
+	if detail.Notes, err = h.pipelineService.ListNotes(r.Context(), applicationID); err != nil {
+		http.Error(w, "Failed to fetch notes", http.StatusInternalServerError)
+		return
+	}
+	if detail.Ratings, err = h.pipelineService.ListRatings(r.Context(), applicationID); err != nil {
+		http.Error(w, "Failed to fetch ratings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// AddNote adds an internal note to an application. Applicants never see
+// notes.
+func (h *PipelineHandler) AddNote(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	_, applicationID, ok := h.memberApplication(w, r, models.OrgRole.CanEdit)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	body := strings.TrimSpace(req.Body)
+	if body == "" || utf8.RuneCountInString(body) > models.MaxNoteLength {
+		http.Error(w, "Note must be between 1 and 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	note := &models.ApplicationNote{ApplicationID: applicationID, AuthorID: &userID, Body: body}
+	if err := h.pipelineService.AddNote(r.Context(), note); err != nil {
+		http.Error(w, "Failed to add note", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// Rate sets the authenticated user's rating of an applicant, from 1 to 5
+func (h *PipelineHandler) Rate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	_, applicationID, ok := h.memberApplication(w, r, models.OrgRole.CanEdit)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Rating int `json:"rating"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Rating < 1 || req.Rating > 5 {
+		http.Error(w, "Rating must be between 1 and 5", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.pipelineService.RateApplication(r.Context(), applicationID, userID, req.Rating); err != nil {
+		http.Error(w, "Failed to save rating", http.StatusInternalServerError)
+		return
+	}
+
+	ratings, err := h.pipelineService.ListRatings(r.Context(), applicationID)
+	if err != nil {
+		http.Error(w, "Failed to fetch ratings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ratings)
+}
+
+// Bulk moves or rejects several applications to a job at once. Each is
+// moved on its own, so the ones that can't be moved are reported without
+// holding back the rest.
+func (h *PipelineHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	job, ok := h.memberJob(w, r, models.OrgRole.CanEdit)
+	if !ok {
+		return
+	}
+
+	var req BulkApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.ApplicationIDs) == 0 || len(req.ApplicationIDs) > maxBulkApplications {
+		http.Error(w, "Between 1 and 100 applications required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Reason) > maxReasonLength {
+		http.Error(w, "Reason is too long", http.StatusBadRequest)
+		return
+	}
+
+	stage := req.Stage
+	switch req.Action {
+	case "move":
+		if stage == "" {
+			http.Error(w, "Stage required", http.StatusBadRequest)
+			return
+		}
+	case "reject":
+		stage = "rejected"
+	default:
+		http.Error(w, "Action must be move or reject", http.StatusBadRequest)
+		return
+	}
+
+	pipeline, err := h.pipelineService.GetPipeline(r.Context(), job.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch pipeline", http.StatusInternalServerError)
+		return
+	}
+
+	result := &BulkApplicationResult{Moved: []int{}, Failed: map[int]string{}}
+	reason := strings.TrimSpace(req.Reason)
+	for _, applicationID := range req.ApplicationIDs {
+		event, err := h.pipelineService.MoveApplication(r.Context(), job.ID, applicationID, userID, stage, reason)
+		switch {
+		case errors.Is(err, models.ErrInvalidStage):
+			http.Error(w, "Unknown stage", http.StatusBadRequest)
+			return
+		case errors.Is(err, models.ErrApplicationNotFound), errors.Is(err, models.ErrStageTransition):
+			result.Failed[applicationID] = err.Error()
+		case err != nil:
+			http.Error(w, "Failed to update applications", http.StatusInternalServerError)
+			return
+		default:
+			result.Moved = append(result.Moved, applicationID)
+			notifyApplicant(r.Context(), h.notificationService, job, pipeline, event)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// notifyApplicant tells an applicant their application to a job moved to
+// another stage
+func notifyApplicant(ctx context.Context, notificationService *models.NotificationService, job *models.Job, pipeline models.Pipeline, event *models.ApplicationEvent) {
+	name := event.ToStage
+	if stage := pipeline.Stage(event.ToStage); stage != nil {
+		name = stage.Name
+	}
+
+	if err := notificationService.CreateNotification(ctx, &models.Notification{
+		UserID:  event.ApplicantID,
+		Kind:    models.NotificationApplicationStatus,
+		JobID:   &job.ID,
+		Message: fmt.Sprintf("Your application for %q is now at %s", job.Title, name),
+	}); err != nil {
+		log.Printf("Failed to notify applicant of application %d: %v", event.ApplicationID, err)
+	}
+}
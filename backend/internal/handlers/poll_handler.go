@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+type PollHandler struct {
+	activityPubService *activitypub.Service
+	pollService        *models.PollService
+}
+
+func NewPollHandler(activityPubService *activitypub.Service, pollService *models.PollService) *PollHandler {
+	return &PollHandler{
+		activityPubService: activityPubService,
+		pollService:        pollService,
+	}
+}
+
+type VoteRequest struct {
+	Choices []int `json:"choices"`
+}
+
+// Get returns the poll attached to a post with its current tallies
+func (h *PollHandler) Get(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	poll, err := h.pollService.GetPollByPost(r.Context(), postID)
+	if err != nil {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// Vote records the authenticated user's choices in a poll
+func (h *PollHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	var req VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	poll, err := h.activityPubService.Vote(r.Context(), userID, postID, req.Choices)
+	switch {
+	case errors.Is(err, models.ErrInvalidChoice):
+		http.Error(w, "Invalid choice", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrPollClosed):
+		http.Error(w, "Poll has ended", http.StatusConflict)
+		return
+	case errors.Is(err, models.ErrAlreadyVoted):
+		http.Error(w, "Already voted", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/models"
+)
+
+// recommendationsPerPage is the page size of recommendations
+const recommendationsPerPage = 20
+
+type RecommendationHandler struct {
+	recommendationService *models.RecommendationService
+	candidateService      *models.CandidateService
+	jobService            *models.JobService
+	jobDetailsService     *models.JobDetailsService
+	userService           *models.UserService
+	skillService          *models.SkillService
+	orgService            *models.OrganizationService
+}
+
+func NewRecommendationHandler(recommendationService *models.RecommendationService, candidateService *models.CandidateService, jobService *models.JobService, jobDetailsService *models.JobDetailsService, userService *models.UserService, skillService *models.SkillService, orgService *models.OrganizationService) *RecommendationHandler {
+	return &RecommendationHandler{
+		recommendationService: recommendationService,
+		candidateService:      candidateService,
+		jobService:            jobService,
+		jobDetailsService:     jobDetailsService,
+		userService:           userService,
+		skillService:          skillService,
+		orgService:            orgService,
+	}
+}
+
+// JobRecommendation is a recommended job with how well it fits
+type JobRecommendation struct {
+	Job *models.JobWithDetails `json:"job"`
+	models.MatchScore
+}
+
+// CandidateProfile is the public part of a suggested candidate's profile
+type CandidateProfile struct {
+	Username    string              `json:"username"`
+	DisplayName string              `json:"display_name"`
+	AvatarURL   string              `json:"avatar_url,omitempty"`
+	Bio         string              `json:"bio,omitempty"`
+	Location    string              `json:"location,omitempty"`
+	Seniority   models.Seniority    `json:"seniority,omitempty"`
+	Skills      []*models.UserSkill `json:"skills"`
+}
+
+// CandidateSuggestion is a suggested candidate with how well they fit
+type CandidateSuggestion struct {
+	User *CandidateProfile `json:"user"`
+	models.MatchScore
+}
+
+// Jobs returns a page of jobs recommended to the authenticated user, with
+// the score of each factor
+func (h *RecommendationHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	page := pageParam(r)
+
+	matches, err := h.recommendationService.RecommendJobs(r.Context(), userID,
+		(page-1)*recommendationsPerPage, recommendationsPerPage)
+	if err != nil {
+		http.Error(w, "Failed to fetch recommendations", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]int, len(matches))
+	for i, match := range matches {
+		ids[i] = match.JobID
+	}
+	jobs, err := h.jobService.GetJobs(r.Context(), ids)
+	if err != nil {
+		http.Error(w, "Failed to fetch recommendations", http.StatusInternalServerError)
+		return
+	}
+	withDetails, err := h.jobDetailsService.WithDetails(r.Context(), jobs...)
+	if err != nil {
+		http.Error(w, "Failed to fetch recommendations", http.StatusInternalServerError)
+		return
+	}
+
+	scores := make(map[int]models.MatchScore, len(matches))
+	for _, match := range matches {
+		scores[match.JobID] = match.MatchScore
+	}
+	recommendations := make([]*JobRecommendation, 0, len(withDetails))
+	for _, job := range withDetails {
+		recommendations = append(recommendations, &JobRecommendation{Job: job, MatchScore: scores[job.ID]})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recommendations)
+}
+
+// Candidates returns a page of discoverable users suggested for a job. Only
+// those who can edit the job can see them.
+func (h *RecommendationHandler) Candidates(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	page := pageParam(r)
+
+	job, err := h.jobService.GetJob(r.Context(), jobID)
+	if err != nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	role, err := h.orgService.JobRole(r.Context(), job, userID)
+	if err != nil {
+		http.Error(w, "Failed to check permissions", http.StatusInternalServerError)
+		return
+	}
+	if !role.CanEdit() {
+		http.Error(w, "Unauthorized", http.StatusForbidden)
+		return
+	}
+
+	matches, err := h.recommendationService.SuggestCandidates(r.Context(), jobID, job.PostedBy,
+		(page-1)*recommendationsPerPage, recommendationsPerPage)
+	if err != nil {
+		http.Error(w, "Failed to fetch candidates", http.StatusInternalServerError)
+		return
+	}
+
+	suggestions := make([]*CandidateSuggestion, 0, len(matches))
+	for _, match := range matches {
+		profile, err := h.candidateProfile(r, match.UserID)
+		if err != nil {
+			http.Error(w, "Failed to fetch candidates", http.StatusInternalServerError)
+			return
+		}
+		suggestions = append(suggestions, &CandidateSuggestion{User: profile, MatchScore: match.MatchScore})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suggestions)
+}
+
+// candidateProfile loads what posters see of a suggested candidate
+func (h *RecommendationHandler) candidateProfile(r *http.Request, userID int) (*CandidateProfile, error) {
+	user, err := h.userService.GetUserByID(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := h.candidateService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	skills, err := h.skillService.ListUserSkills(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CandidateProfile{
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   user.AvatarURL,
+		Bio:         user.Bio,
+		Location:    prefs.Location,
+		Seniority:   prefs.Seniority,
+		Skills:      skills,
+	}, nil
+}
+
+// Preferences returns what the authenticated user is looking for in a job
+func (h *RecommendationHandler) Preferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	prefs, err := h.candidateService.GetPreferences(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// UpdatePreferences replaces what the authenticated user is looking for in
+// a job, including whether posters can discover them as a candidate
+func (h *RecommendationHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var prefs models.CandidatePreferences
+	if err := json.NewDecoder(r.Body).Decode(&prefs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	prefs.Normalize()
+	if err := prefs.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.candidateService.SavePreferences(r.Context(), userID, &prefs); err != nil {
+		http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
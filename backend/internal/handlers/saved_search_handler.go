@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/feed"
+	"openfirm/internal/models"
+)
+
+// savedSearchFeedSize is how many of the newest matches a saved search
+// feed lists
+const savedSearchFeedSize = 50
+
+type SavedSearchHandler struct {
+	savedSearchService *models.SavedSearchService
+	jobSearchService   *models.JobSearchService
+	baseURL            string
+	frontendURL        string
+}
+
+// NewSavedSearchHandler creates a SavedSearchHandler. Feed and unsubscribe
+// links point at baseURL and feed items at job pages under frontendURL.
+func NewSavedSearchHandler(savedSearchService *models.SavedSearchService, jobSearchService *models.JobSearchService, baseURL, frontendURL string) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		savedSearchService: savedSearchService,
+		jobSearchService:   jobSearchService,
+		baseURL:            baseURL,
+		frontendURL:        frontendURL,
+	}
+}
+
+// SavedSearchRequest is the body of saved search creates and updates
+type SavedSearchRequest struct {
+	Name    string                    `json:"name"`
+	Filters models.SavedSearchFilters `json:"filters"`
+	Cadence models.AlertCadence       `json:"cadence"`
+	Email   bool                      `json:"email"`
+}
+
+// SavedSearchResponse is a saved search along with the private URLs of its
+// feeds
+type SavedSearchResponse struct {
+	*models.SavedSearch
+	RSSURL  string `json:"rss_url"`
+	AtomURL string `json:"atom_url"`
+}
+
+func (h *SavedSearchHandler) response(search *models.SavedSearch) *SavedSearchResponse {
+	feedURL := fmt.Sprintf("%s/saved-searches/feed/%s", h.baseURL, search.FeedToken)
+	return &SavedSearchResponse{
+		SavedSearch: search,
+		RSSURL:      feedURL + "?format=rss",
+		AtomURL:     feedURL + "?format=atom",
+	}
+}
+
+// decodeSavedSearch reads and validates a saved search from the request
+// body, writing an error response if it is invalid
+func decodeSavedSearch(w http.ResponseWriter, r *http.Request, search *models.SavedSearch) bool {
+	var req SavedSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	search.Name = req.Name
+	search.Filters = req.Filters
+	search.Cadence = req.Cadence
+	search.Email = req.Email
+	search.Normalize()
+	if err := search.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// List returns the authenticated user's saved searches
+func (h *SavedSearchHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	searches, err := h.savedSearchService.ListSavedSearches(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch saved searches", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]*SavedSearchResponse, 0, len(searches))
+	for _, search := range searches {
+		responses = append(responses, h.response(search))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(responses)
+}
+
+// Create saves a job search for the authenticated user
+func (h *SavedSearchHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	search := &models.SavedSearch{UserID: userID}
+	if !decodeSavedSearch(w, r, search) {
+		return
+	}
+
+	if err := h.savedSearchService.CreateSavedSearch(r.Context(), search); err != nil {
+		switch {
+		case errors.Is(err, models.ErrTooManySavedSearches):
+			http.Error(w, fmt.Sprintf("You can save at most %d searches", models.MaxSavedSearches), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to save search", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h.response(search))
+}
+
+// Update changes the name, filters or alerts of one of the authenticated
+// user's saved searches
+func (h *SavedSearchHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+		return
+	}
+
+	search, err := h.savedSearchService.GetSavedSearch(r.Context(), userID, id)
+	if errors.Is(err, models.ErrSavedSearchNotFound) {
+		http.Error(w, "Saved search not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch saved search", http.StatusInternalServerError)
+		return
+	}
+
+	if !decodeSavedSearch(w, r, search) {
+		return
+	}
+
+	if err := h.savedSearchService.UpdateSavedSearch(r.Context(), search); err != nil {
+		switch {
+		case errors.Is(err, models.ErrSavedSearchNotFound):
+			http.Error(w, "Saved search not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update saved search", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.response(search))
+}
+
+// Delete deletes one of the authenticated user's saved searches
+func (h *SavedSearchHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.savedSearchService.DeleteSavedSearch(r.Context(), userID, id); err != nil {
+		switch {
+		case errors.Is(err, models.ErrSavedSearchNotFound):
+			http.Error(w, "Saved search not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to delete saved search", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feed returns the newest jobs matching a saved search as RSS or Atom. It
+// needs no login: the token in the URL grants access.
+func (h *SavedSearchHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	search, err := h.savedSearchService.GetSavedSearchByFeedToken(r.Context(), token)
+	if errors.Is(err, models.ErrSavedSearchNotFound) {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	q := search.Filters.JobSearch()
+	q.Limit = savedSearchFeedSize
+	result, err := h.jobSearchService.SearchJobs(r.Context(), q)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	f := &feed.Feed{
+		Title:       search.Name,
+		Description: fmt.Sprintf("New jobs matching the saved search %q", search.Name),
+		Link:        h.frontendURL + "/jobs",
+		Self:        fmt.Sprintf("%s/saved-searches/feed/%s", h.baseURL, token),
+		Updated:     search.CreatedAt,
+		Items:       make([]*feed.Item, 0, len(result.Jobs)),
+	}
+	for _, job := range result.Jobs {
+		f.Items = append(f.Items, jobItem(job, h.frontendURL))
+	}
+
+	// The URL is a secret, so keep shared caches from storing the feed
+	w.Header().Set("Cache-Control", "private")
+	writeFeed(w, r, f)
+}
+
+// Unsubscribe stops email alerts of a saved search from the link in an
+// alert email, without logging in
+func (h *SavedSearchHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	search, err := h.savedSearchService.Unsubscribe(r.Context(), chi.URLParam(r, "token"))
+	if errors.Is(err, models.ErrSavedSearchNotFound) {
+		http.Error(w, "Saved search not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "You will no longer receive emails for the saved search %q. You can turn them back on in your saved searches.\n", search.Name)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"openfirm/internal/models"
+)
+
+// sitemapSize is how many URLs a sitemap lists, the most the sitemap
+// protocol allows
+const sitemapSize = 50000
+
+type SitemapHandler struct {
+	jobSearchService *models.JobSearchService
+	baseURL          string
+	frontendURL      string
+}
+
+// NewSitemapHandler creates a SitemapHandler listing job pages under
+// frontendURL. Sitemap pages are linked under baseURL.
+func NewSitemapHandler(jobSearchService *models.JobSearchService, baseURL, frontendURL string) *SitemapHandler {
+	return &SitemapHandler{
+		jobSearchService: jobSearchService,
+		baseURL:          baseURL,
+		frontendURL:      frontendURL,
+	}
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// Jobs handles /sitemap.xml requests, listing the pages of listed jobs.
+// Past sitemapSize jobs it returns a sitemap index instead, linking to
+// pages given by the page parameter.
+func (h *SitemapHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	total, err := h.jobSearchService.CountListedJobs(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	pages := (total + sitemapSize - 1) / sitemapSize
+	rawPage := r.URL.Query().Get("page")
+	if rawPage == "" && pages > 1 {
+		index := &sitemapIndex{}
+		for page := 1; page <= pages; page++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: fmt.Sprintf("%s/sitemap.xml?page=%d", h.baseURL, page)})
+		}
+		writeSitemap(w, index)
+		return
+	}
+
+	page := 1
+	if rawPage != "" {
+		page, err = strconv.Atoi(rawPage)
+		if err != nil || page < 1 {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+
+	jobs, err := h.jobSearchService.ListListedJobs(r.Context(), (page-1)*sitemapSize, sitemapSize)
+	if err != nil {
+		http.Error(w, "Failed to fetch jobs", http.StatusInternalServerError)
+		return
+	}
+
+	set := &sitemapURLSet{URLs: make([]sitemapURL, 0, len(jobs))}
+	for _, job := range jobs {
+		set.URLs = append(set.URLs, sitemapURL{
+			Loc:     fmt.Sprintf("%s/jobs/%d", h.frontendURL, job.ID),
+			LastMod: job.LastModified.UTC().Format(time.RFC3339),
+		})
+	}
+	writeSitemap(w, set)
+}
+
+// writeSitemap writes a sitemap or sitemap index as the response
+func writeSitemap(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(v)
+}
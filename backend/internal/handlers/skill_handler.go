@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/models"
+)
+
+// autocompleteLimit is how many skills autocomplete suggests
+const autocompleteLimit = 10
+
+type SkillHandler struct {
+	skillService *models.SkillService
+	userService  *models.UserService
+}
+
+func NewSkillHandler(skillService *models.SkillService, userService *models.UserService) *SkillHandler {
+	return &SkillHandler{
+		skillService: skillService,
+		userService:  userService,
+	}
+}
+
+// Autocomplete suggests skills whose name or an alias starts with the q
+// parameter, most used first
+func (h *SkillHandler) Autocomplete(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimSpace(r.URL.Query().Get("q"))
+	if prefix == "" {
+		http.Error(w, "Query parameter required", http.StatusBadRequest)
+		return
+	}
+
+	skills, err := h.skillService.Autocomplete(r.Context(), prefix, autocompleteLimit)
+	if err != nil {
+		http.Error(w, "Failed to fetch skills", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(skills)
+}
+
+// Get returns a skill of the taxonomy with its aliases
+func (h *SkillHandler) Get(w http.ResponseWriter, r *http.Request) {
+	skill, err := h.skillService.GetSkillBySlug(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "Skill not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(skill)
+}
+
+// UserSkills returns the skills on a user's profile
+func (h *SkillHandler) UserSkills(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userService.GetUserByUsername(r.Context(), chi.URLParam(r, "username"))
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	skills, err := h.skillService.ListUserSkills(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to fetch skills", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(skills)
+}
+
+// UpdateUserSkills replaces the skills on the authenticated user's profile.
+// Skills are given by name or alias with a proficiency from 1 to 5.
+func (h *SkillHandler) UpdateUserSkills(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	var req []*models.UserSkillInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err := h.skillService.SetUserSkills(r.Context(), userID, req)
+	switch {
+	case errors.Is(err, models.ErrInvalidSkill):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "Failed to update skills", http.StatusInternalServerError)
+		return
+	}
+
+	skills, err := h.skillService.ListUserSkills(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch skills", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(skills)
+}
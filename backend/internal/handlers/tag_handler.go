@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+// hashtagNameRe matches a hashtag name as it appears in a URL
+var hashtagNameRe = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+
+type TagHandler struct {
+	activityPubService *activitypub.Service
+	timelineService    *models.TimelineService
+	tagService         *models.TagService
+	frontendURL        string
+}
+
+func NewTagHandler(activityPubService *activitypub.Service, timelineService *models.TimelineService, tagService *models.TagService, frontendURL string) *TagHandler {
+	return &TagHandler{
+		activityPubService: activityPubService,
+		timelineService:    timelineService,
+		tagService:         tagService,
+		frontendURL:        frontendURL,
+	}
+}
+
+// hashtagParam returns the normalized hashtag name from the URL
+func hashtagParam(r *http.Request) (string, bool) {
+	name := chi.URLParam(r, "name")
+	if !hashtagNameRe.MatchString(name) {
+		return "", false
+	}
+	return models.NormalizeHashtag(name), true
+}
+
+// pageParam returns the page number from the query string, defaulting to 1
+func pageParam(r *http.Request) int {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// Collection handles /tags/{name} requests, returning the ActivityStreams
+// collection of a hashtag or redirecting browsers to its timeline page
+func (h *TagHandler) Collection(w http.ResponseWriter, r *http.Request) {
+	name, ok := hashtagParam(r)
+	if !ok {
+		http.Error(w, "Invalid hashtag", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	mediaType := negotiate(r, activityOffers...)
+	if mediaType == "" {
+		http.Error(w, "Not Acceptable", http.StatusNotAcceptable)
+		return
+	}
+	if !isActivityPubMediaType(mediaType) {
+		http.Redirect(w, r, fmt.Sprintf("%s/tags/%s", h.frontendURL, name), http.StatusSeeOther)
+		return
+	}
+
+	page := 0
+	if r.URL.Query().Get("page") != "" {
+		page = pageParam(r)
+	}
+
+	collection, err := h.activityPubService.GetTagCollection(r.Context(), name, page)
+	if err != nil {
+		http.Error(w, "Failed to get tag collection", http.StatusInternalServerError)
+		return
+	}
+
+	writeActivity(w, mediaType, collection)
+}
+
+// Timeline returns the local posts, job postings and federated posts
+// carrying a hashtag
+func (h *TagHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	name, ok := hashtagParam(r)
+	if !ok {
+		http.Error(w, "Invalid hashtag", http.StatusBadRequest)
+		return
+	}
+
+	page := pageParam(r)
+	limit := 20
+	offset := (page - 1) * limit
+
+	items, err := h.timelineService.HashtagTimeline(r.Context(), name, offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch timeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tag":   name,
+		"items": items,
+		"page":  page,
+	})
+}
+
+// Home returns the authenticated user's home timeline, including
+// everything tagged with the hashtags they follow
+func (h *TagHandler) Home(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	page := pageParam(r)
+	limit := 20
+	offset := (page - 1) * limit
+
+	items, err := h.timelineService.HomeTimeline(r.Context(), userID, offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch timeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+		"page":  page,
+	})
+}
+
+// Federated returns the federated timeline: local posts and job postings
+// along with everything received from other servers and relays. Pass
+// remote=true to leave out local content.
+func (h *TagHandler) Federated(w http.ResponseWriter, r *http.Request) {
+	remoteOnly, _ := strconv.ParseBool(r.URL.Query().Get("remote"))
+
+	page := pageParam(r)
+	limit := 20
+	offset := (page - 1) * limit
+
+	items, err := h.timelineService.FederatedTimeline(r.Context(), remoteOnly, offset, limit)
+	if err != nil {
+		http.Error(w, "Failed to fetch timeline", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+		"page":  page,
+	})
+}
+
+// Followed returns the hashtags the authenticated user follows
+func (h *TagHandler) Followed(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	names, err := h.tagService.ListFollowedHashtags(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to fetch followed hashtags", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(names)
+}
+
+// Follow adds a hashtag to the authenticated user's home timeline
+func (h *TagHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	name, ok := hashtagParam(r)
+	if !ok {
+		http.Error(w, "Invalid hashtag", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tagService.FollowHashtag(r.Context(), userID, name); err != nil {
+		http.Error(w, "Failed to follow hashtag", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unfollow removes a hashtag from the authenticated user's home timeline
+func (h *TagHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(int)
+
+	name, ok := hashtagParam(r)
+	if !ok {
+		http.Error(w, "Invalid hashtag", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tagService.UnfollowHashtag(r.Context(), userID, name); err != nil {
+		http.Error(w, "Failed to unfollow hashtag", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
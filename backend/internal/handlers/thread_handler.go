@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"openfirm/internal/activitypub"
+)
+
+type ThreadHandler struct {
+	activityPubService *activitypub.Service
+}
+
+func NewThreadHandler(activityPubService *activitypub.Service) *ThreadHandler {
+	return &ThreadHandler{
+		activityPubService: activityPubService,
+	}
+}
+
+// PostThread returns the conversation around a post
+func (h *ThreadHandler) PostThread(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	h.thread(w, r, h.activityPubService.PostIRI(postID))
+}
+
+// JobThread returns the discussion on a job posting
+func (h *ThreadHandler) JobThread(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	h.thread(w, r, h.activityPubService.JobIRI(jobID))
+}
+
+func (h *ThreadHandler) thread(w http.ResponseWriter, r *http.Request, iri string) {
+	thread, err := h.activityPubService.GetThread(r.Context(), iri)
+	if err != nil {
+		http.Error(w, "Thread not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(thread)
+}
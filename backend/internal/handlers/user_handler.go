@@ -11,14 +11,16 @@ import (
 )
 
 type UserHandler struct {
-	userService *models.UserService
-	jwtSecret   []byte
+	userService  *models.UserService
+	mediaService *models.MediaService
+	jwtSecret    []byte
 }
 
-func NewUserHandler(userService *models.UserService, jwtSecret []byte) *UserHandler {
+func NewUserHandler(userService *models.UserService, mediaService *models.MediaService, jwtSecret []byte) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		jwtSecret:   jwtSecret,
+		userService:  userService,
+		mediaService: mediaService,
+		jwtSecret:    jwtSecret,
 	}
 }
 
@@ -39,7 +41,11 @@ type UpdateProfileRequest struct {
 	Bio        string `json:"bio"`
 	Location   string `json:"location"`
 	Website    string `json:"website"`
-	AvatarURL  string `json:"avatar_url"`
+	// AvatarMediaID is an image uploaded through the media API. Leaving it
+	// unset keeps the current avatar.
+	AvatarMediaID *int `json:"avatar_media_id"`
+	// RemoveAvatar clears the current avatar
+	RemoveAvatar bool `json:"remove_avatar"`
 }
 
 type AuthResponse struct {
@@ -146,7 +152,18 @@ func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	user.Bio = req.Bio
 	user.Location = req.Location
 	user.Website = req.Website
-	user.AvatarURL = req.AvatarURL
+
+	switch {
+	case req.AvatarMediaID != nil:
+		avatar, err := h.mediaService.GetMedia(r.Context(), *req.AvatarMediaID)
+		if err != nil || avatar.UserID != userID {
+			http.Error(w, "Invalid avatar", http.StatusUnprocessableEntity)
+			return
+		}
+		user.AvatarURL = avatar.URL
+	case req.RemoveAvatar:
+		user.AvatarURL = ""
+	}
 
 	if err := h.userService.UpdateUser(r.Context(), user); err != nil {
 		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
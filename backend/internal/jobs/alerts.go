@@ -0,0 +1,221 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"openfirm/internal/mail"
+	"openfirm/internal/models"
+)
+
+const (
+	// alertJobLimit is how many jobs an alert lists at most
+	alertJobLimit = 20
+	// notificationJobLimit is how many of them the notification names
+	notificationJobLimit = 5
+)
+
+// AlertConfig sets how often saved searches are matched and where alerts
+// link to
+type AlertConfig struct {
+	// Interval is how often new jobs are matched against saved searches
+	Interval time.Duration
+	// BaseURL is the URL of this server, used for unsubscribe links
+	BaseURL string
+	// FrontendURL is the URL of the web app, used for job links
+	FrontendURL string
+}
+
+// AlertMatcher matches new jobs against saved searches and alerts their
+// owners at the cadence they chose
+type AlertMatcher struct {
+	savedSearchService  *models.SavedSearchService
+	jobSearchService    *models.JobSearchService
+	jobService          *models.JobService
+	userService         *models.UserService
+	notificationService *models.NotificationService
+	mailer              mail.Mailer
+	cfg                 AlertConfig
+}
+
+func NewAlertMatcher(savedSearchService *models.SavedSearchService, jobSearchService *models.JobSearchService, jobService *models.JobService, userService *models.UserService, notificationService *models.NotificationService, mailer mail.Mailer, cfg AlertConfig) *AlertMatcher {
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Minute
+	}
+
+	return &AlertMatcher{
+		savedSearchService:  savedSearchService,
+		jobSearchService:    jobSearchService,
+		jobService:          jobService,
+		userService:         userService,
+		notificationService: notificationService,
+		mailer:              mailer,
+		cfg:                 cfg,
+	}
+}
+
+// Run matches saved searches and sends alerts every interval until ctx is
+// cancelled
+func (m *AlertMatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.match(ctx); err != nil {
+			log.Printf("Failed to match saved searches: %v", err)
+		}
+		if err := m.alert(ctx); err != nil {
+			log.Printf("Failed to send saved search alerts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// match records the jobs listed since each saved search was last checked
+func (m *AlertMatcher) match(ctx context.Context) error {
+	// Searches are checked up to the time taken before querying, so a job
+	// listed while the batch runs is picked up by the next run
+	now := time.Now()
+	due, err := m.savedSearchService.ListDueForMatching(ctx, now, batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, search := range due {
+		ids, err := m.jobSearchService.ListNewMatches(ctx, search.Filters.JobSearch(), search.LastCheckedAt, batchSize)
+		if err != nil {
+			log.Printf("Failed to match saved search %d: %v", search.ID, err)
+			continue
+		}
+		if err := m.savedSearchService.RecordMatches(ctx, search.ID, ids, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alert notifies the owners of saved searches with new matches, by email
+// too if they asked for it
+func (m *AlertMatcher) alert(ctx context.Context) error {
+	due, err := m.savedSearchService.ListDueForAlert(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, search := range due {
+		ids, err := m.savedSearchService.ListPendingMatches(ctx, search.ID, alertJobLimit)
+		if err != nil {
+			return err
+		}
+		// Every match was unlisted before the alert went out
+		if len(ids) == 0 {
+			if err := m.savedSearchService.DiscardPendingMatches(ctx, search.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		jobs, err := m.jobService.GetJobs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			continue
+		}
+
+		if err := m.notify(ctx, search, jobs); err != nil {
+			log.Printf("Failed to notify owner of saved search %d: %v", search.ID, err)
+			continue
+		}
+		if search.Email {
+			m.email(ctx, search, jobs)
+		}
+
+		if err := m.savedSearchService.MarkAlerted(ctx, search.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notify sends the owner of a saved search a notification naming its new
+// matches
+func (m *AlertMatcher) notify(ctx context.Context, search *models.SavedSearch, jobs []*models.Job) error {
+	names := make([]string, 0, notificationJobLimit)
+	for i, job := range jobs {
+		if i == notificationJobLimit {
+			names = append(names, fmt.Sprintf("and %d more", len(jobs)-i))
+			break
+		}
+		names = append(names, jobName(job))
+	}
+
+	n := &models.Notification{
+		UserID: search.UserID,
+		Kind:   models.NotificationSavedSearch,
+		Message: fmt.Sprintf("%s matching your saved search %q: %s",
+			plural(len(jobs), "New job", "New jobs"), search.Name, strings.Join(names, "; ")),
+	}
+	if len(jobs) == 1 {
+		jobID := jobs[0].ID
+		n.JobID = &jobID
+	}
+	return m.notificationService.CreateNotification(ctx, n)
+}
+
+// email sends the owner of a saved search a digest of its new matches.
+// Failures are logged since the notification already went out.
+func (m *AlertMatcher) email(ctx context.Context, search *models.SavedSearch, jobs []*models.Job) {
+	user, err := m.userService.GetUserByID(ctx, search.UserID)
+	if err != nil {
+		log.Printf("Failed to load owner of saved search %d: %v", search.ID, err)
+		return
+	}
+	if user.Email == "" {
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s matched your saved search %q:\n\n",
+		plural(len(jobs), "A new job", fmt.Sprintf("%d new jobs", len(jobs))), search.Name)
+	for _, job := range jobs {
+		fmt.Fprintf(&body, "%s\n", jobName(job))
+		if job.Location != "" {
+			fmt.Fprintf(&body, "%s\n", job.Location)
+		}
+		fmt.Fprintf(&body, "%s/jobs/%d\n\n", m.cfg.FrontendURL, job.ID)
+	}
+	fmt.Fprintf(&body, "To stop these emails, open %s/saved-searches/unsubscribe/%s\n",
+		m.cfg.BaseURL, search.UnsubscribeToken)
+
+	subject := fmt.Sprintf("%s for %q", plural(len(jobs), "New job", "New jobs"), search.Name)
+	err = m.mailer.Send(ctx, user.Email, subject, body.String())
+	if err != nil && !errors.Is(err, mail.ErrDisabled) {
+		log.Printf("Failed to email alert for saved search %d: %v", search.ID, err)
+	}
+}
+
+// jobName names a job by its title and company
+func jobName(job *models.Job) string {
+	if job.Company == "" {
+		return job.Title
+	}
+	return fmt.Sprintf("%s at %s", job.Title, job.Company)
+}
+
+// plural returns one if n is 1 and many otherwise
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"openfirm/internal/models"
+)
+
+// BookmarkReminder notifies users when the reminder on one of their
+// bookmarks is due
+type BookmarkReminder struct {
+	bookmarkService     *models.BookmarkService
+	notificationService *models.NotificationService
+	interval            time.Duration
+}
+
+// NewBookmarkReminder creates a BookmarkReminder checking for due
+// reminders every interval, or every minute if interval is zero
+func NewBookmarkReminder(bookmarkService *models.BookmarkService, notificationService *models.NotificationService, interval time.Duration) *BookmarkReminder {
+	if interval == 0 {
+		interval = time.Minute
+	}
+
+	return &BookmarkReminder{
+		bookmarkService:     bookmarkService,
+		notificationService: notificationService,
+		interval:            interval,
+	}
+}
+
+// Run sends due reminders every interval until ctx is cancelled
+func (b *BookmarkReminder) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := b.remind(ctx); err != nil {
+			log.Printf("Failed to send bookmark reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remind notifies the owners of bookmarks whose reminder is due
+func (b *BookmarkReminder) remind(ctx context.Context) error {
+	due, err := b.bookmarkService.ListDueReminders(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, bookmark := range due {
+		claimed, err := b.bookmarkService.MarkReminded(ctx, bookmark.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		name := bookmark.Title
+		if name == "" {
+			name = bookmark.Summary
+		}
+		if name == "" {
+			name = bookmark.URL
+		}
+		message := fmt.Sprintf("Reminder about your bookmarked %s %q", bookmark.Kind, name)
+		if bookmark.Note != "" {
+			message += ": " + bookmark.Note
+		}
+
+		if err := b.notificationService.CreateNotification(ctx, &models.Notification{
+			UserID:  bookmark.UserID,
+			Kind:    models.NotificationBookmarkReminder,
+			JobID:   bookmark.JobID,
+			Message: message,
+		}); err != nil {
+			log.Printf("Failed to send reminder of bookmark %d: %v", bookmark.ID, err)
+		}
+	}
+	return nil
+}
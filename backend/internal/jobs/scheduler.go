@@ -0,0 +1,146 @@
+// Package jobs runs the background work that keeps job postings current.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"openfirm/internal/activitypub"
+	"openfirm/internal/models"
+)
+
+// batchSize is how many jobs are reminded or expired per run
+const batchSize = 100
+
+// SchedulerConfig sets when the scheduler runs and reminds posters
+type SchedulerConfig struct {
+	// Interval is how often expiring jobs are checked
+	Interval time.Duration
+	// RemindBefore is how long before a job expires its poster is reminded
+	RemindBefore time.Duration
+}
+
+// Scheduler expires job postings when their expiry passes and reminds
+// posters beforehand
+type Scheduler struct {
+	jobService          *models.JobService
+	lifecycleService    *models.JobLifecycleService
+	notificationService *models.NotificationService
+	activityPubService  *activitypub.Service
+	cfg                 SchedulerConfig
+}
+
+func NewScheduler(jobService *models.JobService, lifecycleService *models.JobLifecycleService, notificationService *models.NotificationService, activityPubService *activitypub.Service, cfg SchedulerConfig) *Scheduler {
+	if cfg.Interval == 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.RemindBefore == 0 {
+		cfg.RemindBefore = 3 * 24 * time.Hour
+	}
+
+	return &Scheduler{
+		jobService:          jobService,
+		lifecycleService:    lifecycleService,
+		notificationService: notificationService,
+		activityPubService:  activityPubService,
+		cfg:                 cfg,
+	}
+}
+
+// Run sends reminders and expires jobs every interval until ctx is
+// cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.remind(ctx); err != nil {
+			log.Printf("Failed to send job expiry reminders: %v", err)
+		}
+		if err := s.expire(ctx); err != nil {
+			log.Printf("Failed to expire jobs: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// remind notifies the posters of jobs about to expire
+func (s *Scheduler) remind(ctx context.Context) error {
+	due, err := s.lifecycleService.ListDueForReminder(ctx, time.Now().Add(s.cfg.RemindBefore), batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, expiring := range due {
+		job, err := s.jobService.GetJob(ctx, expiring.JobID)
+		if err != nil {
+			log.Printf("Failed to load job %d: %v", expiring.JobID, err)
+			continue
+		}
+
+		claimed, err := s.lifecycleService.MarkReminded(ctx, job.ID)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		message := fmt.Sprintf("Your job posting %q expires on %s. Update its expiry date to keep it listed.",
+			job.Title, expiring.ExpiresAt.UTC().Format("January 2, 2006"))
+		if err := s.notify(ctx, job, models.NotificationJobExpiring, message); err != nil {
+			log.Printf("Failed to remind poster of job %d: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// expire closes jobs whose expiry has passed and federates the change
+func (s *Scheduler) expire(ctx context.Context) error {
+	due, err := s.lifecycleService.ListDueForExpiry(ctx, time.Now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, expiring := range due {
+		job, err := s.jobService.GetJob(ctx, expiring.JobID)
+		if err != nil {
+			log.Printf("Failed to load job %d: %v", expiring.JobID, err)
+			continue
+		}
+
+		// The job has expired once its state changes, whether or not remote
+		// servers can be told, so the poster is notified either way
+		if err := s.lifecycleService.Transition(ctx, job.ID, models.JobExpired); err != nil {
+			log.Printf("Failed to expire job %d: %v", job.ID, err)
+			continue
+		}
+
+		message := fmt.Sprintf("Your job posting %q has expired. You can repost it to advertise it again.", job.Title)
+		if err := s.notify(ctx, job, models.NotificationJobExpired, message); err != nil {
+			log.Printf("Failed to notify poster of job %d: %v", job.ID, err)
+		}
+		if err := s.activityPubService.PublishJobUpdate(ctx, job); err != nil {
+			log.Printf("Failed to federate expiry of job %d: %v", job.ID, err)
+		}
+	}
+	return nil
+}
+
+// notify sends a notification about a job to its poster
+func (s *Scheduler) notify(ctx context.Context, job *models.Job, kind models.NotificationKind, message string) error {
+	jobID := job.ID
+	return s.notificationService.CreateNotification(ctx, &models.Notification{
+		UserID:  job.PostedBy,
+		Kind:    kind,
+		JobID:   &jobID,
+		Message: message,
+	})
+}
@@ -0,0 +1,91 @@
+// Package mail sends the few emails the server needs, such as domain
+// verification codes.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrDisabled is returned by DisabledMailer
+var ErrDisabled = errors.New("email is not configured")
+
+// Mailer sends plain text email
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// DisabledMailer refuses to send anything. It is used when no SMTP server
+// is configured.
+type DisabledMailer struct{}
+
+func (DisabledMailer) Send(ctx context.Context, to, subject, body string) error {
+	return ErrDisabled
+}
+
+// SMTPMailer sends email through an SMTP server, upgrading to TLS when the
+// server offers it
+type SMTPMailer struct {
+	// Address is the host:port of the server
+	Address string
+	// From is the sender address
+	From string
+	// Username and Password authenticate with PLAIN auth if set
+	Username string
+	Password string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("invalid email header")
+	}
+
+	host, _, err := net.SplitHostPort(m.Address)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n"+
+		"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		m.From, to, subject, time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(body, "\n", "\r\n"))
+
+	// net/smtp has no context support, so the send runs until it finishes
+	// but the caller stops waiting when ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.Address, auth, m.From, []string{to}, []byte(msg))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MailerFromEnv returns an SMTPMailer for the server at SMTP_ADDRESS,
+// sending as SMTP_FROM and authenticating with SMTP_USERNAME and
+// SMTP_PASSWORD, or a DisabledMailer if SMTP_ADDRESS isn't set
+func MailerFromEnv() Mailer {
+	address := os.Getenv("SMTP_ADDRESS")
+	if address == "" {
+		return DisabledMailer{}
+	}
+	return &SMTPMailer{
+		Address:  address,
+		From:     os.Getenv("SMTP_FROM"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+	}
+}
@@ -0,0 +1,342 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"openfirm/internal/models"
+)
+
+const (
+	// maxFetchAttempts is how often a remote file is tried before giving up
+	maxFetchAttempts = 3
+	// fetchBatchSize is the number of pending files fetched per cycle
+	fetchBatchSize = 20
+	// evictBatchSize is the number of files evicted per query
+	evictBatchSize = 100
+	// fetchTimeout bounds a single remote fetch
+	fetchTimeout = 30 * time.Second
+)
+
+var (
+	// ErrRemoteMediaUnavailable is returned for files that can't be served
+	// because they failed to fetch or were rejected
+	ErrRemoteMediaUnavailable = errors.New("remote media unavailable")
+
+	errRejected = errors.New("rejected")
+)
+
+// remoteMediaTypes are the content types accepted from remote servers.
+// Unlike uploads, remote files are stored as fetched.
+var remoteMediaTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"audio/mpeg":      ".mp3",
+	"application/ogg": ".ogg",
+}
+
+// CacheConfig sets the limits of the remote media cache
+type CacheConfig struct {
+	// MaxFileSize is the largest remote file cached, in bytes
+	MaxFileSize int64
+	// MaxTotalSize is the size the cache is evicted down to, least recently
+	// used first. Zero means no limit.
+	MaxTotalSize int64
+	// MaxDomainSize limits the space any one remote server's files take
+	// up, so a single busy server can't push everything else out. Zero
+	// means no limit.
+	MaxDomainSize int64
+	// Interval is how often pending files are fetched and the limits
+	// enforced
+	Interval time.Duration
+}
+
+// Cache fetches media from remote servers into our own storage so clients
+// never load it from the remote server directly
+type Cache struct {
+	storage        Storage
+	remoteMediaSvc *models.RemoteMediaService
+	client         *http.Client
+	cfg            CacheConfig
+}
+
+func NewCache(storage Storage, remoteMediaSvc *models.RemoteMediaService, cfg CacheConfig) *Cache {
+	if cfg.MaxFileSize == 0 {
+		cfg.MaxFileSize = 40 << 20
+	}
+	if cfg.Interval == 0 {
+		cfg.Interval = time.Minute
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: RefusePrivateAddresses}
+	return &Cache{
+		storage:        storage,
+		remoteMediaSvc: remoteMediaSvc,
+		client: &http.Client{
+			Timeout:   fetchTimeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+		cfg: cfg,
+	}
+}
+
+// RefusePrivateAddresses is a net.Dialer Control that stops remote URLs
+// from reaching our own network
+func RefusePrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return fmt.Errorf("refusing to connect to %s", address)
+	}
+	return nil
+}
+
+// Run fetches pending files and enforces the size limits every interval
+// until ctx is cancelled
+func (c *Cache) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.fetchPending(ctx); err != nil {
+			log.Printf("Failed to fetch remote media: %v", err)
+		}
+		if err := c.Evict(ctx); err != nil {
+			log.Printf("Failed to evict remote media: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fetchPending fetches a batch of files waiting to be cached
+func (c *Cache) fetchPending(ctx context.Context) error {
+	pending, err := c.remoteMediaSvc.ListPendingRemoteMedia(ctx, maxFetchAttempts, fetchBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if err := c.Fetch(ctx, m); err != nil {
+			log.Printf("Failed to cache %s: %v", m.RemoteURL, err)
+		}
+	}
+	return nil
+}
+
+// Fetch downloads a remote file into storage, validating its type and size
+func (c *Cache) Fetch(ctx context.Context, m *models.RemoteMedia) error {
+	data, mediaType, err := c.download(ctx, m.RemoteURL)
+	if err != nil {
+		permanent := errors.Is(err, errRejected)
+		if markErr := c.remoteMediaSvc.MarkRemoteMediaFailed(ctx, m.ID, err.Error(), maxFetchAttempts, permanent); markErr != nil {
+			return markErr
+		}
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(m.RemoteURL))
+	m.StorageKey = fmt.Sprintf("remote/%s/%s%s", m.Domain, hex.EncodeToString(sum[:]), remoteMediaTypes[mediaType])
+	m.MediaType = mediaType
+	m.Size = int64(len(data))
+
+	if err := c.storage.Put(ctx, m.StorageKey, bytes.NewReader(data), m.Size, mediaType); err != nil {
+		return err
+	}
+	return c.remoteMediaSvc.MarkRemoteMediaCached(ctx, m)
+}
+
+// download retrieves a remote file. Files that are too large or of a type
+// we don't accept are rejected permanently.
+func (c *Cache) download(ctx context.Context, rawURL string) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return nil, "", fmt.Errorf("%w: not an https URL", errRejected)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s responded with %s", rawURL, resp.Status)
+	}
+	if resp.ContentLength > c.cfg.MaxFileSize {
+		return nil, "", fmt.Errorf("%w: %d bytes exceeds size limit", errRejected, resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.cfg.MaxFileSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > c.cfg.MaxFileSize {
+		return nil, "", fmt.Errorf("%w: exceeds size limit", errRejected)
+	}
+
+	// Trust the content over the declared type, but require them to agree
+	// so a server can't pass off one kind of file as another
+	mediaType := http.DetectContentType(data)
+	if _, ok := remoteMediaTypes[mediaType]; !ok {
+		return nil, "", fmt.Errorf("%w: unsupported type %s", errRejected, mediaType)
+	}
+	declared, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !compatibleTypes(declared, mediaType) {
+		return nil, "", fmt.Errorf("%w: declared %s but got %s", errRejected, declared, mediaType)
+	}
+
+	return data, mediaType, nil
+}
+
+// compatibleTypes reports whether a declared content type agrees with the
+// sniffed one. Only the kind of media has to match since servers use many
+// aliases, and generic binary types are accepted.
+func compatibleTypes(declared, sniffed string) bool {
+	switch declared {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return true
+	}
+
+	kind := func(t string) string {
+		if t == "application/ogg" {
+			return "ogg"
+		}
+		return strings.SplitN(t, "/", 2)[0]
+	}
+	if kind(sniffed) == "ogg" {
+		return strings.HasSuffix(declared, "/ogg")
+	}
+	return kind(declared) == kind(sniffed)
+}
+
+// Open returns a cached file for serving, fetching it first if it is
+// pending or was evicted
+func (c *Cache) Open(ctx context.Context, id int) (io.ReadCloser, *models.RemoteMedia, error) {
+	m, err := c.remoteMediaSvc.GetRemoteMedia(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch m.Status {
+	case models.RemoteMediaFailed:
+		return nil, nil, ErrRemoteMediaUnavailable
+	case models.RemoteMediaPending, models.RemoteMediaEvicted:
+		if err := c.Fetch(ctx, m); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrRemoteMediaUnavailable, err)
+		}
+	}
+
+	f, err := c.storage.Get(ctx, m.StorageKey)
+	if errors.Is(err, ErrNotFound) {
+		// Removed from storage behind our back; fetch it again next time
+		c.remoteMediaSvc.MarkRemoteMediaEvicted(ctx, m.ID)
+		return nil, nil, ErrRemoteMediaUnavailable
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.remoteMediaSvc.TouchRemoteMedia(ctx, m.ID); err != nil {
+		log.Printf("Failed to record access to remote media %d: %v", m.ID, err)
+	}
+	return f, m, nil
+}
+
+// Evict enforces the per-domain and total size limits, removing the least
+// recently used files first
+func (c *Cache) Evict(ctx context.Context) error {
+	if c.cfg.MaxDomainSize > 0 {
+		domains, err := c.remoteMediaSvc.DomainsOverQuota(ctx, c.cfg.MaxDomainSize)
+		if err != nil {
+			return err
+		}
+		for _, domain := range domains {
+			if _, err := c.evictDown(ctx, domain, c.cfg.MaxDomainSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.cfg.MaxTotalSize > 0 {
+		if _, err := c.evictDown(ctx, "", c.cfg.MaxTotalSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge removes every cached file, or only those of one domain. Purged
+// files are fetched again if they are requested later.
+func (c *Cache) Purge(ctx context.Context, domain string) (int, error) {
+	return c.evictDown(ctx, domain, 0)
+}
+
+// evictDown evicts the least recently used files of a domain, or of all
+// domains, until they take up at most limit bytes. It returns the number
+// of files evicted.
+func (c *Cache) evictDown(ctx context.Context, domain string, limit int64) (int, error) {
+	size, err := c.remoteMediaSvc.CachedSize(ctx, domain)
+	if err != nil {
+		return 0, err
+	}
+
+	evicted := 0
+	for size > limit {
+		batch, err := c.remoteMediaSvc.ListLeastRecentlyUsed(ctx, domain, evictBatchSize)
+		if err != nil {
+			return evicted, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, m := range batch {
+			if size <= limit {
+				break
+			}
+			if err := c.storage.Delete(ctx, m.StorageKey); err != nil {
+				return evicted, err
+			}
+			if err := c.remoteMediaSvc.MarkRemoteMediaEvicted(ctx, m.ID); err != nil {
+				return evicted, err
+			}
+			size -= m.Size
+			evicted++
+		}
+	}
+	return evicted, nil
+}
@@ -0,0 +1,53 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// MaxDocumentSize is the largest document upload accepted, in bytes
+const MaxDocumentSize = 10 << 20
+
+// ResumeType is the only type accepted for resumes
+const ResumeType = "application/pdf"
+
+// allowedDocumentTypes are the sniffed content types accepted for
+// documents attached to applications
+var allowedDocumentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// DocumentExtension returns the file extension for a stored document type
+func DocumentExtension(contentType string) string {
+	return allowedDocumentTypes[contentType]
+}
+
+// SniffDocument reads up to MaxDocumentSize bytes from r and returns them
+// along with the content type detected from the data itself. Documents are
+// stored as uploaded, so only types browsers won't run are accepted.
+func SniffDocument(r io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxDocumentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxDocumentSize {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedDocumentTypes[contentType]; !ok || !bytes.HasPrefix(data, documentMagic[contentType]) {
+		return nil, "", ErrUnsupportedType
+	}
+	return data, contentType, nil
+}
+
+// documentMagic are the leading bytes each document type must start with.
+// DetectContentType skips leading whitespace, which real files never have.
+var documentMagic = map[string][]byte{
+	"application/pdf": []byte("%PDF-"),
+	"image/jpeg":      {0xff, 0xd8, 0xff},
+	"image/png":       []byte("\x89PNG\r\n\x1a\n"),
+}
@@ -0,0 +1,171 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	"github.com/buckket/go-blurhash"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxImageSize is the largest image upload accepted, in bytes
+	MaxImageSize = 10 << 20
+	// maxImagePixels guards against decompression bombs
+	maxImagePixels = 40_000_000
+	// thumbnailSize is the longest side of generated thumbnails
+	thumbnailSize = 400
+	// blurhashSize is the longest side of the image blurhashes are computed
+	// from; hashing the full image would be needlessly slow
+	blurhashSize = 32
+)
+
+var (
+	ErrTooLarge        = errors.New("media exceeds size limit")
+	ErrUnsupportedType = errors.New("unsupported media type")
+)
+
+// allowedImageTypes maps sniffed content types to the type the processed
+// image is stored as. WebP is stored as PNG as there is no encoder for it.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": "image/jpeg",
+	"image/png":  "image/png",
+	"image/gif":  "image/gif",
+	"image/webp": "image/png",
+}
+
+// Extension returns the file extension for a stored content type
+func Extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	default:
+		return ""
+	}
+}
+
+// Processed is an uploaded image ready to be stored
+type Processed struct {
+	Data      []byte
+	MediaType string
+	Width     int
+	Height    int
+	Thumbnail []byte
+	Blurhash  string
+}
+
+// SniffImage reads up to MaxImageSize bytes from r and returns them along
+// with the content type detected from the data itself, ignoring whatever
+// type the client claimed
+func SniffImage(r io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxImageSize {
+		return nil, "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return nil, "", ErrUnsupportedType
+	}
+	return data, contentType, nil
+}
+
+// ProcessImage validates an uploaded image, strips its metadata by
+// re-encoding it, and generates a thumbnail and blurhash. Animated GIFs are
+// kept as uploaded since GIF carries no EXIF data.
+func ProcessImage(r io.Reader) (*Processed, error) {
+	data, contentType, err := SniffImage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	processed := &Processed{
+		MediaType: allowedImageTypes[contentType],
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+	}
+
+	// Re-encoding drops EXIF and any other embedded metadata
+	switch processed.MediaType {
+	case "image/gif":
+		processed.Data = data
+	case "image/jpeg":
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+		processed.Data = buf.Bytes()
+	default:
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		processed.Data = buf.Bytes()
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, resize(img, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	processed.Thumbnail = thumb.Bytes()
+
+	processed.Blurhash, err = blurhash.Encode(4, 3, resize(img, blurhashSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return processed, nil
+}
+
+// resize scales an image down so its longest side is at most size pixels,
+// flattening transparency onto white
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, height*size/width
+		} else {
+			width, height = width*size/height, size
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
@@ -0,0 +1,70 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage stores media in an S3-compatible bucket, such as AWS S3 or a
+// local MinIO instance
+type S3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+func NewS3Storage(cfg Config) (*S3Storage, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %v", err)
+	}
+
+	publicURL := cfg.S3PublicURL
+	if publicURL == "" {
+		scheme := "http"
+		if cfg.S3UseSSL {
+			scheme = "https"
+		}
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, cfg.S3Endpoint, cfg.S3Bucket)
+	}
+
+	return &S3Storage{
+		client:    client,
+		bucket:    cfg.S3Bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Storage) URL(key string) string {
+	return fmt.Sprintf("%s/%s", s.publicURL, key)
+}
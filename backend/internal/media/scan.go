@@ -0,0 +1,114 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInfected is returned by a Scanner for files that carry malware
+var ErrInfected = errors.New("file failed virus scan")
+
+// Scanner checks uploaded documents for malware before they are stored.
+// Scan returns ErrInfected, wrapped with the name of what was found, for
+// infected files and another error if the file couldn't be scanned.
+type Scanner interface {
+	Scan(ctx context.Context, data []byte) error
+}
+
+// NoopScanner accepts every file. It is used when no scanner is configured.
+type NoopScanner struct{}
+
+func (NoopScanner) Scan(ctx context.Context, data []byte) error {
+	return nil
+}
+
+// clamdChunkSize is the size of the chunks files are streamed to clamd in
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner scans files with a ClamAV daemon over its INSTREAM command
+type ClamdScanner struct {
+	// Network and Address locate clamd, e.g. "tcp" and "localhost:3310"
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+func (s *ClamdScanner) Scan(ctx context.Context, data []byte) error {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to clamd: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+	size := make([]byte, 4)
+	for len(data) > 0 {
+		n := len(data)
+		if n > clamdChunkSize {
+			n = clamdChunkSize
+		}
+		binary.BigEndian.PutUint32(size, uint32(n))
+		if _, err := conn.Write(size); err != nil {
+			return err
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return err
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(conn, 1024))
+	if err != nil {
+		return err
+	}
+	result := strings.TrimSpace(string(bytes.TrimRight(reply, "\x00")))
+	switch {
+	case strings.HasSuffix(result, " OK"):
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		found := strings.TrimSuffix(strings.TrimPrefix(result, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s", ErrInfected, found)
+	default:
+		return fmt.Errorf("clamd scan failed: %s", result)
+	}
+}
+
+// ScannerFromEnv returns a ClamdScanner for the clamd at CLAMD_ADDRESS,
+// either host:port or the path of a unix socket, or a NoopScanner if it
+// isn't set
+func ScannerFromEnv() Scanner {
+	address := os.Getenv("CLAMD_ADDRESS")
+	switch {
+	case address == "":
+		return NoopScanner{}
+	case strings.HasPrefix(address, "/"):
+		return &ClamdScanner{Network: "unix", Address: address}
+	default:
+		return &ClamdScanner{Network: "tcp", Address: address}
+	}
+}
@@ -0,0 +1,88 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrNotFound is returned when a stored object doesn't exist
+var ErrNotFound = errors.New("media not found")
+
+// Storage stores media files under opaque keys
+type Storage interface {
+	// Put stores the contents of r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key
+	Delete(ctx context.Context, key string) error
+	// URL returns the public URL the object under key is served from
+	URL(key string) string
+}
+
+// Config selects and configures a Storage backend
+type Config struct {
+	// Driver is either "local" or "s3"
+	Driver string
+
+	// LocalDir is the directory files are written to by the local driver
+	LocalDir string
+	// BaseURL is the public URL of this server, used to build local URLs
+	BaseURL string
+
+	S3Endpoint  string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	// S3PublicURL is where the bucket is publicly reachable, for example a
+	// CDN in front of it. It defaults to the endpoint and bucket.
+	S3PublicURL string
+}
+
+// NewStorage creates the Storage backend described by cfg
+func NewStorage(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.BaseURL)
+	case "s3":
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown media storage driver: %s", cfg.Driver)
+	}
+}
+
+// ConfigFromEnv reads the storage configuration from MEDIA_DRIVER,
+// MEDIA_DIR, BASE_URL and the S3_* variables
+func ConfigFromEnv() Config {
+	useSSL, _ := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+	return Config{
+		Driver:      os.Getenv("MEDIA_DRIVER"),
+		LocalDir:    os.Getenv("MEDIA_DIR"),
+		BaseURL:     os.Getenv("BASE_URL"),
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:    useSSL,
+		S3PublicURL: os.Getenv("S3_PUBLIC_URL"),
+	}
+}
+
+// DocumentConfigFromEnv reads the configuration of the private storage
+// applicants' documents are kept in from DOCUMENT_DRIVER, DOCUMENT_DIR and
+// DOCUMENT_S3_BUCKET, sharing the S3 credentials of media storage. It must
+// not point at the public media directory or bucket: documents are only
+// served through signed links.
+func DocumentConfigFromEnv() Config {
+	cfg := ConfigFromEnv()
+	cfg.Driver = os.Getenv("DOCUMENT_DRIVER")
+	cfg.LocalDir = os.Getenv("DOCUMENT_DIR")
+	cfg.S3Bucket = os.Getenv("DOCUMENT_S3_BUCKET")
+	cfg.S3PublicURL = ""
+	return cfg
+}
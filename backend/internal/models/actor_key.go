@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ActorKey is the key pair a local actor signs its deliveries with. Keys
+// are stored by actor IRI so users, organizations and the instance actor
+// share one table.
+type ActorKey struct {
+	ActorIRI      string    `json:"actor_iri"`
+	PublicKeyPem  string    `json:"public_key_pem"`
+	PrivateKeyPem string    `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ActorKeyService struct {
+	db *pgxpool.Pool
+}
+
+func NewActorKeyService(db *pgxpool.Pool) *ActorKeyService {
+	return &ActorKeyService{db: db}
+}
+
+// GetKey returns the key pair of an actor
+func (s *ActorKeyService) GetKey(ctx context.Context, actorIRI string) (*ActorKey, error) {
+	key := &ActorKey{}
+	err := s.db.QueryRow(ctx, `
+		SELECT actor_iri, public_key_pem, private_key_pem, created_at
+		FROM actor_keys
+		WHERE actor_iri = $1`, actorIRI,
+	).Scan(&key.ActorIRI, &key.PublicKeyPem, &key.PrivateKeyPem, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CreateKey stores the key pair of an actor unless it already has one, and
+// returns the pair that is stored. Two callers generating a key at once
+// thus end up using the same one.
+func (s *ActorKeyService) CreateKey(ctx context.Context, key *ActorKey) (*ActorKey, error) {
+	if _, err := s.db.Exec(ctx, `
+		INSERT INTO actor_keys (actor_iri, public_key_pem, private_key_pem)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (actor_iri) DO NOTHING`,
+		key.ActorIRI, key.PublicKeyPem, key.PrivateKeyPem); err != nil {
+		return nil, err
+	}
+	return s.GetKey(ctx, key.ActorIRI)
+}
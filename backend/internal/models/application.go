@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrAlreadyApplied is returned when a user applies to a job they already
+// have an active application to
+var ErrAlreadyApplied = errors.New("already applied to this job")
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation
+const uniqueViolation = "23505"
+
+// ApplicationStatusChange is a step of an application's timeline as its
+// applicant sees it, without who made it or why
+type ApplicationStatusChange struct {
+	Stage     string    `json:"stage"`
+	StageName string    `json:"stage_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MyApplication is an application as its applicant sees it
+type MyApplication struct {
+	ID          int                        `json:"id"`
+	JobID       int                        `json:"job_id"`
+	JobTitle    string                     `json:"job_title"`
+	Company     string                     `json:"company"`
+	CoverLetter string                     `json:"cover_letter"`
+	Status      string                     `json:"status"`
+	StageName   string                     `json:"stage_name"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	Timeline    []*ApplicationStatusChange `json:"timeline,omitempty"`
+}
+
+// stageName returns the name of a stage, whose custom name is empty if the
+// job uses the default pipeline
+func stageName(key, custom string) string {
+	if custom != "" {
+		return custom
+	}
+	for _, stages := range [][]*PipelineStage{defaultActiveStages, finalStages} {
+		if stage := Pipeline(stages).Stage(key); stage != nil {
+			return stage.Name
+		}
+	}
+	return key
+}
+
+// myApplicationColumns selects a MyApplication from applications (a)
+// joined with their jobs (j) and the name of their stage (ps)
+const myApplicationColumns = `a.id, a.job_id, j.title, j.company, a.cover_letter, a.status,
+	COALESCE(ps.name, ''), a.created_at`
+
+func scanMyApplication(row interface{ Scan(...interface{}) error }) (*MyApplication, error) {
+	a := &MyApplication{}
+	var custom string
+	if err := row.Scan(&a.ID, &a.JobID, &a.JobTitle, &a.Company, &a.CoverLetter, &a.Status,
+		&custom, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	a.StageName = stageName(a.Status, custom)
+	return a, nil
+}
+
+type ApplicationService struct {
+	db *pgxpool.Pool
+}
+
+func NewApplicationService(db *pgxpool.Pool) *ApplicationService {
+	return &ApplicationService{db: db}
+}
+
+// HasApplied reports whether a user has an application to a job they
+// haven't withdrawn
+func (s *ApplicationService) HasApplied(ctx context.Context, jobID, userID int) (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM job_applications
+			WHERE job_id = $1 AND user_id = $2 AND status <> 'withdrawn'
+		)`, jobID, userID).Scan(&exists)
+	return exists, err
+}
+
+// applicationConflict returns ErrAlreadyApplied if err is the unique
+// violation raised when a user's second active application to a job is
+// stored, as when two applications race past HasApplied, and err otherwise
+func applicationConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation &&
+		pgErr.ConstraintName == "job_applications_active_idx" {
+		return ErrAlreadyApplied
+	}
+	return err
+}
+
+// ListUserApplications returns a page of a user's applications, newest
+// first
+func (s *ApplicationService) ListUserApplications(ctx context.Context, userID, offset, limit int) ([]*MyApplication, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+myApplicationColumns+`
+		FROM job_applications a
+		JOIN jobs j ON j.id = a.job_id
+		LEFT JOIN pipeline_stages ps ON ps.job_id = a.job_id AND ps.key = a.status
+		WHERE a.user_id = $1
+		ORDER BY a.created_at DESC, a.id DESC
+		OFFSET $2 LIMIT $3`, userID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applications := []*MyApplication{}
+	for rows.Next() {
+		a, err := scanMyApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, a)
+	}
+	return applications, rows.Err()
+}
+
+// GetUserApplication returns one of a user's applications with its
+// timeline, or ErrApplicationNotFound if it isn't theirs
+func (s *ApplicationService) GetUserApplication(ctx context.Context, userID, applicationID int) (*MyApplication, error) {
+	a, err := scanMyApplication(s.db.QueryRow(ctx, `
+		SELECT `+myApplicationColumns+`
+		FROM job_applications a
+		JOIN jobs j ON j.id = a.job_id
+		LEFT JOIN pipeline_stages ps ON ps.job_id = a.job_id AND ps.key = a.status
+		WHERE a.id = $1 AND a.user_id = $2`, applicationID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT e.from_stage, COALESCE(fs.name, ''), e.to_stage, COALESCE(ts.name, ''), e.created_at
+		FROM application_events e
+		LEFT JOIN pipeline_stages fs ON fs.job_id = $2 AND fs.key = e.from_stage
+		LEFT JOIN pipeline_stages ts ON ts.job_id = $2 AND ts.key = e.to_stage
+		WHERE e.application_id = $1
+		ORDER BY e.created_at, e.id`, applicationID, a.JobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// The timeline starts with the stage the application was made in, which
+	// is the stage the first move left
+	a.Timeline = []*ApplicationStatusChange{{Stage: a.Status, StageName: a.StageName, CreatedAt: a.CreatedAt}}
+	for rows.Next() {
+		change := &ApplicationStatusChange{}
+		var from, fromName, toName string
+		if err := rows.Scan(&from, &fromName, &change.Stage, &toName, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(a.Timeline) == 1 {
+			a.Timeline[0].Stage = from
+			a.Timeline[0].StageName = stageName(from, fromName)
+		}
+		change.StageName = stageName(change.Stage, toName)
+		a.Timeline = append(a.Timeline, change)
+	}
+	return a, rows.Err()
+}
@@ -0,0 +1,527 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// QuestionKind is the kind of answer an application question takes
+type QuestionKind string
+
+const (
+	QuestionText   QuestionKind = "text"
+	QuestionChoice QuestionKind = "choice"
+	QuestionYesNo  QuestionKind = "yes_no"
+	QuestionFile   QuestionKind = "file"
+)
+
+func (k QuestionKind) Valid() bool {
+	switch k {
+	case QuestionText, QuestionChoice, QuestionYesNo, QuestionFile:
+		return true
+	}
+	return false
+}
+
+const (
+	// MaxQuestions is how many questions a job can ask applicants
+	MaxQuestions    = 20
+	maxPromptLen    = 500
+	maxOptions      = 20
+	maxOptionLen    = 100
+	MaxAnswerLength = 5000
+	maxFilenameLen  = 255
+)
+
+var (
+	ErrInvalidQuestion  = errors.New("invalid question")
+	ErrQuestionInUse    = errors.New("question already has answers")
+	ErrInvalidAnswer    = errors.New("invalid answer")
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// ApplicationQuestion is a question employers ask applicants to a job.
+// Options are the choices of a choice question.
+type ApplicationQuestion struct {
+	ID       int          `json:"id"`
+	Kind     QuestionKind `json:"kind"`
+	Prompt   string       `json:"prompt"`
+	Required bool         `json:"required"`
+	Options  []string     `json:"options"`
+}
+
+// Validate checks a question an employer is adding or changing
+func (q *ApplicationQuestion) Validate() error {
+	q.Prompt = strings.TrimSpace(q.Prompt)
+	if !q.Kind.Valid() {
+		return fmt.Errorf("%w: kind must be text, choice, yes_no or file", ErrInvalidQuestion)
+	}
+	if q.Prompt == "" || utf8.RuneCountInString(q.Prompt) > maxPromptLen {
+		return fmt.Errorf("%w: prompt must be between 1 and %d characters", ErrInvalidQuestion, maxPromptLen)
+	}
+
+	if q.Kind != QuestionChoice {
+		if len(q.Options) > 0 {
+			return fmt.Errorf("%w: only choice questions have options", ErrInvalidQuestion)
+		}
+		q.Options = []string{}
+		return nil
+	}
+	if len(q.Options) < 2 || len(q.Options) > maxOptions {
+		return fmt.Errorf("%w: choice questions need between 2 and %d options", ErrInvalidQuestion, maxOptions)
+	}
+	seen := make(map[string]bool)
+	for i, option := range q.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > maxOptionLen || seen[option] {
+			return fmt.Errorf("%w: options must be unique and between 1 and %d characters", ErrInvalidQuestion, maxOptionLen)
+		}
+		seen[option] = true
+		q.Options[i] = option
+	}
+	return nil
+}
+
+// Document is a file a user uploaded for their applications. Documents are
+// private and only downloaded through signed links.
+type Document struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	StorageKey string    `json:"-"`
+	Filename   string    `json:"filename"`
+	MediaType  string    `json:"media_type"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CleanFilename returns the name of an uploaded file without any path,
+// shortened to a length safe to store and send back in headers
+func CleanFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	for utf8.RuneCountInString(name) > maxFilenameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "document"
+	}
+	return name
+}
+
+// AnswerInput is an applicant's answer to a question. File questions are
+// answered with a document, yes/no questions with "yes" or "no", and
+// choice questions with one of the options.
+type AnswerInput struct {
+	QuestionID int    `json:"question_id"`
+	Value      string `json:"value"`
+	DocumentID *int   `json:"document_id,omitempty"`
+}
+
+// ApplicationAnswer is an answer as employers see it, with its question
+type ApplicationAnswer struct {
+	QuestionID int          `json:"question_id"`
+	Kind       QuestionKind `json:"kind"`
+	Prompt     string       `json:"prompt"`
+	Value      string       `json:"value,omitempty"`
+	Document   *Document    `json:"document,omitempty"`
+}
+
+// documentColumns selects a Document from documents (d)
+const documentColumns = `d.id, d.user_id, d.storage_key, d.filename, d.media_type, d.size, d.created_at`
+
+func scanDocument(row interface{ Scan(...interface{}) error }) (*Document, error) {
+	d := &Document{}
+	err := row.Scan(&d.ID, &d.UserID, &d.StorageKey, &d.Filename, &d.MediaType, &d.Size, &d.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDocumentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type DocumentService struct {
+	db *pgxpool.Pool
+}
+
+func NewDocumentService(db *pgxpool.Pool) *DocumentService {
+	return &DocumentService{db: db}
+}
+
+// CreateDocument records an uploaded document
+func (s *DocumentService) CreateDocument(ctx context.Context, d *Document) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO documents (user_id, storage_key, filename, media_type, size)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		d.UserID, d.StorageKey, d.Filename, d.MediaType, d.Size,
+	).Scan(&d.ID, &d.CreatedAt)
+}
+
+// GetDocument returns a document by ID
+func (s *DocumentService) GetDocument(ctx context.Context, id int) (*Document, error) {
+	return scanDocument(s.db.QueryRow(ctx, `
+		SELECT `+documentColumns+`
+		FROM documents d
+		WHERE d.id = $1`, id))
+}
+
+// GetUserDocument returns one of a user's documents, or ErrDocumentNotFound
+// if it isn't theirs
+func (s *DocumentService) GetUserDocument(ctx context.Context, userID, id int) (*Document, error) {
+	return scanDocument(s.db.QueryRow(ctx, `
+		SELECT `+documentColumns+`
+		FROM documents d
+		WHERE d.id = $1 AND d.user_id = $2`, id, userID))
+}
+
+// GetProfileResume returns the resume on a user's profile, or nil if they
+// haven't stored one
+func (s *DocumentService) GetProfileResume(ctx context.Context, userID int) (*Document, error) {
+	d, err := scanDocument(s.db.QueryRow(ctx, `
+		SELECT `+documentColumns+`
+		FROM profile_resumes p
+		JOIN documents d ON d.id = p.document_id
+		WHERE p.user_id = $1`, userID))
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// SetProfileResume stores one of a user's documents as their profile resume
+func (s *DocumentService) SetProfileResume(ctx context.Context, userID, documentID int) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO profile_resumes (user_id, document_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET document_id = EXCLUDED.document_id`, userID, documentID)
+	return err
+}
+
+// DeleteProfileResume removes the resume from a user's profile. The
+// document stays attached to applications it was sent with.
+func (s *DocumentService) DeleteProfileResume(ctx context.Context, userID int) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM profile_resumes WHERE user_id = $1`, userID)
+	return err
+}
+
+type ApplicationFormService struct {
+	db *pgxpool.Pool
+}
+
+func NewApplicationFormService(db *pgxpool.Pool) *ApplicationFormService {
+	return &ApplicationFormService{db: db}
+}
+
+// ListQuestions returns the questions of a job in order
+func (s *ApplicationFormService) ListQuestions(ctx context.Context, jobID int) ([]*ApplicationQuestion, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, kind, prompt, required, options
+		FROM application_questions
+		WHERE job_id = $1
+		ORDER BY position`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []*ApplicationQuestion{}
+	for rows.Next() {
+		q := &ApplicationQuestion{}
+		if err := rows.Scan(&q.ID, &q.Kind, &q.Prompt, &q.Required, &q.Options); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+// SetQuestions replaces the questions of a job with the ones given, in
+// order. Questions with an ID update the existing question; those without
+// are added. Questions that have been answered can't be removed or change
+// kind, so applications keep what they were asked.
+func (s *ApplicationFormService) SetQuestions(ctx context.Context, jobID int, questions []*ApplicationQuestion) error {
+	if len(questions) > MaxQuestions {
+		return fmt.Errorf("%w: at most %d questions", ErrInvalidQuestion, MaxQuestions)
+	}
+	for _, q := range questions {
+		if q == nil {
+			return ErrInvalidQuestion
+		}
+		if err := q.Validate(); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT q.id, q.kind, EXISTS (SELECT 1 FROM application_answers a WHERE a.question_id = q.id)
+		FROM application_questions q
+		WHERE q.job_id = $1
+		FOR UPDATE`, jobID)
+	if err != nil {
+		return err
+	}
+	kinds := make(map[int]QuestionKind)
+	answered := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		var kind QuestionKind
+		var hasAnswers bool
+		if err := rows.Scan(&id, &kind, &hasAnswers); err != nil {
+			rows.Close()
+			return err
+		}
+		kinds[id] = kind
+		answered[id] = hasAnswers
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	kept := make(map[int]bool)
+	for position, q := range questions {
+		if q.ID == 0 {
+			if err := tx.QueryRow(ctx, `
+				INSERT INTO application_questions (job_id, kind, prompt, required, options, position)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id`,
+				jobID, q.Kind, q.Prompt, q.Required, q.Options, position,
+			).Scan(&q.ID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		kind, ok := kinds[q.ID]
+		if !ok || kept[q.ID] {
+			return fmt.Errorf("%w: unknown question %d", ErrInvalidQuestion, q.ID)
+		}
+		if kind != q.Kind && answered[q.ID] {
+			return ErrQuestionInUse
+		}
+		kept[q.ID] = true
+		if _, err := tx.Exec(ctx, `
+			UPDATE application_questions
+			SET kind = $2, prompt = $3, required = $4, options = $5, position = $6
+			WHERE id = $1`,
+			q.ID, q.Kind, q.Prompt, q.Required, q.Options, position); err != nil {
+			return err
+		}
+	}
+
+	for id := range kinds {
+		if kept[id] {
+			continue
+		}
+		if answered[id] {
+			return ErrQuestionInUse
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM application_questions WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// copyQuestions gives a job the questions of another, for reposts
+func copyQuestions(ctx context.Context, tx pgx.Tx, fromJobID, toJobID int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO application_questions (job_id, kind, prompt, required, options, position)
+		SELECT $2, kind, prompt, required, options, position
+		FROM application_questions
+		WHERE job_id = $1`, fromJobID, toJobID)
+	return err
+}
+
+// CheckAnswers validates a user's answers to the questions of a job and
+// returns them cleaned up, leaving out unanswered optional questions
+func (s *ApplicationFormService) CheckAnswers(ctx context.Context, jobID, userID int, answers []*AnswerInput) ([]*AnswerInput, error) {
+	questions, err := s.ListQuestions(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	given := make(map[int]*AnswerInput)
+	for _, answer := range answers {
+		if answer == nil || given[answer.QuestionID] != nil {
+			return nil, fmt.Errorf("%w: each question can be answered once", ErrInvalidAnswer)
+		}
+		given[answer.QuestionID] = answer
+	}
+
+	checked := []*AnswerInput{}
+	for _, q := range questions {
+		answer := given[q.ID]
+		delete(given, q.ID)
+		if answer != nil {
+			answer.Value = strings.TrimSpace(answer.Value)
+		}
+		if answer == nil || (answer.Value == "" && answer.DocumentID == nil) {
+			if q.Required {
+				return nil, fmt.Errorf("%w: %q requires an answer", ErrInvalidAnswer, q.Prompt)
+			}
+			continue
+		}
+
+		if err := s.checkAnswer(ctx, q, userID, answer); err != nil {
+			return nil, err
+		}
+		checked = append(checked, answer)
+	}
+
+	if len(given) > 0 {
+		return nil, fmt.Errorf("%w: answer to a question this job doesn't ask", ErrInvalidAnswer)
+	}
+	return checked, nil
+}
+
+func (s *ApplicationFormService) checkAnswer(ctx context.Context, q *ApplicationQuestion, userID int, answer *AnswerInput) error {
+	if q.Kind == QuestionFile {
+		if answer.DocumentID == nil || answer.Value != "" {
+			return fmt.Errorf("%w: %q must be answered with a document", ErrInvalidAnswer, q.Prompt)
+		}
+		var owned bool
+		if err := s.db.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM documents WHERE id = $1 AND user_id = $2)`,
+			*answer.DocumentID, userID).Scan(&owned); err != nil {
+			return err
+		}
+		if !owned {
+			return ErrDocumentNotFound
+		}
+		return nil
+	}
+
+	if answer.DocumentID != nil {
+		return fmt.Errorf("%w: %q can't be answered with a document", ErrInvalidAnswer, q.Prompt)
+	}
+	switch q.Kind {
+	case QuestionYesNo:
+		if answer.Value != "yes" && answer.Value != "no" {
+			return fmt.Errorf("%w: %q must be answered yes or no", ErrInvalidAnswer, q.Prompt)
+		}
+	case QuestionChoice:
+		for _, option := range q.Options {
+			if answer.Value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %q must be answered with one of its options", ErrInvalidAnswer, q.Prompt)
+	default:
+		if utf8.RuneCountInString(answer.Value) > MaxAnswerLength {
+			return fmt.Errorf("%w: answers are limited to %d characters", ErrInvalidAnswer, MaxAnswerLength)
+		}
+	}
+	return nil
+}
+
+// SubmitApplication stores a new application together with the resume and
+// checked answers sent with it in one transaction, so an application never
+// exists without its submission. It returns ErrAlreadyApplied if the
+// applicant already has an active application to the job.
+func (s *ApplicationFormService) SubmitApplication(ctx context.Context, application *JobApplication, resumeID *int, answers []*AnswerInput) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO job_applications (job_id, user_id, cover_letter, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		application.JobID, application.UserID, application.CoverLetter, application.Status,
+	).Scan(&application.ID, &application.CreatedAt)
+	if err != nil {
+		return applicationConflict(err)
+	}
+
+	if resumeID != nil {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO application_resumes (application_id, document_id)
+			VALUES ($1, $2)`, application.ID, *resumeID); err != nil {
+			return err
+		}
+	}
+	for _, answer := range answers {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO application_answers (application_id, question_id, value, document_id)
+			VALUES ($1, $2, $3, $4)`,
+			application.ID, answer.QuestionID, answer.Value, answer.DocumentID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetResume returns the resume sent with an application, or nil if none was
+func (s *ApplicationFormService) GetResume(ctx context.Context, applicationID int) (*Document, error) {
+	d, err := scanDocument(s.db.QueryRow(ctx, `
+		SELECT `+documentColumns+`
+		FROM application_resumes r
+		JOIN documents d ON d.id = r.document_id
+		WHERE r.application_id = $1`, applicationID))
+	if errors.Is(err, ErrDocumentNotFound) {
+		return nil, nil
+	}
+	return d, err
+}
+
+// ListAnswers returns the answers sent with an application in the order
+// the questions are asked
+func (s *ApplicationFormService) ListAnswers(ctx context.Context, applicationID int) ([]*ApplicationAnswer, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT q.id, q.kind, q.prompt, a.value, d.id, d.filename, d.media_type, d.size, d.created_at
+		FROM application_answers a
+		JOIN application_questions q ON q.id = a.question_id
+		LEFT JOIN documents d ON d.id = a.document_id
+		WHERE a.application_id = $1
+		ORDER BY q.position`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	answers := []*ApplicationAnswer{}
+	for rows.Next() {
+		a := &ApplicationAnswer{}
+		var documentID *int
+		var filename, mediaType *string
+		var size *int64
+		var createdAt *time.Time
+		if err := rows.Scan(&a.QuestionID, &a.Kind, &a.Prompt, &a.Value,
+			&documentID, &filename, &mediaType, &size, &createdAt); err != nil {
+			return nil, err
+		}
+		if documentID != nil {
+			a.Document = &Document{ID: *documentID, Filename: *filename, MediaType: *mediaType, Size: *size, CreatedAt: *createdAt}
+		}
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
@@ -0,0 +1,488 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// BookmarkKind identifies what kind of object a bookmark points to
+type BookmarkKind string
+
+const (
+	BookmarkJob  BookmarkKind = "job"
+	BookmarkPost BookmarkKind = "post"
+)
+
+func (k BookmarkKind) Valid() bool {
+	return k == BookmarkJob || k == BookmarkPost
+}
+
+// BookmarkSort orders bookmark lists
+type BookmarkSort string
+
+const (
+	// BookmarkSortCreated lists the most recently bookmarked first
+	BookmarkSortCreated BookmarkSort = "created"
+	// BookmarkSortReminder lists the soonest reminders first and bookmarks
+	// without one last
+	BookmarkSortReminder BookmarkSort = "reminder"
+	BookmarkSortTitle    BookmarkSort = "title"
+)
+
+func (s BookmarkSort) Valid() bool {
+	switch s {
+	case BookmarkSortCreated, BookmarkSortReminder, BookmarkSortTitle:
+		return true
+	}
+	return false
+}
+
+const (
+	// MaxBookmarks is how many bookmarks a user can keep
+	MaxBookmarks = 1000
+	// MaxBookmarkLists is how many lists a user can file bookmarks under
+	MaxBookmarkLists       = 50
+	maxBookmarkListNameLen = 100
+	maxBookmarkNoteLen     = 5000
+	// Snapshots of bookmarked objects are cut to these lengths
+	maxBookmarkTitleLen   = 200
+	maxBookmarkSummaryLen = 500
+)
+
+var (
+	ErrBookmarkNotFound     = errors.New("bookmark not found")
+	ErrAlreadyBookmarked    = errors.New("object is already bookmarked")
+	ErrTooManyBookmarks     = errors.New("too many bookmarks")
+	ErrInvalidBookmark      = errors.New("invalid bookmark")
+	ErrBookmarkListNotFound = errors.New("bookmark list not found")
+	ErrBookmarkListTaken    = errors.New("bookmark list name is taken")
+	ErrTooManyBookmarkLists = errors.New("too many bookmark lists")
+)
+
+// BookmarkList is a named list a user files bookmarks under
+type BookmarkList struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Count     int       `json:"count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidateBookmarkListName checks the name of a bookmark list
+func ValidateBookmarkListName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > maxBookmarkListNameLen {
+		return fmt.Errorf("%w: list name must be between 1 and %d characters", ErrInvalidBookmark, maxBookmarkListNameLen)
+	}
+	return nil
+}
+
+// Bookmark is a job posting or post a user saved for later. Bookmarks are
+// private to their user and never federated.
+//
+// ObjectIRI identifies the object, and JobID or PostID is set when it is
+// local. Title, Summary, URL and Author are a snapshot taken when the
+// object was bookmarked, so bookmarks of remote objects stay readable when
+// the object changes or disappears from the cache.
+type Bookmark struct {
+	ID        int          `json:"id"`
+	UserID    int          `json:"user_id"`
+	ListID    *int         `json:"list_id"`
+	Kind      BookmarkKind `json:"kind"`
+	ObjectIRI string       `json:"object_iri"`
+	JobID     *int         `json:"job_id,omitempty"`
+	PostID    *int         `json:"post_id,omitempty"`
+	Title     string       `json:"title"`
+	Summary   string       `json:"summary"`
+	URL       string       `json:"url"`
+	Author    string       `json:"author"`
+	Note      string       `json:"note"`
+	RemindAt  *time.Time   `json:"remind_at"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// Normalize tidies up user input and trims the snapshot to size before
+// validation
+func (b *Bookmark) Normalize() {
+	b.Note = strings.TrimSpace(b.Note)
+	b.Title = truncateRunes(strings.TrimSpace(b.Title), maxBookmarkTitleLen)
+	b.Summary = truncateRunes(strings.TrimSpace(b.Summary), maxBookmarkSummaryLen)
+}
+
+// Validate checks a bookmark
+func (b *Bookmark) Validate() error {
+	if !b.Kind.Valid() || b.ObjectIRI == "" {
+		return fmt.Errorf("%w: a job posting or post is required", ErrInvalidBookmark)
+	}
+	if utf8.RuneCountInString(b.Note) > maxBookmarkNoteLen {
+		return fmt.Errorf("%w: note must be at most %d characters", ErrInvalidBookmark, maxBookmarkNoteLen)
+	}
+	return nil
+}
+
+// truncateRunes cuts s to at most n runes, ending it with an ellipsis if
+// it was cut
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// BookmarkQuery filters and orders a user's bookmarks
+type BookmarkQuery struct {
+	UserID int
+	// ListID limits the bookmarks to a list, and Unfiled to those in none
+	ListID  int
+	Unfiled bool
+	Kind    BookmarkKind
+	// Query matches the title, summary, author and note
+	Query string
+	// HasReminder limits the bookmarks to those with a reminder, and
+	// RemindBefore to those whose reminder is before a time
+	HasReminder  bool
+	RemindBefore time.Time
+	Sort         BookmarkSort
+	Offset       int
+	Limit        int
+}
+
+const bookmarkColumns = `id, user_id, list_id, kind, object_iri, job_id, post_id, title, summary, url,
+	author, note, remind_at, created_at, updated_at`
+
+func scanBookmark(row interface{ Scan(...interface{}) error }) (*Bookmark, error) {
+	b := &Bookmark{}
+	err := row.Scan(&b.ID, &b.UserID, &b.ListID, &b.Kind, &b.ObjectIRI, &b.JobID, &b.PostID, &b.Title,
+		&b.Summary, &b.URL, &b.Author, &b.Note, &b.RemindAt, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBookmarkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+type BookmarkService struct {
+	db *pgxpool.Pool
+}
+
+func NewBookmarkService(db *pgxpool.Pool) *BookmarkService {
+	return &BookmarkService{db: db}
+}
+
+// CreateBookmarkList creates a bookmark list, enforcing MaxBookmarkLists
+func (s *BookmarkService) CreateBookmarkList(ctx context.Context, list *BookmarkList) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user's lists so concurrent creates can't exceed the limit
+	var count int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM bookmark_lists WHERE user_id = $1 FOR UPDATE
+		) l`, list.UserID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxBookmarkLists {
+		return ErrTooManyBookmarkLists
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO bookmark_lists (user_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at`,
+		list.UserID, list.Name,
+	).Scan(&list.ID, &list.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrBookmarkListTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListBookmarkLists returns a user's bookmark lists by name, with how many
+// bookmarks each holds
+func (s *BookmarkService) ListBookmarkLists(ctx context.Context, userID int) ([]*BookmarkList, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT l.id, l.user_id, l.name, COUNT(b.id), l.created_at
+		FROM bookmark_lists l
+		LEFT JOIN bookmarks b ON b.list_id = l.id
+		WHERE l.user_id = $1
+		GROUP BY l.id
+		ORDER BY lower(l.name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*BookmarkList{}
+	for rows.Next() {
+		list := &BookmarkList{}
+		if err := rows.Scan(&list.ID, &list.UserID, &list.Name, &list.Count, &list.CreatedAt); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+// RenameBookmarkList renames one of a user's bookmark lists
+func (s *BookmarkService) RenameBookmarkList(ctx context.Context, userID, id int, name string) error {
+	var exists bool
+	err := s.db.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM bookmark_lists
+			WHERE user_id = $1 AND lower(name) = lower($2) AND id <> $3)`,
+		userID, name, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrBookmarkListTaken
+	}
+
+	tag, err := s.db.Exec(ctx, `
+		UPDATE bookmark_lists
+		SET name = $3
+		WHERE id = $1 AND user_id = $2`, id, userID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBookmarkListNotFound
+	}
+	return nil
+}
+
+// DeleteBookmarkList deletes one of a user's bookmark lists. Its bookmarks
+// are kept, filed under no list.
+func (s *BookmarkService) DeleteBookmarkList(ctx context.Context, userID, id int) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM bookmark_lists
+		WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBookmarkListNotFound
+	}
+	return nil
+}
+
+// checkList returns ErrBookmarkListNotFound unless a list belongs to a
+// user. A nil list is always fine.
+func checkList(ctx context.Context, q interface {
+	QueryRow(context.Context, string, ...interface{}) pgx.Row
+}, userID int, listID *int) error {
+	if listID == nil {
+		return nil
+	}
+	var exists bool
+	if err := q.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM bookmark_lists WHERE id = $1 AND user_id = $2)`,
+		*listID, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBookmarkListNotFound
+	}
+	return nil
+}
+
+// CreateBookmark saves a bookmark, enforcing MaxBookmarks
+func (s *BookmarkService) CreateBookmark(ctx context.Context, b *Bookmark) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkList(ctx, tx, b.UserID, b.ListID); err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM bookmarks WHERE user_id = $1`, b.UserID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxBookmarks {
+		return ErrTooManyBookmarks
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO bookmarks (user_id, list_id, kind, object_iri, job_id, post_id, title, summary, url,
+			author, note, remind_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (user_id, object_iri) DO NOTHING
+		RETURNING id, created_at, updated_at`,
+		b.UserID, b.ListID, b.Kind, b.ObjectIRI, b.JobID, b.PostID, b.Title, b.Summary, b.URL,
+		b.Author, b.Note, b.RemindAt,
+	).Scan(&b.ID, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyBookmarked
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetBookmark returns one of a user's bookmarks
+func (s *BookmarkService) GetBookmark(ctx context.Context, userID, id int) (*Bookmark, error) {
+	return scanBookmark(s.db.QueryRow(ctx, `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks
+		WHERE id = $1 AND user_id = $2`, id, userID))
+}
+
+// UpdateBookmark saves changes to a bookmark's list, note and reminder. A
+// changed reminder is sent again even if an earlier one went out.
+func (s *BookmarkService) UpdateBookmark(ctx context.Context, b *Bookmark) error {
+	if err := checkList(ctx, s.db, b.UserID, b.ListID); err != nil {
+		return err
+	}
+
+	err := s.db.QueryRow(ctx, `
+		UPDATE bookmarks
+		SET list_id = $3, note = $4, remind_at = $5, updated_at = NOW(),
+			reminded_at = CASE WHEN remind_at IS DISTINCT FROM $5 THEN NULL ELSE reminded_at END
+		WHERE id = $1 AND user_id = $2
+		RETURNING updated_at`,
+		b.ID, b.UserID, b.ListID, b.Note, b.RemindAt,
+	).Scan(&b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrBookmarkNotFound
+	}
+	return err
+}
+
+// DeleteBookmark deletes one of a user's bookmarks
+func (s *BookmarkService) DeleteBookmark(ctx context.Context, userID, id int) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM bookmarks
+		WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrBookmarkNotFound
+	}
+	return nil
+}
+
+// ListBookmarks returns a page of a user's bookmarks matching a query along
+// with the total number of matches
+func (s *BookmarkService) ListBookmarks(ctx context.Context, q *BookmarkQuery) ([]*Bookmark, int, error) {
+	var args queryArgs
+	conds := []string{"user_id = " + args.add(q.UserID)}
+	if q.ListID != 0 {
+		conds = append(conds, "list_id = "+args.add(q.ListID))
+	}
+	if q.Unfiled {
+		conds = append(conds, "list_id IS NULL")
+	}
+	if q.Kind != "" {
+		conds = append(conds, "kind = "+args.add(string(q.Kind)))
+	}
+	if q.Query != "" {
+		pattern := args.add(likePattern(q.Query))
+		conds = append(conds, fmt.Sprintf("(title ILIKE %[1]s OR summary ILIKE %[1]s OR author ILIKE %[1]s OR note ILIKE %[1]s)", pattern))
+	}
+	if q.HasReminder {
+		conds = append(conds, "remind_at IS NOT NULL")
+	}
+	if !q.RemindBefore.IsZero() {
+		conds = append(conds, "remind_at <= "+args.add(q.RemindBefore))
+	}
+	where := strings.Join(conds, " AND ")
+
+	var total int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM bookmarks WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC, id DESC"
+	switch q.Sort {
+	case BookmarkSortReminder:
+		order = "remind_at NULLS LAST, " + order
+	case BookmarkSortTitle:
+		order = "lower(title), " + order
+	}
+
+	rows, err := s.db.Query(ctx, fmt.Sprintf(`
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks
+		WHERE %s
+		ORDER BY %s
+		OFFSET %s LIMIT %s`, where, order, args.add(q.Offset), args.add(q.Limit)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	bookmarks := []*Bookmark{}
+	for rows.Next() {
+		b, err := scanBookmark(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, total, rows.Err()
+}
+
+// ListDueReminders returns the bookmarks whose reminder is due by a time
+// and hasn't been sent
+func (s *BookmarkService) ListDueReminders(ctx context.Context, before time.Time, limit int) ([]*Bookmark, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+bookmarkColumns+`
+		FROM bookmarks
+		WHERE remind_at <= $1 AND reminded_at IS NULL
+		ORDER BY remind_at
+		LIMIT $2`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []*Bookmark
+	for rows.Next() {
+		b, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// MarkReminded records that a bookmark's reminder was sent. It returns
+// false if it already was, so that concurrent runs remind only once.
+func (s *BookmarkService) MarkReminded(ctx context.Context, id int) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE bookmarks
+		SET reminded_at = NOW()
+		WHERE id = $1 AND reminded_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxPreferredLocationLen is the longest preferred location accepted
+const maxPreferredLocationLen = 100
+
+// CandidatePreferences is what a user is looking for in a job, used to
+// recommend jobs to them and, if they are discoverable, to suggest them to
+// posters
+type CandidatePreferences struct {
+	Discoverable bool   `json:"discoverable"`
+	Location     string `json:"location"`
+	// RemotePolicies are the policies the user accepts; empty accepts any
+	RemotePolicies []RemotePolicy `json:"remote_policies"`
+	SalaryMin      *int64         `json:"salary_min,omitempty"`
+	SalaryCurrency string         `json:"salary_currency,omitempty"`
+	PayPeriod      PayPeriod      `json:"pay_period,omitempty"`
+	Seniority      Seniority      `json:"seniority,omitempty"`
+
+	// annualSalaryMin is SalaryMin converted to a yearly amount
+	annualSalaryMin *int64
+}
+
+// Normalize tidies up user input before validation
+func (p *CandidatePreferences) Normalize() {
+	p.Location = strings.TrimSpace(p.Location)
+	p.SalaryCurrency = strings.ToUpper(strings.TrimSpace(p.SalaryCurrency))
+	if p.RemotePolicies == nil {
+		p.RemotePolicies = []RemotePolicy{}
+	}
+}
+
+// Validate checks a user's preferences
+func (p *CandidatePreferences) Validate() error {
+	if utf8.RuneCountInString(p.Location) > maxPreferredLocationLen {
+		return errors.New("location is too long")
+	}
+	for _, policy := range p.RemotePolicies {
+		if !policy.Valid() {
+			return fmt.Errorf("invalid remote policy: %s", policy)
+		}
+	}
+	if p.SalaryMin != nil {
+		if *p.SalaryMin < 0 {
+			return errors.New("salary cannot be negative")
+		}
+		if !currencyRe.MatchString(p.SalaryCurrency) {
+			return errors.New("salary currency must be a three-letter ISO 4217 code")
+		}
+		if !p.PayPeriod.Valid() {
+			return errors.New("pay period must be one of hour, day, week, month or year")
+		}
+	} else if p.SalaryCurrency != "" || p.PayPeriod != "" {
+		return errors.New("salary currency and pay period require a salary")
+	}
+	if p.Seniority != "" && !p.Seniority.Valid() {
+		return fmt.Errorf("invalid seniority: %s", p.Seniority)
+	}
+	return nil
+}
+
+// candidatePreferencesColumns are the columns scanned by scanPreferences
+const candidatePreferencesColumns = `discoverable, location, remote_policies, salary_min,
+	COALESCE(salary_currency, ''), COALESCE(pay_period, ''), COALESCE(seniority, ''), annual_salary_min`
+
+func scanPreferences(row interface{ Scan(...interface{}) error }, p *CandidatePreferences, extra ...interface{}) error {
+	var policies []string
+	dest := append([]interface{}{&p.Discoverable, &p.Location, &policies, &p.SalaryMin,
+		&p.SalaryCurrency, &p.PayPeriod, &p.Seniority, &p.annualSalaryMin}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	p.RemotePolicies = make([]RemotePolicy, 0, len(policies))
+	for _, policy := range policies {
+		p.RemotePolicies = append(p.RemotePolicies, RemotePolicy(policy))
+	}
+	return nil
+}
+
+type CandidateService struct {
+	db *pgxpool.Pool
+}
+
+func NewCandidateService(db *pgxpool.Pool) *CandidateService {
+	return &CandidateService{db: db}
+}
+
+// GetPreferences returns a user's preferences, which are empty and not
+// discoverable if they never set any
+func (s *CandidateService) GetPreferences(ctx context.Context, userID int) (*CandidatePreferences, error) {
+	p := &CandidatePreferences{}
+	err := scanPreferences(s.db.QueryRow(ctx, `
+		SELECT `+candidatePreferencesColumns+`
+		FROM candidate_preferences
+		WHERE user_id = $1`, userID), p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &CandidatePreferences{RemotePolicies: []RemotePolicy{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SavePreferences stores a user's preferences
+func (s *CandidateService) SavePreferences(ctx context.Context, userID int, p *CandidatePreferences) error {
+	policies := make([]string, 0, len(p.RemotePolicies))
+	for _, policy := range p.RemotePolicies {
+		policies = append(policies, string(policy))
+	}
+
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO candidate_preferences (user_id, discoverable, location, remote_policies,
+			salary_min, salary_currency, pay_period, seniority)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''))
+		ON CONFLICT (user_id) DO UPDATE
+		SET discoverable = EXCLUDED.discoverable, location = EXCLUDED.location,
+			remote_policies = EXCLUDED.remote_policies, salary_min = EXCLUDED.salary_min,
+			salary_currency = EXCLUDED.salary_currency, pay_period = EXCLUDED.pay_period,
+			seniority = EXCLUDED.seniority, updated_at = NOW()`,
+		userID, p.Discoverable, p.Location, policies, p.SalaryMin,
+		p.SalaryCurrency, string(p.PayPeriod), string(p.Seniority))
+	return err
+}
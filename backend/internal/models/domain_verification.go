@@ -0,0 +1,243 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// VerificationMethod is how an organization proved it controls a domain
+type VerificationMethod string
+
+const (
+	// VerifyDNS looks for the token in a TXT record of the domain
+	VerifyDNS VerificationMethod = "dns"
+	// VerifyWellKnown fetches the token from a file under /.well-known
+	VerifyWellKnown VerificationMethod = "well_known"
+	// VerifyEmail sends a code to an admin address at the domain
+	VerifyEmail VerificationMethod = "email"
+)
+
+func (m VerificationMethod) Valid() bool {
+	switch m {
+	case VerifyDNS, VerifyWellKnown, VerifyEmail:
+		return true
+	}
+	return false
+}
+
+// VerificationEmailLocalParts are the addresses at a domain a verification
+// code can be sent to. Only the domain's administrators should be able to
+// read them, unlike any mailbox at a webmail provider.
+var VerificationEmailLocalParts = []string{"admin", "administrator", "hostmaster", "postmaster", "webmaster"}
+
+const (
+	// emailCodeTTL is how long an emailed verification code can be used
+	emailCodeTTL = 30 * time.Minute
+	// maxEmailCodeAttempts is how many wrong codes void an emailed code
+	maxEmailCodeAttempts = 5
+)
+
+const (
+	// EmailCodesPerDomain is how many codes can be emailed to a domain in
+	// EmailCodeWindow, whichever organizations ask for them
+	EmailCodesPerDomain = 5
+	// EmailCodesPerOrganization is how many codes an organization can have
+	// emailed in EmailCodeWindow, to any of its domains
+	EmailCodesPerOrganization = 10
+	EmailCodeWindow           = time.Hour
+)
+
+const (
+	// UnverifiedJobLimit is how many jobs a user can post in
+	// UnverifiedJobWindow for anyone other than a verified organization
+	UnverifiedJobLimit  = 3
+	UnverifiedJobWindow = 24 * time.Hour
+)
+
+var (
+	ErrDomainNotFound = errors.New("domain not found")
+	ErrDomainVerified = errors.New("domain is already verified")
+	ErrDomainTaken    = errors.New("domain is verified by another organization")
+	ErrInvalidCode    = errors.New("invalid or expired verification code")
+	ErrTooManyCodes   = errors.New("too many verification codes requested")
+)
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashCode returns the stored form of an emailed verification code
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetDomain returns a domain an organization claims
+func (s *OrganizationService) GetDomain(ctx context.Context, orgID int, domain string) (*OrganizationDomain, error) {
+	d, err := scanDomain(s.db.QueryRow(ctx, `
+		SELECT `+domainColumns+`
+		FROM organization_domains
+		WHERE organization_id = $1 AND domain = $2`, orgID, domain))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDomainNotFound
+	}
+	return d, err
+}
+
+// MarkDomainVerified records that an organization proved it controls a
+// domain. A domain can only be verified by one organization.
+func (s *OrganizationService) MarkDomainVerified(ctx context.Context, orgID int, domain string, method VerificationMethod) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE organization_domains
+		SET verified_at = NOW(), method = $3, email_code_hash = NULL, email_code_expires = NULL
+		WHERE organization_id = $1 AND domain = $2 AND verified_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM organization_domains
+				WHERE domain = $2 AND verified_at IS NOT NULL)`,
+		orgID, domain, method)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	d, err := s.GetDomain(ctx, orgID, domain)
+	switch {
+	case err != nil:
+		return err
+	case d.VerifiedAt != nil:
+		return ErrDomainVerified
+	default:
+		return ErrDomainTaken
+	}
+}
+
+// StartEmailVerification creates a code to email to an admin address at a
+// domain, replacing any earlier one. The code is returned for sending and
+// only its hash is kept. As each code resets the wrong guesses allowed and
+// sends an email, ErrTooManyCodes is returned once the organization or the
+// domain has had too many codes in EmailCodeWindow.
+func (s *OrganizationService) StartEmailVerification(ctx context.Context, orgID int, domain string) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the organization, then every claim of the domain, so concurrent
+	// requests can't both pass the limits
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM organizations WHERE id = $1 FOR UPDATE`, orgID); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `
+		SELECT 1 FROM organization_domains
+		WHERE domain = $1
+		ORDER BY organization_id
+		FOR UPDATE`, domain); err != nil {
+		return "", err
+	}
+
+	var byOrg, byDomain int
+	if err := tx.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE organization_id = $1),
+			COUNT(*) FILTER (WHERE domain = $2)
+		FROM verification_emails
+		WHERE (organization_id = $1 OR domain = $2) AND sent_at > $3`,
+		orgID, domain, time.Now().Add(-EmailCodeWindow)).Scan(&byOrg, &byDomain); err != nil {
+		return "", err
+	}
+	if byOrg >= EmailCodesPerOrganization || byDomain >= EmailCodesPerDomain {
+		return "", ErrTooManyCodes
+	}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE organization_domains
+		SET email_code_hash = $3, email_code_expires = $4, email_code_attempts = 0
+		WHERE organization_id = $1 AND domain = $2 AND verified_at IS NULL`,
+		orgID, domain, hashCode(code), time.Now().Add(emailCodeTTL))
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := s.GetDomain(ctx, orgID, domain); err != nil {
+			return "", err
+		}
+		return "", ErrDomainVerified
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO verification_emails (organization_id, domain)
+		VALUES ($1, $2)`, orgID, domain); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConfirmEmailVerification verifies a domain with a code sent by
+// StartEmailVerification. Codes expire, and too many wrong guesses void
+// them.
+func (s *OrganizationService) ConfirmEmailVerification(ctx context.Context, orgID int, domain, code string) error {
+	var hash *string
+	var expires *time.Time
+	var attempts int
+	err := s.db.QueryRow(ctx, `
+		UPDATE organization_domains
+		SET email_code_attempts = email_code_attempts + 1
+		WHERE organization_id = $1 AND domain = $2
+		RETURNING email_code_hash, email_code_expires, email_code_attempts`,
+		orgID, domain).Scan(&hash, &expires, &attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDomainNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if hash == nil || expires == nil || time.Now().After(*expires) || attempts > maxEmailCodeAttempts ||
+		subtle.ConstantTimeCompare([]byte(*hash), []byte(hashCode(code))) != 1 {
+		return ErrInvalidCode
+	}
+	return s.MarkDomainVerified(ctx, orgID, domain, VerifyEmail)
+}
+
+// CountUnverifiedJobs returns how many jobs a user posted since a time
+// that aren't for a verified organization
+func (s *OrganizationService) CountUnverifiedJobs(ctx context.Context, userID int, since time.Time) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM jobs j
+		WHERE j.posted_by = $1 AND j.created_at > $2
+			AND NOT EXISTS (
+				SELECT 1
+				FROM job_organizations jo
+				JOIN organization_domains d ON d.organization_id = jo.organization_id
+				WHERE jo.job_id = j.id AND d.verified_at IS NOT NULL)`,
+		userID, since).Scan(&count)
+	return count, err
+}
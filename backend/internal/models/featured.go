@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxFeaturedItems is how many posts and job postings a user can pin to
+// their profile
+const MaxFeaturedItems = 5
+
+// FeaturedKind identifies what kind of object a featured item points to
+type FeaturedKind string
+
+const (
+	FeaturedPost FeaturedKind = "post"
+	FeaturedJob  FeaturedKind = "job"
+)
+
+// Valid reports whether k is a known featured kind
+func (k FeaturedKind) Valid() bool {
+	return k == FeaturedPost || k == FeaturedJob
+}
+
+var (
+	ErrFeaturedLimit   = errors.New("featured item limit reached")
+	ErrAlreadyFeatured = errors.New("object is already featured")
+	ErrNotFeatured     = errors.New("object is not featured")
+)
+
+// FeaturedItem is a post or job posting pinned to a user's profile
+type FeaturedItem struct {
+	ID        int          `json:"id"`
+	UserID    int          `json:"user_id"`
+	Kind      FeaturedKind `json:"kind"`
+	ObjectID  int          `json:"object_id"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type FeaturedService struct {
+	db *pgxpool.Pool
+}
+
+func NewFeaturedService(db *pgxpool.Pool) *FeaturedService {
+	return &FeaturedService{db: db}
+}
+
+// ListFeatured returns a user's featured items, most recently pinned first
+func (s *FeaturedService) ListFeatured(ctx context.Context, userID int) ([]*FeaturedItem, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, user_id, kind, object_id, created_at
+		FROM featured_items
+		WHERE user_id = $1
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*FeaturedItem
+	for rows.Next() {
+		item := &FeaturedItem{}
+		if err := rows.Scan(&item.ID, &item.UserID, &item.Kind, &item.ObjectID, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AddFeatured pins an item to a user's profile, enforcing MaxFeaturedItems
+func (s *FeaturedService) AddFeatured(ctx context.Context, item *FeaturedItem) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the user row so concurrent pins can't exceed the limit
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, item.UserID); err != nil {
+		return err
+	}
+
+	var count int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM featured_items WHERE user_id = $1`, item.UserID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= MaxFeaturedItems {
+		return ErrFeaturedLimit
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO featured_items (user_id, kind, object_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, kind, object_id) DO NOTHING
+		RETURNING id, created_at`,
+		item.UserID, item.Kind, item.ObjectID).Scan(&item.ID, &item.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrAlreadyFeatured
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveFeatured unpins an item from a user's profile
+func (s *FeaturedService) RemoveFeatured(ctx context.Context, userID int, kind FeaturedKind, objectID int) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM featured_items
+		WHERE user_id = $1 AND kind = $2 AND object_id = $3`,
+		userID, kind, objectID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFeatured
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Follower is a remote actor following a local user
+type Follower struct {
+	ActorIRI    string
+	Inbox       string
+	SharedInbox string
+}
+
+type FollowerService struct {
+	db *pgxpool.Pool
+}
+
+func NewFollowerService(db *pgxpool.Pool) *FollowerService {
+	return &FollowerService{db: db}
+}
+
+// AddFollower records a remote actor following a user
+func (s *FollowerService) AddFollower(ctx context.Context, userID int, f *Follower) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO followers (user_id, actor_iri, inbox, shared_inbox)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (user_id, actor_iri) DO UPDATE
+		SET inbox = EXCLUDED.inbox, shared_inbox = EXCLUDED.shared_inbox`,
+		userID, f.ActorIRI, f.Inbox, f.SharedInbox)
+	return err
+}
+
+// RemoveFollower forgets a remote actor following a user
+func (s *FollowerService) RemoveFollower(ctx context.Context, userID int, actorIRI string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM followers
+		WHERE user_id = $1 AND actor_iri = $2`, userID, actorIRI)
+	return err
+}
+
+// FollowerInboxes returns the distinct inboxes of a user's followers,
+// preferring shared inboxes
+func (s *FollowerService) FollowerInboxes(ctx context.Context, userID int) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT COALESCE(shared_inbox, inbox)
+		FROM followers
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes, rows.Err()
+}
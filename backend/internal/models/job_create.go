@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// NewJobParts holds what is stored along with a new job
+type NewJobParts struct {
+	// OrganizationID is the organization the job is posted for, nil for
+	// none
+	OrganizationID *int
+	Details        *JobDetails
+	State          JobState
+	ExpiresAt      *time.Time
+	// Skills are the job's skills, resolved by SkillService.ResolveJobSkills
+	Skills *JobSkillList
+	// RepostedFrom is the expired job this one reposts. Its skills and
+	// application questions are copied, and Skills is ignored.
+	RepostedFrom *int
+	// ExternalRef is the ID the organization's own system gives an
+	// imported job. It requires OrganizationID.
+	ExternalRef string
+}
+
+// CreateJobWithParts stores a new job together with its organization,
+// structured fields, state, skills and external reference in one
+// transaction, so a failure in any of them leaves no job behind. It
+// returns ErrExternalRefTaken if another of the organization's jobs has
+// the external reference.
+func (s *JobService) CreateJobWithParts(ctx context.Context, job *Job, parts *NewJobParts) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO jobs (title, company, location, description, requirements,
+			salary_range, contact_email, posted_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		job.Title, job.Company, job.Location, job.Description, job.Requirements,
+		job.SalaryRange, job.ContactEmail, job.PostedBy,
+	).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if parts.OrganizationID != nil {
+		if err := setJobOrganization(ctx, tx, job.ID, *parts.OrganizationID); err != nil {
+			return err
+		}
+	}
+	if err := saveDetails(ctx, tx, job.ID, parts.Details); err != nil {
+		return err
+	}
+	if err := initLifecycle(ctx, tx, job.ID, parts.State, parts.ExpiresAt, parts.RepostedFrom); err != nil {
+		return err
+	}
+
+	if parts.ExternalRef != "" {
+		if err := insertExternalRef(ctx, tx, *parts.OrganizationID, job.ID, parts.ExternalRef); err != nil {
+			return err
+		}
+	}
+
+	if parts.RepostedFrom != nil {
+		if err := copyJobSkills(ctx, tx, *parts.RepostedFrom, job.ID); err != nil {
+			return err
+		}
+		if err := copyQuestions(ctx, tx, *parts.RepostedFrom, job.ID); err != nil {
+			return err
+		}
+	} else if parts.Skills != nil {
+		if err := replaceJobSkills(ctx, tx, job.ID, parts.Skills); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// JobUpdateParts holds what is saved along with changes to a job
+type JobUpdateParts struct {
+	Details *JobDetails
+	// SetExpiry replaces the job's expiry with ExpiresAt, which nil clears
+	SetExpiry bool
+	ExpiresAt *time.Time
+	// Skills are the job's skills, resolved by SkillService.ResolveJobSkills
+	Skills *JobSkillList
+}
+
+// UpdateJobWithParts saves changes to a job together with its structured
+// fields, expiry and skills in one transaction, so a failure in any of
+// them leaves the job as it was
+func (s *JobService) UpdateJobWithParts(ctx context.Context, job *Job, parts *JobUpdateParts) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		UPDATE jobs
+		SET title = $2, company = $3, location = $4, description = $5, requirements = $6,
+			salary_range = $7, contact_email = $8, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`,
+		job.ID, job.Title, job.Company, job.Location, job.Description, job.Requirements,
+		job.SalaryRange, job.ContactEmail,
+	).Scan(&job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := saveDetails(ctx, tx, job.ID, parts.Details); err != nil {
+		return err
+	}
+	if parts.SetExpiry {
+		if err := setExpiry(ctx, tx, job.ID, parts.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	if parts.Skills != nil {
+		if err := replaceJobSkills(ctx, tx, job.ID, parts.Skills); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
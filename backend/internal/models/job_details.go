@@ -0,0 +1,383 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmploymentType is the kind of contract a job is offered on
+type EmploymentType string
+
+const (
+	EmploymentFullTime   EmploymentType = "full_time"
+	EmploymentPartTime   EmploymentType = "part_time"
+	EmploymentContract   EmploymentType = "contract"
+	EmploymentTemporary  EmploymentType = "temporary"
+	EmploymentInternship EmploymentType = "internship"
+	EmploymentVolunteer  EmploymentType = "volunteer"
+)
+
+var employmentTypeLabels = map[EmploymentType]string{
+	EmploymentFullTime:   "Full-time",
+	EmploymentPartTime:   "Part-time",
+	EmploymentContract:   "Contract",
+	EmploymentTemporary:  "Temporary",
+	EmploymentInternship: "Internship",
+	EmploymentVolunteer:  "Volunteer",
+}
+
+// Valid reports whether t is a known employment type
+func (t EmploymentType) Valid() bool {
+	_, ok := employmentTypeLabels[t]
+	return ok
+}
+
+// Label returns the display name of an employment type
+func (t EmploymentType) Label() string {
+	return employmentTypeLabels[t]
+}
+
+// RemotePolicy is where a job can be done from
+type RemotePolicy string
+
+const (
+	RemoteOnsite RemotePolicy = "onsite"
+	RemoteHybrid RemotePolicy = "hybrid"
+	RemoteRemote RemotePolicy = "remote"
+)
+
+// Valid reports whether p is a known remote policy
+func (p RemotePolicy) Valid() bool {
+	return p == RemoteOnsite || p == RemoteHybrid || p == RemoteRemote
+}
+
+// Seniority is the experience level a job is aimed at
+type Seniority string
+
+const (
+	SeniorityIntern    Seniority = "intern"
+	SeniorityJunior    Seniority = "junior"
+	SeniorityMid       Seniority = "mid"
+	SenioritySenior    Seniority = "senior"
+	SeniorityLead      Seniority = "lead"
+	SeniorityPrincipal Seniority = "principal"
+	SeniorityExecutive Seniority = "executive"
+)
+
+// Valid reports whether s is a known seniority level
+func (s Seniority) Valid() bool {
+	switch s {
+	case SeniorityIntern, SeniorityJunior, SeniorityMid, SenioritySenior,
+		SeniorityLead, SeniorityPrincipal, SeniorityExecutive:
+		return true
+	}
+	return false
+}
+
+// PayPeriod is the period a salary is paid for
+type PayPeriod string
+
+const (
+	PayHour  PayPeriod = "hour"
+	PayDay   PayPeriod = "day"
+	PayWeek  PayPeriod = "week"
+	PayMonth PayPeriod = "month"
+	PayYear  PayPeriod = "year"
+)
+
+// Valid reports whether p is a known pay period
+func (p PayPeriod) Valid() bool {
+	switch p {
+	case PayHour, PayDay, PayWeek, PayMonth, PayYear:
+		return true
+	}
+	return false
+}
+
+const (
+	maxRemoteRegions   = 10
+	maxRemoteRegionLen = 64
+)
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// JobDetails holds the structured fields of a job posting. Jobs created
+// before they existed have none, and every field is optional.
+type JobDetails struct {
+	SalaryMin      *int64         `json:"salary_min,omitempty"`
+	SalaryMax      *int64         `json:"salary_max,omitempty"`
+	SalaryCurrency string         `json:"salary_currency,omitempty"`
+	PayPeriod      PayPeriod      `json:"pay_period,omitempty"`
+	EmploymentType EmploymentType `json:"employment_type,omitempty"`
+	RemotePolicy   RemotePolicy   `json:"remote_policy,omitempty"`
+	// RemoteRegions restricts where remote candidates may live, e.g. "EU"
+	RemoteRegions []string  `json:"remote_regions,omitempty"`
+	Seniority     Seniority `json:"seniority,omitempty"`
+}
+
+// Normalize tidies up user input before validation
+func (d *JobDetails) Normalize() {
+	d.SalaryCurrency = strings.ToUpper(strings.TrimSpace(d.SalaryCurrency))
+
+	regions := d.RemoteRegions[:0]
+	for _, region := range d.RemoteRegions {
+		if region = strings.TrimSpace(region); region != "" {
+			regions = append(regions, region)
+		}
+	}
+	d.RemoteRegions = regions
+}
+
+// Equal reports whether two jobs have the same structured fields
+func (d *JobDetails) Equal(other *JobDetails) bool {
+	if !equalInt64(d.SalaryMin, other.SalaryMin) || !equalInt64(d.SalaryMax, other.SalaryMax) ||
+		d.SalaryCurrency != other.SalaryCurrency || d.PayPeriod != other.PayPeriod ||
+		d.EmploymentType != other.EmploymentType || d.RemotePolicy != other.RemotePolicy ||
+		d.Seniority != other.Seniority || len(d.RemoteRegions) != len(other.RemoteRegions) {
+		return false
+	}
+	for i, region := range d.RemoteRegions {
+		if other.RemoteRegions[i] != region {
+			return false
+		}
+	}
+	return true
+}
+
+// equalInt64 reports whether two optional numbers are equal
+func equalInt64(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Validate checks the structured fields of a job
+func (d *JobDetails) Validate() error {
+	if d.SalaryMin != nil || d.SalaryMax != nil {
+		if (d.SalaryMin != nil && *d.SalaryMin < 0) || (d.SalaryMax != nil && *d.SalaryMax < 0) {
+			return errors.New("salary cannot be negative")
+		}
+		if d.SalaryMin != nil && d.SalaryMax != nil && *d.SalaryMin > *d.SalaryMax {
+			return errors.New("minimum salary cannot exceed maximum salary")
+		}
+		if !currencyRe.MatchString(d.SalaryCurrency) {
+			return errors.New("salary currency must be a three-letter ISO 4217 code")
+		}
+		if !d.PayPeriod.Valid() {
+			return errors.New("pay period must be one of hour, day, week, month or year")
+		}
+	} else if d.SalaryCurrency != "" || d.PayPeriod != "" {
+		return errors.New("salary currency and pay period require a salary")
+	}
+
+	if d.EmploymentType != "" && !d.EmploymentType.Valid() {
+		return fmt.Errorf("invalid employment type: %s", d.EmploymentType)
+	}
+	if d.RemotePolicy != "" && !d.RemotePolicy.Valid() {
+		return fmt.Errorf("invalid remote policy: %s", d.RemotePolicy)
+	}
+	if len(d.RemoteRegions) > 0 {
+		if d.RemotePolicy != RemoteRemote && d.RemotePolicy != RemoteHybrid {
+			return errors.New("remote regions require a remote or hybrid policy")
+		}
+		if len(d.RemoteRegions) > maxRemoteRegions {
+			return fmt.Errorf("at most %d remote regions are allowed", maxRemoteRegions)
+		}
+		for _, region := range d.RemoteRegions {
+			if len(region) > maxRemoteRegionLen {
+				return errors.New("remote region is too long")
+			}
+		}
+	}
+	if d.Seniority != "" && !d.Seniority.Valid() {
+		return fmt.Errorf("invalid seniority: %s", d.Seniority)
+	}
+	return nil
+}
+
+// groupThousands formats n with comma separators
+func groupThousands(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// SalaryText formats the structured salary for display, such as
+// "USD 80,000–100,000 per year", or returns "" if there is none
+func (d *JobDetails) SalaryText() string {
+	var amount string
+	switch {
+	case d.SalaryMin != nil && d.SalaryMax != nil && *d.SalaryMin != *d.SalaryMax:
+		amount = groupThousands(*d.SalaryMin) + "–" + groupThousands(*d.SalaryMax)
+	case d.SalaryMin != nil:
+		amount = groupThousands(*d.SalaryMin)
+	case d.SalaryMax != nil:
+		amount = "up to " + groupThousands(*d.SalaryMax)
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%s %s per %s", d.SalaryCurrency, amount, d.PayPeriod)
+}
+
+// JobWithDetails is a job along with its structured fields, lifecycle and
+// skills, as returned by the API. Type carries the display name of the
+// employment type.
+type JobWithDetails struct {
+	*Job
+	JobDetails
+	JobLifecycle
+	Type             string   `json:"type,omitempty"`
+	Skills           []string `json:"skills"`
+	NiceToHaveSkills []string `json:"nice_to_have_skills"`
+	// Organization is who the job is posted for, if anyone. Verified badges
+	// jobs from organizations that proved they control a domain.
+	Organization *Organization `json:"organization,omitempty"`
+	Verified     bool          `json:"verified"`
+}
+
+type JobDetailsService struct {
+	db               *pgxpool.Pool
+	lifecycleService *JobLifecycleService
+	skillService     *SkillService
+	orgService       *OrganizationService
+}
+
+func NewJobDetailsService(db *pgxpool.Pool) *JobDetailsService {
+	return &JobDetailsService{
+		db:               db,
+		lifecycleService: NewJobLifecycleService(db),
+		skillService:     NewSkillService(db),
+		orgService:       NewOrganizationService(db),
+	}
+}
+
+// saveDetails stores the structured fields of a job
+func saveDetails(ctx context.Context, tx pgx.Tx, jobID int, d *JobDetails) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO job_details (job_id, salary_min, salary_max, salary_currency, pay_period,
+			employment_type, remote_policy, remote_regions, seniority)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, NULLIF($9, ''))
+		ON CONFLICT (job_id) DO UPDATE
+		SET salary_min = EXCLUDED.salary_min, salary_max = EXCLUDED.salary_max,
+			salary_currency = EXCLUDED.salary_currency, pay_period = EXCLUDED.pay_period,
+			employment_type = EXCLUDED.employment_type, remote_policy = EXCLUDED.remote_policy,
+			remote_regions = EXCLUDED.remote_regions, seniority = EXCLUDED.seniority`,
+		jobID, d.SalaryMin, d.SalaryMax, d.SalaryCurrency, string(d.PayPeriod),
+		string(d.EmploymentType), string(d.RemotePolicy), append([]string{}, d.RemoteRegions...), string(d.Seniority))
+	return err
+}
+
+// GetDetails returns the structured fields of a job, which are all empty
+// for jobs that have none
+func (s *JobDetailsService) GetDetails(ctx context.Context, jobID int) (*JobDetails, error) {
+	d := &JobDetails{}
+	err := s.db.QueryRow(ctx, `
+		SELECT salary_min, salary_max, COALESCE(salary_currency, ''), COALESCE(pay_period, ''),
+			COALESCE(employment_type, ''), COALESCE(remote_policy, ''), remote_regions, COALESCE(seniority, '')
+		FROM job_details
+		WHERE job_id = $1`, jobID,
+	).Scan(&d.SalaryMin, &d.SalaryMax, &d.SalaryCurrency, &d.PayPeriod,
+		&d.EmploymentType, &d.RemotePolicy, &d.RemoteRegions, &d.Seniority)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &JobDetails{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// listDetails returns the structured fields of jobs by job ID. Jobs that
+// have none are left out.
+func (s *JobDetailsService) listDetails(ctx context.Context, jobIDs []int) (map[int]*JobDetails, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT job_id, salary_min, salary_max, COALESCE(salary_currency, ''), COALESCE(pay_period, ''),
+			COALESCE(employment_type, ''), COALESCE(remote_policy, ''), remote_regions, COALESCE(seniority, '')
+		FROM job_details
+		WHERE job_id = ANY($1)`, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	details := make(map[int]*JobDetails)
+	for rows.Next() {
+		d := &JobDetails{}
+		var jobID int
+		if err := rows.Scan(&jobID, &d.SalaryMin, &d.SalaryMax, &d.SalaryCurrency, &d.PayPeriod,
+			&d.EmploymentType, &d.RemotePolicy, &d.RemoteRegions, &d.Seniority); err != nil {
+			return nil, err
+		}
+		details[jobID] = d
+	}
+	return details, rows.Err()
+}
+
+// WithDetails attaches their structured fields, lifecycle, skills and
+// organization to jobs. Each is loaded for all the jobs in one query.
+func (s *JobDetailsService) WithDetails(ctx context.Context, jobs ...*Job) ([]*JobWithDetails, error) {
+	ids := make([]int, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+
+	details, err := s.listDetails(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	lifecycles, err := s.lifecycleService.listLifecycles(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	skills, err := s.skillService.listJobsSkills(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	orgs, err := s.orgService.listJobOrganizations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*JobWithDetails, 0, len(jobs))
+	for _, job := range jobs {
+		d, ok := details[job.ID]
+		if !ok {
+			d = &JobDetails{}
+		}
+		l, ok := lifecycles[job.ID]
+		if !ok {
+			l = &JobLifecycle{State: JobPublished}
+		}
+		org := orgs[job.ID]
+
+		j := &JobWithDetails{
+			Job:              job,
+			JobDetails:       *d,
+			JobLifecycle:     *l,
+			Type:             d.EmploymentType.Label(),
+			Skills:           []string{},
+			NiceToHaveSkills: []string{},
+			Organization:     org,
+			Verified:         org != nil && org.Verified,
+		}
+		for _, skill := range skills[job.ID] {
+			if skill.Required {
+				j.Skills = append(j.Skills, skill.Name)
+			} else {
+				j.NiceToHaveSkills = append(j.NiceToHaveSkills, skill.Name)
+			}
+		}
+		result = append(result, j)
+	}
+	return result, nil
+}
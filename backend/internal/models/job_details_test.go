@@ -0,0 +1,95 @@
+package models
+
+import "testing"
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+func TestJobDetailsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		details JobDetails
+		wantErr bool
+	}{
+		{name: "empty", details: JobDetails{}},
+		{
+			name:    "salary range",
+			details: JobDetails{SalaryMin: int64Ptr(80000), SalaryMax: int64Ptr(100000), SalaryCurrency: "USD", PayPeriod: PayYear},
+		},
+		{
+			name:    "minimum only",
+			details: JobDetails{SalaryMin: int64Ptr(25), SalaryCurrency: "EUR", PayPeriod: PayHour},
+		},
+		{
+			name:    "maximum only",
+			details: JobDetails{SalaryMax: int64Ptr(5000), SalaryCurrency: "GBP", PayPeriod: PayMonth},
+		},
+		{
+			name:    "equal bounds",
+			details: JobDetails{SalaryMin: int64Ptr(500), SalaryMax: int64Ptr(500), SalaryCurrency: "CHF", PayPeriod: PayDay},
+		},
+		{
+			name:    "negative minimum",
+			details: JobDetails{SalaryMin: int64Ptr(-1), SalaryCurrency: "USD", PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "negative maximum",
+			details: JobDetails{SalaryMax: int64Ptr(-1), SalaryCurrency: "USD", PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "minimum above maximum",
+			details: JobDetails{SalaryMin: int64Ptr(100000), SalaryMax: int64Ptr(80000), SalaryCurrency: "USD", PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "missing currency",
+			details: JobDetails{SalaryMin: int64Ptr(80000), PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "lowercase currency",
+			details: JobDetails{SalaryMin: int64Ptr(80000), SalaryCurrency: "usd", PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "currency too long",
+			details: JobDetails{SalaryMin: int64Ptr(80000), SalaryCurrency: "USDT", PayPeriod: PayYear},
+			wantErr: true,
+		},
+		{
+			name:    "missing pay period",
+			details: JobDetails{SalaryMin: int64Ptr(80000), SalaryCurrency: "USD"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown pay period",
+			details: JobDetails{SalaryMin: int64Ptr(80000), SalaryCurrency: "USD", PayPeriod: "fortnight"},
+			wantErr: true,
+		},
+		{
+			name:    "currency without salary",
+			details: JobDetails{SalaryCurrency: "USD"},
+			wantErr: true,
+		},
+		{
+			name:    "pay period without salary",
+			details: JobDetails{PayPeriod: PayYear},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.details.Validate()
+			if tt.wantErr && err == nil {
+				t.Fatal("Validate() = nil, want an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+		})
+	}
+}
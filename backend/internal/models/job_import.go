@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxImportJobs is how many jobs a single import can hold
+	MaxImportJobs = 500
+	// MaxExternalRefLength is how long an external reference can be
+	MaxExternalRefLength = 200
+)
+
+// ErrExternalRefTaken is returned when an external reference already
+// belongs to another of the organization's jobs
+var ErrExternalRefTaken = errors.New("external reference belongs to another job")
+
+// ValidExternalRef reports whether ref can identify an imported job
+func ValidExternalRef(ref string) bool {
+	return ref != "" && utf8.RuneCountInString(ref) <= MaxExternalRefLength && strings.TrimSpace(ref) == ref
+}
+
+// JobImportService tracks the external references of jobs organizations
+// import from their own systems
+type JobImportService struct {
+	db *pgxpool.Pool
+}
+
+func NewJobImportService(db *pgxpool.Pool) *JobImportService {
+	return &JobImportService{db: db}
+}
+
+// ListExternalRefs returns the external references of an organization's
+// jobs, mapped to the job IDs
+func (s *JobImportService) ListExternalRefs(ctx context.Context, orgID int) (map[string]int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT external_ref, job_id
+		FROM job_external_refs
+		WHERE organization_id = $1`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make(map[string]int)
+	for rows.Next() {
+		var ref string
+		var jobID int
+		if err := rows.Scan(&ref, &jobID); err != nil {
+			return nil, err
+		}
+		refs[ref] = jobID
+	}
+	return refs, rows.Err()
+}
+
+// GetJobByExternalRef returns the ID of the organization's job with an
+// external reference
+func (s *JobImportService) GetJobByExternalRef(ctx context.Context, orgID int, ref string) (int, error) {
+	var jobID int
+	err := s.db.QueryRow(ctx, `
+		SELECT job_id
+		FROM job_external_refs
+		WHERE organization_id = $1 AND external_ref = $2`, orgID, ref,
+	).Scan(&jobID)
+	return jobID, err
+}
+
+// insertExternalRef gives a new job an external reference, returning
+// ErrExternalRefTaken if another job has it
+func insertExternalRef(ctx context.Context, tx pgx.Tx, orgID, jobID int, ref string) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO job_external_refs (job_id, organization_id, external_ref)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, external_ref) DO NOTHING
+		RETURNING job_id`, jobID, orgID, ref,
+	).Scan(&jobID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrExternalRefTaken
+	}
+	return err
+}
+
+// SetExternalRef sets the external reference of one of an organization's
+// jobs, replacing any it had
+func (s *JobImportService) SetExternalRef(ctx context.Context, orgID, jobID int, ref string) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO job_external_refs (job_id, organization_id, external_ref)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (job_id) DO UPDATE
+		SET external_ref = EXCLUDED.external_ref`, jobID, orgID, ref)
+	return err
+}
+
+// ListOrganizationJobIDs returns the IDs of all of an organization's jobs
+// whatever their state, oldest first
+func (s *JobImportService) ListOrganizationJobIDs(ctx context.Context, orgID int) ([]int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT jo.job_id
+		FROM job_organizations jo
+		JOIN jobs j ON j.id = jo.job_id
+		WHERE jo.organization_id = $1
+		ORDER BY j.created_at, j.id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
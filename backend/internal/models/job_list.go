@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+)
+
+// GetJobs returns the jobs with the given IDs in one query, in the order of
+// ids. Jobs that no longer exist are left out.
+func (s *JobService) GetJobs(ctx context.Context, ids []int) ([]*Job, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, title, company, location, description, requirements,
+			salary_range, contact_email, posted_by, created_at, updated_at
+		FROM jobs
+		WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*Job, len(ids))
+	for rows.Next() {
+		job := &Job{}
+		if err := rows.Scan(&job.ID, &job.Title, &job.Company, &job.Location, &job.Description,
+			&job.Requirements, &job.SalaryRange, &job.ContactEmail, &job.PostedBy,
+			&job.CreatedAt, &job.UpdatedAt); err != nil {
+			return nil, err
+		}
+		byID[job.ID] = job
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(byID))
+	for _, id := range ids {
+		if job, ok := byID[id]; ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,363 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobSort orders job search results
+type JobSort string
+
+const (
+	// JobSortRelevance ranks by how well jobs match the query, falling back
+	// to recency when there is no query
+	JobSortRelevance JobSort = "relevance"
+	JobSortRecent    JobSort = "recent"
+)
+
+// Valid reports whether s is a known sort order
+func (s JobSort) Valid() bool {
+	return s == JobSortRelevance || s == JobSortRecent
+}
+
+// facetLimit is the number of values returned per facet
+const facetLimit = 10
+
+// searchFrom joins jobs with their structured details, which older jobs
+// don't have
+const searchFrom = `jobs j LEFT JOIN job_details d ON d.job_id = j.id`
+
+// remotePolicyExpr is the remote policy of a job, guessed from its location
+// when it has none
+const remotePolicyExpr = `COALESCE(d.remote_policy, CASE WHEN j.location ILIKE '%remote%' THEN 'remote' ELSE 'onsite' END)`
+
+// JobSearch describes a job search. Zero values leave a filter unset.
+type JobSearch struct {
+	// Query is matched against title, company, description and
+	// requirements using web search syntax: quoted phrases, OR and -word
+	Query    string
+	Location string
+	Company  string
+	Remote   RemotePolicy
+	// EmploymentType and Seniority match jobs with exactly that value
+	EmploymentType EmploymentType
+	Seniority      Seniority
+	// MinSalary matches jobs in SalaryCurrency whose yearly salary can reach
+	// at least this much, and MaxSalary those whose yearly salary starts at
+	// no more than this much
+	MinSalary      int64
+	MaxSalary      int64
+	SalaryCurrency string
+	// Skills are names or aliases of skills every matching job must list,
+	// whether required or nice to have
+	Skills      []string
+	PostedSince time.Time
+	Sort        JobSort
+	Offset      int
+	Limit       int
+}
+
+// FacetCount is the number of matching jobs with a given value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// JobSearchResult is a page of search results with the total number of
+// matches and facet counts for building filter chips
+type JobSearchResult struct {
+	Jobs   []*JobWithDetails        `json:"jobs"`
+	Total  int                      `json:"total"`
+	Facets map[string][]*FacetCount `json:"facets"`
+}
+
+type JobSearchService struct {
+	db                *pgxpool.Pool
+	jobService        *JobService
+	jobDetailsService *JobDetailsService
+}
+
+func NewJobSearchService(db *pgxpool.Pool) *JobSearchService {
+	return &JobSearchService{
+		db:                db,
+		jobService:        NewJobService(db),
+		jobDetailsService: NewJobDetailsService(db),
+	}
+}
+
+// queryArgs collects the positional arguments of a query being built
+type queryArgs []interface{}
+
+// add appends an argument and returns its placeholder
+func (a *queryArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// likePattern returns a pattern matching s anywhere, with LIKE wildcards
+// in s escaped
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// where builds the conditions of a search. The filter of the facet named in
+// except is left out, so a facet counts what selecting each of its values
+// would match rather than only the value already selected.
+func (q *JobSearch) where(args *queryArgs, except string) string {
+	conds := []string{listedJobCondition}
+
+	if q.Query != "" {
+		conds = append(conds, fmt.Sprintf("j.search_vector @@ websearch_to_tsquery('english', %s)", args.add(q.Query)))
+	}
+	if q.Location != "" && except != "location" {
+		conds = append(conds, "j.location ILIKE "+args.add(likePattern(q.Location)))
+	}
+	if q.Company != "" && except != "company" {
+		conds = append(conds, "lower(j.company) = lower("+args.add(q.Company)+")")
+	}
+	if q.Remote != "" && except != "remote" {
+		conds = append(conds, fmt.Sprintf("%s = %s", remotePolicyExpr, args.add(string(q.Remote))))
+	}
+	if q.EmploymentType != "" && except != "employment_type" {
+		conds = append(conds, "d.employment_type = "+args.add(string(q.EmploymentType)))
+	}
+	if q.Seniority != "" && except != "seniority" {
+		conds = append(conds, "d.seniority = "+args.add(string(q.Seniority)))
+	}
+	// Salary has no facet, as counts per amount wouldn't make useful chips,
+	// so its filter is never left out
+	if q.SalaryCurrency != "" {
+		conds = append(conds, "d.salary_currency = "+args.add(q.SalaryCurrency))
+		if q.MinSalary > 0 {
+			conds = append(conds, "COALESCE(d.annual_salary_max, d.annual_salary_min) >= "+args.add(q.MinSalary))
+		}
+		if q.MaxSalary > 0 {
+			conds = append(conds, "COALESCE(d.annual_salary_min, d.annual_salary_max) <= "+args.add(q.MaxSalary))
+		}
+	}
+	if len(q.Skills) > 0 && except != "skills" {
+		for _, skill := range q.Skills {
+			slug := args.add(NormalizeSkill(skill))
+			conds = append(conds, fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM job_skills js
+				JOIN skills sk ON sk.id = js.skill_id
+				WHERE js.job_id = j.id AND (sk.slug = %[1]s
+					OR sk.id = (SELECT skill_id FROM skill_aliases WHERE alias = %[1]s)))`, slug))
+		}
+	}
+	if !q.PostedSince.IsZero() && except != "posted" {
+		conds = append(conds, "j.created_at >= "+args.add(q.PostedSince))
+	}
+
+	return strings.Join(conds, " AND ")
+}
+
+// SearchJobs returns a page of jobs matching a search along with the total
+// number of matches and facet counts
+func (s *JobSearchService) SearchJobs(ctx context.Context, q *JobSearch) (*JobSearchResult, error) {
+	result := &JobSearchResult{Jobs: []*JobWithDetails{}, Facets: make(map[string][]*FacetCount)}
+
+	var args queryArgs
+	where := q.where(&args, "")
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM "+searchFrom+" WHERE "+where, args...).Scan(&result.Total); err != nil {
+		return nil, err
+	}
+
+	order := "j.created_at DESC, j.id DESC"
+	if q.Sort != JobSortRecent && q.Query != "" {
+		order = fmt.Sprintf("ts_rank_cd(j.search_vector, websearch_to_tsquery('english', %s)) DESC, %s", args.add(q.Query), order)
+	}
+	query := fmt.Sprintf(`
+		SELECT j.id
+		FROM %s
+		WHERE %s
+		ORDER BY %s
+		OFFSET %s LIMIT %s`, searchFrom, where, order, args.add(q.Offset), args.add(q.Limit))
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load through JobService so results match every other job listing
+	jobs, err := s.jobService.GetJobs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) > 0 {
+		if result.Jobs, err = s.jobDetailsService.WithDetails(ctx, jobs...); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, facet := range jobFacets {
+		counts, err := s.facet(ctx, q, name, facet)
+		if err != nil {
+			return nil, err
+		}
+		result.Facets[name] = counts
+	}
+
+	return result, nil
+}
+
+// ListNewMatches returns the IDs of listed jobs matching a search that
+// were posted or moved to another state after since, newest first. Jobs
+// published from a draft or reopened count as new.
+func (s *JobSearchService) ListNewMatches(ctx context.Context, q *JobSearch, since time.Time, limit int) ([]int, error) {
+	var args queryArgs
+	where := q.where(&args, "")
+	query := fmt.Sprintf(`
+		SELECT j.id
+		FROM %[1]s
+		WHERE %[2]s AND (j.created_at > %[3]s OR EXISTS (
+			SELECT 1 FROM job_states st WHERE st.job_id = j.id AND st.state_changed_at > %[3]s))
+		ORDER BY j.created_at DESC, j.id DESC
+		LIMIT %[4]s`, searchFrom, where, args.add(since), args.add(limit))
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ListedJob is a listed job as it appears in the sitemap
+type ListedJob struct {
+	ID int
+	// LastModified is when the job was posted or last changed state
+	LastModified time.Time
+}
+
+// CountListedJobs returns how many jobs are listed
+func (s *JobSearchService) CountListedJobs(ctx context.Context) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM jobs j WHERE "+listedJobCondition).Scan(&count)
+	return count, err
+}
+
+// ListListedJobs returns a page of the listed jobs, oldest first so that
+// pages stay stable as jobs are posted
+func (s *JobSearchService) ListListedJobs(ctx context.Context, offset, limit int) ([]*ListedJob, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT j.id, GREATEST(j.created_at, COALESCE(js.state_changed_at, j.created_at))
+		FROM jobs j
+		LEFT JOIN job_states js ON js.job_id = j.id
+		WHERE `+listedJobCondition+`
+		ORDER BY j.id
+		OFFSET $1 LIMIT $2`, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*ListedJob
+	for rows.Next() {
+		job := &ListedJob{}
+		if err := rows.Scan(&job.ID, &job.LastModified); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// jobFacets maps facet names to the query counting jobs per value. The
+// placeholders receive the joined tables and the search conditions.
+var jobFacets = map[string]string{
+	"company": `
+		SELECT j.company, COUNT(*)
+		FROM %s
+		WHERE %s AND j.company <> ''
+		GROUP BY j.company
+		ORDER BY COUNT(*) DESC, j.company`,
+	"location": `
+		SELECT j.location, COUNT(*)
+		FROM %s
+		WHERE %s AND j.location <> ''
+		GROUP BY j.location
+		ORDER BY COUNT(*) DESC, j.location`,
+	"remote": `
+		SELECT ` + remotePolicyExpr + `, COUNT(*)
+		FROM %s
+		WHERE %s
+		GROUP BY 1
+		ORDER BY COUNT(*) DESC, 1`,
+	"employment_type": `
+		SELECT d.employment_type, COUNT(*)
+		FROM %s
+		WHERE %s AND d.employment_type IS NOT NULL
+		GROUP BY d.employment_type
+		ORDER BY COUNT(*) DESC, d.employment_type`,
+	"seniority": `
+		SELECT d.seniority, COUNT(*)
+		FROM %s
+		WHERE %s AND d.seniority IS NOT NULL
+		GROUP BY d.seniority
+		ORDER BY COUNT(*) DESC, d.seniority`,
+	"skills": `
+		SELECT sk.name, COUNT(*)
+		FROM %s
+		JOIN job_skills js ON js.job_id = j.id
+		JOIN skills sk ON sk.id = js.skill_id
+		WHERE %s
+		GROUP BY sk.name
+		ORDER BY COUNT(*) DESC, sk.name`,
+	"posted": `
+		SELECT period, COUNT(*)
+		FROM %s
+		CROSS JOIN (VALUES ('24h', INTERVAL '1 day'), ('7d', INTERVAL '7 days'), ('30d', INTERVAL '30 days')) p (period, age)
+		WHERE %s AND j.created_at >= NOW() - p.age
+		GROUP BY period, age
+		ORDER BY age`,
+}
+
+// facet returns the counts of one facet
+func (s *JobSearchService) facet(ctx context.Context, q *JobSearch, name, query string) ([]*FacetCount, error) {
+	var args queryArgs
+	sql := fmt.Sprintf(query, searchFrom, q.where(&args, name)) + " LIMIT " + args.add(facetLimit)
+
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*FacetCount{}
+	for rows.Next() {
+		c := &FacetCount{}
+		if err := rows.Scan(&c.Value, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
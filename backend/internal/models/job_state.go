@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JobState is where a job posting is in its lifecycle
+type JobState string
+
+const (
+	// JobDraft postings are only visible to their poster and not federated
+	JobDraft     JobState = "draft"
+	JobPublished JobState = "published"
+	// JobPaused postings are hidden from listings but can be published again
+	JobPaused  JobState = "paused"
+	JobFilled  JobState = "filled"
+	JobExpired JobState = "expired"
+)
+
+// Valid reports whether s is a known job state
+func (s JobState) Valid() bool {
+	_, ok := jobTransitions[s]
+	return ok
+}
+
+// Closed reports whether a job has ended for good, leaving reposting as the
+// only way to advertise it again
+func (s JobState) Closed() bool {
+	return s == JobFilled || s == JobExpired
+}
+
+// jobTransitions maps each state to the states a job can move to from it
+var jobTransitions = map[JobState][]JobState{
+	JobDraft:     {JobPublished},
+	JobPublished: {JobPaused, JobFilled, JobExpired},
+	JobPaused:    {JobPublished, JobFilled, JobExpired},
+	JobFilled:    {},
+	JobExpired:   {},
+}
+
+// CanTransition reports whether a job can move from one state to another
+func (s JobState) CanTransition(to JobState) bool {
+	for _, state := range jobTransitions[s] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+var ErrInvalidTransition = errors.New("invalid job state transition")
+
+// listedJobCondition matches jobs (j) that appear in listings: published
+// and not past their expiry, even if the scheduler hasn't caught up yet
+const listedJobCondition = `NOT EXISTS (
+	SELECT 1 FROM job_states st
+	WHERE st.job_id = j.id AND (st.state <> 'published' OR st.expires_at <= NOW()))`
+
+// JobLifecycle is the state of a job posting and when it expires
+type JobLifecycle struct {
+	State          JobState   `json:"state"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	RepostedFrom   *int       `json:"reposted_from,omitempty"`
+	StateChangedAt *time.Time `json:"state_changed_at,omitempty"`
+}
+
+// Listed reports whether a job with this lifecycle appears in listings, as
+// listedJobCondition does
+func (l *JobLifecycle) Listed() bool {
+	return l.State == JobPublished && (l.ExpiresAt == nil || l.ExpiresAt.After(time.Now()))
+}
+
+// ExpiringJob is a job due for a reminder or for expiry
+type ExpiringJob struct {
+	JobID     int
+	ExpiresAt time.Time
+}
+
+type JobLifecycleService struct {
+	db *pgxpool.Pool
+}
+
+func NewJobLifecycleService(db *pgxpool.Pool) *JobLifecycleService {
+	return &JobLifecycleService{db: db}
+}
+
+// initLifecycle records the initial state and expiry of a new job
+func initLifecycle(ctx context.Context, tx pgx.Tx, jobID int, state JobState, expiresAt *time.Time, repostedFrom *int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO job_states (job_id, state, expires_at, reposted_from)
+		VALUES ($1, $2, $3, $4)`,
+		jobID, state, expiresAt, repostedFrom)
+	return err
+}
+
+// GetLifecycle returns the lifecycle of a job. Jobs created before
+// lifecycles existed are published and never expire.
+func (s *JobLifecycleService) GetLifecycle(ctx context.Context, jobID int) (*JobLifecycle, error) {
+	l := &JobLifecycle{}
+	err := s.db.QueryRow(ctx, `
+		SELECT state, expires_at, reposted_from, state_changed_at
+		FROM job_states
+		WHERE job_id = $1`, jobID,
+	).Scan(&l.State, &l.ExpiresAt, &l.RepostedFrom, &l.StateChangedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &JobLifecycle{State: JobPublished}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// listLifecycles returns the lifecycles of jobs by job ID. Jobs created
+// before lifecycles existed are left out.
+func (s *JobLifecycleService) listLifecycles(ctx context.Context, jobIDs []int) (map[int]*JobLifecycle, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT job_id, state, expires_at, reposted_from, state_changed_at
+		FROM job_states
+		WHERE job_id = ANY($1)`, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lifecycles := make(map[int]*JobLifecycle)
+	for rows.Next() {
+		l := &JobLifecycle{}
+		var jobID int
+		if err := rows.Scan(&jobID, &l.State, &l.ExpiresAt, &l.RepostedFrom, &l.StateChangedAt); err != nil {
+			return nil, err
+		}
+		lifecycles[jobID] = l
+	}
+	return lifecycles, rows.Err()
+}
+
+// setExpiry changes when a job expires. A new reminder is sent before the
+// new date.
+func setExpiry(ctx context.Context, tx pgx.Tx, jobID int, expiresAt *time.Time) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO job_states (job_id, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (job_id) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at, reminded_at = NULL`,
+		jobID, expiresAt)
+	return err
+}
+
+// Transition moves a job to a new state, returning ErrInvalidTransition if
+// its current state doesn't allow it
+func (s *JobLifecycleService) Transition(ctx context.Context, jobID int, to JobState) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO job_states (job_id) VALUES ($1)
+		ON CONFLICT (job_id) DO NOTHING`, jobID); err != nil {
+		return err
+	}
+
+	var from JobState
+	if err := tx.QueryRow(ctx, `
+		SELECT state FROM job_states WHERE job_id = $1 FOR UPDATE`, jobID,
+	).Scan(&from); err != nil {
+		return err
+	}
+	if !from.CanTransition(to) {
+		return ErrInvalidTransition
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE job_states
+		SET state = $2, state_changed_at = NOW()
+		WHERE job_id = $1`, jobID, to); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListDueForReminder returns open jobs expiring before a time whose poster
+// hasn't been reminded yet
+func (s *JobLifecycleService) ListDueForReminder(ctx context.Context, before time.Time, limit int) ([]*ExpiringJob, error) {
+	return s.listExpiring(ctx, `
+		SELECT job_id, expires_at
+		FROM job_states
+		WHERE state IN ('published', 'paused') AND expires_at <= $1 AND reminded_at IS NULL
+		ORDER BY expires_at
+		LIMIT $2`, before, limit)
+}
+
+// ListDueForExpiry returns open jobs whose expiry has passed
+func (s *JobLifecycleService) ListDueForExpiry(ctx context.Context, now time.Time, limit int) ([]*ExpiringJob, error) {
+	return s.listExpiring(ctx, `
+		SELECT job_id, expires_at
+		FROM job_states
+		WHERE state IN ('published', 'paused') AND expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2`, now, limit)
+}
+
+func (s *JobLifecycleService) listExpiring(ctx context.Context, query string, args ...interface{}) ([]*ExpiringJob, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*ExpiringJob
+	for rows.Next() {
+		job := &ExpiringJob{}
+		if err := rows.Scan(&job.JobID, &job.ExpiresAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// MarkReminded records that the poster of a job was reminded of its expiry.
+// It reports false if another run already did, so reminders go out once.
+func (s *JobLifecycleService) MarkReminded(ctx context.Context, jobID int) (bool, error) {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE job_states
+		SET reminded_at = NOW()
+		WHERE job_id = $1 AND reminded_at IS NULL`, jobID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
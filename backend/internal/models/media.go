@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MaxPostAttachments is the number of media attachments a post can carry
+const MaxPostAttachments = 4
+
+var ErrMediaUnavailable = errors.New("media not found or already attached")
+
+// MediaAttachment is an uploaded image, federated as a Document
+type MediaAttachment struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	PostID       *int      `json:"post_id,omitempty"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	URL          string    `json:"url"`
+	PreviewURL   string    `json:"preview_url"`
+	MediaType    string    `json:"media_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Blurhash     string    `json:"blurhash"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type MediaService struct {
+	db *pgxpool.Pool
+}
+
+func NewMediaService(db *pgxpool.Pool) *MediaService {
+	return &MediaService{db: db}
+}
+
+const mediaColumns = `id, user_id, post_id, storage_key, thumbnail_key, url, preview_url,
+	media_type, size, width, height, blurhash, description, created_at`
+
+func scanMedia(row interface{ Scan(...interface{}) error }) (*MediaAttachment, error) {
+	m := &MediaAttachment{}
+	err := row.Scan(&m.ID, &m.UserID, &m.PostID, &m.StorageKey, &m.ThumbnailKey, &m.URL, &m.PreviewURL,
+		&m.MediaType, &m.Size, &m.Width, &m.Height, &m.Blurhash, &m.Description, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CreateMedia stores an uploaded attachment that isn't attached to a post yet
+func (s *MediaService) CreateMedia(ctx context.Context, m *MediaAttachment) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO media_attachments (user_id, storage_key, thumbnail_key, url, preview_url,
+			media_type, size, width, height, blurhash, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`,
+		m.UserID, m.StorageKey, m.ThumbnailKey, m.URL, m.PreviewURL,
+		m.MediaType, m.Size, m.Width, m.Height, m.Blurhash, m.Description,
+	).Scan(&m.ID, &m.CreatedAt)
+}
+
+// GetMedia returns an attachment by ID
+func (s *MediaService) GetMedia(ctx context.Context, id int) (*MediaAttachment, error) {
+	return scanMedia(s.db.QueryRow(ctx, `
+		SELECT `+mediaColumns+`
+		FROM media_attachments
+		WHERE id = $1`, id))
+}
+
+// GetMediaByURL returns the attachment served from a URL
+func (s *MediaService) GetMediaByURL(ctx context.Context, url string) (*MediaAttachment, error) {
+	return scanMedia(s.db.QueryRow(ctx, `
+		SELECT `+mediaColumns+`
+		FROM media_attachments
+		WHERE url = $1`, url))
+}
+
+// attachMedia attaches a user's unattached uploads to a post, in the order
+// given. It fails with ErrMediaUnavailable if any of them belongs to
+// someone else or is already attached, rolling back the transaction.
+func attachMedia(ctx context.Context, tx pgx.Tx, userID, postID int, mediaIDs []int) error {
+	for i, id := range mediaIDs {
+		tag, err := tx.Exec(ctx, `
+			UPDATE media_attachments
+			SET post_id = $1, position = $2
+			WHERE id = $3 AND user_id = $4 AND post_id IS NULL`,
+			postID, i, id, userID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrMediaUnavailable
+		}
+	}
+	return nil
+}
+
+// ListPostMedia returns the attachments of a post in order
+func (s *MediaService) ListPostMedia(ctx context.Context, postID int) ([]*MediaAttachment, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+mediaColumns+`
+		FROM media_attachments
+		WHERE post_id = $1
+		ORDER BY position`, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*MediaAttachment
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, m)
+	}
+	return attachments, rows.Err()
+}
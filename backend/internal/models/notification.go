@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotificationKind identifies what a notification is about
+type NotificationKind string
+
+const (
+	NotificationJobExpiring NotificationKind = "job_expiring"
+	NotificationJobExpired  NotificationKind = "job_expired"
+	// NotificationApplicationStatus tells applicants their application
+	// moved to another stage
+	NotificationApplicationStatus NotificationKind = "application_status"
+	// NotificationSavedSearch lists new jobs matching a saved search
+	NotificationSavedSearch NotificationKind = "saved_search"
+	// NotificationBookmarkReminder is the reminder set on a bookmark
+	NotificationBookmarkReminder NotificationKind = "bookmark_reminder"
+)
+
+// Notification is a message to a local user about one of their objects
+type Notification struct {
+	ID        int              `json:"id"`
+	UserID    int              `json:"user_id"`
+	Kind      NotificationKind `json:"kind"`
+	JobID     *int             `json:"job_id,omitempty"`
+	Message   string           `json:"message"`
+	ReadAt    *time.Time       `json:"read_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type NotificationService struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationService(db *pgxpool.Pool) *NotificationService {
+	return &NotificationService{db: db}
+}
+
+// CreateNotification stores a notification for a user
+func (s *NotificationService) CreateNotification(ctx context.Context, n *Notification) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO notifications (user_id, kind, job_id, message)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		n.UserID, n.Kind, n.JobID, n.Message,
+	).Scan(&n.ID, &n.CreatedAt)
+}
+
+// ListNotifications returns a page of a user's notifications, newest first
+func (s *NotificationService) ListNotifications(ctx context.Context, userID int, unreadOnly bool, offset, limit int) ([]*Notification, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, user_id, kind, job_id, message, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND (NOT $2 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		OFFSET $3 LIMIT $4`, userID, unreadOnly, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*Notification{}
+	for rows.Next() {
+		n := &Notification{}
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.JobID, &n.Message, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkRead marks a user's notifications up to and including id as read
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id int) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE notifications
+		SET read_at = NOW()
+		WHERE user_id = $1 AND id <= $2 AND read_at IS NULL`, userID, id)
+	return err
+}
@@ -0,0 +1,589 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OrgRole is what a member can do in an organization. Owners manage the
+// organization and its members, recruiters manage its jobs and applicants,
+// and viewers can see them.
+type OrgRole string
+
+const (
+	OrgOwner     OrgRole = "owner"
+	OrgRecruiter OrgRole = "recruiter"
+	OrgViewer    OrgRole = "viewer"
+)
+
+func (r OrgRole) Valid() bool {
+	switch r {
+	case OrgOwner, OrgRecruiter, OrgViewer:
+		return true
+	}
+	return false
+}
+
+// CanView reports whether the role can see an organization's jobs and
+// applicants. The empty role is not a member.
+func (r OrgRole) CanView() bool {
+	return r.Valid()
+}
+
+// CanEdit reports whether the role can post and change jobs and move
+// applicants through the pipeline
+func (r OrgRole) CanEdit() bool {
+	return r == OrgOwner || r == OrgRecruiter
+}
+
+// CanManage reports whether the role can change the organization's
+// profile, domains and members
+func (r OrgRole) CanManage() bool {
+	return r == OrgOwner
+}
+
+const (
+	maxOrgNameLen        = 100
+	maxOrgDescriptionLen = 5000
+	maxOrgLocationLen    = 100
+	maxOrgURLLen         = 500
+)
+
+var (
+	ErrInvalidOrganization = errors.New("invalid organization")
+	ErrSlugTaken           = errors.New("organization slug is taken")
+	ErrLastOwner           = errors.New("organization must keep an owner")
+	ErrInvalidDomain       = errors.New("invalid domain")
+)
+
+var (
+	orgSlugRe = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,38}[a-z0-9])?$`)
+	domainRe  = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+)
+
+// Organization is a company or other employer that posts jobs through its
+// members. It is Verified once it has proven it controls one of its
+// domains.
+type Organization struct {
+	ID          int       `json:"id"`
+	Slug        string    `json:"slug"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Website     string    `json:"website"`
+	LogoURL     string    `json:"logo_url"`
+	Location    string    `json:"location"`
+	Verified    bool      `json:"verified"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Normalize tidies up user input before validation
+func (o *Organization) Normalize() {
+	o.Slug = strings.ToLower(strings.TrimSpace(o.Slug))
+	o.Name = strings.TrimSpace(o.Name)
+	o.Description = strings.TrimSpace(o.Description)
+	o.Website = strings.TrimSpace(o.Website)
+	o.LogoURL = strings.TrimSpace(o.LogoURL)
+	o.Location = strings.TrimSpace(o.Location)
+}
+
+// Validate checks an organization's profile
+func (o *Organization) Validate() error {
+	if !orgSlugRe.MatchString(o.Slug) {
+		return fmt.Errorf("%w: slug must be 1 to 40 lowercase letters, digits or dashes", ErrInvalidOrganization)
+	}
+	if o.Name == "" || utf8.RuneCountInString(o.Name) > maxOrgNameLen {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidOrganization, maxOrgNameLen)
+	}
+	if utf8.RuneCountInString(o.Description) > maxOrgDescriptionLen {
+		return fmt.Errorf("%w: description is too long", ErrInvalidOrganization)
+	}
+	if utf8.RuneCountInString(o.Location) > maxOrgLocationLen {
+		return fmt.Errorf("%w: location is too long", ErrInvalidOrganization)
+	}
+	for _, link := range []string{o.Website, o.LogoURL} {
+		if link == "" {
+			continue
+		}
+		u, err := url.Parse(link)
+		if err != nil || len(link) > maxOrgURLLen || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: links must be http or https URLs", ErrInvalidOrganization)
+		}
+	}
+	return nil
+}
+
+// NormalizeDomain returns a domain lowercased without a trailing dot, or
+// ErrInvalidDomain if it isn't a valid hostname
+func NormalizeDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !domainRe.MatchString(domain) {
+		return "", ErrInvalidDomain
+	}
+	return domain, nil
+}
+
+// OrganizationMember is a user's membership of an organization
+type OrganizationMember struct {
+	UserID      int       `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Role        OrgRole   `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Membership is an organization a user belongs to and their role in it
+type Membership struct {
+	Organization *Organization `json:"organization"`
+	Role         OrgRole       `json:"role"`
+}
+
+// OrganizationDomain is a domain an organization claims. Token is the
+// challenge that proves control of the domain when published in DNS or at
+// /.well-known.
+type OrganizationDomain struct {
+	Domain     string             `json:"domain"`
+	Token      string             `json:"token,omitempty"`
+	Method     VerificationMethod `json:"method,omitempty"`
+	VerifiedAt *time.Time         `json:"verified_at"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// OrganizationFollower is a remote actor following an organization
+type OrganizationFollower struct {
+	ActorIRI    string
+	Inbox       string
+	SharedInbox string
+}
+
+const organizationColumns = `o.id, o.slug, o.name, o.description, o.website, o.logo_url, o.location,
+	EXISTS (SELECT 1 FROM organization_domains vd WHERE vd.organization_id = o.id AND vd.verified_at IS NOT NULL),
+	o.created_at`
+
+func scanOrganization(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*Organization, error) {
+	o := &Organization{}
+	dest := append([]interface{}{&o.ID, &o.Slug, &o.Name, &o.Description, &o.Website,
+		&o.LogoURL, &o.Location, &o.Verified, &o.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+type OrganizationService struct {
+	db *pgxpool.Pool
+}
+
+func NewOrganizationService(db *pgxpool.Pool) *OrganizationService {
+	return &OrganizationService{db: db}
+}
+
+// CreateOrganization creates an organization owned by a user
+func (s *OrganizationService) CreateOrganization(ctx context.Context, o *Organization, ownerID int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO organizations (slug, name, description, website, logo_url, location)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (slug) DO NOTHING
+		RETURNING id, created_at`,
+		o.Slug, o.Name, o.Description, o.Website, o.LogoURL, o.Location,
+	).Scan(&o.ID, &o.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrSlugTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)`, o.ID, ownerID, OrgOwner); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetOrganization returns an organization by ID
+func (s *OrganizationService) GetOrganization(ctx context.Context, id int) (*Organization, error) {
+	return scanOrganization(s.db.QueryRow(ctx, `
+		SELECT `+organizationColumns+`
+		FROM organizations o
+		WHERE o.id = $1`, id))
+}
+
+// GetOrganizationBySlug returns an organization by slug
+func (s *OrganizationService) GetOrganizationBySlug(ctx context.Context, slug string) (*Organization, error) {
+	return scanOrganization(s.db.QueryRow(ctx, `
+		SELECT `+organizationColumns+`
+		FROM organizations o
+		WHERE o.slug = $1`, strings.ToLower(slug)))
+}
+
+// UpdateOrganization saves an organization's profile. The slug can't be
+// changed, as it names the organization's actor.
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, o *Organization) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE organizations
+		SET name = $2, description = $3, website = $4, logo_url = $5, location = $6
+		WHERE id = $1`,
+		o.ID, o.Name, o.Description, o.Website, o.LogoURL, o.Location)
+	return err
+}
+
+// GetRole returns a user's role in an organization, or "" if they aren't a
+// member
+func (s *OrganizationService) GetRole(ctx context.Context, orgID, userID int) (OrgRole, error) {
+	var role OrgRole
+	err := s.db.QueryRow(ctx, `
+		SELECT role FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2`, orgID, userID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// ListMemberships returns the organizations a user belongs to
+func (s *OrganizationService) ListMemberships(ctx context.Context, userID int) ([]*Membership, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+organizationColumns+`, m.role
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.user_id = $1
+		ORDER BY o.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []*Membership{}
+	for rows.Next() {
+		m := &Membership{}
+		if m.Organization, err = scanOrganization(rows, &m.Role); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// ListMembers returns the members of an organization, owners first
+func (s *OrganizationService) ListMembers(ctx context.Context, orgID int) ([]*OrganizationMember, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT m.user_id, u.username, u.display_name, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.role = 'owner' DESC, m.role = 'recruiter' DESC, u.username`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*OrganizationMember{}
+	for rows.Next() {
+		m := &OrganizationMember{}
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SetMember adds a user to an organization or changes their role. An
+// organization's last owner can't be demoted.
+func (s *OrganizationService) SetMember(ctx context.Context, orgID, userID int, role OrgRole) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if role != OrgOwner {
+		if err := checkOtherOwner(ctx, tx, orgID, userID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+		orgID, userID, role); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveMember removes a user from an organization. An organization's last
+// owner can't be removed.
+func (s *OrganizationService) RemoveMember(ctx context.Context, orgID, userID int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkOtherOwner(ctx, tx, orgID, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2`, orgID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// checkOtherOwner returns ErrLastOwner if userID is the only owner of an
+// organization. The owners are locked until the transaction ends so two
+// owners can't demote each other at once.
+func checkOtherOwner(ctx context.Context, tx pgx.Tx, orgID, userID int) error {
+	rows, err := tx.Query(ctx, `
+		SELECT user_id FROM organization_members
+		WHERE organization_id = $1 AND role = 'owner'
+		FOR UPDATE`, orgID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	isOwner, others := false, 0
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if id == userID {
+			isOwner = true
+		} else {
+			others++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if isOwner && others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+const domainColumns = `domain, token, COALESCE(method, ''), verified_at, created_at`
+
+func scanDomain(row interface{ Scan(...interface{}) error }) (*OrganizationDomain, error) {
+	d := &OrganizationDomain{}
+	if err := row.Scan(&d.Domain, &d.Token, &d.Method, &d.VerifiedAt, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListDomains returns the domains an organization claims
+func (s *OrganizationService) ListDomains(ctx context.Context, orgID int) ([]*OrganizationDomain, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+domainColumns+`
+		FROM organization_domains
+		WHERE organization_id = $1
+		ORDER BY domain`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []*OrganizationDomain{}
+	for rows.Next() {
+		d, err := scanDomain(rows)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// AddDomain claims a domain for an organization with a new verification
+// token. It stays unverified until the organization proves it controls the
+// domain.
+func (s *OrganizationService) AddDomain(ctx context.Context, orgID int, domain string) error {
+	token, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(ctx, `
+		INSERT INTO organization_domains (organization_id, domain, token)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, orgID, domain, token)
+	return err
+}
+
+// RemoveDomain drops a domain an organization claims
+func (s *OrganizationService) RemoveDomain(ctx context.Context, orgID int, domain string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM organization_domains
+		WHERE organization_id = $1 AND domain = $2`, orgID, domain)
+	return err
+}
+
+// setJobOrganization records the organization a job is posted for
+func setJobOrganization(ctx context.Context, tx pgx.Tx, jobID, orgID int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO job_organizations (job_id, organization_id)
+		VALUES ($1, $2)
+		ON CONFLICT (job_id) DO UPDATE SET organization_id = EXCLUDED.organization_id`, jobID, orgID)
+	return err
+}
+
+// GetJobOrganization returns the organization a job is posted for, or nil
+// if it belongs to its poster alone
+func (s *OrganizationService) GetJobOrganization(ctx context.Context, jobID int) (*Organization, error) {
+	o, err := scanOrganization(s.db.QueryRow(ctx, `
+		SELECT `+organizationColumns+`
+		FROM job_organizations jo
+		JOIN organizations o ON o.id = jo.organization_id
+		WHERE jo.job_id = $1`, jobID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return o, err
+}
+
+// listJobOrganizations returns the organizations jobs are posted for by
+// job ID. Jobs without one are left out.
+func (s *OrganizationService) listJobOrganizations(ctx context.Context, jobIDs []int) (map[int]*Organization, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+organizationColumns+`, jo.job_id
+		FROM job_organizations jo
+		JOIN organizations o ON o.id = jo.organization_id
+		WHERE jo.job_id = ANY($1)`, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := make(map[int]*Organization)
+	for rows.Next() {
+		var jobID int
+		o, err := scanOrganization(rows, &jobID)
+		if err != nil {
+			return nil, err
+		}
+		orgs[jobID] = o
+	}
+	return orgs, rows.Err()
+}
+
+// JobRole returns what a user can do with a job. Members of the job's
+// organization have their role in it; jobs without an organization give
+// their poster the owner role. Everyone else gets "".
+func (s *OrganizationService) JobRole(ctx context.Context, job *Job, userID int) (OrgRole, error) {
+	var orgID *int
+	err := s.db.QueryRow(ctx, `
+		SELECT organization_id FROM job_organizations WHERE job_id = $1`, job.ID).Scan(&orgID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if job.PostedBy == userID {
+			return OrgOwner, nil
+		}
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.GetRole(ctx, *orgID, userID)
+}
+
+// ListJobIDs returns a page of the listed jobs of an organization, newest
+// first
+func (s *OrganizationService) ListJobIDs(ctx context.Context, orgID, offset, limit int) ([]int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT j.id
+		FROM jobs j
+		JOIN job_organizations jo ON jo.job_id = j.id
+		WHERE jo.organization_id = $1 AND `+listedJobCondition+`
+		ORDER BY j.created_at DESC
+		OFFSET $2 LIMIT $3`, orgID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddFollower records a remote actor following an organization
+func (s *OrganizationService) AddFollower(ctx context.Context, orgID int, f *OrganizationFollower) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO organization_followers (organization_id, actor_iri, inbox, shared_inbox)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (organization_id, actor_iri) DO UPDATE
+		SET inbox = EXCLUDED.inbox, shared_inbox = EXCLUDED.shared_inbox`,
+		orgID, f.ActorIRI, f.Inbox, f.SharedInbox)
+	return err
+}
+
+// RemoveFollower forgets a remote actor following an organization
+func (s *OrganizationService) RemoveFollower(ctx context.Context, orgID int, actorIRI string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM organization_followers
+		WHERE organization_id = $1 AND actor_iri = $2`, orgID, actorIRI)
+	return err
+}
+
+// FollowerInboxes returns the distinct inboxes of an organization's
+// followers, preferring shared inboxes
+func (s *OrganizationService) FollowerInboxes(ctx context.Context, orgID int) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT DISTINCT COALESCE(shared_inbox, inbox)
+		FROM organization_followers
+		WHERE organization_id = $1`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes, rows.Err()
+}
+
+// CountFollowers returns how many remote actors follow an organization
+func (s *OrganizationService) CountFollowers(ctx context.Context, orgID int) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM organization_followers
+		WHERE organization_id = $1`, orgID).Scan(&count)
+	return count, err
+}
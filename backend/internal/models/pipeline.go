@@ -0,0 +1,472 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StageKind tells the stages an application is still moving through from
+// the final outcomes
+type StageKind string
+
+const (
+	StageActive    StageKind = "active"
+	StageHired     StageKind = "hired"
+	StageRejected  StageKind = "rejected"
+	StageWithdrawn StageKind = "withdrawn"
+)
+
+const (
+	// maxPipelineStages is how many active stages a job can have
+	maxPipelineStages = 15
+	maxStageNameLen   = 50
+	// MaxNoteLength is the longest internal note accepted, in characters
+	MaxNoteLength = 5000
+)
+
+var (
+	ErrApplicationNotFound = errors.New("application not found")
+	ErrInvalidPipeline     = errors.New("invalid pipeline")
+	ErrInvalidStage        = errors.New("unknown stage")
+	ErrStageTransition     = errors.New("application cannot move to that stage")
+	ErrStageInUse          = errors.New("stage still has applications")
+	ErrCannotWithdraw      = errors.New("application can no longer be withdrawn")
+)
+
+var stageKeyRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// PipelineStage is a stage of a job's hiring pipeline. Count is the number
+// of applications in it when listed with counts.
+type PipelineStage struct {
+	Key   string    `json:"key"`
+	Name  string    `json:"name"`
+	Kind  StageKind `json:"kind"`
+	Count int       `json:"count"`
+}
+
+// finalStages end every pipeline. Only applicants can move their own
+// applications to withdrawn.
+var finalStages = []*PipelineStage{
+	{Key: "hired", Name: "Hired", Kind: StageHired},
+	{Key: "rejected", Name: "Rejected", Kind: StageRejected},
+	{Key: "withdrawn", Name: "Withdrawn", Kind: StageWithdrawn},
+}
+
+// defaultActiveStages are used by jobs that don't configure their own
+var defaultActiveStages = []*PipelineStage{
+	{Key: "applied", Name: "Applied", Kind: StageActive},
+	{Key: "screening", Name: "Screening", Kind: StageActive},
+	{Key: "interview", Name: "Interview", Kind: StageActive},
+	{Key: "offer", Name: "Offer", Kind: StageActive},
+}
+
+// Pipeline is the ordered stages of a job: its active stages followed by
+// hired, rejected and withdrawn
+type Pipeline []*PipelineStage
+
+// First returns the stage new applications start in
+func (p Pipeline) First() *PipelineStage {
+	return p[0]
+}
+
+// Stage returns the stage with a key, or nil
+func (p Pipeline) Stage(key string) *PipelineStage {
+	for _, stage := range p {
+		if stage.Key == key {
+			return stage
+		}
+	}
+	return nil
+}
+
+func (p Pipeline) index(key string) int {
+	for i, stage := range p {
+		if stage.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// CanMove reports whether an employer can move an application between two
+// stages. Applications move forward through active stages any number at a
+// time, or back a single stage to undo a move. Anyone still in the pipeline
+// can be rejected, only those at the last active stage can be hired, and
+// the final stages can't be left.
+func (p Pipeline) CanMove(from, to string) bool {
+	i, k := p.index(from), p.index(to)
+	if i < 0 || k < 0 || i == k || p[i].Kind != StageActive {
+		return false
+	}
+
+	switch p[k].Kind {
+	case StageRejected:
+		return true
+	case StageHired:
+		return i == len(p)-len(finalStages)-1
+	case StageWithdrawn:
+		return false
+	default:
+		return k > i || k == i-1
+	}
+}
+
+// ApplicationEvent records an application moving between stages
+type ApplicationEvent struct {
+	ID            int       `json:"id"`
+	ApplicationID int       `json:"application_id"`
+	ActorID       *int      `json:"actor_id"`
+	FromStage     string    `json:"from_stage"`
+	ToStage       string    `json:"to_stage"`
+	Reason        string    `json:"reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// ApplicantID is the user who applied, set when an application is moved
+	ApplicantID int `json:"-"`
+}
+
+// ApplicationNote is an internal note on an applicant, never shown to them
+type ApplicationNote struct {
+	ID            int       `json:"id"`
+	ApplicationID int       `json:"application_id"`
+	AuthorID      *int      `json:"author_id"`
+	Body          string    `json:"body"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ApplicationRating is one reviewer's rating of an applicant, from 1 to 5
+type ApplicationRating struct {
+	UserID    int       `json:"user_id"`
+	Rating    int       `json:"rating"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PipelineService struct {
+	db *pgxpool.Pool
+}
+
+func NewPipelineService(db *pgxpool.Pool) *PipelineService {
+	return &PipelineService{db: db}
+}
+
+// GetPipeline returns the stages of a job, with the number of applications
+// in each
+func (s *PipelineService) GetPipeline(ctx context.Context, jobID int) (Pipeline, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT key, name, kind
+		FROM pipeline_stages
+		WHERE job_id = $1
+		ORDER BY position`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pipeline Pipeline
+	for rows.Next() {
+		stage := &PipelineStage{}
+		if err := rows.Scan(&stage.Key, &stage.Name, &stage.Kind); err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, stage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(pipeline) == 0 {
+		for _, stage := range append(append([]*PipelineStage{}, defaultActiveStages...), finalStages...) {
+			defaultStage := *stage
+			pipeline = append(pipeline, &defaultStage)
+		}
+	}
+
+	counts, err := s.db.Query(ctx, `
+		SELECT status, COUNT(*)
+		FROM job_applications
+		WHERE job_id = $1
+		GROUP BY status`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer counts.Close()
+
+	for counts.Next() {
+		var key string
+		var count int
+		if err := counts.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		if stage := pipeline.Stage(key); stage != nil {
+			stage.Count = count
+		}
+	}
+	return pipeline, counts.Err()
+}
+
+// SetPipeline replaces the active stages of a job. The final stages are
+// always added at the end. Stages that still have applications can't be
+// removed.
+func (s *PipelineService) SetPipeline(ctx context.Context, jobID int, active []*PipelineStage) (Pipeline, error) {
+	if len(active) == 0 || len(active) > maxPipelineStages {
+		return nil, ErrInvalidPipeline
+	}
+
+	pipeline := Pipeline{}
+	for _, stage := range active {
+		if stage == nil {
+			return nil, ErrInvalidPipeline
+		}
+		name := strings.TrimSpace(stage.Name)
+		if !stageKeyRe.MatchString(stage.Key) || name == "" || utf8.RuneCountInString(name) > maxStageNameLen {
+			return nil, ErrInvalidPipeline
+		}
+		if pipeline.Stage(stage.Key) != nil || Pipeline(finalStages).Stage(stage.Key) != nil {
+			return nil, ErrInvalidPipeline
+		}
+		pipeline = append(pipeline, &PipelineStage{Key: stage.Key, Name: name, Kind: StageActive})
+	}
+	for _, stage := range finalStages {
+		finalStage := *stage
+		pipeline = append(pipeline, &finalStage)
+	}
+
+	current, err := s.GetPipeline(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range current {
+		if stage.Count > 0 && pipeline.Stage(stage.Key) == nil {
+			return nil, ErrStageInUse
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM pipeline_stages WHERE job_id = $1`, jobID); err != nil {
+		return nil, err
+	}
+	for position, stage := range pipeline {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO pipeline_stages (job_id, key, name, kind, position)
+			VALUES ($1, $2, $3, $4, $5)`, jobID, stage.Key, stage.Name, stage.Kind, position); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.GetPipeline(ctx, jobID)
+}
+
+// ApplicationStage returns the stage of an application to a job, or
+// ErrApplicationNotFound if it isn't one of the job's applications
+func (s *PipelineService) ApplicationStage(ctx context.Context, jobID, applicationID int) (string, error) {
+	var stage string
+	err := s.db.QueryRow(ctx, `
+		SELECT status FROM job_applications
+		WHERE id = $1 AND job_id = $2`, applicationID, jobID).Scan(&stage)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrApplicationNotFound
+	}
+	return stage, err
+}
+
+// MoveApplication moves an application to another stage of its job's
+// pipeline and records who moved it and why
+func (s *PipelineService) MoveApplication(ctx context.Context, jobID, applicationID, actorID int, to, reason string) (*ApplicationEvent, error) {
+	pipeline, err := s.GetPipeline(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if pipeline.Stage(to) == nil {
+		return nil, ErrInvalidStage
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var from string
+	var applicantID int
+	err = tx.QueryRow(ctx, `
+		SELECT status, user_id FROM job_applications
+		WHERE id = $1 AND job_id = $2
+		FOR UPDATE`, applicationID, jobID).Scan(&from, &applicantID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Applications in a stage that no longer exists are treated as new
+	current := from
+	if pipeline.Stage(current) == nil {
+		current = pipeline.First().Key
+	}
+	if !pipeline.CanMove(current, to) {
+		return nil, ErrStageTransition
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE job_applications SET status = $2 WHERE id = $1`, applicationID, to); err != nil {
+		return nil, err
+	}
+
+	event := &ApplicationEvent{ApplicationID: applicationID, ActorID: &actorID, FromStage: from, ToStage: to, Reason: reason, ApplicantID: applicantID}
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	return event, tx.Commit(ctx)
+}
+
+// Withdraw lets an applicant withdraw one of their applications that is
+// still in an active stage
+func (s *PipelineService) Withdraw(ctx context.Context, userID, applicationID int) (*ApplicationEvent, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var from string
+	err = tx.QueryRow(ctx, `
+		SELECT status FROM job_applications
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`, applicationID, userID).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrApplicationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if Pipeline(finalStages).Stage(from) != nil {
+		return nil, ErrCannotWithdraw
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE job_applications SET status = 'withdrawn' WHERE id = $1`, applicationID); err != nil {
+		return nil, err
+	}
+
+	event := &ApplicationEvent{ApplicationID: applicationID, ActorID: &userID, FromStage: from, ToStage: "withdrawn", ApplicantID: userID}
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	return event, tx.Commit(ctx)
+}
+
+func insertEvent(ctx context.Context, tx pgx.Tx, event *ApplicationEvent) error {
+	return tx.QueryRow(ctx, `
+		INSERT INTO application_events (application_id, actor_id, from_stage, to_stage, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		event.ApplicationID, event.ActorID, event.FromStage, event.ToStage, event.Reason,
+	).Scan(&event.ID, &event.CreatedAt)
+}
+
+// ListEvents returns the audit trail of an application, oldest first
+func (s *PipelineService) ListEvents(ctx context.Context, applicationID int) ([]*ApplicationEvent, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, application_id, actor_id, from_stage, to_stage, reason, created_at
+		FROM application_events
+		WHERE application_id = $1
+		ORDER BY created_at, id`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*ApplicationEvent{}
+	for rows.Next() {
+		e := &ApplicationEvent{}
+		if err := rows.Scan(&e.ID, &e.ApplicationID, &e.ActorID, &e.FromStage, &e.ToStage, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// AddNote adds an internal note to an application
+func (s *PipelineService) AddNote(ctx context.Context, note *ApplicationNote) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO application_notes (application_id, author_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		note.ApplicationID, note.AuthorID, note.Body,
+	).Scan(&note.ID, &note.CreatedAt)
+}
+
+// ListNotes returns the internal notes on an application, oldest first
+func (s *PipelineService) ListNotes(ctx context.Context, applicationID int) ([]*ApplicationNote, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, application_id, author_id, body, created_at
+		FROM application_notes
+		WHERE application_id = $1
+		ORDER BY created_at, id`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []*ApplicationNote{}
+	for rows.Next() {
+		n := &ApplicationNote{}
+		if err := rows.Scan(&n.ID, &n.ApplicationID, &n.AuthorID, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// RateApplication sets a reviewer's rating of an applicant, replacing any
+// earlier rating of theirs
+func (s *PipelineService) RateApplication(ctx context.Context, applicationID, userID, rating int) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO application_ratings (application_id, user_id, rating)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (application_id, user_id) DO UPDATE
+		SET rating = EXCLUDED.rating, updated_at = NOW()`, applicationID, userID, rating)
+	return err
+}
+
+// ListRatings returns the ratings of an applicant
+func (s *PipelineService) ListRatings(ctx context.Context, applicationID int) ([]*ApplicationRating, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT user_id, rating, updated_at
+		FROM application_ratings
+		WHERE application_id = $1
+		ORDER BY updated_at`, applicationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []*ApplicationRating{}
+	for rows.Next() {
+		r := &ApplicationRating{}
+		if err := rows.Scan(&r.UserID, &r.Rating, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, r)
+	}
+	return ratings, rows.Err()
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	MinPollOptions  = 2
+	MaxPollOptions  = 10
+	MinPollDuration = 5 * time.Minute
+	MaxPollDuration = 30 * 24 * time.Hour
+)
+
+var (
+	ErrPollClosed    = errors.New("poll has ended")
+	ErrInvalidChoice = errors.New("invalid poll choice")
+	ErrAlreadyVoted  = errors.New("already voted in poll")
+	// ErrInvalidPoll wraps the reasons a new poll is rejected
+	ErrInvalidPoll = errors.New("invalid poll")
+)
+
+// Poll is a question attached to a post, federated as a Question
+type Poll struct {
+	ID     int `json:"id"`
+	PostID int `json:"post_id"`
+	// Multiple allows voters to pick several options (anyOf instead of oneOf)
+	Multiple    bool          `json:"multiple"`
+	EndTime     time.Time     `json:"end_time"`
+	Options     []*PollOption `json:"options"`
+	VotersCount int           `json:"voters_count"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// PollOption is one of the answers of a poll
+type PollOption struct {
+	Name  string `json:"name"`
+	Votes int    `json:"votes"`
+}
+
+// Closed reports whether voting has ended
+func (p *Poll) Closed() bool {
+	return !time.Now().Before(p.EndTime)
+}
+
+// Validate checks the options and duration of a new poll
+func (p *Poll) Validate() error {
+	if len(p.Options) < MinPollOptions || len(p.Options) > MaxPollOptions {
+		return fmt.Errorf("%w: poll must have between 2 and 10 options", ErrInvalidPoll)
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range p.Options {
+		if option.Name == "" || seen[option.Name] {
+			return fmt.Errorf("%w: poll options must be unique and non-empty", ErrInvalidPoll)
+		}
+		seen[option.Name] = true
+	}
+
+	duration := time.Until(p.EndTime)
+	if duration < MinPollDuration || duration > MaxPollDuration {
+		return fmt.Errorf("%w: poll must last between 5 minutes and 30 days", ErrInvalidPoll)
+	}
+	return nil
+}
+
+type PollService struct {
+	db *pgxpool.Pool
+}
+
+func NewPollService(db *pgxpool.Pool) *PollService {
+	return &PollService{db: db}
+}
+
+// insertPoll stores a poll and its options
+func insertPoll(ctx context.Context, tx pgx.Tx, poll *Poll) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO polls (post_id, multiple, end_time)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		poll.PostID, poll.Multiple, poll.EndTime,
+	).Scan(&poll.ID, &poll.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i, option := range poll.Options {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO poll_options (poll_id, position, name)
+			VALUES ($1, $2, $3)`, poll.ID, i, option.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPollByPost returns the poll attached to a post, with current tallies
+func (s *PollService) GetPollByPost(ctx context.Context, postID int) (*Poll, error) {
+	poll := &Poll{}
+	err := s.db.QueryRow(ctx, `
+		SELECT p.id, p.post_id, p.multiple, p.end_time, p.created_at,
+			(SELECT COUNT(DISTINCT voter_iri) FROM poll_votes WHERE poll_id = p.id)
+		FROM polls p
+		WHERE p.post_id = $1`, postID,
+	).Scan(&poll.ID, &poll.PostID, &poll.Multiple, &poll.EndTime, &poll.CreatedAt, &poll.VotersCount)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT o.name, COUNT(v.id)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.poll_id = o.poll_id AND v.position = o.position
+		WHERE o.poll_id = $1
+		GROUP BY o.position, o.name
+		ORDER BY o.position`, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		option := &PollOption{}
+		if err := rows.Scan(&option.Name, &option.Votes); err != nil {
+			return nil, err
+		}
+		poll.Options = append(poll.Options, option)
+	}
+	return poll, rows.Err()
+}
+
+// RecordVote records a vote for the named options in one transaction, so
+// either all of them are counted or none is. voteIRI identifies the remote
+// Note carrying the vote and is empty for local votes; remote servers send
+// one Note per option.
+func (s *PollService) RecordVote(ctx context.Context, poll *Poll, voterIRI, voteIRI string, optionNames ...string) error {
+	if poll.Closed() {
+		return ErrPollClosed
+	}
+	if len(optionNames) == 0 || (!poll.Multiple && len(optionNames) > 1) {
+		return ErrInvalidChoice
+	}
+	seen := make(map[string]bool)
+	for _, name := range optionNames {
+		if seen[name] {
+			return ErrInvalidChoice
+		}
+		seen[name] = true
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the poll so concurrent votes from one voter are serialized
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM polls WHERE id = $1 FOR UPDATE`, poll.ID); err != nil {
+		return err
+	}
+
+	// A single-choice poll accepts one vote per voter
+	if !poll.Multiple {
+		var voted bool
+		if err := tx.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = $1 AND voter_iri = $2)`,
+			poll.ID, voterIRI).Scan(&voted); err != nil {
+			return err
+		}
+		if voted {
+			return ErrAlreadyVoted
+		}
+	}
+
+	for _, name := range optionNames {
+		var position int
+		err = tx.QueryRow(ctx, `
+			SELECT position FROM poll_options
+			WHERE poll_id = $1 AND name = $2`, poll.ID, name).Scan(&position)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidChoice
+		}
+		if err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO poll_votes (poll_id, position, voter_iri, vote_iri)
+			VALUES ($1, $2, $3, NULLIF($4, ''))
+			ON CONFLICT DO NOTHING`, poll.ID, position, voterIRI, voteIRI)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrAlreadyVoted
+		}
+	}
+
+	return tx.Commit(ctx)
+}
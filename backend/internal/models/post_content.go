@@ -0,0 +1,10 @@
+package models
+
+import "context"
+
+// SetPostContent replaces the rendered content of a post, e.g. once the
+// accounts it mentions on other servers have been looked up
+func (s *PostService) SetPostContent(ctx context.Context, id int, content string) error {
+	_, err := s.db.Exec(ctx, `UPDATE posts SET content = $2 WHERE id = $1`, id, content)
+	return err
+}
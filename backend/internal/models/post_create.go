@@ -0,0 +1,61 @@
+package models
+
+import "context"
+
+// NewPostParts holds what is stored along with a new post
+type NewPostParts struct {
+	Tags []*Tag
+	// Thread returns the position of the post in its conversation once
+	// the post has an ID
+	Thread func(post *Post) *ThreadEntry
+	// Poll, when set, is attached to the post
+	Poll *Poll
+	// MediaIDs are the author's uploads to attach, in order
+	MediaIDs []int
+}
+
+// CreatePostWithParts stores a new post together with its tags, thread
+// position, poll and attachments in one transaction, so a failure in any
+// of them, such as ErrMediaUnavailable, leaves no post behind
+func (s *PostService) CreatePostWithParts(ctx context.Context, post *Post, parts *NewPostParts) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO posts (user_id, content)
+		VALUES ($1, $2)
+		RETURNING id, created_at`,
+		post.UserID, post.Content,
+	).Scan(&post.ID, &post.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceTags(ctx, tx, ObjectPost, post.ID, parts.Tags); err != nil {
+		return err
+	}
+
+	if parts.Thread != nil {
+		if err := saveEntry(ctx, tx, parts.Thread(post)); err != nil {
+			return err
+		}
+	}
+
+	if parts.Poll != nil {
+		parts.Poll.PostID = post.ID
+		if err := insertPoll(ctx, tx, parts.Poll); err != nil {
+			return err
+		}
+	}
+
+	if len(parts.MediaIDs) > 0 {
+		if err := attachMedia(ctx, tx, post.UserID, post.ID, parts.MediaIDs); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// matchPoolSize is how many jobs or candidates are scored per request. The
+// pool is picked by skill overlap, which carries the most weight.
+const matchPoolSize = 500
+
+// neutralScore is given to a factor when either side left it unspecified,
+// so missing data neither helps nor hurts
+const neutralScore = 0.5
+
+// Match factors and their weights in the total score
+const (
+	FactorSkills    = "skills"
+	FactorLocation  = "location"
+	FactorSalary    = "salary"
+	FactorSeniority = "seniority"
+)
+
+var matchWeights = map[string]float64{
+	FactorSkills:    0.4,
+	FactorLocation:  0.2,
+	FactorSalary:    0.2,
+	FactorSeniority: 0.2,
+}
+
+// candidateWeights score candidates suggested to employers. Salary is left
+// out: how a job's pay compares with a candidate's minimum would reveal the
+// minimum, which candidates only share to find jobs.
+var candidateWeights = map[string]float64{
+	FactorSkills:    0.5,
+	FactorLocation:  0.25,
+	FactorSeniority: 0.25,
+}
+
+// seniorityRanks orders seniority levels for comparing them
+var seniorityRanks = map[Seniority]int{
+	SeniorityIntern:    0,
+	SeniorityJunior:    1,
+	SeniorityMid:       2,
+	SenioritySenior:    3,
+	SeniorityLead:      4,
+	SeniorityPrincipal: 5,
+	SeniorityExecutive: 6,
+}
+
+// MatchScore rates how well a candidate and a job fit, from 0 to 1, with
+// the score of each factor
+type MatchScore struct {
+	Score     float64            `json:"score"`
+	Breakdown map[string]float64 `json:"breakdown"`
+}
+
+// JobMatch is a job recommended to a user
+type JobMatch struct {
+	JobID int
+	MatchScore
+}
+
+// CandidateMatch is a user suggested to the poster of a job
+type CandidateMatch struct {
+	UserID int
+	MatchScore
+}
+
+// matchJob holds what scoring needs to know about a job
+type matchJob struct {
+	id        int
+	location  string
+	remote    RemotePolicy
+	regions   []string
+	annualMin *int64
+	annualMax *int64
+	currency  string
+	seniority Seniority
+	// skillWeight is the weight of all the job's skills: 1 for each
+	// required skill and 0.5 for each nice to have
+	skillWeight float64
+}
+
+// matchJobColumns selects a matchJob from jobs (j) joined with their
+// details (d)
+const matchJobColumns = `j.id, j.location, ` + remotePolicyExpr + `, COALESCE(d.remote_regions, '{}'),
+	d.annual_salary_min, d.annual_salary_max, COALESCE(d.salary_currency, ''), COALESCE(d.seniority, ''),
+	COALESCE((SELECT SUM(CASE WHEN js.required THEN 1 ELSE 0.5 END) FROM job_skills js WHERE js.job_id = j.id), 0)::float8`
+
+func scanMatchJob(row interface{ Scan(...interface{}) error }, job *matchJob, extra ...interface{}) error {
+	dest := append([]interface{}{&job.id, &job.location, &job.remote, &job.regions,
+		&job.annualMin, &job.annualMax, &job.currency, &job.seniority, &job.skillWeight}, extra...)
+	return row.Scan(dest...)
+}
+
+// matchedSkillWeight sums the weights of the job's (js) skills the user
+// (us) has, scaled by proficiency from 0.6 for beginners to 1 for experts
+const matchedSkillWeight = `COALESCE(SUM(CASE WHEN js.skill_id IS NULL OR us.skill_id IS NULL THEN 0
+	ELSE (CASE WHEN js.required THEN 1 ELSE 0.5 END) * (0.5 + us.proficiency / 10.0) END), 0)::float8`
+
+type RecommendationService struct {
+	db               *pgxpool.Pool
+	candidateService *CandidateService
+}
+
+func NewRecommendationService(db *pgxpool.Pool) *RecommendationService {
+	return &RecommendationService{db: db, candidateService: NewCandidateService(db)}
+}
+
+// RecommendJobs returns a page of listed jobs ranked by how well they fit a
+// user's skills and preferences, best first. Jobs the user posted or that
+// are posted for one of their organizations are left out.
+func (s *RecommendationService) RecommendJobs(ctx context.Context, userID, offset, limit int) ([]*JobMatch, error) {
+	prefs, err := s.candidateService.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+matchJobColumns+`, `+matchedSkillWeight+` AS matched
+		FROM jobs j
+		LEFT JOIN job_details d ON d.job_id = j.id
+		LEFT JOIN job_skills js ON js.job_id = j.id
+		LEFT JOIN user_skills us ON us.skill_id = js.skill_id AND us.user_id = $1
+		WHERE `+listedJobCondition+` AND j.posted_by <> $1
+			AND NOT EXISTS (
+				SELECT 1 FROM job_organizations jo
+				JOIN organization_members m ON m.organization_id = jo.organization_id
+				WHERE jo.job_id = j.id AND m.user_id = $1)
+		GROUP BY j.id, d.job_id
+		ORDER BY matched DESC, j.created_at DESC
+		LIMIT $2`, userID, matchPoolSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*JobMatch
+	for rows.Next() {
+		job := &matchJob{}
+		var matched float64
+		if err := scanMatchJob(rows, job, &matched); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &JobMatch{JobID: job.id, MatchScore: scoreMatch(job, prefs, matched, matchWeights)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, k int) bool {
+		return matches[i].Score > matches[k].Score
+	})
+	start, end := pageBounds(len(matches), offset, limit)
+	return matches[start:end], nil
+}
+
+// SuggestCandidates returns a page of discoverable users ranked by how well
+// they fit a job, best first. The job's poster and the members of its
+// organization are never suggested.
+func (s *RecommendationService) SuggestCandidates(ctx context.Context, jobID, posterID, offset, limit int) ([]*CandidateMatch, error) {
+	job := &matchJob{}
+	if err := scanMatchJob(s.db.QueryRow(ctx, `
+		SELECT `+matchJobColumns+`
+		FROM jobs j
+		LEFT JOIN job_details d ON d.job_id = j.id
+		WHERE j.id = $1`, jobID), job); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+candidatePreferencesColumns+`, p.user_id, `+matchedSkillWeight+` AS matched
+		FROM candidate_preferences p
+		LEFT JOIN user_skills us ON us.user_id = p.user_id
+		LEFT JOIN job_skills js ON js.skill_id = us.skill_id AND js.job_id = $1
+		WHERE p.discoverable AND p.user_id <> $2
+			AND NOT EXISTS (
+				SELECT 1 FROM job_organizations jo
+				JOIN organization_members m ON m.organization_id = jo.organization_id
+				WHERE jo.job_id = $1 AND m.user_id = p.user_id)
+		GROUP BY p.user_id
+		ORDER BY matched DESC, p.updated_at DESC
+		LIMIT $3`, jobID, posterID, matchPoolSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*CandidateMatch
+	for rows.Next() {
+		prefs := &CandidatePreferences{}
+		var userID int
+		var matched float64
+		if err := scanPreferences(rows, prefs, &userID, &matched); err != nil {
+			return nil, err
+		}
+		matches = append(matches, &CandidateMatch{UserID: userID, MatchScore: scoreMatch(job, prefs, matched, candidateWeights)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matches, func(i, k int) bool {
+		return matches[i].Score > matches[k].Score
+	})
+	start, end := pageBounds(len(matches), offset, limit)
+	return matches[start:end], nil
+}
+
+// scoreMatch rates how well a candidate fits a job on the factors in
+// weights. matched is the weight of the job's skills the candidate has.
+func scoreMatch(job *matchJob, prefs *CandidatePreferences, matched float64, weights map[string]float64) MatchScore {
+	scores := map[string]float64{
+		FactorSkills:    skillScore(job, matched),
+		FactorLocation:  locationScore(job, prefs),
+		FactorSalary:    salaryScore(job, prefs),
+		FactorSeniority: seniorityScore(job, prefs),
+	}
+
+	breakdown := make(map[string]float64, len(weights))
+	var total float64
+	for factor, weight := range weights {
+		breakdown[factor] = round2(scores[factor])
+		total += weight * scores[factor]
+	}
+	return MatchScore{Score: round2(total), Breakdown: breakdown}
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func skillScore(job *matchJob, matched float64) float64 {
+	if job.skillWeight == 0 {
+		return neutralScore
+	}
+	return math.Min(matched/job.skillWeight, 1)
+}
+
+// locationScore checks the job's remote policy against those the candidate
+// accepts, then where the job is against where the candidate is
+func locationScore(job *matchJob, prefs *CandidatePreferences) float64 {
+	policy := 1.0
+	if len(prefs.RemotePolicies) > 0 {
+		policy = 0.3
+		for _, accepted := range prefs.RemotePolicies {
+			if accepted == job.remote {
+				policy = 1
+			}
+		}
+	}
+
+	where := strings.ToLower(prefs.Location)
+	place := neutralScore
+	switch {
+	case job.remote == RemoteRemote && len(job.regions) == 0:
+		place = 1
+	case where == "":
+	case job.remote == RemoteRemote:
+		place = 0
+		for _, region := range job.regions {
+			if strings.Contains(where, strings.ToLower(region)) {
+				place = 1
+			}
+		}
+	case job.location != "":
+		place = 0
+		if sameLocation(where, strings.ToLower(job.location)) {
+			place = 1
+		}
+	}
+	return policy * place
+}
+
+// sameLocation reports whether two lowercased locations refer to the same
+// place, comparing their most specific part, e.g. the city of "Berlin,
+// Germany"
+func sameLocation(a, b string) bool {
+	first := func(s string) string {
+		return strings.TrimSpace(strings.SplitN(s, ",", 2)[0])
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a) || first(a) == first(b)
+}
+
+// salaryScore compares the most the job pays in a year with the least the
+// candidate will accept. Salaries in different currencies are not compared.
+func salaryScore(job *matchJob, prefs *CandidatePreferences) float64 {
+	offered := job.annualMax
+	if offered == nil {
+		offered = job.annualMin
+	}
+	wanted := prefs.annualSalaryMin
+	if offered == nil || wanted == nil || job.currency != prefs.SalaryCurrency {
+		return neutralScore
+	}
+	if *wanted == 0 || *offered >= *wanted {
+		return 1
+	}
+	return float64(*offered) / float64(*wanted)
+}
+
+// seniorityScore is 1 for the same level, 0.5 a level apart and 0 further
+func seniorityScore(job *matchJob, prefs *CandidatePreferences) float64 {
+	if job.seniority == "" || prefs.Seniority == "" {
+		return neutralScore
+	}
+	distance := math.Abs(float64(seniorityRanks[job.seniority] - seniorityRanks[prefs.Seniority]))
+	return math.Max(1-distance/2, 0)
+}
+
+// pageBounds returns the slice bounds of a page of n items
+func pageBounds(n, offset, limit int) (int, int) {
+	start := offset
+	if start > n {
+		start = n
+	}
+	end := start + limit
+	if end > n {
+		end = n
+	}
+	return start, end
+}
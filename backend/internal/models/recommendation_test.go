@@ -0,0 +1,135 @@
+package models
+
+import "testing"
+
+func TestScoreMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		// adjust changes a job and preferences that match on every factor
+		adjust        func(job *matchJob, prefs *CandidatePreferences)
+		matched       float64
+		wantJob       float64
+		wantCandidate float64
+		wantFactors   map[string]float64
+	}{
+		{
+			name:          "perfect match",
+			adjust:        func(job *matchJob, prefs *CandidatePreferences) {},
+			matched:       2,
+			wantJob:       1,
+			wantCandidate: 1,
+			wantFactors:   map[string]float64{FactorSkills: 1, FactorLocation: 1, FactorSalary: 1, FactorSeniority: 1},
+		},
+		{
+			name:          "half the skills",
+			adjust:        func(job *matchJob, prefs *CandidatePreferences) {},
+			matched:       1,
+			wantJob:       0.8,
+			wantCandidate: 0.75,
+			wantFactors:   map[string]float64{FactorSkills: 0.5, FactorLocation: 1, FactorSalary: 1, FactorSeniority: 1},
+		},
+		{
+			name: "salary below the minimum",
+			adjust: func(job *matchJob, prefs *CandidatePreferences) {
+				job.annualMax = int64Ptr(50000)
+			},
+			matched:       2,
+			wantJob:       0.9,
+			wantCandidate: 1,
+			wantFactors:   map[string]float64{FactorSkills: 1, FactorLocation: 1, FactorSalary: 0.5, FactorSeniority: 1},
+		},
+		{
+			name: "salary in another currency",
+			adjust: func(job *matchJob, prefs *CandidatePreferences) {
+				job.currency = "EUR"
+				job.annualMax = int64Ptr(1000)
+			},
+			matched:       2,
+			wantJob:       0.9,
+			wantCandidate: 1,
+			wantFactors:   map[string]float64{FactorSkills: 1, FactorLocation: 1, FactorSalary: neutralScore, FactorSeniority: 1},
+		},
+		{
+			name: "seniority two levels apart",
+			adjust: func(job *matchJob, prefs *CandidatePreferences) {
+				job.seniority = SeniorityJunior
+			},
+			matched:       2,
+			wantJob:       0.8,
+			wantCandidate: 0.75,
+			wantFactors:   map[string]float64{FactorSkills: 1, FactorLocation: 1, FactorSalary: 1, FactorSeniority: 0},
+		},
+		{
+			name: "office elsewhere",
+			adjust: func(job *matchJob, prefs *CandidatePreferences) {
+				job.remote = RemoteOnsite
+				job.location = "Lisbon, Portugal"
+			},
+			matched:       2,
+			wantJob:       0.8,
+			wantCandidate: 0.75,
+			wantFactors:   map[string]float64{FactorSkills: 1, FactorLocation: 0, FactorSalary: 1, FactorSeniority: 1},
+		},
+		{
+			name: "nothing specified",
+			adjust: func(job *matchJob, prefs *CandidatePreferences) {
+				*job = matchJob{}
+				*prefs = CandidatePreferences{}
+			},
+			wantJob:       neutralScore,
+			wantCandidate: neutralScore,
+			wantFactors: map[string]float64{
+				FactorSkills: neutralScore, FactorLocation: neutralScore, FactorSalary: neutralScore, FactorSeniority: neutralScore,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := matchJob{
+				remote:      RemoteRemote,
+				annualMax:   int64Ptr(120000),
+				currency:    "USD",
+				seniority:   SenioritySenior,
+				skillWeight: 2,
+			}
+			prefs := CandidatePreferences{
+				Location:        "Berlin, Germany",
+				SalaryCurrency:  "USD",
+				Seniority:       SenioritySenior,
+				annualSalaryMin: int64Ptr(100000),
+			}
+			tt.adjust(&job, &prefs)
+
+			got := scoreMatch(&job, &prefs, tt.matched, matchWeights)
+			if got.Score != tt.wantJob {
+				t.Errorf("job score = %v, want %v", got.Score, tt.wantJob)
+			}
+			for factor, want := range tt.wantFactors {
+				if got.Breakdown[factor] != want {
+					t.Errorf("%s = %v, want %v", factor, got.Breakdown[factor], want)
+				}
+			}
+
+			got = scoreMatch(&job, &prefs, tt.matched, candidateWeights)
+			if got.Score != tt.wantCandidate {
+				t.Errorf("candidate score = %v, want %v", got.Score, tt.wantCandidate)
+			}
+			if _, ok := got.Breakdown[FactorSalary]; ok {
+				t.Error("candidate breakdown reveals the salary factor")
+			}
+		})
+	}
+}
+
+func TestMatchWeightsSumToOne(t *testing.T) {
+	for name, weights := range map[string]map[string]float64{"match": matchWeights, "candidate": candidateWeights} {
+		var sum float64
+		for _, weight := range weights {
+			sum += weight
+		}
+		if round2(sum) != 1 {
+			t.Errorf("%s weights sum to %v, want 1", name, sum)
+		}
+	}
+}
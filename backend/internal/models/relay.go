@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RelayState is where a relay subscription is in the Follow handshake
+type RelayState string
+
+const (
+	RelayPending  RelayState = "pending"
+	RelayAccepted RelayState = "accepted"
+	RelayRejected RelayState = "rejected"
+)
+
+// Relay is a relay our instance actor follows. Relays push the public posts
+// of every subscribed server to us as Announces.
+type Relay struct {
+	ID    int    `json:"id"`
+	Inbox string `json:"inbox"`
+	// ActorIRI is learned from the relay's Accept
+	ActorIRI         string     `json:"actor_iri,omitempty"`
+	FollowActivityID string     `json:"follow_activity_id"`
+	State            RelayState `json:"state"`
+	// Forward sends our public posts and job postings to the relay
+	Forward   bool      `json:"forward"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RelayService struct {
+	db *pgxpool.Pool
+}
+
+func NewRelayService(db *pgxpool.Pool) *RelayService {
+	return &RelayService{db: db}
+}
+
+const relayColumns = `id, inbox_url, COALESCE(actor_iri, ''), follow_activity_id, state, forward, created_at, updated_at`
+
+func scanRelay(row interface{ Scan(...interface{}) error }) (*Relay, error) {
+	r := &Relay{}
+	err := row.Scan(&r.ID, &r.Inbox, &r.ActorIRI, &r.FollowActivityID, &r.State, &r.Forward, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateRelay stores a new pending relay subscription
+func (s *RelayService) CreateRelay(ctx context.Context, relay *Relay) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO relays (inbox_url, follow_activity_id, forward)
+		VALUES ($1, $2, $3)
+		RETURNING id, state, created_at, updated_at`,
+		relay.Inbox, relay.FollowActivityID, relay.Forward,
+	).Scan(&relay.ID, &relay.State, &relay.CreatedAt, &relay.UpdatedAt)
+}
+
+// GetRelay returns a relay by ID
+func (s *RelayService) GetRelay(ctx context.Context, id int) (*Relay, error) {
+	return scanRelay(s.db.QueryRow(ctx, `
+		SELECT `+relayColumns+`
+		FROM relays
+		WHERE id = $1`, id))
+}
+
+// GetRelayByFollow returns the relay subscribed to with a Follow activity
+func (s *RelayService) GetRelayByFollow(ctx context.Context, followActivityID string) (*Relay, error) {
+	return scanRelay(s.db.QueryRow(ctx, `
+		SELECT `+relayColumns+`
+		FROM relays
+		WHERE follow_activity_id = $1`, followActivityID))
+}
+
+// GetAcceptedRelayByActor returns the accepted relay with the given actor
+func (s *RelayService) GetAcceptedRelayByActor(ctx context.Context, actorIRI string) (*Relay, error) {
+	return scanRelay(s.db.QueryRow(ctx, `
+		SELECT `+relayColumns+`
+		FROM relays
+		WHERE actor_iri = $1 AND state = 'accepted'`, actorIRI))
+}
+
+// ListRelays returns all relay subscriptions
+func (s *RelayService) ListRelays(ctx context.Context) ([]*Relay, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+relayColumns+`
+		FROM relays
+		ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relays := []*Relay{}
+	for rows.Next() {
+		relay, err := scanRelay(rows)
+		if err != nil {
+			return nil, err
+		}
+		relays = append(relays, relay)
+	}
+	return relays, rows.Err()
+}
+
+// ListForwardingInboxes returns the inboxes of accepted relays we forward
+// our public activities to
+func (s *RelayService) ListForwardingInboxes(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT inbox_url
+		FROM relays
+		WHERE state = 'accepted' AND forward`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inbox string
+		if err := rows.Scan(&inbox); err != nil {
+			return nil, err
+		}
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes, rows.Err()
+}
+
+// SetRelayState records the relay's response to our Follow
+func (s *RelayService) SetRelayState(ctx context.Context, id int, state RelayState, actorIRI string) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE relays
+		SET state = $2, actor_iri = COALESCE(NULLIF($3, ''), actor_iri), updated_at = NOW()
+		WHERE id = $1`, id, state, actorIRI)
+	return err
+}
+
+// SetRelayForward turns forwarding our public activities to a relay on or off
+func (s *RelayService) SetRelayForward(ctx context.Context, id int, forward bool) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE relays SET forward = $2, updated_at = NOW() WHERE id = $1`, id, forward)
+	return err
+}
+
+// DeleteRelay removes a relay subscription
+func (s *RelayService) DeleteRelay(ctx context.Context, id int) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM relays WHERE id = $1`, id)
+	return err
+}
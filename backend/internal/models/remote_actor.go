@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RemoteActorProfile is the display information of an actor on another
+// server. Its avatar is served through the remote media cache.
+type RemoteActorProfile struct {
+	IRI               string    `json:"iri"`
+	PreferredUsername string    `json:"preferred_username"`
+	Name              string    `json:"name"`
+	URL               string    `json:"url,omitempty"`
+	AvatarMediaID     *int      `json:"avatar_media_id,omitempty"`
+	FetchedAt         time.Time `json:"fetched_at"`
+}
+
+type RemoteActorService struct {
+	db *pgxpool.Pool
+}
+
+func NewRemoteActorService(db *pgxpool.Pool) *RemoteActorService {
+	return &RemoteActorService{db: db}
+}
+
+// UpsertRemoteActor stores the profile of a remote actor
+func (s *RemoteActorService) UpsertRemoteActor(ctx context.Context, actor *RemoteActorProfile) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO remote_actors (iri, preferred_username, name, url, avatar_media_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (iri) DO UPDATE
+		SET preferred_username = EXCLUDED.preferred_username, name = EXCLUDED.name,
+			url = EXCLUDED.url, avatar_media_id = EXCLUDED.avatar_media_id, fetched_at = NOW()
+		RETURNING fetched_at`,
+		actor.IRI, actor.PreferredUsername, actor.Name, actor.URL, actor.AvatarMediaID,
+	).Scan(&actor.FetchedAt)
+}
+
+// GetRemoteActor returns the stored profile of a remote actor
+func (s *RemoteActorService) GetRemoteActor(ctx context.Context, iri string) (*RemoteActorProfile, error) {
+	actor := &RemoteActorProfile{}
+	err := s.db.QueryRow(ctx, `
+		SELECT iri, preferred_username, name, url, avatar_media_id, fetched_at
+		FROM remote_actors
+		WHERE iri = $1`, iri,
+	).Scan(&actor.IRI, &actor.PreferredUsername, &actor.Name, &actor.URL, &actor.AvatarMediaID, &actor.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return actor, nil
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RemoteMediaStatus is where a remote file is in the cache
+type RemoteMediaStatus string
+
+const (
+	// RemoteMediaPending files are waiting to be fetched in the background
+	RemoteMediaPending RemoteMediaStatus = "pending"
+	// RemoteMediaCached files are in our storage
+	RemoteMediaCached RemoteMediaStatus = "cached"
+	// RemoteMediaFailed files could not be fetched or were rejected
+	RemoteMediaFailed RemoteMediaStatus = "failed"
+	// RemoteMediaEvicted files were removed from storage and are fetched
+	// again the next time they are requested
+	RemoteMediaEvicted RemoteMediaStatus = "evicted"
+)
+
+// RemoteMedia is a file hosted on another server that we serve from our own
+// storage so clients never contact the remote server
+type RemoteMedia struct {
+	ID             int               `json:"id"`
+	RemoteURL      string            `json:"remote_url"`
+	Domain         string            `json:"domain"`
+	Status         RemoteMediaStatus `json:"status"`
+	StorageKey     string            `json:"-"`
+	MediaType      string            `json:"media_type"`
+	Size           int64             `json:"size"`
+	Attempts       int               `json:"attempts"`
+	LastError      string            `json:"last_error,omitempty"`
+	FetchedAt      *time.Time        `json:"fetched_at,omitempty"`
+	LastAccessedAt time.Time         `json:"last_accessed_at"`
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// RemoteAttachment is a media attachment of a remote post
+type RemoteAttachment struct {
+	RemoteMediaID int    `json:"remote_media_id"`
+	MediaType     string `json:"media_type"`
+	Description   string `json:"description"`
+	Blurhash      string `json:"blurhash"`
+}
+
+type RemoteMediaService struct {
+	db *pgxpool.Pool
+}
+
+func NewRemoteMediaService(db *pgxpool.Pool) *RemoteMediaService {
+	return &RemoteMediaService{db: db}
+}
+
+const remoteMediaColumns = `id, remote_url, domain, status, COALESCE(storage_key, ''), media_type, size,
+	attempts, last_error, fetched_at, last_accessed_at, created_at`
+
+func scanRemoteMedia(row interface{ Scan(...interface{}) error }) (*RemoteMedia, error) {
+	m := &RemoteMedia{}
+	err := row.Scan(&m.ID, &m.RemoteURL, &m.Domain, &m.Status, &m.StorageKey, &m.MediaType, &m.Size,
+		&m.Attempts, &m.LastError, &m.FetchedAt, &m.LastAccessedAt, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RegisterRemoteMedia records a remote file to be cached, returning the
+// existing record if the URL is already known
+func (s *RemoteMediaService) RegisterRemoteMedia(ctx context.Context, remoteURL string) (*RemoteMedia, error) {
+	u, err := url.Parse(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanRemoteMedia(s.db.QueryRow(ctx, `
+		INSERT INTO remote_media (remote_url, domain)
+		VALUES ($1, $2)
+		ON CONFLICT (remote_url) DO UPDATE SET remote_url = EXCLUDED.remote_url
+		RETURNING `+remoteMediaColumns, remoteURL, u.Hostname()))
+}
+
+// GetRemoteMedia returns a remote file by ID
+func (s *RemoteMediaService) GetRemoteMedia(ctx context.Context, id int) (*RemoteMedia, error) {
+	return scanRemoteMedia(s.db.QueryRow(ctx, `
+		SELECT `+remoteMediaColumns+`
+		FROM remote_media
+		WHERE id = $1`, id))
+}
+
+// ListPendingRemoteMedia returns files waiting to be fetched that have been
+// tried fewer than maxAttempts times, oldest first
+func (s *RemoteMediaService) ListPendingRemoteMedia(ctx context.Context, maxAttempts, limit int) ([]*RemoteMedia, error) {
+	return s.list(ctx, `
+		SELECT `+remoteMediaColumns+`
+		FROM remote_media
+		WHERE status = 'pending' AND attempts < $1
+		ORDER BY created_at
+		LIMIT $2`, maxAttempts, limit)
+}
+
+// ListLeastRecentlyUsed returns cached files in the order they should be
+// evicted, optionally restricted to one domain
+func (s *RemoteMediaService) ListLeastRecentlyUsed(ctx context.Context, domain string, limit int) ([]*RemoteMedia, error) {
+	return s.list(ctx, `
+		SELECT `+remoteMediaColumns+`
+		FROM remote_media
+		WHERE status = 'cached' AND ($1 = '' OR domain = $1)
+		ORDER BY last_accessed_at
+		LIMIT $2`, domain, limit)
+}
+
+func (s *RemoteMediaService) list(ctx context.Context, query string, args ...interface{}) ([]*RemoteMedia, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []*RemoteMedia
+	for rows.Next() {
+		m, err := scanRemoteMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// CachedSize returns the total size of the cached files, optionally
+// restricted to one domain
+func (s *RemoteMediaService) CachedSize(ctx context.Context, domain string) (int64, error) {
+	var size int64
+	err := s.db.QueryRow(ctx, `
+		SELECT COALESCE(SUM(size), 0)
+		FROM remote_media
+		WHERE status = 'cached' AND ($1 = '' OR domain = $1)`, domain).Scan(&size)
+	return size, err
+}
+
+// DomainsOverQuota returns the domains whose cached files take up more than
+// quota bytes
+func (s *RemoteMediaService) DomainsOverQuota(ctx context.Context, quota int64) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT domain
+		FROM remote_media
+		WHERE status = 'cached'
+		GROUP BY domain
+		HAVING SUM(size) > $1`, quota)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// MarkRemoteMediaCached records that a file has been stored
+func (s *RemoteMediaService) MarkRemoteMediaCached(ctx context.Context, m *RemoteMedia) error {
+	return s.db.QueryRow(ctx, `
+		UPDATE remote_media
+		SET status = 'cached', storage_key = $2, media_type = $3, size = $4,
+			attempts = attempts + 1, last_error = '', fetched_at = NOW()
+		WHERE id = $1
+		RETURNING status, attempts, fetched_at`,
+		m.ID, m.StorageKey, m.MediaType, m.Size,
+	).Scan(&m.Status, &m.Attempts, &m.FetchedAt)
+}
+
+// MarkRemoteMediaFailed records a failed fetch. The file stays pending
+// until it has failed maxAttempts times, unless permanent is set.
+func (s *RemoteMediaService) MarkRemoteMediaFailed(ctx context.Context, id int, reason string, maxAttempts int, permanent bool) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE remote_media
+		SET attempts = attempts + 1, last_error = $2,
+			status = CASE WHEN $3 OR attempts + 1 >= $4 THEN 'failed' ELSE status END
+		WHERE id = $1`, id, reason, permanent, maxAttempts)
+	return err
+}
+
+// MarkRemoteMediaEvicted records that a file was removed from storage
+func (s *RemoteMediaService) MarkRemoteMediaEvicted(ctx context.Context, id int) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE remote_media
+		SET status = 'evicted', storage_key = NULL, size = 0, attempts = 0
+		WHERE id = $1`, id)
+	return err
+}
+
+// TouchRemoteMedia records that a file was served, for LRU eviction
+func (s *RemoteMediaService) TouchRemoteMedia(ctx context.Context, id int) error {
+	_, err := s.db.Exec(ctx, `
+		UPDATE remote_media SET last_accessed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// SetRemotePostAttachments replaces the attachments of a remote post
+func (s *RemoteMediaService) SetRemotePostAttachments(ctx context.Context, remotePostID int, attachments []*RemoteAttachment) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM remote_post_attachments WHERE remote_post_id = $1`, remotePostID); err != nil {
+		return err
+	}
+
+	for i, a := range attachments {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO remote_post_attachments (remote_post_id, position, remote_media_id, media_type, description, blurhash)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			remotePostID, i, a.RemoteMediaID, a.MediaType, a.Description, a.Blurhash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListRemotePostAttachments returns the attachments of a remote post in order
+func (s *RemoteMediaService) ListRemotePostAttachments(ctx context.Context, remotePostID int) ([]*RemoteAttachment, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT remote_media_id, media_type, description, blurhash
+		FROM remote_post_attachments
+		WHERE remote_post_id = $1
+		ORDER BY position`, remotePostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []*RemoteAttachment
+	for rows.Next() {
+		a := &RemoteAttachment{}
+		if err := rows.Scan(&a.RemoteMediaID, &a.MediaType, &a.Description, &a.Blurhash); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
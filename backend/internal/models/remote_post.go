@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RemotePost is a post received from another server. Content has already
+// been sanitized when it is stored.
+type RemotePost struct {
+	ID          int       `json:"id"`
+	IRI         string    `json:"iri"`
+	ActorIRI    string    `json:"actor_iri"`
+	Content     string    `json:"content"`
+	URL         string    `json:"url,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RemotePostService struct {
+	db *pgxpool.Pool
+}
+
+func NewRemotePostService(db *pgxpool.Pool) *RemotePostService {
+	return &RemotePostService{db: db}
+}
+
+// UpsertRemotePost stores a remote post, updating it if its IRI is known
+func (s *RemotePostService) UpsertRemotePost(ctx context.Context, post *RemotePost) error {
+	return s.db.QueryRow(ctx, `
+		INSERT INTO remote_posts (iri, actor_iri, content, url, published_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (iri) DO UPDATE
+		SET content = EXCLUDED.content, url = EXCLUDED.url, updated_at = NOW()
+		RETURNING id, created_at, updated_at`,
+		post.IRI, post.ActorIRI, post.Content, post.URL, post.PublishedAt,
+	).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+}
+
+// GetRemotePostByIRI returns a remote post by its IRI
+func (s *RemotePostService) GetRemotePostByIRI(ctx context.Context, iri string) (*RemotePost, error) {
+	post := &RemotePost{}
+	err := s.db.QueryRow(ctx, `
+		SELECT id, iri, actor_iri, content, COALESCE(url, ''), published_at, created_at, updated_at
+		FROM remote_posts
+		WHERE iri = $1`, iri,
+	).Scan(&post.ID, &post.IRI, &post.ActorIRI, &post.Content, &post.URL,
+		&post.PublishedAt, &post.CreatedAt, &post.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
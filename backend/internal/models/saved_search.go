@@ -0,0 +1,434 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AlertCadence is how often a saved search sends alerts of new matches:
+// as soon as jobs match, or at most once a day or week
+type AlertCadence string
+
+const (
+	AlertInstant AlertCadence = "instant"
+	AlertDaily   AlertCadence = "daily"
+	AlertWeekly  AlertCadence = "weekly"
+)
+
+func (c AlertCadence) Valid() bool {
+	switch c {
+	case AlertInstant, AlertDaily, AlertWeekly:
+		return true
+	}
+	return false
+}
+
+const (
+	// MaxSavedSearches is how many searches a user can save
+	MaxSavedSearches       = 20
+	maxSavedSearchNameLen  = 100
+	maxSavedSearchQueryLen = 500
+)
+
+var (
+	ErrSavedSearchNotFound  = errors.New("saved search not found")
+	ErrInvalidSavedSearch   = errors.New("invalid saved search")
+	ErrTooManySavedSearches = errors.New("too many saved searches")
+)
+
+// SavedSearchFilters are the job board filters of a saved search, named as
+// the job board's query parameters
+type SavedSearchFilters struct {
+	Query          string         `json:"q,omitempty"`
+	Location       string         `json:"location,omitempty"`
+	Company        string         `json:"company,omitempty"`
+	Remote         RemotePolicy   `json:"remote,omitempty"`
+	EmploymentType EmploymentType `json:"employment_type,omitempty"`
+	Seniority      Seniority      `json:"seniority,omitempty"`
+	SalaryCurrency string         `json:"currency,omitempty"`
+	MinSalary      int64          `json:"salary_min,omitempty"`
+	MaxSalary      int64          `json:"salary_max,omitempty"`
+	Skills         []string       `json:"skills,omitempty"`
+}
+
+// JobSearch returns the search the filters describe
+func (f *SavedSearchFilters) JobSearch() *JobSearch {
+	return &JobSearch{
+		Query:          f.Query,
+		Location:       f.Location,
+		Company:        f.Company,
+		Remote:         f.Remote,
+		EmploymentType: f.EmploymentType,
+		Seniority:      f.Seniority,
+		MinSalary:      f.MinSalary,
+		MaxSalary:      f.MaxSalary,
+		SalaryCurrency: f.SalaryCurrency,
+		Skills:         f.Skills,
+		Sort:           JobSortRecent,
+	}
+}
+
+// SavedSearch is a job search a user is alerted about. FeedToken and
+// UnsubscribeToken are secrets that grant access to its feed and email
+// alerts without logging in.
+type SavedSearch struct {
+	ID               int                `json:"id"`
+	UserID           int                `json:"user_id"`
+	Name             string             `json:"name"`
+	Filters          SavedSearchFilters `json:"filters"`
+	Cadence          AlertCadence       `json:"cadence"`
+	Email            bool               `json:"email"`
+	FeedToken        string             `json:"-"`
+	UnsubscribeToken string             `json:"-"`
+	LastCheckedAt    time.Time          `json:"-"`
+	LastSentAt       *time.Time         `json:"last_sent_at"`
+	CreatedAt        time.Time          `json:"created_at"`
+}
+
+// Normalize tidies up user input before validation
+func (s *SavedSearch) Normalize() {
+	s.Name = strings.TrimSpace(s.Name)
+	f := &s.Filters
+	f.Query = strings.TrimSpace(f.Query)
+	f.Location = strings.TrimSpace(f.Location)
+	f.Company = strings.TrimSpace(f.Company)
+	f.SalaryCurrency = strings.ToUpper(strings.TrimSpace(f.SalaryCurrency))
+
+	skills := f.Skills[:0]
+	for _, skill := range f.Skills {
+		if skill = strings.TrimSpace(skill); skill != "" {
+			skills = append(skills, skill)
+		}
+	}
+	f.Skills = skills
+}
+
+// Validate checks a saved search
+func (s *SavedSearch) Validate() error {
+	if s.Name == "" || utf8.RuneCountInString(s.Name) > maxSavedSearchNameLen {
+		return fmt.Errorf("%w: name must be between 1 and %d characters", ErrInvalidSavedSearch, maxSavedSearchNameLen)
+	}
+	if !s.Cadence.Valid() {
+		return fmt.Errorf("%w: cadence must be instant, daily or weekly", ErrInvalidSavedSearch)
+	}
+
+	f := &s.Filters
+	for _, text := range []string{f.Query, f.Location, f.Company} {
+		if utf8.RuneCountInString(text) > maxSavedSearchQueryLen {
+			return fmt.Errorf("%w: filters are too long", ErrInvalidSavedSearch)
+		}
+	}
+	if f.Remote != "" && !f.Remote.Valid() {
+		return fmt.Errorf("%w: invalid remote filter", ErrInvalidSavedSearch)
+	}
+	if f.EmploymentType != "" && !f.EmploymentType.Valid() {
+		return fmt.Errorf("%w: invalid employment type filter", ErrInvalidSavedSearch)
+	}
+	if f.Seniority != "" && !f.Seniority.Valid() {
+		return fmt.Errorf("%w: invalid seniority filter", ErrInvalidSavedSearch)
+	}
+	if f.SalaryCurrency != "" && !currencyRe.MatchString(f.SalaryCurrency) {
+		return fmt.Errorf("%w: currency must be a three-letter ISO 4217 code", ErrInvalidSavedSearch)
+	}
+	if f.MinSalary < 0 || f.MaxSalary < 0 || ((f.MinSalary > 0 || f.MaxSalary > 0) && f.SalaryCurrency == "") {
+		return fmt.Errorf("%w: salary filter requires a currency", ErrInvalidSavedSearch)
+	}
+	if f.MaxSalary > 0 && f.MinSalary > f.MaxSalary {
+		return fmt.Errorf("%w: minimum salary cannot exceed maximum salary", ErrInvalidSavedSearch)
+	}
+	if len(f.Skills) > MaxJobSkills {
+		return fmt.Errorf("%w: at most %d skills are allowed", ErrInvalidSavedSearch, MaxJobSkills)
+	}
+	for _, skill := range f.Skills {
+		if !ValidSkillName(skill) {
+			return fmt.Errorf("%w: skills must be 1 to %d characters long", ErrInvalidSavedSearch, MaxSkillLength)
+		}
+	}
+	return nil
+}
+
+const savedSearchColumns = `id, user_id, name, filters, cadence, email, feed_token, unsubscribe_token,
+	last_checked_at, last_sent_at, created_at`
+
+func scanSavedSearch(row interface{ Scan(...interface{}) error }) (*SavedSearch, error) {
+	s := &SavedSearch{}
+	err := row.Scan(&s.ID, &s.UserID, &s.Name, &s.Filters, &s.Cadence, &s.Email, &s.FeedToken,
+		&s.UnsubscribeToken, &s.LastCheckedAt, &s.LastSentAt, &s.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type SavedSearchService struct {
+	db *pgxpool.Pool
+}
+
+func NewSavedSearchService(db *pgxpool.Pool) *SavedSearchService {
+	return &SavedSearchService{db: db}
+}
+
+// CreateSavedSearch saves a search for its user. Only jobs listed from now
+// on are alerted about.
+func (s *SavedSearchService) CreateSavedSearch(ctx context.Context, search *SavedSearch) error {
+	feedToken, err := randomHex(20)
+	if err != nil {
+		return err
+	}
+	unsubscribeToken, err := randomHex(20)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO saved_searches (user_id, name, filters, cadence, email, feed_token, unsubscribe_token)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE (SELECT COUNT(*) FROM saved_searches WHERE user_id = $1) < $8
+		RETURNING id, last_checked_at, created_at`,
+		search.UserID, search.Name, &search.Filters, search.Cadence, search.Email, feedToken, unsubscribeToken,
+		MaxSavedSearches,
+	).Scan(&search.ID, &search.LastCheckedAt, &search.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTooManySavedSearches
+	}
+	if err != nil {
+		return err
+	}
+	search.FeedToken = feedToken
+	search.UnsubscribeToken = unsubscribeToken
+	return nil
+}
+
+// ListSavedSearches returns a user's saved searches, oldest first
+func (s *SavedSearchService) ListSavedSearches(ctx context.Context, userID int) ([]*SavedSearch, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE user_id = $1
+		ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []*SavedSearch{}
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+// GetSavedSearch returns one of a user's saved searches
+func (s *SavedSearchService) GetSavedSearch(ctx context.Context, userID, id int) (*SavedSearch, error) {
+	return scanSavedSearch(s.db.QueryRow(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE id = $1 AND user_id = $2`, id, userID))
+}
+
+// GetSavedSearchByFeedToken returns the saved search a private feed URL
+// belongs to
+func (s *SavedSearchService) GetSavedSearchByFeedToken(ctx context.Context, token string) (*SavedSearch, error) {
+	return scanSavedSearch(s.db.QueryRow(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE feed_token = $1`, token))
+}
+
+// UpdateSavedSearch saves changes to a saved search's name, filters and
+// alerts
+func (s *SavedSearchService) UpdateSavedSearch(ctx context.Context, search *SavedSearch) error {
+	tag, err := s.db.Exec(ctx, `
+		UPDATE saved_searches
+		SET name = $3, filters = $4, cadence = $5, email = $6
+		WHERE id = $1 AND user_id = $2`,
+		search.ID, search.UserID, search.Name, &search.Filters, search.Cadence, search.Email)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// DeleteSavedSearch deletes one of a user's saved searches
+func (s *SavedSearchService) DeleteSavedSearch(ctx context.Context, userID, id int) error {
+	tag, err := s.db.Exec(ctx, `
+		DELETE FROM saved_searches
+		WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// Unsubscribe stops email alerts of the saved search an unsubscribe link
+// belongs to, returning it
+func (s *SavedSearchService) Unsubscribe(ctx context.Context, token string) (*SavedSearch, error) {
+	return scanSavedSearch(s.db.QueryRow(ctx, `
+		UPDATE saved_searches
+		SET email = FALSE
+		WHERE unsubscribe_token = $1
+		RETURNING `+savedSearchColumns, token))
+}
+
+// ListDueForMatching returns the saved searches least recently matched
+// against new jobs, if they were last matched before a time
+func (s *SavedSearchService) ListDueForMatching(ctx context.Context, before time.Time, limit int) ([]*SavedSearch, error) {
+	return s.list(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches
+		WHERE last_checked_at < $1
+		ORDER BY last_checked_at
+		LIMIT $2`, before, limit)
+}
+
+// ListDueForAlert returns the saved searches with unsent matches whose
+// cadence lets them send an alert now
+func (s *SavedSearchService) ListDueForAlert(ctx context.Context, limit int) ([]*SavedSearch, error) {
+	return s.list(ctx, `
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches ss
+		WHERE EXISTS (
+				SELECT 1 FROM saved_search_matches m
+				WHERE m.saved_search_id = ss.id AND m.notified_at IS NULL)
+			AND (ss.last_sent_at IS NULL
+				OR ss.cadence = 'instant'
+				OR (ss.cadence = 'daily' AND ss.last_sent_at <= NOW() - INTERVAL '1 day')
+				OR (ss.cadence = 'weekly' AND ss.last_sent_at <= NOW() - INTERVAL '7 days'))
+		ORDER BY ss.last_sent_at NULLS FIRST
+		LIMIT $1`, limit)
+}
+
+// list runs a query returning saved searches
+func (s *SavedSearchService) list(ctx context.Context, query string, args ...interface{}) ([]*SavedSearch, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []*SavedSearch
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+// RecordMatches stores jobs that matched a saved search and moves its
+// checkpoint to checkedAt
+func (s *SavedSearchService) RecordMatches(ctx context.Context, searchID int, jobIDs []int, checkedAt time.Time) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(jobIDs) > 0 {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO saved_search_matches (saved_search_id, job_id)
+			SELECT $1, unnest($2::int[])
+			ON CONFLICT DO NOTHING`, searchID, jobIDs); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE saved_searches SET last_checked_at = $2 WHERE id = $1`, searchID, checkedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ListPendingMatches returns the IDs of the jobs that matched a saved
+// search since its last alert and are still listed, newest first
+func (s *SavedSearchService) ListPendingMatches(ctx context.Context, searchID, limit int) ([]int, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT j.id
+		FROM saved_search_matches m
+		JOIN jobs j ON j.id = m.job_id
+		WHERE m.saved_search_id = $1 AND m.notified_at IS NULL AND `+listedJobCondition+`
+		ORDER BY j.created_at DESC
+		LIMIT $2`, searchID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkAlerted records that a saved search sent an alert and settles its
+// pending matches
+func (s *SavedSearchService) MarkAlerted(ctx context.Context, searchID int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := settleMatches(ctx, tx, searchID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE saved_searches SET last_sent_at = NOW() WHERE id = $1`, searchID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DiscardPendingMatches settles the pending matches of a saved search
+// without an alert, for when every matching job closed before it went out
+func (s *SavedSearchService) DiscardPendingMatches(ctx context.Context, searchID int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := settleMatches(ctx, tx, searchID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// settleMatches marks all pending matches of a saved search as handled,
+// including any beyond what an alert listed
+func settleMatches(ctx context.Context, tx pgx.Tx, searchID int) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE saved_search_matches
+		SET notified_at = NOW()
+		WHERE saved_search_id = $1 AND notified_at IS NULL`, searchID)
+	return err
+}
@@ -0,0 +1,432 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// MaxSkillLength is the longest skill name accepted, in characters
+	MaxSkillLength = 50
+	// MaxJobSkills is how many skills a job posting can list
+	MaxJobSkills = 30
+	// MaxUserSkills is how many skills a user can list on their profile
+	MaxUserSkills = 50
+)
+
+var ErrInvalidSkill = errors.New("invalid skill")
+
+// ValidSkillName reports whether name can be added to the taxonomy
+func ValidSkillName(name string) bool {
+	name = strings.Join(strings.Fields(name), " ")
+	return name != "" && utf8.RuneCountInString(name) <= MaxSkillLength
+}
+
+// NormalizeSkill returns the slug a skill name or alias is looked up by:
+// lowercased with runs of whitespace collapsed
+func NormalizeSkill(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// Skill is an entry of the skills taxonomy shared by jobs and profiles
+type Skill struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Slug    string   `json:"slug"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// JobSkill is a skill a job asks for, either required or nice to have
+type JobSkill struct {
+	Skill
+	Required bool `json:"required"`
+}
+
+// Proficiency rates how well a user knows a skill, from 1 (beginner) to 5
+// (expert)
+type Proficiency int
+
+// Valid reports whether p is a known proficiency level
+func (p Proficiency) Valid() bool {
+	return p >= 1 && p <= 5
+}
+
+// UserSkill is a skill on a user's profile
+type UserSkill struct {
+	Skill
+	Proficiency Proficiency `json:"proficiency"`
+}
+
+// UserSkillInput is a skill a user adds to their profile, by name or alias
+type UserSkillInput struct {
+	Name        string      `json:"name"`
+	Proficiency Proficiency `json:"proficiency"`
+}
+
+type SkillService struct {
+	db *pgxpool.Pool
+}
+
+func NewSkillService(db *pgxpool.Pool) *SkillService {
+	return &SkillService{db: db}
+}
+
+// ResolveSkill returns the skill a name or alias refers to, adding it to
+// the taxonomy if it is new
+func (s *SkillService) ResolveSkill(ctx context.Context, name string) (*Skill, error) {
+	if !ValidSkillName(name) {
+		return nil, ErrInvalidSkill
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	slug := NormalizeSkill(name)
+
+	skill := &Skill{}
+	err := s.db.QueryRow(ctx, `
+		SELECT s.id, s.name, s.slug
+		FROM skills s
+		WHERE s.slug = $1 OR s.id = (SELECT skill_id FROM skill_aliases WHERE alias = $1)
+		ORDER BY s.slug = $1 DESC
+		LIMIT 1`, slug,
+	).Scan(&skill.ID, &skill.Name, &skill.Slug)
+	if err == nil {
+		return skill, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	// The no-op update returns the row when another request added it first
+	err = s.db.QueryRow(ctx, `
+		INSERT INTO skills (name, slug)
+		VALUES ($1, $2)
+		ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+		RETURNING id, name, slug`, name, slug,
+	).Scan(&skill.ID, &skill.Name, &skill.Slug)
+	if err != nil {
+		return nil, err
+	}
+	return skill, nil
+}
+
+// resolveSkills resolves a list of names, dropping duplicates
+func (s *SkillService) resolveSkills(ctx context.Context, names []string, seen map[int]bool) ([]*Skill, error) {
+	var skills []*Skill
+	for _, name := range names {
+		skill, err := s.ResolveSkill(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", err, name)
+		}
+		if !seen[skill.ID] {
+			seen[skill.ID] = true
+			skills = append(skills, skill)
+		}
+	}
+	return skills, nil
+}
+
+// GetSkillBySlug returns a skill along with its aliases
+func (s *SkillService) GetSkillBySlug(ctx context.Context, slug string) (*Skill, error) {
+	skill := &Skill{}
+	err := s.db.QueryRow(ctx, `
+		SELECT s.id, s.name, s.slug,
+			ARRAY(SELECT alias FROM skill_aliases WHERE skill_id = s.id ORDER BY alias)
+		FROM skills s
+		WHERE s.slug = $1`, NormalizeSkill(slug),
+	).Scan(&skill.ID, &skill.Name, &skill.Slug, &skill.Aliases)
+	if err != nil {
+		return nil, err
+	}
+	return skill, nil
+}
+
+// Autocomplete returns skills whose name or an alias starts with a prefix,
+// most used first
+func (s *SkillService) Autocomplete(ctx context.Context, prefix string, limit int) ([]*Skill, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(NormalizeSkill(prefix)) + "%"
+
+	rows, err := s.db.Query(ctx, `
+		SELECT s.id, s.name, s.slug
+		FROM skills s
+		WHERE s.slug LIKE $1
+			OR EXISTS (SELECT 1 FROM skill_aliases a WHERE a.skill_id = s.id AND a.alias LIKE $1)
+		ORDER BY (SELECT COUNT(*) FROM job_skills WHERE skill_id = s.id)
+			+ (SELECT COUNT(*) FROM user_skills WHERE skill_id = s.id) DESC, s.name
+		LIMIT $2`, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []*Skill{}
+	for rows.Next() {
+		skill := &Skill{}
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Slug); err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
+
+// ListSkills returns the whole taxonomy with aliases, by name
+func (s *SkillService) ListSkills(ctx context.Context) ([]*Skill, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT s.id, s.name, s.slug,
+			ARRAY(SELECT alias FROM skill_aliases WHERE skill_id = s.id ORDER BY alias)
+		FROM skills s
+		ORDER BY s.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var skills []*Skill
+	for rows.Next() {
+		skill := &Skill{}
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Slug, &skill.Aliases); err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
+
+// AddAlias makes an alternative spelling resolve to a skill. If the alias
+// was a skill of its own, its jobs and users move to the target skill and
+// it is removed.
+func (s *SkillService) AddAlias(ctx context.Context, skillID int, alias string) error {
+	alias = NormalizeSkill(alias)
+	if alias == "" {
+		return ErrInvalidSkill
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var mergedID int
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM skills WHERE slug = $1`, alias).Scan(&mergedID)
+	switch {
+	case err == nil:
+		if mergedID == skillID {
+			return ErrInvalidSkill
+		}
+		if err := mergeSkill(ctx, tx, mergedID, skillID); err != nil {
+			return err
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO skill_aliases (alias, skill_id)
+		VALUES ($1, $2)
+		ON CONFLICT (alias) DO UPDATE SET skill_id = EXCLUDED.skill_id`, alias, skillID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// mergeSkill moves everything using one skill over to another and deletes
+// it
+func mergeSkill(ctx context.Context, tx pgx.Tx, fromID, intoID int) error {
+	statements := []string{
+		`INSERT INTO job_skills (job_id, skill_id, required, position)
+		SELECT job_id, $2, required, position FROM job_skills WHERE skill_id = $1
+		ON CONFLICT DO NOTHING`,
+		`INSERT INTO user_skills (user_id, skill_id, proficiency, position)
+		SELECT user_id, $2, proficiency, position FROM user_skills WHERE skill_id = $1
+		ON CONFLICT DO NOTHING`,
+		`UPDATE skill_aliases SET skill_id = $2 WHERE skill_id = $1`,
+		`DELETE FROM skills WHERE id = $1`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(ctx, statement, fromID, intoID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobSkillList is the resolved skills of a job posting, in order
+type JobSkillList struct {
+	Required   []*Skill
+	NiceToHave []*Skill
+}
+
+// ResolveJobSkills resolves the skill names of a job posting, dropping
+// duplicates and enforcing MaxJobSkills
+func (s *SkillService) ResolveJobSkills(ctx context.Context, required, niceToHave []string) (*JobSkillList, error) {
+	seen := make(map[int]bool)
+	requiredSkills, err := s.resolveSkills(ctx, required, seen)
+	if err != nil {
+		return nil, err
+	}
+	optionalSkills, err := s.resolveSkills(ctx, niceToHave, seen)
+	if err != nil {
+		return nil, err
+	}
+	if len(requiredSkills)+len(optionalSkills) > MaxJobSkills {
+		return nil, fmt.Errorf("%w: at most %d skills are allowed", ErrInvalidSkill, MaxJobSkills)
+	}
+	return &JobSkillList{Required: requiredSkills, NiceToHave: optionalSkills}, nil
+}
+
+// replaceJobSkills replaces the skills of a job within a transaction
+func replaceJobSkills(ctx context.Context, tx pgx.Tx, jobID int, skills *JobSkillList) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM job_skills WHERE job_id = $1`, jobID); err != nil {
+		return err
+	}
+	position := 0
+	for _, list := range []struct {
+		skills   []*Skill
+		required bool
+	}{{skills.Required, true}, {skills.NiceToHave, false}} {
+		for _, skill := range list.skills {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO job_skills (job_id, skill_id, required, position)
+				VALUES ($1, $2, $3, $4)`, jobID, skill.ID, list.required, position); err != nil {
+				return err
+			}
+			position++
+		}
+	}
+	return nil
+}
+
+// copyJobSkills gives a job the same skills as another
+func copyJobSkills(ctx context.Context, tx pgx.Tx, fromJobID, toJobID int) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO job_skills (job_id, skill_id, required, position)
+		SELECT $2, skill_id, required, position FROM job_skills WHERE job_id = $1
+		ON CONFLICT DO NOTHING`, fromJobID, toJobID)
+	return err
+}
+
+// ListJobSkills returns the skills of a job, required ones first
+func (s *SkillService) ListJobSkills(ctx context.Context, jobID int) ([]*JobSkill, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT s.id, s.name, s.slug, js.required
+		FROM job_skills js
+		JOIN skills s ON s.id = js.skill_id
+		WHERE js.job_id = $1
+		ORDER BY js.required DESC, js.position`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []*JobSkill{}
+	for rows.Next() {
+		skill := &JobSkill{}
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Slug, &skill.Required); err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
+
+// listJobsSkills returns the skills of jobs by job ID, in the order
+// ListJobSkills returns them
+func (s *SkillService) listJobsSkills(ctx context.Context, jobIDs []int) (map[int][]*JobSkill, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT js.job_id, s.id, s.name, s.slug, js.required
+		FROM job_skills js
+		JOIN skills s ON s.id = js.skill_id
+		WHERE js.job_id = ANY($1)
+		ORDER BY js.job_id, js.required DESC, js.position`, jobIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := make(map[int][]*JobSkill)
+	for rows.Next() {
+		skill := &JobSkill{}
+		var jobID int
+		if err := rows.Scan(&jobID, &skill.ID, &skill.Name, &skill.Slug, &skill.Required); err != nil {
+			return nil, err
+		}
+		skills[jobID] = append(skills[jobID], skill)
+	}
+	return skills, rows.Err()
+}
+
+// SetUserSkills replaces the skills on a user's profile
+func (s *SkillService) SetUserSkills(ctx context.Context, userID int, inputs []*UserSkillInput) error {
+	if len(inputs) > MaxUserSkills {
+		return fmt.Errorf("%w: at most %d skills are allowed", ErrInvalidSkill, MaxUserSkills)
+	}
+
+	seen := make(map[int]bool)
+	var skills []*UserSkill
+	for _, input := range inputs {
+		if input == nil {
+			return ErrInvalidSkill
+		}
+		if !input.Proficiency.Valid() {
+			return fmt.Errorf("%w: proficiency of %q must be between 1 and 5", ErrInvalidSkill, input.Name)
+		}
+		skill, err := s.ResolveSkill(ctx, input.Name)
+		if err != nil {
+			return fmt.Errorf("%w: %q", err, input.Name)
+		}
+		if !seen[skill.ID] {
+			seen[skill.ID] = true
+			skills = append(skills, &UserSkill{Skill: *skill, Proficiency: input.Proficiency})
+		}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_skills WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for position, skill := range skills {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_skills (user_id, skill_id, proficiency, position)
+			VALUES ($1, $2, $3, $4)`, userID, skill.ID, skill.Proficiency, position); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// ListUserSkills returns the skills on a user's profile in the order they
+// listed them
+func (s *SkillService) ListUserSkills(ctx context.Context, userID int) ([]*UserSkill, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT s.id, s.name, s.slug, us.proficiency
+		FROM user_skills us
+		JOIN skills s ON s.id = us.skill_id
+		WHERE us.user_id = $1
+		ORDER BY us.position`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	skills := []*UserSkill{}
+	for rows.Next() {
+		skill := &UserSkill{}
+		if err := rows.Scan(&skill.ID, &skill.Name, &skill.Slug, &skill.Proficiency); err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+	return skills, rows.Err()
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ObjectKind identifies the kind of object a tag is attached to
+type ObjectKind string
+
+const (
+	ObjectPost       ObjectKind = "post"
+	ObjectJob        ObjectKind = "job"
+	ObjectRemotePost ObjectKind = "remote_post"
+)
+
+// TagKind is the ActivityStreams type of a tag
+type TagKind string
+
+const (
+	TagMention TagKind = "Mention"
+	TagHashtag TagKind = "Hashtag"
+)
+
+// Tag is a mention or hashtag attached to a post or job posting. Hashtag
+// names are stored normalized, without the leading '#'.
+type Tag struct {
+	Kind TagKind `json:"type"`
+	Name string  `json:"name"`
+	Href string  `json:"href"`
+}
+
+// NormalizeHashtag returns the canonical form of a hashtag name
+func NormalizeHashtag(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "#"))
+}
+
+type TagService struct {
+	db *pgxpool.Pool
+}
+
+func NewTagService(db *pgxpool.Pool) *TagService {
+	return &TagService{db: db}
+}
+
+// SetTags replaces the tags attached to an object
+func (s *TagService) SetTags(ctx context.Context, kind ObjectKind, objectID int, tags []*Tag) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceTags(ctx, tx, kind, objectID, tags); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// replaceTags replaces the tags of an object within a transaction
+func replaceTags(ctx context.Context, tx pgx.Tx, kind ObjectKind, objectID int, tags []*Tag) error {
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM object_tags
+		WHERE object_kind = $1 AND object_id = $2`, kind, objectID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO object_tags (object_kind, object_id, kind, name, href)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING`,
+			kind, objectID, tag.Kind, tag.Name, tag.Href); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTags returns the tags attached to an object
+func (s *TagService) ListTags(ctx context.Context, kind ObjectKind, objectID int) ([]*Tag, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT kind, name, href
+		FROM object_tags
+		WHERE object_kind = $1 AND object_id = $2
+		ORDER BY kind, name`, kind, objectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	for rows.Next() {
+		tag := &Tag{}
+		if err := rows.Scan(&tag.Kind, &tag.Name, &tag.Href); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// FollowHashtag adds a hashtag to a user's home timeline
+func (s *TagService) FollowHashtag(ctx context.Context, userID int, name string) error {
+	_, err := s.db.Exec(ctx, `
+		INSERT INTO hashtag_follows (user_id, name)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, userID, NormalizeHashtag(name))
+	return err
+}
+
+// UnfollowHashtag removes a hashtag from a user's home timeline
+func (s *TagService) UnfollowHashtag(ctx context.Context, userID int, name string) error {
+	_, err := s.db.Exec(ctx, `
+		DELETE FROM hashtag_follows
+		WHERE user_id = $1 AND name = $2`, userID, NormalizeHashtag(name))
+	return err
+}
+
+// ListFollowedHashtags returns the hashtags a user follows
+func (s *TagService) ListFollowedHashtags(ctx context.Context, userID int) ([]string, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT name
+		FROM hashtag_follows
+		WHERE user_id = $1
+		ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
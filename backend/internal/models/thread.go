@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ThreadEntry records where a local or remote post sits in a conversation
+type ThreadEntry struct {
+	IRI         string     `json:"iri"`
+	Kind        ObjectKind `json:"kind"`
+	ObjectID    int        `json:"object_id"`
+	InReplyTo   string     `json:"in_reply_to,omitempty"`
+	Context     string     `json:"context"`
+	PublishedAt time.Time  `json:"published_at"`
+}
+
+// ThreadDescendant is a reply found while walking down a thread
+type ThreadDescendant struct {
+	*ThreadEntry
+	Depth int `json:"depth"`
+}
+
+type ThreadService struct {
+	db *pgxpool.Pool
+}
+
+func NewThreadService(db *pgxpool.Pool) *ThreadService {
+	return &ThreadService{db: db}
+}
+
+// SaveEntry records or updates the thread position of a post
+func (s *ThreadService) SaveEntry(ctx context.Context, entry *ThreadEntry) error {
+	_, err := s.db.Exec(ctx, saveEntryQuery,
+		entry.IRI, entry.Kind, entry.ObjectID, entry.InReplyTo, entry.Context, entry.PublishedAt)
+	return err
+}
+
+const saveEntryQuery = `
+	INSERT INTO post_threads (iri, object_kind, object_id, in_reply_to, context, published_at)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+	ON CONFLICT (iri) DO UPDATE
+	SET in_reply_to = EXCLUDED.in_reply_to, context = EXCLUDED.context`
+
+// saveEntry records the thread position of a post within a transaction
+func saveEntry(ctx context.Context, tx pgx.Tx, entry *ThreadEntry) error {
+	_, err := tx.Exec(ctx, saveEntryQuery,
+		entry.IRI, entry.Kind, entry.ObjectID, entry.InReplyTo, entry.Context, entry.PublishedAt)
+	return err
+}
+
+const threadEntryColumns = `iri, object_kind, object_id, COALESCE(in_reply_to, ''), context, published_at`
+
+// GetEntry returns the thread position of a post by IRI
+func (s *ThreadService) GetEntry(ctx context.Context, iri string) (*ThreadEntry, error) {
+	entry := &ThreadEntry{}
+	err := s.db.QueryRow(ctx, `
+		SELECT `+threadEntryColumns+`
+		FROM post_threads
+		WHERE iri = $1`, iri,
+	).Scan(&entry.IRI, &entry.Kind, &entry.ObjectID, &entry.InReplyTo, &entry.Context, &entry.PublishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetEntryByObject returns the thread position of a post by its kind and id
+func (s *ThreadService) GetEntryByObject(ctx context.Context, kind ObjectKind, objectID int) (*ThreadEntry, error) {
+	entry := &ThreadEntry{}
+	err := s.db.QueryRow(ctx, `
+		SELECT `+threadEntryColumns+`
+		FROM post_threads
+		WHERE object_kind = $1 AND object_id = $2`, kind, objectID,
+	).Scan(&entry.IRI, &entry.Kind, &entry.ObjectID, &entry.InReplyTo, &entry.Context, &entry.PublishedAt)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListReplies returns the direct replies to an object, oldest first
+func (s *ThreadService) ListReplies(ctx context.Context, iri string, offset, limit int) ([]*ThreadEntry, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT `+threadEntryColumns+`
+		FROM post_threads
+		WHERE in_reply_to = $1
+		ORDER BY published_at
+		OFFSET $2 LIMIT $3`, iri, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*ThreadEntry
+	for rows.Next() {
+		entry := &ThreadEntry{}
+		if err := rows.Scan(&entry.IRI, &entry.Kind, &entry.ObjectID, &entry.InReplyTo,
+			&entry.Context, &entry.PublishedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// CountReplies returns the number of direct replies to an object
+func (s *ThreadService) CountReplies(ctx context.Context, iri string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, `SELECT COUNT(*) FROM post_threads WHERE in_reply_to = $1`, iri).Scan(&count)
+	return count, err
+}
+
+// ListDescendants walks down the replies to an object, up to maxDepth
+// levels and limit entries, ordered so that parents precede their replies
+func (s *ThreadService) ListDescendants(ctx context.Context, iri string, maxDepth, limit int) ([]*ThreadDescendant, error) {
+	rows, err := s.db.Query(ctx, `
+		WITH RECURSIVE descendants AS (
+			SELECT t.*, 1 AS depth
+			FROM post_threads t
+			WHERE t.in_reply_to = $1
+			UNION ALL
+			SELECT t.*, d.depth + 1
+			FROM post_threads t
+			JOIN descendants d ON t.in_reply_to = d.iri
+			WHERE d.depth < $2
+		)
+		SELECT `+threadEntryColumns+`, depth
+		FROM descendants
+		ORDER BY depth, published_at
+		LIMIT $3`, iri, maxDepth, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var descendants []*ThreadDescendant
+	for rows.Next() {
+		d := &ThreadDescendant{ThreadEntry: &ThreadEntry{}}
+		if err := rows.Scan(&d.IRI, &d.Kind, &d.ObjectID, &d.InReplyTo, &d.Context,
+			&d.PublishedAt, &d.Depth); err != nil {
+			return nil, err
+		}
+		descendants = append(descendants, d)
+	}
+	return descendants, rows.Err()
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TimelineItem is an entry of a timeline: a local post, a local job posting
+// or a post received from another server
+type TimelineItem struct {
+	Kind ObjectKind `json:"kind"`
+	ID   int        `json:"id"`
+	// IRI is only set for remote posts; local objects derive theirs from ID
+	IRI         string    `json:"iri,omitempty"`
+	Author      string    `json:"author"`
+	Title       string    `json:"title,omitempty"`
+	Content     string    `json:"content"`
+	URL         string    `json:"url,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// timelineQuery selects timeline items across posts, jobs and remote posts.
+// The placeholders receive the condition posts (p), jobs (j) and remote
+// posts (r) must meet to be included; $2 and $3 are the offset and limit.
+// Only listed jobs are included.
+const timelineQuery = `
+	SELECT kind, id, iri, author, title, content, url, published_at FROM (
+		SELECT 'post' AS kind, p.id, '' AS iri, u.username AS author, '' AS title,
+			p.content, '' AS url, p.created_at AS published_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE %[1]s
+		UNION ALL
+		SELECT 'job', j.id, '', u.username, j.title, j.description, '', j.created_at
+		FROM jobs j
+		JOIN users u ON u.id = j.posted_by
+		WHERE (%[2]s) AND ` + listedJobCondition + `
+		UNION ALL
+		SELECT 'remote_post', r.id, r.iri, r.actor_iri, '', r.content,
+			COALESCE(r.url, r.iri), r.published_at
+		FROM remote_posts r
+		WHERE %[3]s
+	) items
+	ORDER BY published_at DESC
+	OFFSET $2 LIMIT $3`
+
+// timelineCountQuery counts the items timelineQuery lists for the same
+// conditions, leaving out unlisted jobs the same way
+const timelineCountQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM posts p WHERE %[1]s) +
+		(SELECT COUNT(*) FROM jobs j WHERE (%[2]s) AND ` + listedJobCondition + `) +
+		(SELECT COUNT(*) FROM remote_posts r WHERE %[3]s)`
+
+// taggedCondition matches objects carrying any of the hashtags in $1
+const taggedCondition = `EXISTS (
+	SELECT 1 FROM object_tags t
+	WHERE t.object_kind = '%s' AND t.object_id = %s.id
+		AND t.kind = 'Hashtag' AND t.name = ANY($1))`
+
+type TimelineService struct {
+	db *pgxpool.Pool
+}
+
+func NewTimelineService(db *pgxpool.Pool) *TimelineService {
+	return &TimelineService{db: db}
+}
+
+// hashtagQuery fills timelineQuery or timelineCountQuery with the
+// conditions of a hashtag timeline
+func hashtagQuery(query string) string {
+	return fmt.Sprintf(query,
+		fmt.Sprintf(taggedCondition, ObjectPost, "p"),
+		fmt.Sprintf(taggedCondition, ObjectJob, "j"),
+		fmt.Sprintf(taggedCondition, ObjectRemotePost, "r"))
+}
+
+// HashtagTimeline returns the posts and job postings carrying a hashtag,
+// newest first
+func (s *TimelineService) HashtagTimeline(ctx context.Context, name string, offset, limit int) ([]*TimelineItem, error) {
+	return s.list(ctx, hashtagQuery(timelineQuery), []string{NormalizeHashtag(name)}, offset, limit)
+}
+
+// CountHashtag returns how many items HashtagTimeline lists for a hashtag,
+// so drafts and expired job postings aren't counted
+func (s *TimelineService) CountHashtag(ctx context.Context, name string) (int, error) {
+	var count int
+	err := s.db.QueryRow(ctx, hashtagQuery(timelineCountQuery), []string{NormalizeHashtag(name)}).Scan(&count)
+	return count, err
+}
+
+// HomeTimeline returns a user's own posts along with everything carrying a
+// hashtag they follow, newest first
+func (s *TimelineService) HomeTimeline(ctx context.Context, userID int, offset, limit int) ([]*TimelineItem, error) {
+	followed := `(SELECT array_agg(name) FROM hashtag_follows WHERE user_id = $1)`
+	tagged := func(kind ObjectKind, alias string) string {
+		return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM object_tags t
+			WHERE t.object_kind = '%s' AND t.object_id = %s.id
+				AND t.kind = 'Hashtag' AND t.name = ANY(%s))`, kind, alias, followed)
+	}
+
+	query := fmt.Sprintf(timelineQuery,
+		"p.user_id = $1 OR "+tagged(ObjectPost, "p"),
+		tagged(ObjectJob, "j"),
+		tagged(ObjectRemotePost, "r"))
+	return s.list(ctx, query, userID, offset, limit)
+}
+
+// FederatedTimeline returns everything public known to this server,
+// including posts relays push to us, newest first. remoteOnly leaves out
+// local posts and job postings.
+func (s *TimelineService) FederatedTimeline(ctx context.Context, remoteOnly bool, offset, limit int) ([]*TimelineItem, error) {
+	query := fmt.Sprintf(timelineQuery, "NOT $1", "NOT $1", "TRUE")
+	return s.list(ctx, query, remoteOnly, offset, limit)
+}
+
+func (s *TimelineService) list(ctx context.Context, query string, arg interface{}, offset, limit int) ([]*TimelineItem, error) {
+	rows, err := s.db.Query(ctx, query, arg, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*TimelineItem{}
+	for rows.Next() {
+		item := &TimelineItem{}
+		if err := rows.Scan(&item.Kind, &item.ID, &item.IRI, &item.Author, &item.Title,
+			&item.Content, &item.URL, &item.PublishedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
@@ -0,0 +1,120 @@
+// Package verify checks that an organization controls a domain it claims.
+package verify
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"openfirm/internal/media"
+)
+
+const (
+	// RecordPrefix starts the DNS TXT record that holds a verification
+	// token, e.g. openfirm-verification=0123abcd
+	RecordPrefix = "openfirm-verification="
+	// WellKnownPath is where a domain serves its verification token
+	WellKnownPath = "/.well-known/openfirm-verification.txt"
+)
+
+// checkTimeout bounds a single DNS lookup or well-known fetch
+const checkTimeout = 10 * time.Second
+
+// ErrNotVerified is returned when a domain doesn't publish the token
+var ErrNotVerified = errors.New("verification token not found")
+
+// Resolver looks up DNS TXT records. *net.Resolver implements it; tests
+// can swap in a fake.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Checker looks for verification tokens in DNS and under /.well-known
+type Checker struct {
+	Resolver Resolver
+	Client   *http.Client
+}
+
+// NewChecker returns a Checker using the system resolver and an HTTP
+// client that won't connect to private addresses
+func NewChecker() *Checker {
+	dialer := &net.Dialer{Timeout: checkTimeout, Control: media.RefusePrivateAddresses}
+	return &Checker{
+		Resolver: net.DefaultResolver,
+		Client: &http.Client{
+			Timeout:       checkTimeout,
+			Transport:     &http.Transport{DialContext: dialer.DialContext},
+			CheckRedirect: sameSite,
+		},
+	}
+}
+
+// sameSite only follows redirects to https on the same domain or its www
+// subdomain, so a token can't be served from somewhere else
+func sameSite(req *http.Request, via []*http.Request) error {
+	if len(via) >= 3 {
+		return errors.New("too many redirects")
+	}
+	domain := strings.TrimPrefix(via[0].URL.Hostname(), "www.")
+	host := req.URL.Hostname()
+	if req.URL.Scheme != "https" || (host != domain && host != "www."+domain) {
+		return fmt.Errorf("refusing redirect to %s", req.URL)
+	}
+	return nil
+}
+
+// CheckDNS returns nil if a TXT record of the domain holds the token
+func (c *Checker) CheckDNS(ctx context.Context, domain, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	records, err := c.Resolver.LookupTXT(ctx, domain)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return ErrNotVerified
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up TXT records of %s: %v", domain, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(record) == RecordPrefix+token {
+			return nil
+		}
+	}
+	return ErrNotVerified
+}
+
+// CheckWellKnown returns nil if the domain serves the token over https at
+// WellKnownPath, alone on a line
+func (c *Checker) CheckWellKnown(ctx context.Context, domain, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+domain+WellKnownPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s%s: %v", domain, WellKnownPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ErrNotVerified
+	}
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 4096))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == token {
+			return nil
+		}
+	}
+	return ErrNotVerified
+}
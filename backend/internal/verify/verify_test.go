@@ -0,0 +1,128 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeResolver answers TXT lookups from a map, or with err if set
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestCheckDNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		wantErr  error
+		wantFail bool
+	}{
+		{
+			name:     "token record",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": {"v=spf1 -all", RecordPrefix + "abc123"}}},
+		},
+		{
+			name:     "token record with whitespace",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": {" " + RecordPrefix + "abc123 "}}},
+		},
+		{
+			name:     "other token",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": {RecordPrefix + "other"}}},
+			wantErr:  ErrNotVerified,
+		},
+		{
+			name:     "token without prefix",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": {"abc123"}}},
+			wantErr:  ErrNotVerified,
+		},
+		{
+			name:     "no records",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": nil}},
+			wantErr:  ErrNotVerified,
+		},
+		{
+			name:     "unknown domain",
+			resolver: &fakeResolver{},
+			wantErr:  ErrNotVerified,
+		},
+		{
+			name:     "lookup failure",
+			resolver: &fakeResolver{err: &net.DNSError{Err: "server misbehaving", Name: "example.com"}},
+			wantFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Checker{Resolver: tt.resolver}
+			err := c.CheckDNS(context.Background(), "example.com", "abc123")
+			switch {
+			case tt.wantFail:
+				if err == nil || errors.Is(err, ErrNotVerified) {
+					t.Errorf("CheckDNS() = %v, want a lookup error", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("CheckDNS() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckWellKnown(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		path    string
+		wantErr error
+	}{
+		{name: "token", status: http.StatusOK, body: "abc123\n"},
+		{name: "token among lines", status: http.StatusOK, body: "# openfirm\n  abc123  \nother\n"},
+		{name: "other token", status: http.StatusOK, body: "other\n", wantErr: ErrNotVerified},
+		{name: "token inside a line", status: http.StatusOK, body: "token: abc123\n", wantErr: ErrNotVerified},
+		{name: "token past the size limit", status: http.StatusOK, body: strings.Repeat("x\n", 4096) + "abc123\n", wantErr: ErrNotVerified},
+		{name: "not found", status: http.StatusNotFound, body: "abc123\n", wantErr: ErrNotVerified},
+		{name: "wrong path", status: http.StatusOK, body: "abc123\n", path: "/other.txt", wantErr: ErrNotVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = WellKnownPath
+			}
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != path {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := &Checker{Client: server.Client()}
+			domain := strings.TrimPrefix(server.URL, "https://")
+			if err := c.CheckWellKnown(context.Background(), domain, "abc123"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckWellKnown() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
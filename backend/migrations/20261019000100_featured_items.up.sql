@@ -0,0 +1,10 @@
+CREATE TABLE featured_items (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL CHECK (kind IN ('post', 'job')),
+    object_id  INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, kind, object_id)
+);
+
+CREATE INDEX featured_items_user_id_idx ON featured_items (user_id, created_at DESC);
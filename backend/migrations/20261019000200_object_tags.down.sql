@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS object_tags;
+DROP TABLE IF EXISTS remote_posts;
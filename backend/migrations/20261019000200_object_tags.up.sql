@@ -0,0 +1,23 @@
+CREATE TABLE remote_posts (
+    id           SERIAL PRIMARY KEY,
+    iri          TEXT NOT NULL UNIQUE,
+    actor_iri    TEXT NOT NULL,
+    content      TEXT NOT NULL,
+    url          TEXT,
+    published_at TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX remote_posts_actor_iri_idx ON remote_posts (actor_iri);
+
+CREATE TABLE object_tags (
+    object_kind TEXT NOT NULL CHECK (object_kind IN ('post', 'job', 'remote_post')),
+    object_id   INTEGER NOT NULL,
+    kind        TEXT NOT NULL CHECK (kind IN ('Mention', 'Hashtag')),
+    name        TEXT NOT NULL,
+    href        TEXT NOT NULL,
+    PRIMARY KEY (object_kind, object_id, kind, name)
+);
+
+CREATE INDEX object_tags_hashtag_idx ON object_tags (name) WHERE kind = 'Hashtag';
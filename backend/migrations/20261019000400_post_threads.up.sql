@@ -0,0 +1,16 @@
+CREATE TABLE post_threads (
+    iri          TEXT PRIMARY KEY,
+    object_kind  TEXT NOT NULL CHECK (object_kind IN ('post', 'remote_post')),
+    object_id    INTEGER NOT NULL,
+    in_reply_to  TEXT,
+    context      TEXT NOT NULL,
+    published_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (object_kind, object_id)
+);
+
+CREATE INDEX post_threads_in_reply_to_idx ON post_threads (in_reply_to);
+CREATE INDEX post_threads_context_idx ON post_threads (context);
+
+-- Posts written before threads were tracked get their entry, which starts a
+-- conversation of their own, the first time they are served or replied to:
+-- their IRIs depend on the instance domain, which migrations don't know.
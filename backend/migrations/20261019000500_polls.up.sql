@@ -0,0 +1,26 @@
+CREATE TABLE polls (
+    id         SERIAL PRIMARY KEY,
+    post_id    INTEGER NOT NULL UNIQUE REFERENCES posts(id) ON DELETE CASCADE,
+    multiple   BOOLEAN NOT NULL DEFAULT FALSE,
+    end_time   TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE poll_options (
+    poll_id  INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name     TEXT NOT NULL,
+    PRIMARY KEY (poll_id, position),
+    UNIQUE (poll_id, name)
+);
+
+CREATE TABLE poll_votes (
+    id         SERIAL PRIMARY KEY,
+    poll_id    INTEGER NOT NULL REFERENCES polls(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    voter_iri  TEXT NOT NULL,
+    vote_iri   TEXT UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (poll_id, position, voter_iri),
+    FOREIGN KEY (poll_id, position) REFERENCES poll_options (poll_id, position) ON DELETE CASCADE
+);
@@ -0,0 +1,19 @@
+CREATE TABLE media_attachments (
+    id            SERIAL PRIMARY KEY,
+    user_id       INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id       INTEGER REFERENCES posts(id) ON DELETE SET NULL,
+    position      INTEGER NOT NULL DEFAULT 0,
+    storage_key   TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT NOT NULL,
+    url           TEXT NOT NULL UNIQUE,
+    preview_url   TEXT NOT NULL,
+    media_type    TEXT NOT NULL,
+    size          BIGINT NOT NULL,
+    width         INTEGER NOT NULL,
+    height        INTEGER NOT NULL,
+    blurhash      TEXT NOT NULL DEFAULT '',
+    description   TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX media_attachments_post_id_idx ON media_attachments (post_id, position);
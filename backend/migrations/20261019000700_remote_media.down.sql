@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS remote_actors;
+DROP TABLE IF EXISTS remote_post_attachments;
+DROP TABLE IF EXISTS remote_media;
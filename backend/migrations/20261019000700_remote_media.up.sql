@@ -0,0 +1,36 @@
+CREATE TABLE remote_media (
+    id               SERIAL PRIMARY KEY,
+    remote_url       TEXT NOT NULL UNIQUE,
+    domain           TEXT NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending',
+    storage_key      TEXT,
+    media_type       TEXT NOT NULL DEFAULT '',
+    size             BIGINT NOT NULL DEFAULT 0,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    last_error       TEXT NOT NULL DEFAULT '',
+    fetched_at       TIMESTAMPTZ,
+    last_accessed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX remote_media_status_idx ON remote_media (status, attempts);
+CREATE INDEX remote_media_lru_idx ON remote_media (domain, last_accessed_at) WHERE status = 'cached';
+
+CREATE TABLE remote_post_attachments (
+    remote_post_id  INTEGER NOT NULL REFERENCES remote_posts(id) ON DELETE CASCADE,
+    position        INTEGER NOT NULL,
+    remote_media_id INTEGER NOT NULL REFERENCES remote_media(id) ON DELETE CASCADE,
+    media_type      TEXT NOT NULL DEFAULT '',
+    description     TEXT NOT NULL DEFAULT '',
+    blurhash        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (remote_post_id, position)
+);
+
+CREATE TABLE remote_actors (
+    iri                TEXT PRIMARY KEY,
+    preferred_username TEXT NOT NULL DEFAULT '',
+    name               TEXT NOT NULL DEFAULT '',
+    url                TEXT NOT NULL DEFAULT '',
+    avatar_media_id    INTEGER REFERENCES remote_media(id) ON DELETE SET NULL,
+    fetched_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,10 @@
+CREATE TABLE relays (
+    id                 SERIAL PRIMARY KEY,
+    inbox_url          TEXT NOT NULL UNIQUE,
+    actor_iri          TEXT,
+    follow_activity_id TEXT NOT NULL UNIQUE,
+    state              TEXT NOT NULL DEFAULT 'pending',
+    forward            BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
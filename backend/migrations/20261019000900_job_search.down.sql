@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS jobs_created_at_idx;
+DROP INDEX IF EXISTS jobs_search_vector_idx;
+ALTER TABLE jobs DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,9 @@
+ALTER TABLE jobs ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(company, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'C') ||
+    setweight(to_tsvector('english', COALESCE(requirements, '')), 'C')
+) STORED;
+
+CREATE INDEX jobs_search_vector_idx ON jobs USING GIN (search_vector);
+CREATE INDEX jobs_created_at_idx ON jobs (created_at DESC);
@@ -0,0 +1,23 @@
+CREATE TABLE job_details (
+    job_id          INTEGER PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    salary_min      BIGINT,
+    salary_max      BIGINT,
+    salary_currency CHAR(3),
+    pay_period      TEXT,
+    employment_type TEXT,
+    remote_policy   TEXT,
+    remote_regions  TEXT[] NOT NULL DEFAULT '{}',
+    seniority       TEXT,
+    -- Salaries converted to a yearly amount so they can be compared
+    -- across pay periods
+    annual_salary_min BIGINT GENERATED ALWAYS AS (salary_min * CASE pay_period
+        WHEN 'hour' THEN 2080 WHEN 'day' THEN 260 WHEN 'week' THEN 52
+        WHEN 'month' THEN 12 ELSE 1 END) STORED,
+    annual_salary_max BIGINT GENERATED ALWAYS AS (salary_max * CASE pay_period
+        WHEN 'hour' THEN 2080 WHEN 'day' THEN 260 WHEN 'week' THEN 52
+        WHEN 'month' THEN 12 ELSE 1 END) STORED,
+    CHECK (salary_min IS NULL OR salary_max IS NULL OR salary_min <= salary_max)
+);
+
+CREATE INDEX job_details_employment_type_idx ON job_details (employment_type);
+CREATE INDEX job_details_remote_policy_idx ON job_details (remote_policy);
@@ -0,0 +1,24 @@
+-- Jobs without a row are published and never expire
+CREATE TABLE job_states (
+    job_id           INTEGER PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    state            TEXT NOT NULL DEFAULT 'published',
+    expires_at       TIMESTAMPTZ,
+    reminded_at      TIMESTAMPTZ,
+    reposted_from    INTEGER REFERENCES jobs(id) ON DELETE SET NULL,
+    state_changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX job_states_expires_at_idx ON job_states (expires_at)
+    WHERE state IN ('published', 'paused');
+
+CREATE TABLE notifications (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind       TEXT NOT NULL,
+    job_id     INTEGER REFERENCES jobs(id) ON DELETE CASCADE,
+    message    TEXT NOT NULL,
+    read_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at DESC);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_skills;
+DROP TABLE IF EXISTS job_skills;
+DROP TABLE IF EXISTS skill_aliases;
+DROP TABLE IF EXISTS skills;
@@ -0,0 +1,87 @@
+-- slug is the normalized name: lowercased with whitespace collapsed
+CREATE TABLE skills (
+    id         SERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    slug       TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX skills_slug_prefix_idx ON skills (slug text_pattern_ops);
+
+-- Alternative spellings resolving to a skill, normalized like slugs
+CREATE TABLE skill_aliases (
+    alias    TEXT PRIMARY KEY,
+    skill_id INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE
+);
+
+CREATE INDEX skill_aliases_prefix_idx ON skill_aliases (alias text_pattern_ops);
+
+CREATE TABLE job_skills (
+    job_id   INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    skill_id INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    required BOOLEAN NOT NULL DEFAULT TRUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (job_id, skill_id)
+);
+
+CREATE INDEX job_skills_skill_id_idx ON job_skills (skill_id);
+
+CREATE TABLE user_skills (
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    skill_id    INTEGER NOT NULL REFERENCES skills(id) ON DELETE CASCADE,
+    proficiency SMALLINT NOT NULL CHECK (proficiency BETWEEN 1 AND 5),
+    position    INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, skill_id)
+);
+
+CREATE INDEX user_skills_skill_id_idx ON user_skills (skill_id);
+
+INSERT INTO skills (name, slug) VALUES
+    ('Go', 'go'),
+    ('JavaScript', 'javascript'),
+    ('TypeScript', 'typescript'),
+    ('Python', 'python'),
+    ('Rust', 'rust'),
+    ('Java', 'java'),
+    ('Kotlin', 'kotlin'),
+    ('C++', 'c++'),
+    ('C#', 'c#'),
+    ('Ruby on Rails', 'ruby on rails'),
+    ('Node.js', 'node.js'),
+    ('Vue.js', 'vue.js'),
+    ('React', 'react'),
+    ('PostgreSQL', 'postgresql'),
+    ('Kubernetes', 'kubernetes'),
+    ('Docker', 'docker'),
+    ('Terraform', 'terraform'),
+    ('AWS', 'aws'),
+    ('GraphQL', 'graphql'),
+    ('REST APIs', 'rest apis'),
+    ('Machine Learning', 'machine learning'),
+    ('ActivityPub', 'activitypub');
+
+INSERT INTO skill_aliases (alias, skill_id)
+SELECT a.alias, s.id
+FROM (VALUES
+    ('golang', 'go'),
+    ('js', 'javascript'),
+    ('ts', 'typescript'),
+    ('py', 'python'),
+    ('cpp', 'c++'),
+    ('csharp', 'c#'),
+    ('rails', 'ruby on rails'),
+    ('ror', 'ruby on rails'),
+    ('node', 'node.js'),
+    ('nodejs', 'node.js'),
+    ('vue', 'vue.js'),
+    ('vuejs', 'vue.js'),
+    ('reactjs', 'react'),
+    ('react.js', 'react'),
+    ('postgres', 'postgresql'),
+    ('psql', 'postgresql'),
+    ('k8s', 'kubernetes'),
+    ('amazon web services', 'aws'),
+    ('rest', 'rest apis'),
+    ('ml', 'machine learning')
+) AS a (alias, slug)
+JOIN skills s ON s.slug = a.slug;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS candidate_preferences;
@@ -0,0 +1,19 @@
+-- What a user is looking for in a job. Only discoverable users are
+-- suggested to posters as candidates.
+CREATE TABLE candidate_preferences (
+    user_id         INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    discoverable    BOOLEAN NOT NULL DEFAULT FALSE,
+    location        TEXT NOT NULL DEFAULT '',
+    remote_policies TEXT[] NOT NULL DEFAULT '{}',
+    salary_min      BIGINT,
+    salary_currency CHAR(3),
+    pay_period      TEXT,
+    seniority       TEXT,
+    annual_salary_min BIGINT GENERATED ALWAYS AS (salary_min * CASE pay_period
+        WHEN 'hour' THEN 2080 WHEN 'day' THEN 260 WHEN 'week' THEN 52
+        WHEN 'month' THEN 12 ELSE 1 END) STORED,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX candidate_preferences_discoverable_idx ON candidate_preferences (user_id)
+    WHERE discoverable;
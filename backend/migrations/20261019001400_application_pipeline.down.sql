@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS application_ratings;
+DROP TABLE IF EXISTS application_notes;
+DROP TABLE IF EXISTS application_events;
+DROP TABLE IF EXISTS pipeline_stages;
@@ -0,0 +1,47 @@
+-- Custom hiring stages of a job. Jobs without any use the default
+-- pipeline: applied, screening, interview, offer, hired, rejected.
+CREATE TABLE pipeline_stages (
+    job_id   INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    key      TEXT NOT NULL,
+    name     TEXT NOT NULL,
+    kind     TEXT NOT NULL DEFAULT 'active',
+    position INTEGER NOT NULL,
+    PRIMARY KEY (job_id, key)
+);
+
+-- Audit trail of applications moving between stages
+CREATE TABLE application_events (
+    id             SERIAL PRIMARY KEY,
+    application_id INTEGER NOT NULL REFERENCES job_applications(id) ON DELETE CASCADE,
+    actor_id       INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    from_stage     TEXT NOT NULL,
+    to_stage       TEXT NOT NULL,
+    reason         TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX application_events_application_id_idx ON application_events (application_id, created_at);
+
+-- Internal notes on applicants, never shown to them
+CREATE TABLE application_notes (
+    id             SERIAL PRIMARY KEY,
+    application_id INTEGER NOT NULL REFERENCES job_applications(id) ON DELETE CASCADE,
+    author_id      INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    body           TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX application_notes_application_id_idx ON application_notes (application_id, created_at);
+
+CREATE TABLE application_ratings (
+    application_id INTEGER NOT NULL REFERENCES job_applications(id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating         SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (application_id, user_id)
+);
+
+-- Map the free-form statuses used so far onto the default pipeline
+UPDATE job_applications
+SET status = CASE status WHEN 'accepted' THEN 'hired' WHEN 'rejected' THEN 'rejected' ELSE 'applied' END
+WHERE status NOT IN ('applied', 'screening', 'interview', 'offer', 'hired', 'rejected');
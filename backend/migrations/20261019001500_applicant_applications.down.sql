@@ -0,0 +1,4 @@
+DELETE FROM pipeline_stages WHERE key = 'withdrawn';
+
+DROP INDEX IF EXISTS job_applications_user_id_idx;
+DROP INDEX IF EXISTS job_applications_active_idx;
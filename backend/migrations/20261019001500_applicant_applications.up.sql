@@ -0,0 +1,20 @@
+-- Applicants can hold one application per job at a time. Later duplicates
+-- are kept but withdrawn, leaving the oldest application active.
+UPDATE job_applications a
+SET status = 'withdrawn'
+WHERE status <> 'withdrawn' AND EXISTS (
+    SELECT 1 FROM job_applications earlier
+    WHERE earlier.job_id = a.job_id AND earlier.user_id = a.user_id
+      AND earlier.status <> 'withdrawn' AND earlier.id < a.id
+);
+
+CREATE UNIQUE INDEX job_applications_active_idx ON job_applications (job_id, user_id)
+    WHERE status <> 'withdrawn';
+
+CREATE INDEX job_applications_user_id_idx ON job_applications (user_id, created_at DESC);
+
+-- Custom pipelines end with withdrawn like the default one
+INSERT INTO pipeline_stages (job_id, key, name, kind, position)
+SELECT job_id, 'withdrawn', 'Withdrawn', 'withdrawn', MAX(position) + 1
+FROM pipeline_stages
+GROUP BY job_id;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS application_resumes;
+DROP TABLE IF EXISTS application_answers;
+DROP TABLE IF EXISTS application_questions;
+DROP TABLE IF EXISTS profile_resumes;
+DROP TABLE IF EXISTS documents;
@@ -0,0 +1,46 @@
+-- Files uploaded by users for their applications, kept in private storage
+CREATE TABLE documents (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    storage_key TEXT NOT NULL UNIQUE,
+    filename    TEXT NOT NULL,
+    media_type  TEXT NOT NULL,
+    size        BIGINT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX documents_user_id_idx ON documents (user_id);
+
+-- The resume stored on a user's profile, reusable when applying
+CREATE TABLE profile_resumes (
+    user_id     INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    document_id INTEGER NOT NULL REFERENCES documents(id) ON DELETE CASCADE
+);
+
+-- Questions employers ask applicants to a job
+CREATE TABLE application_questions (
+    id       SERIAL PRIMARY KEY,
+    job_id   INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    kind     TEXT NOT NULL,
+    prompt   TEXT NOT NULL,
+    required BOOLEAN NOT NULL DEFAULT FALSE,
+    options  TEXT[] NOT NULL DEFAULT '{}',
+    position INTEGER NOT NULL
+);
+
+CREATE INDEX application_questions_job_id_idx ON application_questions (job_id, position);
+
+CREATE TABLE application_answers (
+    application_id INTEGER NOT NULL REFERENCES job_applications(id) ON DELETE CASCADE,
+    question_id    INTEGER NOT NULL REFERENCES application_questions(id),
+    value          TEXT NOT NULL DEFAULT '',
+    document_id    INTEGER REFERENCES documents(id),
+    PRIMARY KEY (application_id, question_id)
+);
+
+CREATE INDEX application_answers_question_id_idx ON application_answers (question_id);
+
+CREATE TABLE application_resumes (
+    application_id INTEGER PRIMARY KEY REFERENCES job_applications(id) ON DELETE CASCADE,
+    document_id    INTEGER NOT NULL REFERENCES documents(id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS organization_followers;
+DROP TABLE IF EXISTS job_organizations;
+DROP TABLE IF EXISTS organization_domains;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,53 @@
+CREATE TABLE organizations (
+    id          SERIAL PRIMARY KEY,
+    slug        TEXT NOT NULL UNIQUE,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    website     TEXT NOT NULL DEFAULT '',
+    logo_url    TEXT NOT NULL DEFAULT '',
+    location    TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role            TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+-- Domains an organization claims. verified_at is set once ownership of
+-- the domain has been proven.
+CREATE TABLE organization_domains (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    domain          TEXT NOT NULL,
+    verified_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, domain)
+);
+
+-- A domain can only be verified by one organization
+CREATE UNIQUE INDEX organization_domains_verified_idx ON organization_domains (domain)
+    WHERE verified_at IS NOT NULL;
+
+-- The organization a job is posted for. Jobs without one belong to their
+-- poster alone.
+CREATE TABLE job_organizations (
+    job_id          INTEGER PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+CREATE INDEX job_organizations_organization_id_idx ON job_organizations (organization_id);
+
+-- Remote actors following an organization for new openings
+CREATE TABLE organization_followers (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    actor_iri       TEXT NOT NULL,
+    inbox           TEXT NOT NULL,
+    shared_inbox    TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, actor_iri)
+);
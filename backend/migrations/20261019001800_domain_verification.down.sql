@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS jobs_posted_by_created_at_idx;
+
+ALTER TABLE organization_domains
+    DROP COLUMN IF EXISTS email_code_attempts,
+    DROP COLUMN IF EXISTS email_code_expires,
+    DROP COLUMN IF EXISTS email_code_hash,
+    DROP COLUMN IF EXISTS method,
+    DROP COLUMN IF EXISTS token;
@@ -0,0 +1,14 @@
+-- token is the challenge published in DNS or at /.well-known to prove
+-- control of a domain. Email verification sends a code to an admin
+-- address at the domain instead; only its hash is kept.
+ALTER TABLE organization_domains
+    ADD COLUMN token               TEXT NOT NULL DEFAULT '',
+    ADD COLUMN method              TEXT,
+    ADD COLUMN email_code_hash     TEXT,
+    ADD COLUMN email_code_expires  TIMESTAMPTZ,
+    ADD COLUMN email_code_attempts INTEGER NOT NULL DEFAULT 0;
+
+UPDATE organization_domains SET token = md5(random()::text || domain);
+
+-- Counting a poster's recent jobs for the unverified posting limit
+CREATE INDEX jobs_posted_by_created_at_idx ON jobs (posted_by, created_at);
@@ -0,0 +1,34 @@
+-- A job search a user saved to be alerted about. filters holds the same
+-- filters as the job board, cadence is instant, daily or weekly.
+-- feed_token makes the private feed URL and unsubscribe_token the link in
+-- alert emails.
+CREATE TABLE saved_searches (
+    id                SERIAL PRIMARY KEY,
+    user_id           INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    filters           JSONB NOT NULL DEFAULT '{}',
+    cadence           TEXT NOT NULL,
+    email             BOOLEAN NOT NULL DEFAULT TRUE,
+    feed_token        TEXT NOT NULL UNIQUE,
+    unsubscribe_token TEXT NOT NULL UNIQUE,
+    -- Jobs listed since last_checked_at haven't been matched yet
+    last_checked_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_sent_at      TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX saved_searches_user_id_idx ON saved_searches (user_id);
+CREATE INDEX saved_searches_last_checked_at_idx ON saved_searches (last_checked_at);
+
+-- Jobs that matched a saved search. notified_at is set once they were sent
+-- in an alert.
+CREATE TABLE saved_search_matches (
+    saved_search_id INTEGER NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    job_id          INTEGER NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    matched_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    notified_at     TIMESTAMPTZ,
+    PRIMARY KEY (saved_search_id, job_id)
+);
+
+CREATE INDEX saved_search_matches_pending_idx ON saved_search_matches (saved_search_id)
+    WHERE notified_at IS NULL;
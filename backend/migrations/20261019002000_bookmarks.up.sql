@@ -0,0 +1,38 @@
+-- Named lists a user files bookmarks under, such as "apply this week"
+CREATE TABLE bookmark_lists (
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX bookmark_lists_user_id_name_idx ON bookmark_lists (user_id, lower(name));
+
+-- A job posting or post a user bookmarked. Bookmarks are private and never
+-- federated. They are keyed by the IRI of the object and keep a snapshot of
+-- it, so bookmarks of remote objects don't depend on the remote post cache.
+-- job_id or post_id is set when the object is local.
+CREATE TABLE bookmarks (
+    id          SERIAL PRIMARY KEY,
+    user_id     INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    list_id     INTEGER REFERENCES bookmark_lists(id) ON DELETE SET NULL,
+    kind        TEXT NOT NULL CHECK (kind IN ('job', 'post')),
+    object_iri  TEXT NOT NULL,
+    job_id      INTEGER REFERENCES jobs(id) ON DELETE CASCADE,
+    post_id     INTEGER REFERENCES posts(id) ON DELETE CASCADE,
+    title       TEXT NOT NULL DEFAULT '',
+    summary     TEXT NOT NULL DEFAULT '',
+    url         TEXT NOT NULL DEFAULT '',
+    author      TEXT NOT NULL DEFAULT '',
+    note        TEXT NOT NULL DEFAULT '',
+    remind_at   TIMESTAMPTZ,
+    -- Set once the reminder was sent, and cleared when remind_at changes
+    reminded_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, object_iri)
+);
+
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, created_at DESC);
+CREATE INDEX bookmarks_list_id_idx ON bookmarks (list_id);
+CREATE INDEX bookmarks_remind_at_idx ON bookmarks (remind_at) WHERE reminded_at IS NULL;
@@ -0,0 +1,8 @@
+-- The ID an organization's own system gives a job it imports, so that
+-- importing the same jobs again updates them instead of posting copies
+CREATE TABLE job_external_refs (
+    job_id          INTEGER PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    external_ref    TEXT NOT NULL,
+    UNIQUE (organization_id, external_ref)
+);
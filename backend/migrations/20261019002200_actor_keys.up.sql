@@ -0,0 +1,6 @@
+CREATE TABLE actor_keys (
+    actor_iri       TEXT PRIMARY KEY,
+    public_key_pem  TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,11 @@
+-- Verification codes emailed to domains, kept to rate-limit new codes per
+-- organization and per domain
+CREATE TABLE verification_emails (
+    id              SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    domain          TEXT NOT NULL,
+    sent_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX verification_emails_organization_idx ON verification_emails (organization_id, sent_at);
+CREATE INDEX verification_emails_domain_idx ON verification_emails (domain, sent_at);
@@ -0,0 +1,11 @@
+-- Remote actors following local users, recorded when their Follow is
+-- accepted
+CREATE TABLE followers (
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_iri    TEXT NOT NULL,
+    inbox        TEXT NOT NULL,
+    shared_inbox TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, actor_iri)
+);